@@ -0,0 +1,429 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// theiaDefaultHostEnv overrides the config file's default_host; the daemon
+// reads it at startup.
+const theiaDefaultHostEnv = "THEIA_DEFAULT_HOST"
+
+// configBinding ties a config file key to the flag it supplies a default
+// for. Value returns "" when the file leaves the key unset.
+type configBinding struct {
+	Key   string
+	Flag  string
+	Value func(cfg config.Config) string
+}
+
+func daemonConfigBindings() []configBinding {
+	return []configBinding{
+		{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+		{Key: "daemon.log_path", Flag: "log-path", Value: func(c config.Config) string { return c.Daemon.LogPath }},
+		{Key: "daemon.live_addr", Flag: "live-addr", Value: func(c config.Config) string { return c.Daemon.LiveAddr }},
+		{Key: "daemon.metrics_addr", Flag: "metrics-addr", Value: func(c config.Config) string { return c.Daemon.MetricsAddr }},
+		{Key: "daemon.dead_letter_path", Flag: "dead-letter-path", Value: func(c config.Config) string { return c.Daemon.DeadLetterPath }},
+		{Key: "daemon.parse_failure_threshold", Flag: "parse-failure-threshold", Value: func(c config.Config) string {
+			return formatFloatSetting(c.Daemon.ParseFailureThreshold)
+		}},
+	}
+}
+
+// serveConfigBindings leaves serve.token_file out when tokenFromEnv is set:
+// THEIA_API_TOKEN outranks the config file, but would itself lose to a
+// --token-file flag the file's value had been applied to.
+func serveConfigBindings(tokenFromEnv bool) []configBinding {
+	bindings := []configBinding{
+		{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+		{Key: "serve.addr", Flag: "addr", Value: func(c config.Config) string { return c.Serve.Addr }},
+	}
+	if !tokenFromEnv {
+		bindings = append(bindings, configBinding{Key: "serve.token_file", Flag: "token-file", Value: func(c config.Config) string { return c.Serve.TokenFile }})
+	}
+	return bindings
+}
+
+func metricsConfigBindings() []configBinding {
+	return []configBinding{
+		{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+		{Key: "metrics.addr", Flag: "addr", Value: func(c config.Config) string { return c.Metrics.Addr }},
+		{Key: "metrics.top", Flag: "top", Value: func(c config.Config) string { return formatIntSetting(c.Metrics.Top) }},
+	}
+}
+
+func statsConfigBindings() []configBinding {
+	return []configBinding{
+		{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+		{Key: "stats.days", Flag: "days", Value: func(c config.Config) string { return formatIntSetting(c.Stats.Days) }},
+		{Key: "stats.host", Flag: "host", Value: func(c config.Config) string { return c.Stats.Host }},
+		{Key: "stats.top", Flag: "top", Value: func(c config.Config) string { return formatIntSetting(c.Stats.Top) }},
+	}
+}
+
+func formatIntSetting(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func formatFloatSetting(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// addConfigFlag registers --config on a command that reads the config file.
+func addConfigFlag(cmd *cobra.Command) {
+	cmd.Flags().String("config", config.DefaultPath, "path to the TOML config file; flags and THEIA_* env vars override its settings (a missing file at the default path is ignored)")
+}
+
+// loadConfigFile reads the file named by --config. The file is optional, so
+// a missing file at the default path yields an empty Config; one the
+// operator named explicitly must exist.
+func loadConfigFile(cmd *cobra.Command) (config.Config, error) {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return config.Config{}, fmt.Errorf("parsing config flag: %w", err)
+	}
+	cfg, err := config.Load(path)
+	if errors.Is(err, fs.ErrNotExist) && !cmd.Flags().Changed("config") {
+		return config.Config{}, nil
+	}
+	return cfg, err
+}
+
+// applyConfigFile loads the config file and fills in every bound flag the
+// operator didn't pass explicitly, so the rest of a command's RunE reads its
+// flags exactly as before.
+func applyConfigFile(cmd *cobra.Command, bindings []configBinding) (config.Config, error) {
+	cfg, err := loadConfigFile(cmd)
+	if err != nil {
+		return config.Config{}, err
+	}
+	if err := applyConfig(cmd, cfg, bindings); err != nil {
+		return config.Config{}, err
+	}
+	return cfg, nil
+}
+
+func applyConfig(cmd *cobra.Command, cfg config.Config, bindings []configBinding) error {
+	for _, b := range bindings {
+		value := b.Value(cfg)
+		if value == "" || cmd.Flags().Changed(b.Flag) {
+			continue
+		}
+		if err := cmd.Flags().Set(b.Flag, value); err != nil {
+			return fmt.Errorf("applying config %s: %w", b.Key, err)
+		}
+	}
+	return nil
+}
+
+// displayTimezones loads the config file's timezone and [host_timezones]
+// for the commands that report. Host keys are normalized the same way
+// --host is, so an entry for "Example.com" still matches.
+func displayTimezones(cfg config.Config) (query.Timezones, error) {
+	var tz query.Timezones
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return query.Timezones{}, fmt.Errorf("timezone %q: %w", cfg.Timezone, err)
+		}
+		tz.Default = loc
+	}
+	for host, name := range cfg.HostTimezones {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return query.Timezones{}, fmt.Errorf("host_timezones.%s %q: %w", host, name, err)
+		}
+		if tz.Hosts == nil {
+			tz.Hosts = make(map[string]*time.Location, len(cfg.HostTimezones))
+		}
+		tz.Hosts[ingest.NormalizeHost(host)] = loc
+	}
+	return tz, nil
+}
+
+// retentionPolicy merges the config file's [retention] over the daemon's
+// defaults, and each [retention.hosts] table over that. Host keys are
+// normalized the same way as in displayTimezones.
+func retentionPolicy(cfg config.Config) (ingest.RetentionPolicy, error) {
+	policy := ingest.RetentionPolicy{Default: mergeRetention(ingest.DefaultRetention(), cfg.Retention.Default)}
+	if err := ingest.ValidateRetention(policy.Default); err != nil {
+		return ingest.RetentionPolicy{}, fmt.Errorf("retention: %w", err)
+	}
+	for _, host := range slices.Sorted(maps.Keys(cfg.Retention.Hosts)) {
+		r := mergeRetention(policy.Default, cfg.Retention.Hosts[host])
+		if err := ingest.ValidateRetention(r); err != nil {
+			return ingest.RetentionPolicy{}, fmt.Errorf("retention.hosts.%s: %w", host, err)
+		}
+		if policy.Hosts == nil {
+			policy.Hosts = make(map[string]ingest.Retention, len(cfg.Retention.Hosts))
+		}
+		policy.Hosts[ingest.NormalizeHost(host)] = r
+	}
+	return policy, nil
+}
+
+// mergeRetention overrides base with every horizon d sets.
+func mergeRetention(base ingest.Retention, d config.RetentionDays) ingest.Retention {
+	pick := func(days, fallback int) int {
+		if days == 0 {
+			return fallback
+		}
+		return days
+	}
+	return ingest.Retention{
+		HourlyStats: pick(d.HourlyStats, base.HourlyStats),
+		StatusCodes: pick(d.StatusCodes, base.StatusCodes),
+		Referrers:   pick(d.Referrers, base.Referrers),
+		VisitorDays: pick(d.VisitorDays, base.VisitorDays),
+		Daily:       pick(d.Daily, base.Daily),
+		Monthly:     pick(d.Monthly, base.Monthly),
+	}
+}
+
+// newConfigCmd builds the `theia config` command group fresh each call, for
+// the same reason as newSystemCmd: cobra commands can only have one parent.
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the theia config file",
+		Long: `Inspect the optional TOML config file read by daemon, serve,
+serve-metrics, stats, parse-check, retention show, export, import-dump and
+the db commands (default ` + config.DefaultPath + `).
+
+Every setting in it can be overridden by the matching flag, and
+default_host and the API token by THEIA_DEFAULT_HOST and THEIA_API_TOKEN.`,
+	}
+
+	configCmd.AddCommand(newConfigCheckCmd())
+
+	return configCmd
+}
+
+func newConfigCheckCmd() *cobra.Command {
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Validate the config file and print the effective settings",
+		Long: `check parses the config file, rejects unknown keys and invalid values,
+and prints the settings each command would run with once the file is
+merged with the built-in defaults and THEIA_* env vars.
+
+Example:
+  theia config check --config /etc/theia/theia.toml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			path, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return fmt.Errorf("parsing config flag: %w", err)
+			}
+			cfg, err := config.Load(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				return &ui.UserError{
+					Err:  fmt.Errorf("config file %s does not exist", path),
+					Hint: "theia config check --config <path>",
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			return runConfigCheck(cmd, path, cfg)
+		},
+	}
+
+	checkCmd.Flags().String("config", config.DefaultPath, "path to the TOML config file")
+
+	return checkCmd
+}
+
+// effectiveSetting is one row of `theia config check` output.
+type effectiveSetting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// commandSettings is one command's block of `theia config check` output.
+type commandSettings struct {
+	Command  string
+	Settings []effectiveSetting
+}
+
+func runConfigCheck(cmd *cobra.Command, path string, cfg config.Config) error {
+	if err := ingest.ValidateRules(ingestRules(cfg.Rules)); err != nil {
+		return fmt.Errorf("rules: %w", err)
+	}
+	if err := ingest.ValidateStageOrder(cfg.Pipeline.Stages, nil); err != nil {
+		return fmt.Errorf("pipeline.stages: %w", err)
+	}
+	if _, err := retentionPolicy(cfg); err != nil {
+		return err
+	}
+
+	tokenFromEnv := os.Getenv(theiaAPITokenEnv) != ""
+	var serveExtra []effectiveSetting
+	if tokenFromEnv {
+		serveExtra = append(serveExtra, effectiveSetting{Key: "token", Value: "(set)", Source: "env " + theiaAPITokenEnv})
+	}
+
+	blocks := []struct {
+		build    func() *cobra.Command
+		bindings []configBinding
+		extra    []effectiveSetting
+	}{
+		{build: newDaemonCmd, bindings: daemonConfigBindings(), extra: daemonExtraSettings(cfg)},
+		{build: newServeCmd, bindings: serveConfigBindings(tokenFromEnv), extra: append(serveExtra, timezoneSettings(cfg)...)},
+		{build: newServeMetricsCmd, bindings: metricsConfigBindings()},
+		{build: newStatsCmd, bindings: statsConfigBindings(), extra: timezoneSettings(cfg)},
+	}
+
+	var out []commandSettings
+	for _, block := range blocks {
+		settings, err := resolveEffectiveSettings(block.build(), cfg, block.bindings)
+		if err != nil {
+			return err
+		}
+		settings.Settings = append(settings.Settings, block.extra...)
+		out = append(out, settings)
+	}
+
+	if err := validateEffectiveAddrs(out); err != nil {
+		return err
+	}
+
+	cmd.Printf("%s is valid.\n", path)
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	for _, block := range out {
+		fmt.Fprintf(tw, "\n[%s]\n", block.Command)
+		for _, s := range block.Settings {
+			fmt.Fprintf(tw, "  %s\t%s\t(%s)\n", s.Key, sanitizeTerminalField(s.Value), s.Source)
+		}
+	}
+	return tw.Flush()
+}
+
+// resolveEffectiveSettings applies cfg to a fresh instance of a command and
+// reads back the flag values it would run with.
+func resolveEffectiveSettings(c *cobra.Command, cfg config.Config, bindings []configBinding) (commandSettings, error) {
+	if err := applyConfig(c, cfg, bindings); err != nil {
+		return commandSettings{}, err
+	}
+	settings := commandSettings{Command: c.Name()}
+	for _, b := range bindings {
+		source := "default"
+		if b.Value(cfg) != "" {
+			source = "config"
+		}
+		settings.Settings = append(settings.Settings, effectiveSetting{
+			Key:    b.Key,
+			Value:  c.Flags().Lookup(b.Flag).Value.String(),
+			Source: source,
+		})
+	}
+	return settings, nil
+}
+
+func defaultHostSetting(cfg config.Config, envHost string) effectiveSetting {
+	switch {
+	case envHost != "":
+		return effectiveSetting{Key: "default_host", Value: envHost, Source: "env " + theiaDefaultHostEnv}
+	case cfg.DefaultHost != "":
+		return effectiveSetting{Key: "default_host", Value: cfg.DefaultHost, Source: "config"}
+	}
+	return effectiveSetting{Key: "default_host", Value: "default", Source: "default"}
+}
+
+// daemonExtraSettings are the daemon's settings that aren't flags.
+func daemonExtraSettings(cfg config.Config) []effectiveSetting {
+	stages := effectiveSetting{Key: "pipeline.stages", Value: strings.Join(ingest.DefaultStageOrder(), ", "), Source: "default"}
+	if len(cfg.Pipeline.Stages) > 0 {
+		stages = effectiveSetting{Key: "pipeline.stages", Value: strings.Join(cfg.Pipeline.Stages, ", "), Source: "config"}
+	}
+	settings := []effectiveSetting{defaultHostSetting(cfg, os.Getenv(theiaDefaultHostEnv)), stages}
+	return append(settings, rulesSettings(cfg.Rules)...)
+}
+
+// timezoneSettings are the display timezones of the commands that report.
+func timezoneSettings(cfg config.Config) []effectiveSetting {
+	settings := []effectiveSetting{{Key: "timezone", Value: "UTC", Source: "default"}}
+	if cfg.Timezone != "" {
+		settings[0] = effectiveSetting{Key: "timezone", Value: cfg.Timezone, Source: "config"}
+	}
+	for _, host := range slices.Sorted(maps.Keys(cfg.HostTimezones)) {
+		settings = append(settings, effectiveSetting{Key: "host_timezones." + host, Value: cfg.HostTimezones[host], Source: "config"})
+	}
+	return settings
+}
+
+// rulesSettings lists the [rules] the file sets; unset rules are omitted
+// since their default is simply "no rule".
+func rulesSettings(r config.RulesConfig) []effectiveSetting {
+	var settings []effectiveSetting
+	add := func(key string, values []string) {
+		if len(values) > 0 {
+			settings = append(settings, effectiveSetting{Key: key, Value: strings.Join(values, ", "), Source: "config"})
+		}
+	}
+	add("rules.exclude_paths", r.ExcludePaths)
+	add("rules.exclude_user_agents", r.ExcludeUserAgents)
+	add("rules.exclude_hosts", r.ExcludeHosts)
+	if r.StripQuery {
+		add("rules.strip_query", []string{"true"})
+	}
+	if r.StripTrailingSlash {
+		add("rules.strip_trailing_slash", []string{"true"})
+	}
+	aliases := make([]string, 0, len(r.HostAliases))
+	for alias, host := range r.HostAliases {
+		aliases = append(aliases, alias+" -> "+host)
+	}
+	slices.Sort(aliases)
+	add("rules.host_aliases", aliases)
+	return settings
+}
+
+// validateEffectiveAddrs applies the loopback-only rule the commands enforce
+// at startup, so `config check` catches a public bind address too.
+func validateEffectiveAddrs(blocks []commandSettings) error {
+	for _, block := range blocks {
+		for _, s := range block.Settings {
+			switch s.Key {
+			case "daemon.live_addr", "daemon.metrics_addr", "serve.addr", "metrics.addr":
+			default:
+				continue
+			}
+			if s.Value == "" {
+				continue
+			}
+			if err := apiserver.ValidateLoopbackAddr(s.Value); err != nil {
+				return fmt.Errorf("%s: %w", s.Key, err)
+			}
+		}
+	}
+	return nil
+}
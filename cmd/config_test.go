@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "theia.toml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func runConfigCheckCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newConfigCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"check"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestConfigCheck_PrintsEffectiveSettings(t *testing.T) {
+	t.Setenv(theiaAPITokenEnv, "")
+	t.Setenv(theiaDefaultHostEnv, "env.example")
+	path := writeConfigFile(t, `
+db_path = "/var/lib/theia/theia.db"
+default_host = "example.com"
+timezone = "Europe/Amsterdam"
+
+[stats]
+days = 30
+`)
+
+	out, err := runConfigCheckCmd(t, "--config", path)
+	if err != nil {
+		t.Fatalf("config check: %v\noutput: %s", err, out)
+	}
+
+	for _, want := range []string{
+		path + " is valid.",
+		"[daemon]",
+		"[serve-metrics]",
+		"/var/lib/theia/theia.db",
+		"stats.days",
+		"30",
+		"/var/log/nginx/access.log",
+		"(default)",
+		"env.example",
+		"(env THEIA_DEFAULT_HOST)",
+		"Europe/Amsterdam",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigCheck_RejectsInvalidFile(t *testing.T) {
+	path := writeConfigFile(t, "[daemon]\nlog_pth = \"/tmp/x\"\n")
+
+	_, err := runConfigCheckCmd(t, "--config", path)
+	if err == nil || !strings.Contains(err.Error(), "daemon.log_pth") {
+		t.Errorf("config check error = %v, want the unknown key named", err)
+	}
+}
+
+func TestConfigCheck_RejectsPublicAddr(t *testing.T) {
+	path := writeConfigFile(t, "[serve]\naddr = \"0.0.0.0:8081\"\n")
+
+	_, err := runConfigCheckCmd(t, "--config", path)
+	if err == nil || !strings.Contains(err.Error(), "serve.addr") {
+		t.Errorf("config check error = %v, want serve.addr rejected", err)
+	}
+}
+
+func TestConfigCheck_MissingFile(t *testing.T) {
+	_, err := runConfigCheckCmd(t, "--config", filepath.Join(t.TempDir(), "missing.toml"))
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("config check error = %v, want a missing-file error", err)
+	}
+}
+
+// The config file only supplies defaults: stats reads db_path and days from
+// it, but an explicit flag still wins.
+func TestStatsCmd_ReadsConfigFileAndFlagsOverride(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	insertStat(t, db, "/", "example.com", time.Now(), statSeed{PageViews: 4, UniqueVisitors: 1})
+	insertStat(t, db, "/", "other.com", time.Now(), statSeed{PageViews: 9, UniqueVisitors: 1})
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	path := writeConfigFile(t, "db_path = \""+dbPath+"\"\n\n[stats]\nhost = \"other.com\"\n")
+
+	run := func(args ...string) statsReport {
+		t.Helper()
+		cmd := newStatsCmd()
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+		cmd.SetArgs(append([]string{"--config", path, "--format", "json"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute: %v\noutput: %s", err, buf.String())
+		}
+		var report statsReport
+		if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+			t.Fatalf("unmarshal JSON: %v\noutput: %s", err, buf.String())
+		}
+		return report
+	}
+
+	if got := run().Summary.Pageviews; got != 9 {
+		t.Errorf("Pageviews with config host = %d, want 9", got)
+	}
+	if got := run("--host", "example.com").Summary.Pageviews; got != 4 {
+		t.Errorf("Pageviews with --host override = %d, want 4", got)
+	}
+}
+
+func TestStatsCmd_ExplicitMissingConfigFails(t *testing.T) {
+	cmd := newStatsCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"--config", filepath.Join(t.TempDir(), "missing.toml")})
+	if err := cmd.Execute(); err == nil {
+		t.Error("Execute succeeded with a missing --config file, want an error")
+	}
+}
+
+func TestConfigCheck_ShowsAndValidatesRules(t *testing.T) {
+	path := writeConfigFile(t, `
+[rules]
+exclude_paths = ["/healthz"]
+
+[rules.host_aliases]
+"www.example.com" = "example.com"
+`)
+	out, err := runConfigCheckCmd(t, "--config", path)
+	if err != nil {
+		t.Fatalf("config check: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{"rules.exclude_paths", "/healthz", "www.example.com -> example.com"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	bad := writeConfigFile(t, "[rules]\nexclude_paths = [\"healthz\"]\n")
+	if _, err := runConfigCheckCmd(t, "--config", bad); err == nil || !strings.Contains(err.Error(), "rules:") {
+		t.Errorf("config check error = %v, want the bad rule rejected", err)
+	}
+}
+
+func TestConfigCheck_ValidatesPipelineStages(t *testing.T) {
+	ok := writeConfigFile(t, "[pipeline]\nstages = [\"rules\", \"bot\"]\n")
+	out, err := runConfigCheckCmd(t, "--config", ok)
+	if err != nil {
+		t.Fatalf("config check: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "rules, bot") {
+		t.Errorf("output missing the configured stage order:\n%s", out)
+	}
+
+	bad := writeConfigFile(t, "[pipeline]\nstages = [\"geoip\"]\n")
+	if _, err := runConfigCheckCmd(t, "--config", bad); err == nil || !strings.Contains(err.Error(), "pipeline.stages") {
+		t.Errorf("config check error = %v, want the unknown stage rejected", err)
+	}
+}
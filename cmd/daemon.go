@@ -2,8 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
+	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
 	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/Elysium-Labs-EU/theia/internal/nginxconf"
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
 	"github.com/spf13/cobra"
 )
 
@@ -12,10 +21,40 @@ func newDaemonCmd() *cobra.Command {
 		Use:   "daemon",
 		Short: "Tail an nginx access log and write analytics to sqlite",
 		Long: `daemon tails an nginx access log, parses each line into a page view,
-and persists hourly aggregated stats to a sqlite database.
+and persists hourly aggregated stats to a sqlite database. It also keeps
+the last 30 minutes of visits in memory for "theia live", and serves
+Prometheus metrics about its own ingestion on --metrics-addr.
+
+Settings not passed as flags are read from the config file (--config).
+Sending the daemon SIGHUP re-reads the file's [rules] table (exclusions,
+path normalization, host aliases) and the goals and funnels in the database
+without restarting; if the new file is invalid, the rules already in force
+are kept.
+
+--log-path can also be a named pipe, or "-" for stdin, so logs can be piped
+in from docker, kubectl or journalctl. A stream is read as-is (there is no
+rotation to follow) and the daemon exits cleanly when it reaches EOF.
+
+--discover reads nginx's config (--nginx-conf and its includes) and tails
+the access log found there instead of --log-path: the one written with
+"$host" appended, or else the only one theia can parse. If that log has no
+host field and belongs to a single server block, its server_name becomes
+the default host unless one is configured. "theia nginx inspect" shows
+what discovery sees.
+
+Every 12 hours the daemon rolls expiring rows up into coarser tiers and
+deletes what no tier keeps any longer, as the config file's [retention]
+table says; "theia retention show" prints the policy in force.
+
+Under systemd (Type=notify) the daemon reports READY once it is reading
+the log, keeps its status line current, and, with WatchdogSec= set, pings
+the watchdog only while page views keep getting written, so a daemon
+wedged on its database is restarted.
 
 Example:
-  theia daemon --log-path /var/log/nginx/access.log --db-path /var/lib/theia/theia.db`,
+  theia daemon --log-path /var/log/nginx/access.log --db-path /var/lib/theia/theia.db
+  docker logs -f nginx 2>/dev/null | theia daemon --log-path - --db-path ./theia.db
+  theia daemon --discover --db-path /var/lib/theia/theia.db`,
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Flags parsed fine to reach here, so any error from this point
@@ -23,6 +62,11 @@ Example:
 			// flags/usage block for it.
 			cmd.SilenceUsage = true
 
+			cfg, err := applyConfigFile(cmd, daemonConfigBindings())
+			if err != nil {
+				return err
+			}
+
 			dbPath, err := cmd.Flags().GetString("db-path")
 			if err != nil {
 				return fmt.Errorf("parsing db-path flag: %w", err)
@@ -33,12 +77,125 @@ Example:
 				return fmt.Errorf("parsing log-path flag: %w", err)
 			}
 
-			return ingest.Run(cmd.Context(), dbPath, logPath)
+			defaultHost := cfg.DefaultHost
+			discover, err := cmd.Flags().GetBool("discover")
+			if err != nil {
+				return fmt.Errorf("parsing discover flag: %w", err)
+			}
+			if discover {
+				nginxConf, err := cmd.Flags().GetString("nginx-conf")
+				if err != nil {
+					return fmt.Errorf("parsing nginx-conf flag: %w", err)
+				}
+				found, err := discoverLogInput(nginxConf)
+				if err != nil {
+					return err
+				}
+				logPath = found.Path
+				log.Printf("Discovered access log %s (%s format) in %s", found.Path, found.Layout, nginxConf)
+				if found.DefaultHost != "" && defaultHost == "" {
+					defaultHost = found.DefaultHost
+					log.Printf("Lines without a host field will be counted as %s (its server_name)", defaultHost)
+				}
+			}
+
+			liveAddr, err := cmd.Flags().GetString("live-addr")
+			if err != nil {
+				return fmt.Errorf("parsing live-addr flag: %w", err)
+			}
+			if liveAddr != "" {
+				if err := apiserver.ValidateLoopbackAddr(liveAddr); err != nil {
+					return err
+				}
+			}
+
+			metricsAddr, err := cmd.Flags().GetString("metrics-addr")
+			if err != nil {
+				return fmt.Errorf("parsing metrics-addr flag: %w", err)
+			}
+			if metricsAddr != "" {
+				if err := apiserver.ValidateLoopbackAddr(metricsAddr); err != nil {
+					return err
+				}
+			}
+
+			deadLetterPath, err := cmd.Flags().GetString("dead-letter-path")
+			if err != nil {
+				return fmt.Errorf("parsing dead-letter-path flag: %w", err)
+			}
+			if deadLetterPath == "" {
+				deadLetterPath = filepath.Join(filepath.Dir(dbPath), "theia-rejected.log")
+			}
+			threshold, err := cmd.Flags().GetFloat64("parse-failure-threshold")
+			if err != nil {
+				return fmt.Errorf("parsing parse-failure-threshold flag: %w", err)
+			}
+			if threshold <= 0 || threshold > 1 {
+				return fmt.Errorf("invalid --parse-failure-threshold %v: must be greater than 0 and at most 1", threshold)
+			}
+
+			retention, err := retentionPolicy(cfg)
+			if err != nil {
+				return err
+			}
+
+			notifier, err := systemd.NotifierFromEnv()
+			if err != nil {
+				return err
+			}
+
+			// SIGHUP re-reads the [rules] table, goals and funnels without
+			// restarting, so no lines are dropped; every other setting still
+			// needs a restart.
+			reload := make(chan os.Signal, 1)
+			signal.Notify(reload, syscall.SIGHUP)
+			defer signal.Stop(reload)
+
+			return ingest.Run(cmd.Context(), ingest.Config{
+				DBPath:                dbPath,
+				LogPath:               logPath,
+				LiveAddr:              liveAddr,
+				MetricsAddr:           metricsAddr,
+				DeadLetterPath:        deadLetterPath,
+				ParseFailureThreshold: threshold,
+				DefaultHost:           defaultHost,
+				Rules:                 ingestRules(cfg.Rules),
+				StageOrder:            cfg.Pipeline.Stages,
+				Retention:             retention,
+				Reload:                reload,
+				Notifier:              notifier,
+				LoadRules: func() (ingest.Rules, error) {
+					reloaded, err := loadConfigFile(cmd)
+					if err != nil {
+						return ingest.Rules{}, err
+					}
+					return ingestRules(reloaded.Rules), nil
+				},
+			})
 		},
 	}
 
 	daemonCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
-	daemonCmd.Flags().String("log-path", "/var/log/nginx/access.log", "path to the nginx access log")
+	daemonCmd.Flags().String("log-path", "/var/log/nginx/access.log", "path to the nginx access log, a named pipe, or - to read from stdin")
+	daemonCmd.Flags().String("live-addr", "127.0.0.1:8083", "address of the realtime view read by theia live (must be 127.0.0.1 or localhost; empty disables)")
+	daemonCmd.Flags().String("metrics-addr", "127.0.0.1:8084", "address the daemon serves Prometheus metrics about its own ingestion on (must be 127.0.0.1 or localhost; empty disables)")
+	daemonCmd.Flags().String("dead-letter-path", "", "file redacted samples of unparseable log lines are written to (default theia-rejected.log next to --db-path)")
+	daemonCmd.Flags().Float64("parse-failure-threshold", ingest.DefaultParseFailureThreshold, "share of unparseable log lines (0-1] above which the daemon logs a warning")
+	daemonCmd.Flags().Bool("discover", false, "find the access log to read in nginx's config instead of passing --log-path")
+	daemonCmd.Flags().String("nginx-conf", nginxconf.DefaultPath, "nginx config read by --discover")
+	daemonCmd.MarkFlagsMutuallyExclusive("discover", "log-path")
+	addConfigFlag(daemonCmd)
 
 	return daemonCmd
 }
+
+func ingestRules(r config.RulesConfig) ingest.Rules {
+	return ingest.Rules{
+		ExcludePaths:       r.ExcludePaths,
+		ExcludeUserAgents:  r.ExcludeUserAgents,
+		ExcludeHosts:       r.ExcludeHosts,
+		HostAliases:        r.HostAliases,
+		StripQuery:         r.StripQuery,
+		StripTrailingSlash: r.StripTrailingSlash,
+	}
+}
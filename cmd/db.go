@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/dump"
+	"github.com/spf13/cobra"
+)
+
+// newDBCmd builds the `theia db` command group fresh each call, for the same
+// reason as newSystemCmd: cobra commands can only have one parent.
+func newDBCmd() *cobra.Command {
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Inspect and maintain the analytics database",
+	}
+
+	dbCmd.AddCommand(newDBStatsCmd())
+	dbCmd.AddCommand(newDBBackupCmd())
+	dbCmd.AddCommand(newDBRestoreCmd())
+	dbCmd.AddCommand(newDBMergeCmd())
+	dbCmd.AddCommand(newDBStatusCmd())
+	dbCmd.AddCommand(newDBMigrateCmd())
+	dbCmd.AddCommand(newDBRollbackCmd())
+	dbCmd.AddCommand(newDBForceCmd())
+	dbCmd.AddCommand(newDBCheckCmd())
+
+	return dbCmd
+}
+
+func newDBStatsCmd() *cobra.Command {
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Print the database file's size and each table's rows and size",
+		Long: `stats prints how big the database file is, how much of it is free
+pages waiting to be vacuumed, and each table's row count and size on disk,
+its indexes included.
+
+The daemon's cleanup deletes rows in batches and then runs an incremental
+vacuum, so the file shrinks without ever being locked for long. Databases
+created before that was added are converted by a one-time VACUUM when the
+daemon or any command first opens them after upgrading.
+
+Example:
+  theia db stats --db-path /var/lib/theia/theia.db`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			if _, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			}); err != nil {
+				return err
+			}
+
+			return withReadingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				space, err := database.GetSpace(ctx, db)
+				if err != nil {
+					return err
+				}
+				return renderSpace(cmd.OutOrStdout(), &space)
+			})
+		},
+	}
+
+	statsCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addMigrateFlag(statsCmd)
+	addConfigFlag(statsCmd)
+
+	return statsCmd
+}
+
+func newDBBackupCmd() *cobra.Command {
+	backupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write a consistent snapshot of the database, even while the daemon writes",
+		Long: `backup writes a snapshot of the database to --out with VACUUM INTO,
+which reads it in one transaction: the daemon keeps writing meanwhile, and
+none of its unfinished writes end up in the snapshot. Copying theia.db
+instead misses whatever is still in its write-ahead log. The database is
+backed up as it is, without migrating it first.
+
+When --out is a directory, the backup is named after the current UTC time
+(theia-20260102T150405Z.db), and --keep deletes all but that many of the
+newest such backups in it, for scheduled backups. --gzip compresses the
+backup.
+
+Examples:
+  theia db backup --out /var/backups/theia.db
+  theia db backup --out /var/backups/theia/ --gzip --keep 7`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			out, err := cmd.Flags().GetString("out")
+			if err != nil {
+				return fmt.Errorf("parsing out flag: %w", err)
+			}
+			compress, err := cmd.Flags().GetBool("gzip")
+			if err != nil {
+				return fmt.Errorf("parsing gzip flag: %w", err)
+			}
+			keep, err := cmd.Flags().GetInt("keep")
+			if err != nil {
+				return fmt.Errorf("parsing keep flag: %w", err)
+			}
+			if out == "" {
+				return errors.New("--out is required")
+			}
+			if keep < 0 {
+				return fmt.Errorf("--keep %d: must be 0 (keep every backup) or more", keep)
+			}
+
+			dir := ""
+			if info, err := os.Stat(out); err == nil && info.IsDir() {
+				dir = out
+				out = filepath.Join(dir, database.BackupName(time.Now(), compress))
+			} else if keep > 0 {
+				return fmt.Errorf("--keep needs --out to be an existing directory, got %s", out)
+			}
+
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			if _, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			}); err != nil {
+				return err
+			}
+
+			if err := withExistingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				return database.Backup(ctx, db, out, compress)
+			}); err != nil {
+				return err
+			}
+			info, err := os.Stat(out)
+			if err != nil {
+				return fmt.Errorf("could not read backup: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Backed up to %s (%s)\n", out, formatBytes(info.Size()))
+
+			if dir == "" || keep == 0 {
+				return nil
+			}
+			pruned, err := database.PruneBackups(dir, keep)
+			for _, path := range pruned {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Deleted old backup %s\n", path)
+			}
+			return err
+		},
+	}
+
+	backupCmd.Flags().String("out", "", "file to write the backup to, or a directory to add a timestamped one to")
+	backupCmd.Flags().Bool("gzip", false, "compress the backup with gzip")
+	backupCmd.Flags().Int("keep", 0, "when --out is a directory, how many of the newest backups in it to keep (0 keeps all)")
+	backupCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addConfigFlag(backupCmd)
+
+	return backupCmd
+}
+
+func newDBRestoreCmd() *cobra.Command {
+	restoreCmd := &cobra.Command{
+		Use:   "restore <backup>",
+		Short: "Replace the database with a backup",
+		Long: `restore replaces the database with a backup written by "theia db
+backup", gzipped or not. Stop the daemon first.
+
+The backup is unpacked beside the database and its schema version checked
+before anything is replaced: a backup without a theia schema, one a failed
+migration left dirty, or one from a newer theia is refused. An older backup
+is migrated up the next time theia opens it. The database it replaces is
+kept beside it with a .pre-restore suffix.
+
+Example:
+  theia db restore /var/backups/theia/theia-20260102T150405Z.db.gz`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			if _, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			}); err != nil {
+				return err
+			}
+			dbPath, err := cmd.Flags().GetString("db-path")
+			if err != nil {
+				return fmt.Errorf("parsing db-path flag: %w", err)
+			}
+			_, statErr := os.Stat(dbPath)
+			replacing := statErr == nil
+
+			version, err := database.Restore(cmd.Context(), args[0], dbPath, database.MigrationsFS, database.MigrationsPath)
+			if err != nil {
+				return fmt.Errorf("restoring %s: %w", args[0], err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Restored %s from %s (schema version %d)\n", dbPath, args[0], version)
+			if replacing {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "The database it replaced is kept at %s\n", dbPath+database.PreRestoreSuffix)
+			}
+			return nil
+		},
+	}
+
+	restoreCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addConfigFlag(restoreCmd)
+
+	return restoreCmd
+}
+
+func newDBMergeCmd() *cobra.Command {
+	mergeCmd := &cobra.Command{
+		Use:   "merge <source.db>...",
+		Short: "Combine the databases of several servers into one",
+		Long: `merge sums the stats in the databases of several servers running theia
+into the database at --db-path, for one view of a site served by more than
+one node. Page views, status codes, referrers, goal hits and funnel steps
+are added up; the daily visitor hashes are unioned, so a visitor who hit
+two nodes on a day counts once while the day is recent enough to keep
+them, and so does a visitor who converted on both.
+
+Each run rebuilds the target from the sources, so merging again after the
+sources grew counts nothing twice. The target must therefore be a new
+database or one only merge has written to; one with stats of its own is
+refused. Sources are opened read-only and must be at the target's schema
+version, so run the same theia everywhere; "theia db backup" copies a
+node's database off it consistently while its daemon runs. At most 10
+databases merge at once.
+
+Funnel step visitors, and daily and monthly unique visitors once days roll
+up, are summed per node: a visitor who hit several nodes counts once per
+node there.
+
+Example:
+  theia db merge node1.db node2.db --db-path /var/lib/theia/combined.db`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			if _, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			}); err != nil {
+				return err
+			}
+			dbPath, err := cmd.Flags().GetString("db-path")
+			if err != nil {
+				return fmt.Errorf("parsing db-path flag: %w", err)
+			}
+			target, err := filepath.Abs(dbPath)
+			if err != nil {
+				return fmt.Errorf("could not resolve %s: %w", dbPath, err)
+			}
+			for _, source := range args {
+				if abs, err := filepath.Abs(source); err == nil && abs == target {
+					return fmt.Errorf("%s is the target database, not a source", source)
+				}
+			}
+
+			return withMigratedDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				counts, err := dump.Merge(ctx, db, args, time.Now())
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Merged %d databases into %s\n\n", len(args), dbPath)
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				_, _ = fmt.Fprintln(w, "TABLE\tROWS")
+				for _, t := range counts {
+					_, _ = fmt.Fprintf(w, "%s\t%d\n", t.Name, t.Rows)
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	mergeCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database to merge into")
+	addConfigFlag(mergeCmd)
+
+	return mergeCmd
+}
+
+func renderSpace(out io.Writer, space *database.Space) error {
+	_, _ = fmt.Fprintf(out, "File size:    %s (%d pages of %d bytes)\n", formatBytes(space.Pages*space.PageSize), space.Pages, space.PageSize)
+	_, _ = fmt.Fprintf(out, "Free pages:   %d (%s)\n", space.FreePages, formatBytes(space.FreePages*space.PageSize))
+	_, _ = fmt.Fprintf(out, "Auto vacuum:  %s\n\n", space.AutoVacuum)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TABLE\tROWS\tSIZE")
+	for _, t := range space.Tables {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", t.Name, t.Rows, formatBytes(t.Bytes))
+	}
+	return w.Flush()
+}
+
+// formatBytes prints n in the largest binary unit it's at least one of.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
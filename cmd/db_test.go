@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/spf13/cobra"
+)
+
+func runDBCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newDBCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestDBStats_PrintsTables(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	if _, err := db.ExecContext(t.Context(),
+		`INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (1, '/', 'example.com', 1, 0, 0)`); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	out, err := runDBCmd(t, "stats", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db stats: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{"Auto vacuum:  incremental", "TABLE", "hourly_stats"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\ngot: %s", want, out)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range []struct {
+		n    int64
+		want string
+	}{
+		{512, "512 B"},
+		{4096, "4.0 KiB"},
+		{3 << 20, "3.0 MiB"},
+	} {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDBBackupAndRestore(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	if _, err := db.ExecContext(t.Context(),
+		`INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (1, '/', 'example.com', 1, 0, 0)`); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	backups := t.TempDir()
+	for range 2 {
+		if out, err := runDBCmd(t, "backup", "--db-path", dbPath, "--out", backups, "--gzip", "--keep", "1"); err != nil {
+			t.Fatalf("db backup: %v\noutput: %s", err, out)
+		}
+		// Backups are named by the second they're taken.
+		time.Sleep(time.Second)
+	}
+	entries, err := os.ReadDir(backups)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".db.gz") {
+		t.Fatalf("backups = %v, want one gzipped backup", entries)
+	}
+
+	out, err := runDBCmd(t, "restore", filepath.Join(backups, entries[0].Name()), "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db restore: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "The database it replaced is kept at "+dbPath+".pre-restore") {
+		t.Errorf("output doesn't name the kept database\ngot: %s", out)
+	}
+}
+
+func TestDBBackup_KeepNeedsDirectory(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+	_, err := runDBCmd(t, "backup", "--db-path", dbPath, "--out", filepath.Join(t.TempDir(), "backup.db"), "--keep", "3")
+	if err == nil || !strings.Contains(err.Error(), "--keep needs --out to be an existing directory") {
+		t.Errorf("db backup error = %v, want one about --keep", err)
+	}
+}
+
+func TestDBMerge(t *testing.T) {
+	var sources []string
+	for range 2 {
+		db, dbPath := setupCmdTestDB(t)
+		if _, err := db.ExecContext(t.Context(),
+			`INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (1, '/', 'example.com', 2, 0, 0)`); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+		database.Close(db) //nolint:errcheck // close before command reads the same file
+		sources = append(sources, dbPath)
+	}
+	target := filepath.Join(t.TempDir(), "combined.db")
+
+	out, err := runDBCmd(t, "merge", sources[0], sources[1], "--db-path", target)
+	if err != nil {
+		t.Fatalf("db merge: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{"Merged 2 databases into " + target, "hourly_stats"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\ngot: %s", want, out)
+		}
+	}
+
+	_, err = runDBCmd(t, "merge", sources[0], target, "--db-path", target)
+	if err == nil || !strings.Contains(err.Error(), "is the target database") {
+		t.Errorf("db merge error = %v, want one about the target", err)
+	}
+}
+
+func TestReportingCommands_ReadWithoutMigrating(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  func() *cobra.Command
+		args []string
+	}{
+		{"db stats", newDBCmd, []string{"stats"}},
+		{"export", newExportCmd, []string{"--out", filepath.Join(t.TempDir(), "dump")}},
+		{"retention show", newRetentionCmd, []string{"show"}},
+		{"goals list", newGoalsCmd, []string{"list"}},
+		{"funnel list", newFunnelCmd, []string{"list"}},
+		{"funnel", newFunnelCmd, []string{"checkout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, dbPath := setupCmdTestDB(t)
+			if _, err := database.Rollback(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+				t.Fatalf("Rollback: %v", err)
+			}
+			database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+			cmd := tt.cmd()
+			cmd.SetOut(&bytes.Buffer{})
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs(append(tt.args, "--db-path", dbPath))
+			if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "is older than") {
+				t.Fatalf("%s on an older schema = %v, want a version error", tt.name, err)
+			}
+
+			cmd = tt.cmd()
+			buf := &bytes.Buffer{}
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append(tt.args, "--db-path", dbPath, "--migrate"))
+			err := cmd.Execute()
+			// The funnel report still refuses a funnel that isn't defined,
+			// but only once the schema has been brought up to read it.
+			if tt.name == "funnel" {
+				if err == nil || strings.Contains(err.Error(), "is older than") {
+					t.Fatalf("funnel --migrate = %v, want an unknown funnel error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s --migrate: %v\noutput: %s", tt.name, err, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/dump"
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/spf13/cobra"
+)
+
+// problemPastRetention is rows a cleanup should already have rolled up or
+// deleted: the daemon's cleanup isn't running, or keeps failing.
+const problemPastRetention = "past retention"
+
+func newDBCheckCmd() *cobra.Command {
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the database file, its schema version and its rows",
+		Long: `check runs SQLite's integrity_check and foreign_key_check, checks the
+schema is at this theia's version and clean, then looks for rows theia
+wouldn't have written:
+
+  negative count             a count below zero
+  host not normalized        a host the daemon would have lowercased
+  past retention             rows older than the retention policy allows,
+                             more than one cleanup interval (12 hours) ago
+  more visitors than views   a host's day with more visitor_days rows than
+                             page and bot views, among the days its hourly
+                             or daily stats still cover
+
+Nothing is changed unless --repair is passed: it clamps negative counts to
+zero, adds rows under an unnormalized host onto the normalized host's, and
+runs the cleanup. Days with more visitors than views aren't repaired, as
+there's no telling which side is wrong. A file that fails the integrity
+check isn't repaired either: restore a backup with "theia db restore".
+
+check exits non-zero while any problem remains, so it can run from a
+timer or monitoring script. Without --repair it opens the database
+read-only, so it's safe beside the daemon and on a copy.
+
+Examples:
+  theia db check --db-path /var/lib/theia/theia.db
+  theia db check --repair --config /etc/theia/theia.toml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			repair, err := cmd.Flags().GetBool("repair")
+			if err != nil {
+				return fmt.Errorf("parsing repair flag: %w", err)
+			}
+
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			cfg, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			})
+			if err != nil {
+				return err
+			}
+			policy, err := retentionPolicy(cfg)
+			if err != nil {
+				return err
+			}
+
+			open := withReadOnlyDB
+			if repair {
+				open = withExistingDB
+			}
+			return open(cmd, func(ctx context.Context, db *sql.DB) error {
+				return runDBCheck(ctx, cmd.OutOrStdout(), db, policy, repair)
+			})
+		},
+	}
+
+	checkCmd.Flags().Bool("repair", false, "fix the problems that can be fixed")
+	checkCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addConfigFlag(checkCmd)
+
+	return checkCmd
+}
+
+func runDBCheck(ctx context.Context, out io.Writer, db *sql.DB, policy ingest.RetentionPolicy, repair bool) error {
+	problems, err := database.CheckIntegrity(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		_, _ = fmt.Fprintln(out, "Integrity:       failed")
+		for _, p := range problems {
+			_, _ = fmt.Fprintf(out, "  %s\n", p)
+		}
+		return errors.New("the database file is damaged: restore a backup with `theia db restore`")
+	}
+	_, _ = fmt.Fprintln(out, "Integrity:       ok")
+
+	if err := database.CheckSchemaVersion(ctx, db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		_, _ = fmt.Fprintln(out, "Schema version:  failed")
+		return err
+	}
+	_, _ = fmt.Fprintln(out, "Schema version:  ok")
+
+	anomalies, err := findAnomalies(ctx, db, policy)
+	if err != nil {
+		return err
+	}
+
+	if repair && slices.ContainsFunc(anomalies, isRepairable) {
+		fixed, err := dump.Repair(ctx, db, ingest.NormalizeHost)
+		if err != nil {
+			return err
+		}
+		if past := pastRetention(anomalies); len(past) > 0 {
+			ingest.PerformCleanup(ctx, db, policy)
+			fixed = append(fixed, past...)
+		}
+		_, _ = fmt.Fprintln(out, "\nRepaired:")
+		if err := renderAnomalies(out, fixed, false); err != nil {
+			return err
+		}
+
+		if anomalies, err = findAnomalies(ctx, db, policy); err != nil {
+			return err
+		}
+	}
+
+	_, _ = fmt.Fprintln(out)
+	if len(anomalies) == 0 {
+		_, _ = fmt.Fprintln(out, "No problems found.")
+		return nil
+	}
+	_, _ = fmt.Fprintln(out, "Problems:")
+	if err := renderAnomalies(out, anomalies, true); err != nil {
+		return err
+	}
+	if !repair && slices.ContainsFunc(anomalies, isRepairable) {
+		return errors.New("the check found problems: --repair fixes the repairable ones")
+	}
+	return errors.New("the check found problems --repair can't fix")
+}
+
+// findAnomalies gathers the dump package's row checks and the rows past
+// retention a cleanup CleanupInterval ago would have removed.
+func findAnomalies(ctx context.Context, db *sql.DB, policy ingest.RetentionPolicy) ([]dump.Anomaly, error) {
+	anomalies, err := dump.FindAnomalies(ctx, db, ingest.NormalizeHost)
+	if err != nil {
+		return nil, err
+	}
+
+	previews, err := ingest.PreviewCleanup(ctx, db, policy, time.Now().Add(-ingest.CleanupInterval))
+	if err != nil {
+		return nil, err
+	}
+	expired := map[string]int{}
+	for _, p := range previews {
+		if p.Rows == 0 {
+			continue
+		}
+		i, ok := expired[p.Table]
+		if !ok {
+			i = len(anomalies)
+			expired[p.Table] = i
+			anomalies = append(anomalies, dump.Anomaly{Table: p.Table, Problem: problemPastRetention, Repairable: true})
+		}
+		anomalies[i].Rows += p.Rows
+	}
+	return anomalies, nil
+}
+
+func pastRetention(anomalies []dump.Anomaly) []dump.Anomaly {
+	var past []dump.Anomaly
+	for _, a := range anomalies {
+		if a.Problem == problemPastRetention {
+			past = append(past, a)
+		}
+	}
+	return past
+}
+
+func isRepairable(a dump.Anomaly) bool { return a.Repairable }
+
+func renderAnomalies(out io.Writer, anomalies []dump.Anomaly, withRepairable bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if !withRepairable {
+		_, _ = fmt.Fprintln(w, "PROBLEM\tTABLE\tROWS")
+		for _, a := range anomalies {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", a.Problem, a.Table, a.Rows)
+		}
+		return w.Flush()
+	}
+
+	_, _ = fmt.Fprintln(w, "PROBLEM\tTABLE\tROWS\tREPAIRABLE")
+	for _, a := range anomalies {
+		repairable := "no"
+		if a.Repairable {
+			repairable = "yes"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", a.Problem, a.Table, a.Rows, repairable)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func TestDBCheck_RepairsWhatItCan(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	for _, stmt := range []string{
+		// Hour 24*19000 is in 2022, long past every retention.
+		`INSERT INTO hourly_scans (bucket, host, signature, count) VALUES (24 * 19000, 'example.com', 'wp-login', 1)`,
+		`INSERT INTO hourly_status_codes (bucket, path, host, status_code, count) VALUES (24 * 19000, '/', 'Example.com', 200, 1)`,
+	} {
+		if _, err := db.ExecContext(t.Context(), stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	out, err := runDBCmd(t, "check", "--db-path", dbPath)
+	if err == nil || !strings.Contains(err.Error(), "--repair fixes") {
+		t.Fatalf("db check error = %v, want one pointing at --repair\noutput: %s", err, out)
+	}
+	for _, want := range []string{"Integrity:       ok", "past retention", "hourly_scans", "host not normalized"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\ngot: %s", want, out)
+		}
+	}
+
+	out, err = runDBCmd(t, "check", "--repair", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db check --repair: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "Repaired:") || !strings.Contains(out, "No problems found.") {
+		t.Errorf("output doesn't report the repair\ngot: %s", out)
+	}
+
+	if out, err := runDBCmd(t, "check", "--db-path", dbPath); err != nil {
+		t.Errorf("db check after repair: %v\noutput: %s", err, out)
+	}
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newDBStatusCmd() *cobra.Command {
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the database's schema version and the migrations theia carries",
+		Long: `status prints the schema version the database is at, whether a
+migration failed partway and left it dirty, and every migration this theia
+carries, applied or pending. It opens the database as it is, without
+migrating it.
+
+Example:
+  theia db status --db-path /var/lib/theia/theia.db`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runSchemaCmd(cmd, func(_ context.Context, db *sql.DB) error {
+				status, err := database.GetSchemaStatus(db, database.MigrationsFS, database.MigrationsPath)
+				if err != nil {
+					return err
+				}
+				return renderSchemaStatus(cmd.OutOrStdout(), &status)
+			})
+		},
+	}
+
+	addSchemaFlags(statusCmd, false)
+	return statusCmd
+}
+
+func newDBMigrateCmd() *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate the schema up, or down with --to",
+		Long: `migrate runs the migrations that take the schema to --to, the newest
+this theia carries by default. The daemon and the commands that change
+the database migrate up on their own, and the reporting ones do with
+--migrate; migrate is for upgrading a database without them, for going to
+a particular version, or down to an older one before running an older
+theia. --to 0 runs every down migration. Down migrations drop the tables
+and columns they undo along with their data, so going down asks first and
+is best preceded by "theia db backup". Stop the daemon first.
+
+Examples:
+  theia db migrate
+  theia db migrate --to 9`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			to, err := cmd.Flags().GetUint("to")
+			if err != nil {
+				return fmt.Errorf("parsing to flag: %w", err)
+			}
+			return runSchemaCmd(cmd, func(_ context.Context, db *sql.DB) error {
+				status, err := database.GetSchemaStatus(db, database.MigrationsFS, database.MigrationsPath)
+				if err != nil {
+					return err
+				}
+				if status.Dirty {
+					return database.DirtyError(status.Version)
+				}
+				// --to 0 is a real target (every migration undone), so
+				// only a missing --to means the newest.
+				if !cmd.Flags().Changed("to") {
+					to = status.Migrations[len(status.Migrations)-1].Version
+				}
+				if to == status.Version {
+					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Schema already at version %d\n", to)
+					return nil
+				}
+				if to < status.Version && !confirmSchemaChange(cmd, fmt.Sprintf(
+					"Migrate the schema down from version %d to %d? Down migrations drop what they undo, data included.", status.Version, to)) {
+					_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Canceled.")
+					return nil
+				}
+
+				if err := database.MigrateTo(db, database.MigrationsFS, database.MigrationsPath, to); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Schema migrated from version %d to %d\n", status.Version, to)
+				return nil
+			})
+		},
+	}
+
+	migrateCmd.Flags().Uint("to", 0, "schema version to migrate to, 0 for none (default the newest)")
+	addSchemaFlags(migrateCmd, true)
+	return migrateCmd
+}
+
+func newDBRollbackCmd() *cobra.Command {
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Undo the newest applied migration",
+		Long: `rollback runs the down migration of the newest migration applied to the
+database, leaving the schema one version older. The down migration drops
+what its migration added, data included, so rollback asks first; take a
+"theia db backup" before. Stop the daemon first: it migrates back up when
+it next starts.
+
+Example:
+  theia db rollback --db-path /var/lib/theia/theia.db`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runSchemaCmd(cmd, func(_ context.Context, db *sql.DB) error {
+				status, err := database.GetSchemaStatus(db, database.MigrationsFS, database.MigrationsPath)
+				if err != nil {
+					return err
+				}
+				if status.Dirty {
+					return database.DirtyError(status.Version)
+				}
+				if status.Version == 0 {
+					return errors.New("no migration to roll back")
+				}
+				if !confirmSchemaChange(cmd, fmt.Sprintf(
+					"Roll back migration %d (%s)? Its down migration drops what it added, data included.", status.Version, migrationName(&status, status.Version))) {
+					_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Canceled.")
+					return nil
+				}
+
+				version, err := database.Rollback(db, database.MigrationsFS, database.MigrationsPath)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Rolled back migration %d, schema at version %d\n", status.Version, version)
+				return nil
+			})
+		},
+	}
+
+	addSchemaFlags(rollbackCmd, true)
+	return rollbackCmd
+}
+
+func newDBForceCmd() *cobra.Command {
+	forceCmd := &cobra.Command{
+		Use:   "force <version>",
+		Short: "Record the schema as a version without running migrations",
+		Long: `force records the database's schema as being at <version> and clears the
+dirty flag, without running any migration. It's how a schema a failed
+migration left dirty is recovered: see which migration failed with "theia
+db status", finish or undo what it did by hand, then force the version the
+schema now matches (the failed one if it's done, the one before if it's
+undone) and run "theia db migrate". Forcing a version the schema doesn't
+match makes later migrations fail or corrupt it, so force asks first.
+
+Example:
+  theia db force 9 --db-path /var/lib/theia/theia.db`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseUint(args[0], 10, 0)
+			if err != nil {
+				return fmt.Errorf("invalid version %q: %w", args[0], err)
+			}
+			return runSchemaCmd(cmd, func(_ context.Context, db *sql.DB) error {
+				status, err := database.GetSchemaStatus(db, database.MigrationsFS, database.MigrationsPath)
+				if err != nil {
+					return err
+				}
+				if !confirmSchemaChange(cmd, fmt.Sprintf(
+					"Record the schema as version %d without running any migration?", version)) {
+					_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Canceled.")
+					return nil
+				}
+
+				if err := database.ForceVersion(db, database.MigrationsFS, database.MigrationsPath, uint(version)); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Schema recorded as version %d (was %s)\n", version, formatSchemaVersion(&status))
+				return nil
+			})
+		},
+	}
+
+	addSchemaFlags(forceCmd, true)
+	return forceCmd
+}
+
+// runSchemaCmd resolves --db-path and runs fn against the database as it is,
+// holding the migration lock.
+func runSchemaCmd(cmd *cobra.Command, fn func(ctx context.Context, db *sql.DB) error) error {
+	// Flags parsed fine to reach here, so any error from this point on is a
+	// runtime failure, not a usage mistake — don't dump the flags/usage
+	// block for it.
+	cmd.SilenceUsage = true
+
+	if _, err := applyConfigFile(cmd, []configBinding{
+		{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+	}); err != nil {
+		return err
+	}
+	return withLockedDB(cmd, fn)
+}
+
+func addSchemaFlags(cmd *cobra.Command, asks bool) {
+	if asks {
+		cmd.Flags().BoolP("yes", "y", false, "skip the confirmation prompt")
+	}
+	cmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addConfigFlag(cmd)
+}
+
+// confirmSchemaChange asks before a change that can lose data, unless --yes
+// was passed.
+func confirmSchemaChange(cmd *cobra.Command, prompt string) bool {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return true
+	}
+	return ui.Confirm(bufio.NewReader(cmd.InOrStdin()), cmd.OutOrStdout(), prompt, false)
+}
+
+func renderSchemaStatus(out io.Writer, status *database.SchemaStatus) error {
+	latest := status.Migrations[len(status.Migrations)-1].Version
+	_, _ = fmt.Fprintf(out, "Schema version:  %s\n", formatSchemaVersion(status))
+	_, _ = fmt.Fprintf(out, "Latest version:  %d\n", latest)
+	if status.Dirty {
+		_, _ = fmt.Fprintf(out, "\n%v\n", database.DirtyError(status.Version))
+	}
+	_, _ = fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tMIGRATION\tSTATE")
+	for _, m := range status.Migrations {
+		state := "pending"
+		switch {
+		case m.Version == status.Version && status.Dirty:
+			state = "dirty"
+		case m.Version <= status.Version:
+			state = "applied"
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, state)
+	}
+	return w.Flush()
+}
+
+func formatSchemaVersion(status *database.SchemaStatus) string {
+	switch {
+	case status.Version == 0:
+		return "none"
+	case status.Dirty:
+		return strconv.FormatUint(uint64(status.Version), 10) + " (dirty)"
+	default:
+		return strconv.FormatUint(uint64(status.Version), 10)
+	}
+}
+
+func migrationName(status *database.SchemaStatus, version uint) string {
+	for _, m := range status.Migrations {
+		if m.Version == version {
+			return m.Name
+		}
+	}
+	return "unknown"
+}
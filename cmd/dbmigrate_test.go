@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func runDBCmdWithInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	cmd := newDBCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func schemaVersion(t *testing.T, dbPath string) (uint, bool) {
+	t.Helper()
+	db, err := database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	version, dirty, err := database.GetCurrentVersion(db, database.MigrationsFS, database.MigrationsPath)
+	if err != nil {
+		t.Fatalf("GetCurrentVersion: %v", err)
+	}
+	return version, dirty
+}
+
+func TestDBRollbackAsksFirst(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+	latest, _ := schemaVersion(t, dbPath)
+
+	out, err := runDBCmdWithInput(t, "n\n", "rollback", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db rollback: %v\noutput: %s", err, out)
+	}
+	if version, _ := schemaVersion(t, dbPath); version != latest || !strings.Contains(out, "Canceled.") {
+		t.Fatalf("declined rollback left version %d, want %d\noutput: %s", version, latest, out)
+	}
+
+	out, err = runDBCmdWithInput(t, "y\n", "rollback", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db rollback: %v\noutput: %s", err, out)
+	}
+	if version, _ := schemaVersion(t, dbPath); version != latest-1 {
+		t.Errorf("version after rollback = %d, want %d", version, latest-1)
+	}
+
+	out, err = runDBCmd(t, "migrate", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db migrate: %v\noutput: %s", err, out)
+	}
+	if version, _ := schemaVersion(t, dbPath); version != latest {
+		t.Errorf("version after migrate = %d, want %d", version, latest)
+	}
+}
+
+func TestDBMigrateToZeroUndoesEveryMigration(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	out, err := runDBCmd(t, "migrate", "--to", "0", "--yes", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db migrate --to 0: %v\noutput: %s", err, out)
+	}
+
+	db, err = database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	status, err := database.GetSchemaStatus(db, database.MigrationsFS, database.MigrationsPath)
+	if err != nil {
+		t.Fatalf("GetSchemaStatus: %v", err)
+	}
+	if status.Version != 0 {
+		t.Errorf("version after migrate --to 0 = %d, want 0\noutput: %s", status.Version, out)
+	}
+}
+
+func TestDBStatusAndForceRecoverDirtySchema(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	if _, err := db.ExecContext(t.Context(), `UPDATE schema_migrations SET version = 9, dirty = 1`); err != nil {
+		t.Fatalf("mark dirty: %v", err)
+	}
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	out, err := runDBCmd(t, "status", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db status: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{"Schema version:  9 (dirty)", "rollups             dirty", "merge_sources       pending"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("status output missing %q\ngot: %s", want, out)
+		}
+	}
+
+	if _, err := runDBCmd(t, "migrate", "--db-path", dbPath); err == nil || !strings.Contains(err.Error(), "theia db force") {
+		t.Errorf("db migrate on a dirty schema = %v, want a pointer to db force", err)
+	}
+
+	out, err = runDBCmd(t, "force", "9", "--yes", "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("db force: %v\noutput: %s", err, out)
+	}
+	if version, dirty := schemaVersion(t, dbPath); version != 9 || dirty {
+		t.Errorf("after force: version %d dirty %v, want 9 clean", version, dirty)
+	}
+}
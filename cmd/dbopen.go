@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/spf13/cobra"
+)
+
+// withMigratedDB opens the database named by --db-path, brings its schema up to
+// date behind the shared migration lock (issue #23), and runs fn against it.
+func withMigratedDB(cmd *cobra.Command, fn func(ctx context.Context, db *sql.DB) error) error {
+	dbPath, err := cmd.Flags().GetString("db-path")
+	if err != nil {
+		return fmt.Errorf("parsing db-path flag: %w", err)
+	}
+
+	db, err := database.Open(cmd.Context(), dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	if err := migrateLocked(db, dbPath); err != nil {
+		return err
+	}
+
+	return fn(cmd.Context(), db)
+}
+
+// migrateLocked brings db's schema up to date behind the shared migration
+// lock, so it doesn't race a daemon starting on the same dbPath.
+func migrateLocked(db *sql.DB, dbPath string) error {
+	release, err := database.AcquireMigrationLock(dbPath)
+	if err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	err = database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath)
+	_ = release() // release error is not actionable here
+	if err != nil {
+		return fmt.Errorf("running migrations: %w", err)
+	}
+	return nil
+}
+
+// openForReading opens dbPath for a command that only reads it. By default
+// it's opened read-only and must already be at this theia's schema version,
+// so reporting never changes a database, live or a copy; with --migrate it's
+// opened read-write and migrated up first, as withMigratedDB does.
+func openForReading(cmd *cobra.Command, dbPath string) (*sql.DB, error) {
+	migrate, err := cmd.Flags().GetBool("migrate")
+	if err != nil {
+		return nil, fmt.Errorf("parsing migrate flag: %w", err)
+	}
+
+	if migrate {
+		db, err := database.Open(cmd.Context(), dbPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := migrateLocked(db, dbPath); err != nil {
+			database.Close(db) //nolint:errcheck // the migration error is the one to report
+			return nil, err
+		}
+		return db, nil
+	}
+
+	db, err := database.OpenReadOnly(cmd.Context(), dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.CheckSchemaVersion(cmd.Context(), db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		database.Close(db) //nolint:errcheck // the version error is the one to report
+		return nil, fmt.Errorf("%s: %w", dbPath, err)
+	}
+	return db, nil
+}
+
+// withReadingDB opens the database named by --db-path with openForReading
+// and runs fn against it.
+func withReadingDB(cmd *cobra.Command, fn func(ctx context.Context, db *sql.DB) error) error {
+	dbPath, err := cmd.Flags().GetString("db-path")
+	if err != nil {
+		return fmt.Errorf("parsing db-path flag: %w", err)
+	}
+
+	db, err := openForReading(cmd, dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	return fn(cmd.Context(), db)
+}
+
+func addMigrateFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("migrate", false, "open the database read-write and migrate its schema up first, instead of reading it as it is")
+}
+
+// withExistingDB opens the database named by --db-path as it is, without
+// migrating it, and runs fn against it. Unlike withMigratedDB it won't create
+// a database that isn't there.
+func withExistingDB(cmd *cobra.Command, fn func(ctx context.Context, db *sql.DB) error) error {
+	dbPath, err := cmd.Flags().GetString("db-path")
+	if err != nil {
+		return fmt.Errorf("parsing db-path flag: %w", err)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("no database at %s: %w", dbPath, err)
+	}
+
+	db, err := database.Open(cmd.Context(), dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	return fn(cmd.Context(), db)
+}
+
+// withLockedDB opens the database named by --db-path as it is and runs fn
+// against it holding the migration lock, so no theia starting meanwhile
+// migrates the schema under fn.
+func withLockedDB(cmd *cobra.Command, fn func(ctx context.Context, db *sql.DB) error) error {
+	return withExistingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+		dbPath, err := cmd.Flags().GetString("db-path")
+		if err != nil {
+			return fmt.Errorf("parsing db-path flag: %w", err)
+		}
+		release, err := database.AcquireMigrationLock(dbPath)
+		if err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		defer release() //nolint:errcheck // release error is not actionable here
+
+		return fn(ctx, db)
+	})
+}
+
+// withReadOnlyDB opens the database named by --db-path read-only, as it is,
+// and runs fn against it.
+func withReadOnlyDB(cmd *cobra.Command, fn func(ctx context.Context, db *sql.DB) error) error {
+	dbPath, err := cmd.Flags().GetString("db-path")
+	if err != nil {
+		return fmt.Errorf("parsing db-path flag: %w", err)
+	}
+
+	db, err := database.OpenReadOnly(cmd.Context(), dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	return fn(cmd.Context(), db)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/dump"
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump every table to portable NDJSON or CSV files",
+		Long: `export writes every table to a directory: one NDJSON or CSV file per
+table and a manifest.json naming the dump's layout version, the schema
+version it came from, the hosts and dates it covers and each file's row
+count. Hours, days and months are written as UTC times and dates rather
+than the integers the database keys them by, so a dump outlives schema
+changes. "theia import-dump" loads one into another database.
+
+--host limits the dump to some hosts, for handing a site's data to whoever
+owns it; such a dump leaves out the tables that aren't per host (parse
+failures, goal and funnel definitions). The dump includes the daily
+visitor hashes behind unique visitor counts, so treat it like the database.
+
+Examples:
+  theia export --out ./theia-dump
+  theia export --out ./example-dump --format csv --host example.com`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			out, err := cmd.Flags().GetString("out")
+			if err != nil {
+				return fmt.Errorf("parsing out flag: %w", err)
+			}
+			formatName, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return fmt.Errorf("parsing format flag: %w", err)
+			}
+			hosts, err := cmd.Flags().GetStringSlice("host")
+			if err != nil {
+				return fmt.Errorf("parsing host flag: %w", err)
+			}
+			if out == "" {
+				return errors.New("--out is required")
+			}
+			format, err := dump.ParseFormat(formatName)
+			if err != nil {
+				return fmt.Errorf("invalid --format: %w", err)
+			}
+			for i, host := range hosts {
+				hosts[i] = ingest.NormalizeHost(host)
+			}
+
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			if _, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			}); err != nil {
+				return err
+			}
+
+			return withReadingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				// openForReading only hands over a database at this theia's
+				// schema version, so that's the version the dump carries.
+				version, err := database.LatestVersion(database.MigrationsFS, database.MigrationsPath)
+				if err != nil {
+					return err
+				}
+				manifest, err := dump.Export(ctx, db, out, dump.ExportOptions{
+					Format:        format,
+					Hosts:         hosts,
+					SchemaVersion: version,
+					CreatedAt:     time.Now(),
+				})
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Exported to %s\n", out)
+				return renderManifest(cmd.OutOrStdout(), &manifest)
+			})
+		},
+	}
+
+	exportCmd.Flags().String("out", "", "directory to write the dump to; created if missing")
+	exportCmd.Flags().String("format", string(dump.FormatNDJSON), "table file format: ndjson or csv")
+	exportCmd.Flags().StringSlice("host", nil, "only dump these hosts (repeatable or comma-separated; default all)")
+	exportCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addMigrateFlag(exportCmd)
+	addConfigFlag(exportCmd)
+
+	return exportCmd
+}
+
+func newImportDumpCmd() *cobra.Command {
+	importCmd := &cobra.Command{
+		Use:   "import-dump <dir>",
+		Short: "Load a dump written by theia export, adding its counts to the database",
+		Long: `import-dump loads a dump written by "theia export" into the database,
+from any theia whose dump layout version this one reads. Counts are added
+onto the rows already there, so dumps from several servers can be merged
+into one database; a visitor who converted on a goal in both counts as
+one converting visitor, and goal and funnel definitions already defined
+are kept as they are. The whole dump is loaded in one transaction: if any
+file doesn't match the manifest, nothing is imported. Importing the same
+dump twice counts its views twice.
+
+The import holds the database's write lock until it finishes, so stop the
+daemon first for a large dump, or its writes may time out meanwhile.
+
+Example:
+  theia import-dump ./theia-dump --db-path /var/lib/theia/theia.db`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			if _, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			}); err != nil {
+				return err
+			}
+
+			return withMigratedDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				manifest, err := dump.Import(ctx, db, args[0])
+				if err != nil {
+					return fmt.Errorf("importing %s: %w", args[0], err)
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Imported %s\n", args[0])
+				return renderManifest(cmd.OutOrStdout(), &manifest)
+			})
+		},
+	}
+
+	importCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addConfigFlag(importCmd)
+
+	return importCmd
+}
+
+func renderManifest(out io.Writer, manifest *dump.Manifest) error {
+	hosts := "none"
+	if len(manifest.Hosts) > 0 {
+		hosts = strings.Join(manifest.Hosts, ", ")
+	}
+	dates := "none"
+	if manifest.From != "" {
+		dates = manifest.From + " to " + manifest.To
+	}
+	_, _ = fmt.Fprintf(out, "Dump version %d, schema version %d, %s\n", manifest.Version, manifest.SchemaVersion, manifest.Format)
+	_, _ = fmt.Fprintf(out, "Hosts: %s\n", hosts)
+	_, _ = fmt.Fprintf(out, "Dates: %s\n\n", dates)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TABLE\tROWS")
+	for _, t := range manifest.Tables {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", t.Name, t.Rows)
+	}
+	return w.Flush()
+}
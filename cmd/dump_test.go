@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/spf13/cobra"
+)
+
+func runDumpCmd(t *testing.T, cmd *cobra.Command, args ...string) (string, error) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestExportThenImportDump(t *testing.T) {
+	src, srcPath := setupCmdTestDB(t)
+	insertStat(t, src, "/", "example.com", time.Now(), statSeed{PageViews: 3})
+	insertStat(t, src, "/", "other.example.org", time.Now(), statSeed{PageViews: 5})
+	database.Close(src) //nolint:errcheck // close before command reopens the same file
+
+	dir := filepath.Join(t.TempDir(), "dump")
+	out, err := runDumpCmd(t, newExportCmd(), "--db-path", srcPath, "--out", dir, "--format", "csv", "--host", "Example.com")
+	if err != nil {
+		t.Fatalf("export: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "Hosts: example.com\n") {
+		t.Errorf("export output doesn't list only example.com\ngot: %s", out)
+	}
+
+	dst, dstPath := setupCmdTestDB(t)
+	database.Close(dst) //nolint:errcheck // close before command reopens the same file
+	out, err = runDumpCmd(t, newImportDumpCmd(), dir, "--db-path", dstPath)
+	if err != nil {
+		t.Fatalf("import-dump: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "hourly_stats          1\n") {
+		t.Errorf("import-dump output doesn't count the hourly_stats row\ngot: %s", out)
+	}
+}
+
+func TestExport_RejectsUnknownFormat(t *testing.T) {
+	_, err := runDumpCmd(t, newExportCmd(), "--out", t.TempDir(), "--format", "xml")
+	if err == nil || !strings.Contains(err.Error(), `unknown dump format "xml"`) {
+		t.Errorf("export error = %v, want an unknown format error", err)
+	}
+}
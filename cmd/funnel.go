@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+//nolint:govet // fieldalignment: JSON output field order follows struct order; reordering would change the rendered output
+type funnelReport struct {
+	Funnel string             `json:"funnel"`
+	Days   int                `json:"days"`
+	Host   string             `json:"host"`
+	Steps  []query.FunnelStep `json:"steps"`
+}
+
+// newFunnelCmd builds the `theia funnel` command: run with a funnel name it
+// renders that funnel's step counts; its subcommands manage definitions.
+func newFunnelCmd() *cobra.Command {
+	funnelCmd := &cobra.Command{
+		Use:   "funnel <name>",
+		Short: "Show or manage multi-step funnels",
+		Long: `A funnel is an ordered list of path patterns, e.g. /pricing > /signup > /welcome.
+The daemon counts how many unique visitors reach each step on the same day,
+having reached every earlier step first, and reports the drop-off between
+steps. Step patterns use the same glob syntax as goals.
+
+Only per-step totals are stored: visitor progress is kept in the daemon's
+memory for the current day and lost on restart, so a visitor partway
+through a funnel then starts over and counts at step 1 a second time if
+they return to it. The daemon reads funnels at startup and on SIGHUP —
+reload it (systemctl reload theia) after adding or removing one; progress
+through funnels the reload leaves unchanged is kept.
+
+Example:
+  theia funnel add checkout /pricing /signup /welcome
+  theia funnel checkout --days 30`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			days, err := cmd.Flags().GetInt("days")
+			if err != nil {
+				return fmt.Errorf("parsing days flag: %w", err)
+			}
+			host, err := cmd.Flags().GetString("host")
+			if err != nil {
+				return fmt.Errorf("parsing host flag: %w", err)
+			}
+			// Hosts are stored lowercased at ingest; normalize the filter
+			// too so --host Example.com matches the example.com bucket.
+			host = ingest.NormalizeHost(host)
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return fmt.Errorf("parsing format flag: %w", err)
+			}
+
+			return withReadingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				return runFunnel(ctx, cmd, db, args[0], days, host, format)
+			})
+		},
+	}
+
+	funnelCmd.PersistentFlags().String("db-path", "./theia.db", "path to the sqlite database")
+	funnelCmd.Flags().Int("days", 7, "number of days to look back")
+	funnelCmd.Flags().String("host", "", "filter by host (empty = all hosts)")
+	funnelCmd.Flags().String("format", "table", "output format: table or json")
+	addMigrateFlag(funnelCmd)
+
+	funnelCmd.AddCommand(newFunnelAddCmd())
+	funnelCmd.AddCommand(newFunnelListCmd())
+	funnelCmd.AddCommand(newFunnelRemoveCmd())
+
+	return funnelCmd
+}
+
+func runFunnel(ctx context.Context, cmd *cobra.Command, db *sql.DB, name string, days int, host, format string) error {
+	if _, err := funnels.Get(ctx, db, name); err != nil {
+		if errors.Is(err, funnels.ErrNotFound) {
+			return &ui.UserError{Err: err, Hint: "theia funnel list"}
+		}
+		return err
+	}
+
+	now := time.Now()
+	steps, err := query.GetFunnelSteps(ctx, db, name, now.AddDate(0, 0, -days), now, host)
+	if err != nil {
+		return err
+	}
+
+	report := funnelReport{Funnel: name, Days: days, Host: host, Steps: steps}
+	if format == "json" {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return renderFunnelTable(cmd, &report)
+}
+
+func renderFunnelTable(cmd *cobra.Command, r *funnelReport) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+	period := fmt.Sprintf("last %d days", r.Days)
+	if r.Host != "" {
+		period += " - " + r.Host
+	}
+	_, _ = fmt.Fprintf(w, "Funnel %s (%s)\n", r.Funnel, period)
+	_, _ = fmt.Fprintln(w, "  STEP\tPATH\tVISITORS\tDROP-OFF\tFROM PREVIOUS\tFROM START")
+	for _, s := range r.Steps {
+		_, _ = fmt.Fprintf(w, "  %d\t%s\t%d\t%d\t%.1f%%\t%.1f%%\n",
+			s.Step, s.PathPattern, s.Visitors, s.DropOff, s.FromPrevious*100, s.FromStart*100)
+	}
+	return w.Flush()
+}
+
+func newFunnelAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "add <name> <step> <step>...",
+		Short:   "Define a new funnel",
+		Example: `  theia funnel add checkout /pricing /signup /welcome`,
+		Args:    cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f := funnels.Funnel{Name: args[0], Steps: args[1:]}
+			if err := funnels.Validate(f); err != nil {
+				return err
+			}
+			// The definition validated fine to reach here, so any error
+			// from this point on is a runtime failure, not a usage mistake.
+			cmd.SilenceUsage = true
+
+			return withMigratedDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				if err := funnels.Add(ctx, db, f); err != nil {
+					if errors.Is(err, funnels.ErrExists) {
+						return &ui.UserError{Err: err, Hint: "theia funnel remove " + f.Name}
+					}
+					return err
+				}
+				cmd.Printf("%s added funnel %s\n", ui.LabelSuccess.Render("✓"), f)
+				cmd.Printf("%s reload the daemon (systemctl reload theia) to start counting it\n", ui.TextMuted.Render("i"))
+				return nil
+			})
+		},
+	}
+}
+
+func newFunnelListCmd() *cobra.Command {
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List defined funnels",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			return withReadingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				defs, err := funnels.List(ctx, db)
+				if err != nil {
+					return err
+				}
+				if len(defs) == 0 {
+					cmd.Println("No funnels defined.")
+					return nil
+				}
+				for _, f := range defs {
+					cmd.Println(f.String())
+				}
+				return nil
+			})
+		},
+	}
+	addMigrateFlag(listCmd)
+
+	return listCmd
+}
+
+func newFunnelRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a funnel and its recorded step counts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			return withMigratedDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				if err := funnels.Remove(ctx, db, args[0]); err != nil {
+					if errors.Is(err, funnels.ErrNotFound) {
+						return &ui.UserError{Err: err, Hint: "theia funnel list"}
+					}
+					return err
+				}
+				cmd.Printf("%s removed funnel %s\n", ui.LabelSuccess.Render("✓"), args[0])
+				return nil
+			})
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func runFunnelCmd(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	cmd := newFunnelCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append(args, "--db-path", dbPath))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestFunnelCmd_AddShowRemove(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	if out, err := runFunnelCmd(t, dbPath, "add", "checkout", "/pricing", "/signup"); err != nil {
+		t.Fatalf("funnel add: %v\noutput: %s", err, out)
+	}
+
+	out, err := runFunnelCmd(t, dbPath, "list")
+	if err != nil {
+		t.Fatalf("funnel list: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "checkout: /pricing > /signup") {
+		t.Errorf("funnel list output missing definition\ngot: %s", out)
+	}
+
+	out, err = runFunnelCmd(t, dbPath, "checkout", "--format", "json")
+	if err != nil {
+		t.Fatalf("funnel show: %v\noutput: %s", err, out)
+	}
+	var report funnelReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\noutput: %s", err, out)
+	}
+	if report.Funnel != "checkout" || len(report.Steps) != 2 {
+		t.Errorf("expected checkout with 2 steps, got %+v", report)
+	}
+
+	if out, err := runFunnelCmd(t, dbPath, "remove", "checkout"); err != nil {
+		t.Fatalf("funnel remove: %v\noutput: %s", err, out)
+	}
+	if _, err := runFunnelCmd(t, dbPath, "checkout"); err == nil {
+		t.Error("expected an error showing a removed funnel, got nil")
+	}
+}
+
+func TestFunnelCmd_AddRejectsSingleStep(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	if _, err := runFunnelCmd(t, dbPath, "add", "checkout", "/pricing"); err == nil {
+		t.Error("expected an error for a one-step funnel, got nil")
+	}
+}
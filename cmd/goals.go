@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// newGoalsCmd builds the `theia goals` command group fresh each call, for the
+// same reason as newSystemCmd: cobra commands can only have one parent.
+func newGoalsCmd() *cobra.Command {
+	goalsCmd := &cobra.Command{
+		Use:   "goals",
+		Short: "Manage conversion goals",
+		Long: `Manage conversion goals: named request patterns the daemon counts as
+completions, e.g. a signup form POST that redirects on success.
+
+A goal is written as:
+
+  name = [METHOD] /path/pattern [with STATUS]
+
+The path pattern is a glob (* matches within one path segment) matched
+against the request path without its query string. METHOD and STATUS are
+optional; when omitted, any method or status matches.
+
+The daemon reads goals at startup and on SIGHUP — reload it (systemctl
+reload theia) after adding or removing one.`,
+	}
+
+	goalsCmd.PersistentFlags().String("db-path", "./theia.db", "path to the sqlite database")
+
+	goalsCmd.AddCommand(newGoalsAddCmd())
+	goalsCmd.AddCommand(newGoalsListCmd())
+	goalsCmd.AddCommand(newGoalsRemoveCmd())
+
+	return goalsCmd
+}
+
+func newGoalsAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <definition>",
+		Short: "Define a new goal",
+		Example: `  theia goals add "signup = POST /register with 302"
+  theia goals add "docs = /docs/*"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goal, err := goals.Parse(args[0])
+			if err != nil {
+				return err
+			}
+			// The definition parsed fine to reach here, so any error from
+			// this point on is a runtime failure, not a usage mistake.
+			cmd.SilenceUsage = true
+
+			return withMigratedDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				if err := goals.Add(ctx, db, goal); err != nil {
+					if errors.Is(err, goals.ErrExists) {
+						return &ui.UserError{Err: err, Hint: "theia goals remove " + goal.Name}
+					}
+					return err
+				}
+				cmd.Printf("%s added goal %s\n", ui.LabelSuccess.Render("✓"), goal)
+				cmd.Printf("%s reload the daemon (systemctl reload theia) to start counting it\n", ui.TextMuted.Render("i"))
+				return nil
+			})
+		},
+	}
+}
+
+func newGoalsListCmd() *cobra.Command {
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List defined goals",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			return withReadingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				defs, err := goals.List(ctx, db)
+				if err != nil {
+					return err
+				}
+				return renderGoalsList(cmd, defs)
+			})
+		},
+	}
+	addMigrateFlag(listCmd)
+
+	return listCmd
+}
+
+func newGoalsRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a goal and its recorded completions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			return withMigratedDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				if err := goals.Remove(ctx, db, args[0]); err != nil {
+					if errors.Is(err, goals.ErrNotFound) {
+						return &ui.UserError{Err: err, Hint: "theia goals list"}
+					}
+					return err
+				}
+				cmd.Printf("%s removed goal %s\n", ui.LabelSuccess.Render("✓"), args[0])
+				return nil
+			})
+		},
+	}
+}
+
+func renderGoalsList(cmd *cobra.Command, defs []goals.Goal) error {
+	if len(defs) == 0 {
+		cmd.Println("No goals defined.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tMETHOD\tPATH\tSTATUS")
+	for _, g := range defs {
+		method := g.Method
+		if method == "" {
+			method = "any"
+		}
+		status := "any"
+		if g.StatusCode != 0 {
+			status = fmt.Sprint(g.StatusCode)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", g.Name, method, g.PathPattern, status)
+	}
+	return w.Flush()
+}
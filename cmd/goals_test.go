@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func runGoalsCmd(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	cmd := newGoalsCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append(args, "--db-path", dbPath))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestGoalsCmd_AddListRemove(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	if out, err := runGoalsCmd(t, dbPath, "add", "signup = POST /register with 302"); err != nil {
+		t.Fatalf("goals add: %v\noutput: %s", err, out)
+	}
+
+	out, err := runGoalsCmd(t, dbPath, "list")
+	if err != nil {
+		t.Fatalf("goals list: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{"signup", "POST", "/register", "302"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("goals list output missing %q\ngot: %s", want, out)
+		}
+	}
+
+	if out, err := runGoalsCmd(t, dbPath, "remove", "signup"); err != nil {
+		t.Fatalf("goals remove: %v\noutput: %s", err, out)
+	}
+
+	out, err = runGoalsCmd(t, dbPath, "list")
+	if err != nil {
+		t.Fatalf("goals list after remove: %v", err)
+	}
+	if !strings.Contains(out, "No goals defined.") {
+		t.Errorf("expected empty goal list after remove, got: %s", out)
+	}
+}
+
+func TestGoalsCmd_RejectsInvalidDefinition(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	if _, err := runGoalsCmd(t, dbPath, "add", "signup /register"); err == nil {
+		t.Error("expected an error for a definition without '=', got nil")
+	}
+}
+
+func TestGoalsCmd_RemoveMissing(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	if _, err := runGoalsCmd(t, dbPath, "remove", "nope"); err == nil {
+		t.Error("expected an error removing an undefined goal, got nil")
+	}
+}
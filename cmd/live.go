@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/Elysium-Labs-EU/theia/internal/live"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// clearScreen moves the cursor home and clears the terminal, so each refresh
+// of `theia live` redraws in place instead of scrolling.
+const clearScreen = "\033[H\033[2J"
+
+func newLiveCmd() *cobra.Command {
+	liveCmd := &cobra.Command{
+		Use:   "live",
+		Short: "Show visitors on the site right now, refreshing in place",
+		Long: `live shows who is on the site right now: active visitors and page views
+over the last 5 and 30 minutes, plus the pages they're on and where they
+came from. It reads the running daemon's in-memory window (see the
+daemon's --live-addr), not the hourly rollups in sqlite, so a spike shows
+up within seconds.
+
+Example:
+  theia live
+  theia live --host example.com --interval 5s
+  theia live --once --format json`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			addr, err := cmd.Flags().GetString("addr")
+			if err != nil {
+				return fmt.Errorf("parsing addr flag: %w", err)
+			}
+			host, err := cmd.Flags().GetString("host")
+			if err != nil {
+				return fmt.Errorf("parsing host flag: %w", err)
+			}
+			host = ingest.NormalizeHost(host)
+			top, err := cmd.Flags().GetInt("top")
+			if err != nil {
+				return fmt.Errorf("parsing top flag: %w", err)
+			}
+			if top <= 0 {
+				return fmt.Errorf("invalid --top %d: must be a positive integer", top)
+			}
+			interval, err := cmd.Flags().GetDuration("interval")
+			if err != nil {
+				return fmt.Errorf("parsing interval flag: %w", err)
+			}
+			if interval < time.Second {
+				return fmt.Errorf("invalid --interval %s: must be at least 1s", interval)
+			}
+			once, err := cmd.Flags().GetBool("once")
+			if err != nil {
+				return fmt.Errorf("parsing once flag: %w", err)
+			}
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return fmt.Errorf("parsing format flag: %w", err)
+			}
+
+			if err := apiserver.ValidateLoopbackAddr(addr); err != nil {
+				return err
+			}
+
+			return runLive(cmd, liveURL(addr, host, top), host, interval, once || format == "json", format)
+		},
+	}
+
+	liveCmd.Flags().String("addr", "127.0.0.1:8083", "address of the daemon's live view (its --live-addr)")
+	liveCmd.Flags().String("host", "", "filter by host (empty = all hosts)")
+	liveCmd.Flags().Int("top", 10, "number of pages/referrers to show")
+	liveCmd.Flags().Duration("interval", 2*time.Second, "how often to refresh")
+	liveCmd.Flags().Bool("once", false, "print one snapshot and exit instead of refreshing")
+	liveCmd.Flags().String("format", "table", "output format: table or json (json implies --once)")
+
+	return liveCmd
+}
+
+func liveURL(addr, host string, top int) string {
+	params := url.Values{}
+	if host != "" {
+		params.Set("host", host)
+	}
+	params.Set("top", strconv.Itoa(top))
+	return "http://" + addr + "/api/v1/live?" + params.Encode()
+}
+
+func runLive(cmd *cobra.Command, endpoint, host string, interval time.Duration, once bool, format string) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	snap, err := fetchLiveSnapshot(cmd.Context(), client, endpoint)
+	if err != nil {
+		return &ui.UserError{Err: err, Hint: "theia daemon --live-addr 127.0.0.1:8083"}
+	}
+	if once {
+		if format == "json" {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(snap)
+		}
+		return renderLiveTable(cmd.OutOrStdout(), snap, host)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cmd.Print(clearScreen)
+		if err := renderLiveTable(cmd.OutOrStdout(), snap, host); err != nil {
+			return err
+		}
+
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// A daemon restart shouldn't end the session: keep showing the last
+		// snapshot with a note until the endpoint answers again.
+		next, err := fetchLiveSnapshot(cmd.Context(), client, endpoint)
+		if err != nil {
+			if cmd.Context().Err() != nil {
+				return nil
+			}
+			cmd.Printf("%s %v\n", ui.LabelWarning.Render("!"), err)
+			continue
+		}
+		snap = next
+	}
+}
+
+func fetchLiveSnapshot(ctx context.Context, client *http.Client, endpoint string) (live.Snapshot, error) {
+	var snap live.Snapshot
+	if err := fetchLiveJSON(ctx, client, endpoint, &snap); err != nil {
+		return live.Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// fetchLiveJSON GETs endpoint from the daemon's live server and decodes the
+// JSON response into v.
+func fetchLiveJSON(ctx context.Context, client *http.Client, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("building live view request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("reaching the daemon's live view: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // close error in defer is not actionable
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("live view returned %s: %s", resp.Status, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding live view: %w", err)
+	}
+	return nil
+}
+
+func renderLiveTable(out io.Writer, snap live.Snapshot, host string) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	title := "Live"
+	if host != "" {
+		title += " - " + host
+	}
+	_, _ = fmt.Fprintf(w, "%s (%s)\n", title, snap.GeneratedAt.Local().Format("15:04:05"))
+	_, _ = fmt.Fprintln(w, "  \tVISITORS\tPAGEVIEWS")
+	_, _ = fmt.Fprintf(w, "  Last 5 minutes\t%d\t%d\n", snap.Visitors5m, snap.Pageviews5m)
+	_, _ = fmt.Fprintf(w, "  Last 30 minutes\t%d\t%d\n", snap.Visitors30m, snap.Pageviews30m)
+
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Current Pages (last 5 minutes)")
+	renderLiveCounts(w, "PATH", snap.TopPaths)
+
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Current Referrers (last 5 minutes)")
+	renderLiveCounts(w, "REFERRER", snap.TopReferrers)
+
+	return w.Flush()
+}
+
+func renderLiveCounts(w io.Writer, label string, counts []live.Count) {
+	if len(counts) == 0 {
+		_, _ = fmt.Fprintln(w, noDataLabel)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "  %s\tVISITORS\tPAGEVIEWS\n", label)
+	for _, c := range counts {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\t%d\n", sanitizeTerminalField(c.Value), c.Visitors, c.Pageviews)
+	}
+}
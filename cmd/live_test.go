@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/live"
+)
+
+func runLiveCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newLiveCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func newLiveTestServer(t *testing.T) string {
+	t.Helper()
+	window := live.NewWindow()
+	window.Record(live.Hit{Time: time.Now(), Host: "example.com", Path: "/viral-post", Referrer: "https://news.ycombinator.com/", Visitor: "a"})
+	window.Record(live.Hit{Time: time.Now(), Host: "example.com", Path: "/viral-post", Visitor: "b"})
+
+	srv := httptest.NewServer(live.Handler(window))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestLiveCmd_OnceTable(t *testing.T) {
+	addr := newLiveTestServer(t)
+
+	out, err := runLiveCmd(t, "--addr", addr, "--once")
+	if err != nil {
+		t.Fatalf("live --once: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{"Last 5 minutes", "/viral-post", "https://news.ycombinator.com/"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("live output missing %q\ngot: %s", want, out)
+		}
+	}
+	if strings.Contains(out, clearScreen) {
+		t.Error("--once must not clear the screen")
+	}
+}
+
+func TestLiveCmd_JSON(t *testing.T) {
+	addr := newLiveTestServer(t)
+
+	out, err := runLiveCmd(t, "--addr", addr, "--format", "json")
+	if err != nil {
+		t.Fatalf("live --format json: %v\noutput: %s", err, out)
+	}
+	var snap live.Snapshot
+	if err := json.Unmarshal([]byte(out), &snap); err != nil {
+		t.Fatalf("unmarshal: %v\noutput: %s", err, out)
+	}
+	if snap.Visitors5m != 2 || snap.Pageviews5m != 2 {
+		t.Errorf("expected 2 visitors and 2 pageviews, got %+v", snap)
+	}
+}
+
+func TestLiveCmd_DaemonNotReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	if _, err := runLiveCmd(t, "--addr", addr, "--once"); err == nil {
+		t.Error("expected an error when nothing listens on --addr, got nil")
+	}
+}
+
+func TestLiveCmd_RejectsNonLoopbackAddr(t *testing.T) {
+	if _, err := runLiveCmd(t, "--addr", "0.0.0.0:8083", "--once"); err == nil {
+		t.Error("expected an error for a non-loopback --addr, got nil")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/Elysium-Labs-EU/theia/database"
 	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
 	"github.com/Elysium-Labs-EU/theia/internal/promsink"
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
 	"github.com/spf13/cobra"
 )
 
@@ -21,7 +22,11 @@ Point a Prometheus scrape_config at this endpoint instead of writing a
 custom scraper against the JSON/CSV stats API.
 
 It binds to 127.0.0.1 only — put it behind a reverse proxy (e.g. nginx)
-to expose it beyond localhost.
+to expose it beyond localhost. Like "theia serve", it supports systemd
+readiness, watchdog and socket activation.
+
+Like "theia serve", it opens the database read-only unless --migrate is
+passed.
 
 Example:
   theia serve-metrics --db-path /var/lib/theia/theia.db --addr 127.0.0.1:8082`,
@@ -32,6 +37,10 @@ Example:
 			// flags/usage block for it.
 			cmd.SilenceUsage = true
 
+			if _, err := applyConfigFile(cmd, metricsConfigBindings()); err != nil {
+				return err
+			}
+
 			dbPath, err := cmd.Flags().GetString("db-path")
 			if err != nil {
 				return fmt.Errorf("parsing db-path flag: %w", err)
@@ -59,21 +68,30 @@ Example:
 	serveMetricsCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
 	serveMetricsCmd.Flags().String("addr", "127.0.0.1:8082", "address to bind the metrics endpoint to (must be 127.0.0.1 or localhost)")
 	serveMetricsCmd.Flags().Int("top", 20, "max number of distinct paths/referrers exported (bounds Prometheus label cardinality)")
+	addMigrateFlag(serveMetricsCmd)
+	addConfigFlag(serveMetricsCmd)
 
 	return serveMetricsCmd
 }
 
 func runServeMetrics(cmd *cobra.Command, dbPath, addr string, top int) error {
-	db, err := database.Open(cmd.Context(), dbPath)
+	db, err := openForReading(cmd, dbPath)
 	if err != nil {
 		return err
 	}
 	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
 
-	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
-		return fmt.Errorf("running migrations: %w", err)
+	notifier, err := systemd.NotifierFromEnv()
+	if err != nil {
+		return err
+	}
+	listener, err := serviceListener(addr)
+	if err != nil {
+		return err
 	}
 
-	cmd.Printf("Metrics endpoint listening on %s\n", addr)
-	return promsink.Run(cmd.Context(), db, promsink.Config{Addr: addr, Top: top})
+	cmd.Printf("Metrics endpoint listening on %s\n", listener.Addr())
+	stop := superviseService(cmd.Context(), notifier, "Serving metrics on "+listener.Addr().String(), db.PingContext)
+	defer stop()
+	return promsink.Run(cmd.Context(), db, promsink.Config{Addr: addr, Top: top, Listener: listener})
 }
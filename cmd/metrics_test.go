@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
 )
 
 func doGet(t *testing.T, url string) (int, []byte) {
@@ -34,7 +36,10 @@ func doGet(t *testing.T, url string) (int, []byte) {
 }
 
 func TestServeMetricsCmd_StopsOnContextCancellation(t *testing.T) {
-	dbPath := filepath.Join(t.TempDir(), "theia.db")
+	// serve-metrics opens the database read-only, so it must exist at this theia's
+	// schema version already.
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
 	addr := "127.0.0.1:18237"
 
 	serveMetricsCmd := newServeMetricsCmd()
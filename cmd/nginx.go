@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/Elysium-Labs-EU/theia/internal/nginxconf"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// newNginxCmd builds the `theia nginx` command group fresh each call, for
+// the same reason as newSystemCmd: cobra commands can only have one parent.
+func newNginxCmd() *cobra.Command {
+	nginxCmd := &cobra.Command{
+		Use:   "nginx",
+		Short: "Read the nginx configuration theia ingests from",
+		Long: `Read nginx's configuration to find the access logs it writes and the
+log_format each one uses, without modifying it.`,
+	}
+
+	nginxCmd.AddCommand(newNginxInspectCmd())
+
+	return nginxCmd
+}
+
+func newNginxInspectCmd() *cobra.Command {
+	inspectCmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "List nginx's access logs and whether theia can read them",
+		Long: `inspect parses nginx.conf and every file it includes, and lists each
+access_log with its log_format definition, the server block it belongs to,
+and whether theia's parser can read it — and if not, why.
+
+It ends with the log "theia daemon --discover" would pick.
+
+Instead of reading the files directly, inspect can read the output of
+"nginx -T", which is what the running nginx actually loaded:
+
+Example:
+  theia nginx inspect
+  sudo nginx -T 2>/dev/null | theia nginx inspect --dump -`,
+		Args: cobra.NoArgs,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			confPath, err := cmd.Flags().GetString("nginx-conf")
+			if err != nil {
+				return fmt.Errorf("parsing nginx-conf flag: %w", err)
+			}
+			dumpPath, err := cmd.Flags().GetString("dump")
+			if err != nil {
+				return fmt.Errorf("parsing dump flag: %w", err)
+			}
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return fmt.Errorf("parsing format flag: %w", err)
+			}
+			if format != "table" && format != "json" {
+				return fmt.Errorf("invalid --format %q: must be table or json", format)
+			}
+
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			conf, err := readNginxConfig(confPath, dumpPath, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			report := buildNginxReport(conf)
+
+			if format == "json" {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			return renderNginxReport(cmd.OutOrStdout(), report)
+		},
+	}
+
+	inspectCmd.Flags().String("nginx-conf", nginxconf.DefaultPath, "path to nginx's main config file")
+	inspectCmd.Flags().String("dump", "", `read "nginx -T" output from this file (- for stdin) instead of --nginx-conf`)
+	inspectCmd.Flags().String("format", "table", "output format: table or json")
+	inspectCmd.MarkFlagsMutuallyExclusive("nginx-conf", "dump")
+
+	return inspectCmd
+}
+
+// inspectedAccessLog is an access_log together with how the daemon would
+// read it: Layout is the parser format its lines match, or Problem says why
+// the daemon can't use it.
+type inspectedAccessLog struct {
+	Log     nginxconf.AccessLog `json:"access_log"`
+	Layout  string              `json:"layout,omitempty"`
+	Problem string              `json:"problem,omitempty"`
+}
+
+// discoveredLog is the access log `theia daemon --discover` reads.
+type discoveredLog struct {
+	Path   string `json:"path"`
+	Layout string `json:"layout"`
+	// DefaultHost is the server_name lines without a host field belong to,
+	// when the log is a standard-format log written by one server block.
+	DefaultHost string `json:"default_host,omitempty"`
+}
+
+type nginxReport struct {
+	Source     string               `json:"source"`
+	AccessLogs []inspectedAccessLog `json:"access_logs"`
+	Formats    map[string]string    `json:"formats"`
+	// Discovered is what --discover would pick; nil when it would fail, in
+	// which case DiscoverError says why.
+	Discovered    *discoveredLog `json:"discovered,omitempty"`
+	DiscoverError string         `json:"discover_error,omitempty"`
+}
+
+// readNginxConfig loads nginx's config from confPath, or from the nginx -T
+// output at dumpPath ("-" for stdin) when one is given.
+func readNginxConfig(confPath, dumpPath string, stdin io.Reader) (nginxconf.Config, error) {
+	if dumpPath == "" {
+		return nginxconf.Load(confPath)
+	}
+	input := stdin
+	if dumpPath != "-" {
+		f, err := os.Open(dumpPath) //nolint:gosec // path is an operator-supplied flag, not request input
+		if err != nil {
+			return nginxconf.Config{}, fmt.Errorf("opening nginx -T output: %w", err)
+		}
+		defer f.Close() //nolint:errcheck // read-only file, close error is not actionable
+		input = f
+	}
+	return nginxconf.ParseDump(input)
+}
+
+func buildNginxReport(conf nginxconf.Config) nginxReport {
+	found := nginxconf.Inspect(conf)
+	report := nginxReport{
+		Source:     conf.Main,
+		AccessLogs: inspectAccessLogs(found.AccessLogs),
+		Formats:    found.Formats,
+	}
+	discovered, err := chooseDiscoveredLog(report.AccessLogs)
+	if err != nil {
+		report.DiscoverError = err.Error()
+	} else {
+		report.Discovered = &discovered
+	}
+	return report
+}
+
+func inspectAccessLogs(logs []nginxconf.AccessLog) []inspectedAccessLog {
+	inspected := make([]inspectedAccessLog, 0, len(logs))
+	for _, l := range logs {
+		layout, problem := accessLogLayout(l)
+		inspected = append(inspected, inspectedAccessLog{Log: l, Layout: layout, Problem: problem})
+	}
+	return inspected
+}
+
+// accessLogLayout returns the parser format l's lines match, or why the
+// daemon can't tail l at all.
+func accessLogLayout(l nginxconf.AccessLog) (layout, problem string) {
+	switch {
+	case strings.HasPrefix(l.Path, "syslog:"):
+		return "", "sent to syslog, not written to a file"
+	case strings.Contains(l.Path, "$"):
+		return "", "path contains variables, so nginx picks the file per request"
+	case !filepath.IsAbs(l.Path):
+		return "", "relative path, resolved against nginx's build prefix"
+	case l.Gzip:
+		return "", "written gzip-compressed"
+	case l.Format == "":
+		return "", fmt.Sprintf("log_format %q is not defined", l.FormatName)
+	}
+	layout, err := ingest.LogFormatLayout(l.Format)
+	if err != nil {
+		return "", fmt.Sprintf("log_format %q: %v", l.FormatName, err)
+	}
+	return layout, ""
+}
+
+// chooseDiscoveredLog picks the access log the daemon should read. The
+// daemon reads one log, so discovery only succeeds when that choice is
+// unambiguous: the one log written with "$host" appended (which carries
+// every vhost), or else the one readable log without it.
+func chooseDiscoveredLog(logs []inspectedAccessLog) (discoveredLog, error) {
+	byPath := map[string][]inspectedAccessLog{}
+	var paths []string
+	for _, l := range logs {
+		if l.Problem != "" {
+			continue
+		}
+		if _, seen := byPath[l.Log.Path]; !seen {
+			paths = append(paths, l.Log.Path)
+		}
+		byPath[l.Log.Path] = append(byPath[l.Log.Path], l)
+	}
+
+	var withHost, standard []string
+	for _, p := range paths {
+		switch sharedLayout(byPath[p]) {
+		case ingest.FormatWithHost:
+			withHost = append(withHost, p)
+		case ingest.FormatStandard:
+			standard = append(standard, p)
+		}
+	}
+
+	switch {
+	case len(withHost) == 1:
+		return discoveredLog{Path: withHost[0], Layout: ingest.FormatWithHost}, nil
+	case len(withHost) > 1:
+		return discoveredLog{}, fmt.Errorf("found %d access logs with a host field (%s) but the daemon reads one; pick it with --log-path",
+			len(withHost), strings.Join(withHost, ", "))
+	case len(standard) == 1:
+		return discoveredLog{
+			Path:        standard[0],
+			Layout:      ingest.FormatStandard,
+			DefaultHost: soleServerName(byPath[standard[0]]),
+		}, nil
+	case len(standard) > 1:
+		return discoveredLog{}, fmt.Errorf("found %d access logs (%s) but the daemon reads one; pick it with --log-path",
+			len(standard), strings.Join(standard, ", "))
+	default:
+		return discoveredLog{}, fmt.Errorf("no access_log theia can read among %d found", len(logs))
+	}
+}
+
+// sharedLayout is the layout every directive writing one file agrees on,
+// or "" when they mix formats, which the daemon can't attribute reliably.
+func sharedLayout(logs []inspectedAccessLog) string {
+	layout := logs[0].Layout
+	for _, l := range logs[1:] {
+		if l.Layout != layout {
+			return ""
+		}
+	}
+	return layout
+}
+
+// soleServerName returns the server_name every line in logs belongs to, or
+// "" when the file is shared between servers, written at http level, or
+// named only by a wildcard, regex or the "_" catch-all.
+func soleServerName(logs []inspectedAccessLog) string {
+	var name string
+	for _, l := range logs {
+		names := l.Log.ServerNames
+		if len(names) == 0 {
+			return ""
+		}
+		if name != "" && !slices.Contains(names, name) {
+			return ""
+		}
+		if name == "" {
+			name = names[0]
+		}
+	}
+	if name == "_" || strings.ContainsAny(name, "*~") {
+		return ""
+	}
+	return name
+}
+
+// discoverLogInput reads nginx's config at confPath and picks the log the
+// daemon should tail.
+func discoverLogInput(confPath string) (discoveredLog, error) {
+	conf, err := nginxconf.Load(confPath)
+	if err != nil {
+		return discoveredLog{}, &ui.UserError{
+			Err:  fmt.Errorf("discovering access log: %w", err),
+			Hint: "theia daemon --log-path <file>",
+		}
+	}
+	found, err := chooseDiscoveredLog(inspectAccessLogs(nginxconf.Inspect(conf).AccessLogs))
+	if err != nil {
+		return discoveredLog{}, &ui.UserError{
+			Err:  fmt.Errorf("discovering access log in %s: %w", confPath, err),
+			Hint: "theia nginx inspect --nginx-conf " + confPath,
+		}
+	}
+	return found, nil
+}
+
+func renderNginxReport(out io.Writer, report nginxReport) error {
+	_, _ = fmt.Fprintf(out, "Access logs in %s:\n\n", sanitizeTerminalField(report.Source))
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ACCESS LOG\tFORMAT\tPARSER\tSERVER\tDEFINED AT\tNOTE")
+	for _, l := range report.AccessLogs {
+		server := "(http)"
+		if len(l.Log.ServerNames) > 0 {
+			server = strings.Join(l.Log.ServerNames, " ")
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s:%d\t%s\n",
+			sanitizeTerminalField(l.Log.Path), sanitizeTerminalField(l.Log.FormatName), dashIfEmpty(l.Layout),
+			sanitizeTerminalField(server), sanitizeTerminalField(l.Log.File), l.Log.Line, dashIfEmpty(l.Problem))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var used []string
+	for _, l := range report.AccessLogs {
+		if !slices.Contains(used, l.Log.FormatName) && l.Log.Format != "" {
+			used = append(used, l.Log.FormatName)
+		}
+	}
+	if len(used) > 0 {
+		_, _ = fmt.Fprintln(out, "\nLog formats:")
+		for _, name := range used {
+			_, _ = fmt.Fprintf(out, "  %s: %s\n", sanitizeTerminalField(name), sanitizeTerminalField(report.Formats[name]))
+		}
+	}
+
+	_, _ = fmt.Fprintln(out)
+	if report.Discovered == nil {
+		_, _ = fmt.Fprintf(out, "theia daemon --discover would fail: %s\n", sanitizeTerminalField(report.DiscoverError))
+		return nil
+	}
+	_, _ = fmt.Fprintf(out, "theia daemon --discover would read %s (%s)", sanitizeTerminalField(report.Discovered.Path), report.Discovered.Layout)
+	if report.Discovered.DefaultHost != "" {
+		_, _ = fmt.Fprintf(out, " with default host %s", sanitizeTerminalField(report.Discovered.DefaultHost))
+	}
+	_, _ = fmt.Fprintln(out, ".")
+	return nil
+}
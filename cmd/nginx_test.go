@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/Elysium-Labs-EU/theia/internal/nginxconf"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+)
+
+const nginxTestDump = `# configuration file /etc/nginx/nginx.conf:
+http {
+    log_format theia_combined '$remote_addr - $remote_user [$time_local] '
+                              '"$request" $status $body_bytes_sent '
+                              '"$http_referer" "$http_user_agent" "$host"';
+    access_log /var/log/nginx/access.log theia_combined;
+    include /etc/nginx/sites-enabled/*;
+}
+
+# configuration file /etc/nginx/sites-enabled/blog:
+server {
+    server_name blog.example.com;
+    access_log /var/log/nginx/blog.log;
+    access_log syslog:server=unix:/dev/log;
+}
+`
+
+func runNginxInspectCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := newNginxCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"inspect"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestNginxInspect_Table(t *testing.T) {
+	out, err := runNginxInspectCmd(t, nginxTestDump, "--dump", "-")
+	if err != nil {
+		t.Fatalf("nginx inspect: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{
+		"Access logs in /etc/nginx/nginx.conf",
+		"/var/log/nginx/access.log",
+		"theia_combined",
+		"with_host",
+		"/etc/nginx/sites-enabled/blog:3",
+		"blog.example.com",
+		"standard",
+		"sent to syslog",
+		`"$http_user_agent" "$host"`,
+		"theia daemon --discover would read /var/log/nginx/access.log (with_host).",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestNginxInspect_JSONFromConfFile(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "nginx.conf")
+	if err := os.WriteFile(conf, []byte("http { server { server_name shop.example.com; access_log /var/log/nginx/shop.log; } }\n"), 0o600); err != nil {
+		t.Fatalf("write conf: %v", err)
+	}
+
+	out, err := runNginxInspectCmd(t, "", "--nginx-conf", conf, "--format", "json")
+	if err != nil {
+		t.Fatalf("nginx inspect: %v\noutput: %s", err, out)
+	}
+	var report nginxReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out)
+	}
+	if len(report.AccessLogs) != 1 || report.AccessLogs[0].Layout != ingest.FormatStandard {
+		t.Fatalf("access logs = %+v", report.AccessLogs)
+	}
+	want := discoveredLog{Path: "/var/log/nginx/shop.log", Layout: ingest.FormatStandard, DefaultHost: "shop.example.com"}
+	if report.Discovered == nil || *report.Discovered != want {
+		t.Errorf("discovered = %+v, want %+v", report.Discovered, want)
+	}
+}
+
+func TestNginxInspect_MissingConf(t *testing.T) {
+	if _, err := runNginxInspectCmd(t, "", "--nginx-conf", filepath.Join(t.TempDir(), "nginx.conf")); err == nil {
+		t.Error("want an error for a missing nginx.conf")
+	}
+}
+
+func inspected(path, layout string, serverNames ...string) inspectedAccessLog {
+	return inspectedAccessLog{
+		Log:    nginxconf.AccessLog{Path: path, ServerNames: serverNames},
+		Layout: layout,
+	}
+}
+
+func TestChooseDiscoveredLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		logs    []inspectedAccessLog
+		want    discoveredLog
+		wantErr string
+	}{
+		{
+			name: "host log wins over per-site logs",
+			logs: []inspectedAccessLog{
+				inspected("/var/log/nginx/access.log", ingest.FormatWithHost),
+				inspected("/var/log/nginx/blog.log", ingest.FormatStandard, "blog.example.com"),
+			},
+			want: discoveredLog{Path: "/var/log/nginx/access.log", Layout: ingest.FormatWithHost},
+		},
+		{
+			name: "single site log gets its server name",
+			logs: []inspectedAccessLog{
+				inspected("/var/log/nginx/blog.log", ingest.FormatStandard, "blog.example.com", "www.blog.example.com"),
+				inspected("/var/log/nginx/blog.log", ingest.FormatStandard, "blog.example.com"),
+			},
+			want: discoveredLog{Path: "/var/log/nginx/blog.log", Layout: ingest.FormatStandard, DefaultHost: "blog.example.com"},
+		},
+		{
+			name: "shared standard log has no default host",
+			logs: []inspectedAccessLog{
+				inspected("/var/log/nginx/access.log", ingest.FormatStandard, "a.example.com"),
+				inspected("/var/log/nginx/access.log", ingest.FormatStandard, "b.example.com"),
+			},
+			want: discoveredLog{Path: "/var/log/nginx/access.log", Layout: ingest.FormatStandard},
+		},
+		{
+			name: "catch-all server name is not a host",
+			logs: []inspectedAccessLog{inspected("/var/log/nginx/access.log", ingest.FormatStandard, "_")},
+			want: discoveredLog{Path: "/var/log/nginx/access.log", Layout: ingest.FormatStandard},
+		},
+		{
+			name: "two host logs are ambiguous",
+			logs: []inspectedAccessLog{
+				inspected("/var/log/nginx/a.log", ingest.FormatWithHost),
+				inspected("/var/log/nginx/b.log", ingest.FormatWithHost),
+			},
+			wantErr: "found 2 access logs with a host field",
+		},
+		{
+			name: "mixed formats in one file are skipped",
+			logs: []inspectedAccessLog{
+				inspected("/var/log/nginx/access.log", ingest.FormatWithHost),
+				inspected("/var/log/nginx/access.log", ingest.FormatStandard),
+			},
+			wantErr: "no access_log theia can read",
+		},
+		{
+			name:    "unreadable logs are skipped",
+			logs:    []inspectedAccessLog{{Log: nginxconf.AccessLog{Path: "syslog:server=x"}, Problem: "sent to syslog"}},
+			wantErr: "no access_log theia can read among 1 found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chooseDiscoveredLog(tt.logs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("chooseDiscoveredLog: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogLayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		log         nginxconf.AccessLog
+		wantLayout  string
+		wantProblem string
+	}{
+		{"combined", nginxconf.AccessLog{Path: "/var/log/nginx/access.log", FormatName: "combined", Format: nginxconf.CombinedFormat}, ingest.FormatStandard, ""},
+		{"variable path", nginxconf.AccessLog{Path: "/var/log/nginx/$host.log", FormatName: "combined", Format: nginxconf.CombinedFormat}, "", "path contains variables"},
+		{"relative path", nginxconf.AccessLog{Path: "logs/access.log", FormatName: "combined", Format: nginxconf.CombinedFormat}, "", "relative path"},
+		{"gzip", nginxconf.AccessLog{Path: "/var/log/nginx/access.log.gz", FormatName: "combined", Format: nginxconf.CombinedFormat, Gzip: true}, "", "gzip"},
+		{"undefined format", nginxconf.AccessLog{Path: "/var/log/nginx/access.log", FormatName: "main"}, "", `log_format "main" is not defined`},
+		{"json format", nginxconf.AccessLog{Path: "/var/log/nginx/access.log", FormatName: "json", Format: `{"ip":"$remote_addr"}`}, "", `log_format "json"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, problem := accessLogLayout(tt.log)
+			if layout != tt.wantLayout {
+				t.Errorf("layout = %q, want %q", layout, tt.wantLayout)
+			}
+			if (tt.wantProblem == "") != (problem == "") || !strings.Contains(problem, tt.wantProblem) {
+				t.Errorf("problem = %q, want it to mention %q", problem, tt.wantProblem)
+			}
+		})
+	}
+}
+
+func TestDaemon_DiscoverAndLogPathAreExclusive(t *testing.T) {
+	cmd := newDaemonCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--discover", "--log-path", "/var/log/nginx/access.log"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("want an error for --discover with --log-path")
+	}
+}
+
+func TestDiscoverLogInput_Failure(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "nginx.conf")
+	if err := os.WriteFile(conf, []byte("http { access_log off; }\n"), 0o600); err != nil {
+		t.Fatalf("write conf: %v", err)
+	}
+
+	_, err := discoverLogInput(conf)
+	var userErr *ui.UserError
+	if !errors.As(err, &userErr) || !strings.Contains(userErr.Hint, "theia nginx inspect") {
+		t.Errorf("err = %v, want a UserError pointing at nginx inspect", err)
+	}
+}
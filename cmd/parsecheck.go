@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// maxParseCheckFailures bounds how many rejected lines the table summary
+// lists; --per-line shows them all.
+const maxParseCheckFailures = 5
+
+// Per-line outcomes in `theia parse-check` output.
+const (
+	parseResultCounted  = "counted"
+	parseResultDropped  = "dropped"
+	parseResultRejected = "rejected"
+)
+
+func newParseCheckCmd() *cobra.Command {
+	parseCheckCmd := &cobra.Command{
+		Use:   "parse-check <file|->",
+		Short: "Check how the daemon would read an access log",
+		Long: `parse-check reads lines from an access log (or stdin, with "-") and reports
+how the daemon would handle each one: which format matched (combined with
+"$host" appended, or plain combined), the normalized host, bot, static and
+scanner classification, whether a [rules] exclusion drops it, and why a line
+was rejected. Nothing is written to the database.
+
+Run it before pointing the daemon at a new vhost, to confirm the log format
+is understood instead of finding out days later that nothing was counted.
+The default host, [rules] and [pipeline] come from the config file, as for
+the daemon.
+
+Example:
+  theia parse-check /var/log/nginx/access.log
+  tail -n 1000 /var/log/nginx/example.com.log | theia parse-check - --per-line`,
+		Args: cobra.ExactArgs(1),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lines, err := cmd.Flags().GetInt("lines")
+			if err != nil {
+				return fmt.Errorf("parsing lines flag: %w", err)
+			}
+			if lines < 0 {
+				return fmt.Errorf("invalid --lines %d: must be 0 (all) or a positive integer", lines)
+			}
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return fmt.Errorf("parsing format flag: %w", err)
+			}
+			if format != "table" && format != "json" {
+				return fmt.Errorf("invalid --format %q: must be table or json", format)
+			}
+			perLine, err := cmd.Flags().GetBool("per-line")
+			if err != nil {
+				return fmt.Errorf("parsing per-line flag: %w", err)
+			}
+
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			cfg, err := loadConfigFile(cmd)
+			if err != nil {
+				return err
+			}
+			checkCfg := ingest.CheckConfig{
+				DefaultHost: cfg.DefaultHost,
+				Rules:       ingestRules(cfg.Rules),
+				StageOrder:  cfg.Pipeline.Stages,
+			}
+
+			return runParseCheck(cmd, args[0], checkCfg, lines, format, perLine)
+		},
+	}
+
+	parseCheckCmd.Flags().Int("lines", 100, "number of lines to check from the start of the input (0 = all)")
+	parseCheckCmd.Flags().String("format", "table", "output format: table or json")
+	parseCheckCmd.Flags().Bool("per-line", false, "report every line, not just the summary")
+	addConfigFlag(parseCheckCmd)
+
+	return parseCheckCmd
+}
+
+// parseCheckSummary aggregates the checked lines.
+type parseCheckSummary struct {
+	Formats  map[string]int `json:"formats"`
+	Reasons  map[string]int `json:"reasons"`
+	Hosts    map[string]int `json:"hosts"`
+	Lines    int            `json:"lines"`
+	Parsed   int            `json:"parsed"`
+	Rejected int            `json:"rejected"`
+	Dropped  int            `json:"dropped"`
+	Bots     int            `json:"bots"`
+	Static   int            `json:"static"`
+	Scanners int            `json:"scanner_probes"`
+}
+
+// parseCheckLine is one line of --per-line output.
+type parseCheckLine struct {
+	Format        string `json:"format,omitempty"`
+	Result        string `json:"result"`
+	Reason        string `json:"reason,omitempty"`
+	Sample        string `json:"sample,omitempty"`
+	Host          string `json:"host,omitempty"`
+	Method        string `json:"method,omitempty"`
+	Path          string `json:"path,omitempty"`
+	Referrer      string `json:"referrer,omitempty"`
+	ScanSignature string `json:"scan_signature,omitempty"`
+	Line          int    `json:"line"`
+	StatusCode    int    `json:"status_code,omitempty"`
+	Bot           bool   `json:"bot"`
+	Static        bool   `json:"static"`
+}
+
+type parseCheckReport struct {
+	Source  string            `json:"source"`
+	Lines   []parseCheckLine  `json:"lines,omitempty"`
+	Summary parseCheckSummary `json:"summary"`
+}
+
+func runParseCheck(cmd *cobra.Command, source string, cfg ingest.CheckConfig, limit int, format string, perLine bool) error {
+	input := cmd.InOrStdin()
+	if source != ingest.StdinLogPath {
+		f, err := os.Open(source) //nolint:gosec // path is an operator-supplied argument, not request input
+		if err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+		defer f.Close() //nolint:errcheck // read-only file, close error is not actionable
+		input = f
+	}
+
+	checks, err := ingest.CheckLines(input, cfg, limit)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", source, err)
+	}
+
+	report := buildParseCheckReport(source, checks, perLine)
+	if format == "json" {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := renderParseCheckTable(cmd.OutOrStdout(), report, checks); err != nil {
+		return err
+	}
+
+	if report.Summary.Lines > 0 && report.Summary.Parsed == 0 {
+		return &ui.UserError{
+			Err:  errors.New("no line matched the log format theia expects; the daemon would count nothing"),
+			Hint: "theia parse-check <file> --per-line",
+		}
+	}
+	return nil
+}
+
+func buildParseCheckReport(source string, checks []ingest.LineCheck, perLine bool) parseCheckReport {
+	report := parseCheckReport{
+		Source: source,
+		Summary: parseCheckSummary{
+			Formats: map[string]int{},
+			Reasons: map[string]int{},
+			Hosts:   map[string]int{},
+			Lines:   len(checks),
+		},
+	}
+	for _, c := range checks {
+		line := toParseCheckLine(c)
+		s := &report.Summary
+		switch line.Result {
+		case parseResultRejected:
+			s.Rejected++
+			s.Reasons[c.Reason]++
+		case parseResultDropped:
+			s.Parsed++
+			s.Dropped++
+		default:
+			s.Parsed++
+			s.Hosts[c.PageView.Host]++
+			if c.PageView.IsBot {
+				s.Bots++
+			}
+			if c.PageView.IsStatic {
+				s.Static++
+			}
+			if c.PageView.ScanSignature != "" {
+				s.Scanners++
+			}
+		}
+		if c.Format != "" {
+			s.Formats[c.Format]++
+		}
+		if perLine {
+			report.Lines = append(report.Lines, line)
+		}
+	}
+	return report
+}
+
+func toParseCheckLine(c ingest.LineCheck) parseCheckLine {
+	line := parseCheckLine{Line: c.Number, Format: c.Format}
+	switch {
+	case c.Reason != "":
+		line.Result = parseResultRejected
+		line.Reason = c.Reason
+		line.Sample = c.Sample
+		return line
+	case c.Dropped:
+		line.Result = parseResultDropped
+	default:
+		line.Result = parseResultCounted
+	}
+	pv := c.PageView
+	line.Host = pv.Host
+	line.Method = pv.Method
+	line.Path = pv.Path
+	line.Referrer = pv.Referrer
+	line.StatusCode = pv.StatusCode
+	line.Bot = pv.IsBot
+	line.Static = pv.IsStatic
+	line.ScanSignature = pv.ScanSignature
+	return line
+}
+
+func renderParseCheckTable(out io.Writer, report parseCheckReport, checks []ingest.LineCheck) error {
+	s := report.Summary
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintf(w, "Checked %d line(s) from %s\n\n", s.Lines, sanitizeTerminalField(report.Source))
+	_, _ = fmt.Fprintf(w, "Parsed\t%d\t%s\n", s.Parsed, percentOf(s.Parsed, s.Lines))
+	for _, f := range sortedKeys(s.Formats) {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\t\n", f, s.Formats[f])
+	}
+	_, _ = fmt.Fprintf(w, "Rejected\t%d\t%s\n", s.Rejected, percentOf(s.Rejected, s.Lines))
+	for _, r := range sortedKeys(s.Reasons) {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\t\n", r, s.Reasons[r])
+	}
+	_, _ = fmt.Fprintf(w, "Dropped by rules\t%d\t\n", s.Dropped)
+	_, _ = fmt.Fprintf(w, "Bots\t%d\t\n", s.Bots)
+	_, _ = fmt.Fprintf(w, "Static assets\t%d\t\n", s.Static)
+	_, _ = fmt.Fprintf(w, "Scanner probes\t%d\t\n", s.Scanners)
+
+	if len(s.Hosts) > 0 {
+		_, _ = fmt.Fprintln(w, "\nHOST\tLINES\t")
+		for _, h := range sortedKeys(s.Hosts) {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t\n", sanitizeTerminalField(h), s.Hosts[h])
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Lines) > 0 {
+		return renderParseCheckLines(out, report.Lines)
+	}
+	return renderParseCheckFailures(out, checks)
+}
+
+func renderParseCheckLines(out io.Writer, lines []parseCheckLine) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "\nLINE\tRESULT\tFORMAT\tHOST\tMETHOD\tPATH\tSTATUS\tBOT\tSTATIC\tSCANNER\tREASON")
+	for _, l := range lines {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			l.Line, l.Result, dashIfEmpty(l.Format), dashIfEmpty(sanitizeTerminalField(l.Host)),
+			dashIfEmpty(sanitizeTerminalField(l.Method)), dashIfEmpty(sanitizeTerminalField(l.Path)),
+			statusOrDash(l.StatusCode), yesNo(l.Bot), yesNo(l.Static), dashIfEmpty(l.ScanSignature), dashIfEmpty(l.Reason))
+	}
+	return w.Flush()
+}
+
+// renderParseCheckFailures lists the first few rejected lines, redacted, so
+// the summary alone shows what a mismatched line looks like.
+func renderParseCheckFailures(out io.Writer, checks []ingest.LineCheck) error {
+	var failures []ingest.LineCheck
+	for _, c := range checks {
+		if c.Reason != "" {
+			failures = append(failures, c)
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(out, "\nFirst rejected lines (up to %d):\n", maxParseCheckFailures)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range failures[:min(len(failures), maxParseCheckFailures)] {
+		_, _ = fmt.Fprintf(w, "  line %d\t%s\t%s\n", c.Number, c.Reason, sanitizeTerminalField(c.Sample))
+	}
+	return w.Flush()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func percentOf(n, total int) string {
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(%.1f%%)", float64(n)*100/float64(total))
+}
+
+func dashIfEmpty(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+func statusOrDash(code int) string {
+	if code == 0 {
+		return "-"
+	}
+	return fmt.Sprint(code)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
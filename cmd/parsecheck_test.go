@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+)
+
+const (
+	parseCheckGoodLine = `203.0.113.7 - - [20/Jul/2026:10:00:00 +0000] "GET /pricing HTTP/1.1" 200 512 "-" "Mozilla/5.0" "example.com"`
+	parseCheckBadLine  = `203.0.113.7 [20/Jul/2026:10:00:00] GET /pricing`
+)
+
+func runParseCheckCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := newParseCheckCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	// Point --config at an empty file, so a config on the test machine
+	// can't leak in.
+	cmd.SetArgs(append(args, "--config", writeConfigFile(t, "")))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestParseCheck_TableSummary(t *testing.T) {
+	t.Setenv(theiaDefaultHostEnv, "")
+	input := parseCheckGoodLine + "\n" + parseCheckGoodLine + "\n" + parseCheckBadLine + "\n"
+
+	out, err := runParseCheckCmd(t, input, "-")
+	if err != nil {
+		t.Fatalf("parse-check: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{
+		"Checked 3 line(s) from -",
+		"Parsed",
+		"(66.7%)",
+		"with_host",
+		"no_match",
+		"example.com",
+		"First rejected lines",
+		"line 3",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "203.0.113.7") {
+		t.Errorf("output leaks the client address:\n%s", out)
+	}
+}
+
+func TestParseCheck_JSONPerLineFromFile(t *testing.T) {
+	t.Setenv(theiaDefaultHostEnv, "")
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte(parseCheckGoodLine+"\n"+parseCheckBadLine+"\n"), 0o600); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	out, err := runParseCheckCmd(t, "", path, "--format", "json", "--per-line")
+	if err != nil {
+		t.Fatalf("parse-check: %v\noutput: %s", err, out)
+	}
+
+	var report parseCheckReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out)
+	}
+	if report.Summary.Lines != 2 || report.Summary.Parsed != 1 || report.Summary.Rejected != 1 {
+		t.Errorf("summary = %+v", report.Summary)
+	}
+	if len(report.Lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(report.Lines))
+	}
+	if got := report.Lines[0]; got.Result != parseResultCounted || got.Host != "example.com" || got.Path != "/pricing" || got.StatusCode != 200 {
+		t.Errorf("line 1 = %+v", got)
+	}
+	if got := report.Lines[1]; got.Result != parseResultRejected || got.Reason != "no_match" || got.Sample == "" {
+		t.Errorf("line 2 = %+v", got)
+	}
+}
+
+func TestParseCheck_LinesLimit(t *testing.T) {
+	input := strings.Repeat(parseCheckGoodLine+"\n", 5)
+
+	out, err := runParseCheckCmd(t, input, "-", "--lines", "2", "--format", "json")
+	if err != nil {
+		t.Fatalf("parse-check: %v\noutput: %s", err, out)
+	}
+	var report parseCheckReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out)
+	}
+	if report.Summary.Lines != 2 {
+		t.Errorf("lines = %d, want 2", report.Summary.Lines)
+	}
+}
+
+func TestParseCheck_AppliesConfigRules(t *testing.T) {
+	t.Setenv(theiaDefaultHostEnv, "")
+	config := writeConfigFile(t, `
+[rules]
+exclude_paths = ["/pricing"]
+`)
+	cmd := newParseCheckCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetIn(strings.NewReader(parseCheckGoodLine + "\n"))
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"-", "--config", config, "--format", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("parse-check: %v\noutput: %s", err, buf.String())
+	}
+
+	var report parseCheckReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v\n%s", err, buf.String())
+	}
+	if report.Summary.Dropped != 1 || len(report.Summary.Hosts) != 0 {
+		t.Errorf("summary = %+v, want the line dropped by the rule", report.Summary)
+	}
+}
+
+func TestParseCheck_NothingParsedIsAnError(t *testing.T) {
+	out, err := runParseCheckCmd(t, parseCheckBadLine+"\n", "-")
+	var userErr *ui.UserError
+	if !errors.As(err, &userErr) {
+		t.Fatalf("err = %v, want a UserError\noutput: %s", err, out)
+	}
+}
+
+func TestParseCheck_InvalidFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-", "--format", "yaml"},
+		{"-", "--lines", "-1"},
+	} {
+		if _, err := runParseCheckCmd(t, "", args...); err == nil {
+			t.Errorf("%v: want an error", args)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+	"github.com/spf13/cobra"
+)
+
+// newRetentionCmd builds the `theia retention` command group fresh each
+// call, for the same reason as newSystemCmd: cobra commands can only have one
+// parent.
+func newRetentionCmd() *cobra.Command {
+	retentionCmd := &cobra.Command{
+		Use:   "retention",
+		Short: "Inspect how long the daemon keeps analytics data",
+		Long: `Inspect the retention policy the daemon's cleanup applies every 12 hours.
+
+Hourly stats, status codes, referrers and visitor days are kept for their
+own number of days, then rolled up into daily totals, and days into monthly
+ones, until the monthly totals expire too. A tier that no coarser one
+outlives is deleted instead of rolled up. The [retention] table of the
+config file sets the horizons, and [retention.hosts."<host>"] tables
+override them for one host:
+
+  [retention]
+  referrers = 30
+
+  [retention.hosts."secure.example.com"]
+  hourly_stats = 14
+  status_codes = 14
+  referrers = 14
+  visitor_days = 14
+  daily = 14
+  monthly = 14`,
+	}
+
+	retentionCmd.AddCommand(newRetentionShowCmd())
+
+	return retentionCmd
+}
+
+func newRetentionShowCmd() *cobra.Command {
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective retention policy and what the next cleanup would remove",
+		Long: `show prints the retention, in days, each host gets once the config
+file is merged with the built-in defaults, then counts the rows the next
+cleanup would roll up or delete. Nothing is changed. A host of "*" is
+every host without a retention of its own for that table.
+
+Example:
+  theia retention show --config /etc/theia/theia.toml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			cfg, err := applyConfigFile(cmd, []configBinding{
+				{Key: "db_path", Flag: "db-path", Value: func(c config.Config) string { return c.DBPath }},
+			})
+			if err != nil {
+				return err
+			}
+			policy, err := retentionPolicy(cfg)
+			if err != nil {
+				return err
+			}
+
+			return withReadingDB(cmd, func(ctx context.Context, db *sql.DB) error {
+				previews, err := ingest.PreviewCleanup(ctx, db, policy, time.Now())
+				if err != nil {
+					return err
+				}
+				return renderRetention(cmd.OutOrStdout(), policy, previews)
+			})
+		},
+	}
+
+	showCmd.Flags().String("db-path", "./theia.db", "path to the sqlite database")
+	addMigrateFlag(showCmd)
+	addConfigFlag(showCmd)
+
+	return showCmd
+}
+
+func renderRetention(out io.Writer, policy ingest.RetentionPolicy, previews []ingest.CleanupPreview) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tHOURLY STATS\tSTATUS CODES\tREFERRERS\tVISITOR DAYS\tDAILY\tMONTHLY")
+	row := func(host string, r ingest.Retention) {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", host, r.HourlyStats, r.StatusCodes, r.Referrers, r.VisitorDays, r.Daily, r.Monthly)
+	}
+	row("*", policy.Default)
+	for _, host := range slices.Sorted(maps.Keys(policy.Hosts)) {
+		row(host, policy.Hosts[host])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var pending []ingest.CleanupPreview
+	for _, p := range previews {
+		if p.Rows > 0 {
+			pending = append(pending, p)
+		}
+	}
+	_, _ = fmt.Fprintln(out)
+	if len(pending) == 0 {
+		_, _ = fmt.Fprintln(out, "The next cleanup has nothing to roll up or delete.")
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(out, "The next cleanup would:")
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ACTION\tTABLE\tHOST\tBEFORE\tROWS")
+	for _, p := range pending {
+		action := "delete"
+		if p.Into != "" {
+			action = "roll into " + p.Into
+		}
+		host := p.Scope
+		if host == "" {
+			host = "*"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", action, p.Table, host, p.Before.Format("2006-01-02"), p.Rows)
+	}
+	return w.Flush()
+}
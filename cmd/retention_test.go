@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/ingest"
+)
+
+func runRetentionShowCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRetentionCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"show"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestRetentionShow_PrintsPolicyAndPendingCleanup(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	old := bucket.Hour(time.Now().AddDate(0, 0, -20))
+	for _, host := range []string{"example.com", "secure.example.com"} {
+		if _, err := db.ExecContext(t.Context(),
+			`INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (?, '/', ?, 1, 0, 0)`, old, host); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	path := writeConfigFile(t, `
+[retention]
+referrers = 30
+
+[retention.hosts."Secure.example.com"]
+hourly_stats = 14
+`)
+	out, err := runRetentionShowCmd(t, "--config", path, "--db-path", dbPath)
+	if err != nil {
+		t.Fatalf("retention show: %v\noutput: %s", err, out)
+	}
+
+	for _, want := range []string{
+		"*                   60            60            30",
+		"secure.example.com  14            60            30",
+		"roll into daily_stats  hourly_stats  secure.example.com",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\ngot: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "hourly_stats  *") {
+		t.Errorf("output plans a cleanup of the default host's recent rows\ngot: %s", out)
+	}
+}
+
+func TestRetentionPolicy_MergesHostsOverDefaults(t *testing.T) {
+	policy, err := retentionPolicy(config.Config{Retention: config.RetentionConfig{
+		Default: config.RetentionDays{Daily: 365},
+		Hosts:   map[string]config.RetentionDays{"Example.com": {VisitorDays: 14}},
+	}})
+	if err != nil {
+		t.Fatalf("retentionPolicy: %v", err)
+	}
+
+	want := ingest.DefaultRetention()
+	want.Daily = 365
+	if policy.Default != want {
+		t.Errorf("Default = %+v, want %+v", policy.Default, want)
+	}
+	want.VisitorDays = 14
+	if got := policy.Hosts["example.com"]; got != want {
+		t.Errorf("Hosts[example.com] = %+v, want %+v", got, want)
+	}
+}
+
+func TestRetentionPolicy_RejectsHostDailyShorterThanHourly(t *testing.T) {
+	_, err := retentionPolicy(config.Config{Retention: config.RetentionConfig{
+		Hosts: map[string]config.RetentionDays{"example.com": {Daily: 30}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "retention.hosts.example.com") {
+		t.Errorf("retentionPolicy error = %v, want one naming retention.hosts.example.com", err)
+	}
+}
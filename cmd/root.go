@@ -32,8 +32,19 @@ No client-side JavaScript required, making it resistant to ad-blockers.`, buildi
 
 	rootCmd.AddCommand(newDaemonCmd())
 	rootCmd.AddCommand(newStatsCmd())
+	rootCmd.AddCommand(newGoalsCmd())
+	rootCmd.AddCommand(newFunnelCmd())
+	rootCmd.AddCommand(newLiveCmd())
+	rootCmd.AddCommand(newScannersCmd())
 	rootCmd.AddCommand(newServeCmd())
 	rootCmd.AddCommand(newServeMetricsCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newRetentionCmd())
+	rootCmd.AddCommand(newDBCmd())
+	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(newImportDumpCmd())
+	rootCmd.AddCommand(newParseCheckCmd())
+	rootCmd.AddCommand(newNginxCmd())
 	rootCmd.AddCommand(newSystemCmd())
 	rootCmd.AddCommand(newCompletionCmd(rootCmd))
 
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
+	"github.com/Elysium-Labs-EU/theia/internal/live"
+	"github.com/Elysium-Labs-EU/theia/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newScannersCmd() *cobra.Command {
+	scannersCmd := &cobra.Command{
+		Use:   "scanners",
+		Short: "List IPs sending scanner probes, ready for fail2ban or nginx deny",
+		Long: `scanners lists the IPs the running daemon saw requesting known scanner
+probe paths (.env files, wp-login.php, .git/config, path traversal, ...)
+within the last hour.
+
+The list lives only in the daemon's memory — IPs are never written to the
+database — so it's read from the daemon's live view (see its --live-addr).
+
+Formats:
+  table     human-readable list (default)
+  nginx     "deny <ip>;" lines, to include from an nginx server block
+  fail2ban  one log line per IP, for a fail2ban filter (see docs/nginx-hardening.md)
+  json      the raw offender list
+
+Example:
+  theia scanners
+  theia scanners --format nginx --min-hits 3 > /etc/nginx/snippets/theia-deny.conf`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Flags parsed fine to reach here, so any error from this point
+			// on is a runtime failure, not a usage mistake — don't dump the
+			// flags/usage block for it.
+			cmd.SilenceUsage = true
+
+			addr, err := cmd.Flags().GetString("addr")
+			if err != nil {
+				return fmt.Errorf("parsing addr flag: %w", err)
+			}
+			minHits, err := cmd.Flags().GetInt("min-hits")
+			if err != nil {
+				return fmt.Errorf("parsing min-hits flag: %w", err)
+			}
+			if minHits <= 0 {
+				return fmt.Errorf("invalid --min-hits %d: must be a positive integer", minHits)
+			}
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return fmt.Errorf("parsing format flag: %w", err)
+			}
+			switch format {
+			case "table", "nginx", "fail2ban", "json":
+			default:
+				return fmt.Errorf("invalid --format %q: must be table, nginx, fail2ban or json", format)
+			}
+
+			if err := apiserver.ValidateLoopbackAddr(addr); err != nil {
+				return err
+			}
+
+			return runScanners(cmd, addr, minHits, format)
+		},
+	}
+
+	scannersCmd.Flags().String("addr", "127.0.0.1:8083", "address of the daemon's live view (its --live-addr)")
+	scannersCmd.Flags().Int("min-hits", 3, "only list IPs with at least this many probes")
+	scannersCmd.Flags().String("format", "table", "output format: table, nginx, fail2ban or json")
+
+	return scannersCmd
+}
+
+func runScanners(cmd *cobra.Command, addr string, minHits int, format string) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	endpoint := "http://" + addr + "/api/v1/scanners?min_hits=" + strconv.Itoa(minHits)
+
+	var resp struct {
+		Offenders []live.Offender `json:"offenders"`
+	}
+	if err := fetchLiveJSON(cmd.Context(), client, endpoint, &resp); err != nil {
+		return &ui.UserError{Err: err, Hint: "theia daemon --live-addr 127.0.0.1:8083"}
+	}
+
+	out := cmd.OutOrStdout()
+	switch format {
+	case "nginx":
+		renderNginxDeny(out, resp.Offenders, time.Now())
+		return nil
+	case "fail2ban":
+		renderFail2ban(out, resp.Offenders)
+		return nil
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.Offenders)
+	default:
+		return renderScannersTable(out, resp.Offenders)
+	}
+}
+
+func renderScannersTable(out io.Writer, offenders []live.Offender) error {
+	if len(offenders) == 0 {
+		_, _ = fmt.Fprintln(out, "No scanner activity in the last hour.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "IP\tHITS\tSIGNATURES\tLAST SEEN")
+	for _, o := range offenders {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", o.IP, o.Hits, strings.Join(o.Signatures, ","), o.LastSeen.Local().Format("15:04:05"))
+	}
+	return w.Flush()
+}
+
+// renderNginxDeny writes one deny rule per offender. The daemon only ever
+// reports literal IP addresses, so each line is a valid directive.
+func renderNginxDeny(out io.Writer, offenders []live.Offender, now time.Time) {
+	_, _ = fmt.Fprintf(out, "# generated by theia scanners at %s\n", now.UTC().Format(time.RFC3339))
+	for _, o := range offenders {
+		_, _ = fmt.Fprintf(out, "deny %s; # %d hits: %s\n", o.IP, o.Hits, strings.Join(o.Signatures, ","))
+	}
+}
+
+// renderFail2ban writes one line per offender in a fixed shape a fail2ban
+// filter can match with "^\S+ theia-scanner <HOST>\s".
+func renderFail2ban(out io.Writer, offenders []live.Offender) {
+	for _, o := range offenders {
+		_, _ = fmt.Fprintf(out, "%s theia-scanner %s hits=%d signatures=%s\n",
+			o.LastSeen.UTC().Format(time.RFC3339), o.IP, o.Hits, strings.Join(o.Signatures, ","))
+	}
+}
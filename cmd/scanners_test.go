@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/live"
+)
+
+func runScannersCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	scanners := live.NewScanners()
+	for range 3 {
+		scanners.Record(live.ScanHit{Time: time.Now(), IP: "203.0.113.9", Signature: "env_file"})
+	}
+	scanners.Record(live.ScanHit{Time: time.Now(), IP: "198.51.100.7", Signature: "wordpress"})
+
+	srv := httptest.NewServer(live.ScannersHandler(scanners))
+	t.Cleanup(srv.Close)
+
+	cmd := newScannersCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"--addr", strings.TrimPrefix(srv.URL, "http://")}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestScannersCmd_NginxFormat(t *testing.T) {
+	out, err := runScannersCmd(t, "--format", "nginx")
+	if err != nil {
+		t.Fatalf("scanners --format nginx: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "deny 203.0.113.9; # 3 hits: env_file\n") {
+		t.Errorf("expected a deny rule for 203.0.113.9\ngot: %s", out)
+	}
+	if strings.Contains(out, "198.51.100.7") {
+		t.Errorf("expected the default --min-hits 3 to leave out a single probe\ngot: %s", out)
+	}
+}
+
+func TestScannersCmd_Fail2banFormat(t *testing.T) {
+	out, err := runScannersCmd(t, "--format", "fail2ban", "--min-hits", "1")
+	if err != nil {
+		t.Fatalf("scanners --format fail2ban: %v\noutput: %s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per offender, got %q", out)
+	}
+	if fields := strings.Fields(lines[0]); len(fields) < 3 || fields[1] != "theia-scanner" || fields[2] != "203.0.113.9" {
+		t.Errorf("expected \"<time> theia-scanner <ip> ...\", got %q", lines[0])
+	}
+}
+
+func TestScannersCmd_RejectsUnknownFormat(t *testing.T) {
+	if _, err := runScannersCmd(t, "--format", "iptables"); err == nil {
+		t.Error("expected an error for an unknown --format, got nil")
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/Elysium-Labs-EU/theia/database"
 	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
 	"github.com/spf13/cobra"
 )
 
@@ -23,7 +25,13 @@ func newServeCmd() *cobra.Command {
 (JSON or CSV) over the same sqlite database the daemon writes to.
 
 It binds to 127.0.0.1 only — put it behind a reverse proxy (e.g. nginx)
-to expose it beyond localhost.
+to expose it beyond localhost. Under systemd it reports readiness and
+watchdog pings (Type=notify, WatchdogSec=), and a socket-activated
+listener (a theia-serve.socket unit) is used instead of --addr.
+
+The database is opened read-only and must be at this theia's schema
+version; --migrate upgrades it first instead, for a serve that may start
+before the daemon has.
 
 Example:
   theia serve --db-path /var/lib/theia/theia.db --token-file /etc/theia/api-token`,
@@ -34,6 +42,15 @@ Example:
 			// flags/usage block for it.
 			cmd.SilenceUsage = true
 
+			cfg, err := applyConfigFile(cmd, serveConfigBindings(os.Getenv(theiaAPITokenEnv) != ""))
+			if err != nil {
+				return err
+			}
+			tz, err := displayTimezones(cfg)
+			if err != nil {
+				return err
+			}
+
 			dbPath, err := cmd.Flags().GetString("db-path")
 			if err != nil {
 				return fmt.Errorf("parsing db-path flag: %w", err)
@@ -60,7 +77,7 @@ Example:
 				return err
 			}
 
-			return runServe(cmd, dbPath, addr, resolvedToken)
+			return runServe(cmd, dbPath, addr, resolvedToken, tz)
 		},
 	}
 
@@ -68,6 +85,8 @@ Example:
 	serveCmd.Flags().String("addr", "127.0.0.1:8081", "address to bind the stats API to (must be 127.0.0.1 or localhost)")
 	serveCmd.Flags().String("token", "", "bearer token required on every request (avoid on shared machines — visible in the process list; prefer --token-file or "+theiaAPITokenEnv)
 	serveCmd.Flags().String("token-file", "", "path to a file containing the bearer token")
+	addMigrateFlag(serveCmd)
+	addConfigFlag(serveCmd)
 
 	return serveCmd
 }
@@ -100,17 +119,24 @@ func resolveAPIToken(token, tokenFile string) (string, error) {
 	return "", fmt.Errorf("no bearer token configured: set --token, --token-file, or %s", theiaAPITokenEnv)
 }
 
-func runServe(cmd *cobra.Command, dbPath, addr, token string) error {
-	db, err := database.Open(cmd.Context(), dbPath)
+func runServe(cmd *cobra.Command, dbPath, addr, token string, tz query.Timezones) error {
+	db, err := openForReading(cmd, dbPath)
 	if err != nil {
 		return err
 	}
 	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
 
-	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
-		return fmt.Errorf("running migrations: %w", err)
+	notifier, err := systemd.NotifierFromEnv()
+	if err != nil {
+		return err
+	}
+	listener, err := serviceListener(addr)
+	if err != nil {
+		return err
 	}
 
-	cmd.Printf("Stats API listening on %s\n", addr)
-	return apiserver.Run(cmd.Context(), db, apiserver.Config{Addr: addr, Token: token})
+	cmd.Printf("Stats API listening on %s\n", listener.Addr())
+	stop := superviseService(cmd.Context(), notifier, "Serving the stats API on "+listener.Addr().String(), db.PingContext)
+	defer stop()
+	return apiserver.Run(cmd.Context(), db, apiserver.Config{Addr: addr, Token: token, Listener: listener, Timezones: tz})
 }
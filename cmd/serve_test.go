@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
 )
 
 // waitForListener polls addr until something accepts TCP connections or
@@ -62,7 +64,10 @@ func doAuthedGet(t *testing.T, url, token string) (int, []byte) {
 // round-trip (auth accepted, auth rejected) against the live listener
 // before shutdown, so this is more than a shutdown-timing check.
 func TestServeCmd_StopsOnContextCancellation(t *testing.T) {
-	dbPath := filepath.Join(t.TempDir(), "theia.db")
+	// serve opens the database read-only, so it must exist at this theia's
+	// schema version already.
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
 	addr := "127.0.0.1:18234"
 	token := "test-token"
 
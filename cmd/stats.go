@@ -11,6 +11,7 @@ import (
 	"unicode"
 
 	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
 	"github.com/Elysium-Labs-EU/theia/internal/ingest"
 	"github.com/Elysium-Labs-EU/theia/internal/query"
 	"github.com/spf13/cobra"
@@ -18,12 +19,25 @@ import (
 
 const noDataLabel = "  (no data)"
 
+// Values of `theia stats --section`.
+const (
+	sectionAll         = "all"
+	sectionBrokenLinks = "broken-links"
+)
+
+type brokenLinksReport struct {
+	BrokenLinks []query.BrokenLink `json:"broken_links"`
+}
+
 //nolint:govet // fieldalignment: JSON output field order follows struct order; reordering would change the rendered output
 type statsReport struct {
 	Summary      query.Summary        `json:"summary"`
 	TopPaths     []query.PathStat     `json:"top_paths"`
 	StatusCodes  []query.StatusStat   `json:"status_codes"`
 	TopReferrers []query.ReferrerStat `json:"top_referrers"`
+	Goals        []query.GoalStat     `json:"goals"`
+	ParseHealth  query.ParseHealth    `json:"parse_failures"`
+	Scans        []query.ScanStat     `json:"scans"`
 }
 
 func newStatsCmd() *cobra.Command {
@@ -34,7 +48,16 @@ func newStatsCmd() *cobra.Command {
 
 Example:
   theia stats --db-path /var/lib/theia/theia.db
-  theia stats --days 30 --host example.com --format json`,
+  theia stats --days 30 --host example.com --format json
+  theia stats --tz Europe/Amsterdam
+
+Days run midnight to midnight in the display timezone: --tz, else the
+config file's [host_timezones] entry for --host, else its timezone, else
+UTC.
+
+The database is opened read-only, so stats can't change it and can read a
+copy or backup; it must be at this theia's schema version, or --migrate
+upgrades it first.`,
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Flags parsed fine to reach here, so any error from this point
@@ -42,6 +65,11 @@ Example:
 			// flags/usage block for it.
 			cmd.SilenceUsage = true
 
+			cfg, err := applyConfigFile(cmd, statsConfigBindings())
+			if err != nil {
+				return err
+			}
+
 			dbPath, err := cmd.Flags().GetString("db-path")
 			if err != nil {
 				return fmt.Errorf("parsing db-path flag: %w", err)
@@ -65,8 +93,23 @@ Example:
 			if err != nil {
 				return fmt.Errorf("parsing top flag: %w", err)
 			}
+			section, err := cmd.Flags().GetString("section")
+			if err != nil {
+				return fmt.Errorf("parsing section flag: %w", err)
+			}
+			if section != sectionAll && section != sectionBrokenLinks {
+				return fmt.Errorf("invalid --section %q: must be %q or %q", section, sectionAll, sectionBrokenLinks)
+			}
+			tzName, err := cmd.Flags().GetString("tz")
+			if err != nil {
+				return fmt.Errorf("parsing tz flag: %w", err)
+			}
+			loc, err := statsLocation(cfg, tzName, host)
+			if err != nil {
+				return err
+			}
 
-			return runStats(cmd, dbPath, days, host, format, top)
+			return runStats(cmd, dbPath, days, host, format, top, section, loc)
 		},
 	}
 
@@ -75,30 +118,53 @@ Example:
 	statsCmd.Flags().String("host", "", "filter by host (empty = all hosts)")
 	statsCmd.Flags().String("format", "table", "output format: table or json")
 	statsCmd.Flags().Int("top", 10, "number of top paths/referrers to show")
+	statsCmd.Flags().String("tz", "", "IANA timezone days are reported in, e.g. Europe/Amsterdam (default: the config file's timezone, else UTC)")
+	statsCmd.Flags().String("section", sectionAll, "report to show: all, or broken-links for 4xx and 5xx responses with the pages linking to them")
+	addMigrateFlag(statsCmd)
+	addConfigFlag(statsCmd)
 
 	return statsCmd
 }
 
-func runStats(cmd *cobra.Command, dbPath string, days int, host, format string, top int) error {
-	db, err := database.Open(cmd.Context(), dbPath)
+// statsLocation picks the display timezone: an explicit --tz wins over the
+// config file's zone for host, which wins over UTC.
+func statsLocation(cfg config.Config, tzName, host string) (*time.Location, error) {
+	if tzName != "" {
+		loc, err := time.LoadLocation(tzName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --tz %q: want an IANA timezone such as Europe/Amsterdam", tzName)
+		}
+		return loc, nil
+	}
+	tz, err := displayTimezones(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return query.DisplayLocation(tz, host), nil
+}
+
+func runStats(cmd *cobra.Command, dbPath string, days int, host, format string, top int, section string, loc *time.Location) error {
+	db, err := openForReading(cmd, dbPath)
 	if err != nil {
 		return err
 	}
 	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
 
-	// Guard against racing a running daemon's migrations on the same db-path
-	// (issue #23): serialize behind the shared migration lock.
-	release, lockErr := database.AcquireMigrationLock(dbPath)
-	if lockErr != nil {
-		return fmt.Errorf("acquiring migration lock: %w", lockErr)
-	}
-	err = database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath)
-	_ = release() // release error is not actionable here
-	if err != nil {
-		return fmt.Errorf("running migrations: %w", err)
+	since := time.Now().In(loc).AddDate(0, 0, -days)
+
+	if section == sectionBrokenLinks {
+		links, err := query.GetBrokenLinks(cmd.Context(), db, since, host, top)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(brokenLinksReport{BrokenLinks: links})
+		}
+		return renderBrokenLinksTable(cmd, links, periodLabel(days, host, loc))
 	}
 
-	since := time.Now().AddDate(0, 0, -days)
 	report, err := collectStats(cmd.Context(), db, since, host, top)
 	if err != nil {
 		return err
@@ -108,7 +174,7 @@ func runStats(cmd *cobra.Command, dbPath string, days int, host, format string,
 	case "json":
 		return renderJSON(cmd, &report)
 	default:
-		return renderTable(cmd, &report, days, host)
+		return renderTable(cmd, &report, periodLabel(days, host, loc))
 	}
 }
 
@@ -133,11 +199,29 @@ func collectStats(ctx context.Context, db *sql.DB, since time.Time, host string,
 		return statsReport{}, err
 	}
 
+	goalStats, err := query.GetGoalStats(ctx, db, since, host)
+	if err != nil {
+		return statsReport{}, err
+	}
+
+	parseHealth, err := query.GetParseHealth(ctx, db, since)
+	if err != nil {
+		return statsReport{}, err
+	}
+
+	scans, err := query.GetScanStats(ctx, db, since, host)
+	if err != nil {
+		return statsReport{}, err
+	}
+
 	return statsReport{
 		Summary:      summary,
 		TopPaths:     paths,
 		StatusCodes:  statuses,
 		TopReferrers: referrers,
+		Goals:        goalStats,
+		ParseHealth:  parseHealth,
+		Scans:        scans,
 	}, nil
 }
 
@@ -161,13 +245,21 @@ func sanitizeTerminalField(s string) string {
 	}, s)
 }
 
-func renderTable(cmd *cobra.Command, r *statsReport, days int, host string) error {
-	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
-
+// periodLabel heads the table sections, naming the display timezone unless
+// it's UTC.
+func periodLabel(days int, host string, loc *time.Location) string {
 	period := fmt.Sprintf("last %d days", days)
+	if loc != time.UTC {
+		period += " in " + loc.String()
+	}
 	if host != "" {
 		period += " - " + host
 	}
+	return period
+}
+
+func renderTable(cmd *cobra.Command, r *statsReport, period string) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
 
 	_, _ = fmt.Fprintf(w, "Summary (%s)\n", period)
 	_, _ = fmt.Fprintf(w, "  Pageviews:\t%d\n", r.Summary.Pageviews)
@@ -207,6 +299,62 @@ func renderTable(cmd *cobra.Command, r *statsReport, days int, host string) erro
 		}
 	}
 
+	// Goals are opt-in (`theia goals add`), so the section is left out
+	// entirely rather than printed as "(no data)" on installs without any.
+	if len(r.Goals) > 0 {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, "Goals")
+		_, _ = fmt.Fprintln(w, "  GOAL\tCOMPLETIONS\tCONVERTERS\tCONVERSION")
+		for _, g := range r.Goals {
+			_, _ = fmt.Fprintf(w, "  %s\t%d\t%d\t%.1f%%\n", g.Goal, g.Completions, g.UniqueVisitors, g.ConversionRate*100)
+		}
+	}
+
+	// Scanner probes are excluded from every count above; show what was
+	// filtered out, when anything was.
+	if len(r.Scans) > 0 {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, "Scanner Probes")
+		_, _ = fmt.Fprintln(w, "  SIGNATURE\tREQUESTS")
+		for _, sc := range r.Scans {
+			_, _ = fmt.Fprintf(w, "  %s\t%d\n", sc.Signature, sc.Count)
+		}
+	}
+
+	// Parse failures aren't host-scoped and are normally zero, so they only
+	// get a section when there's something to look at.
+	if r.ParseHealth.Failures > 0 {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, "Parse Failures (all hosts)")
+		_, _ = fmt.Fprintf(w, "  Failed lines:\t%d of %d (%.1f%%)\n", r.ParseHealth.Failures, r.ParseHealth.Lines, r.ParseHealth.FailureRate*100)
+		_, _ = fmt.Fprintln(w, "  REASON\tCOUNT")
+		for _, f := range r.ParseHealth.Reasons {
+			_, _ = fmt.Fprintf(w, "  %s\t%d\n", f.Reason, f.Count)
+		}
+	}
+
+	return w.Flush()
+}
+
+func renderBrokenLinksTable(cmd *cobra.Command, links []query.BrokenLink, period string) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintf(w, "Broken Links (%s)\n", period)
+	if len(links) == 0 {
+		_, _ = fmt.Fprintln(w, noDataLabel)
+		return w.Flush()
+	}
+	_, _ = fmt.Fprintln(w, "  STATUS\tPATH\tHOST\tREFERRER\tCOUNT\t")
+	for _, l := range links {
+		// Internal links are the ones that can be fixed on our own site,
+		// so they get flagged in the table too, not just in JSON.
+		fixable := ""
+		if l.Internal {
+			fixable = "internal"
+		}
+		_, _ = fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%d\t%s\n",
+			l.StatusCode, sanitizeTerminalField(l.Path), sanitizeTerminalField(l.Host), sanitizeTerminalField(l.Referrer), l.Count, fixable)
+	}
 	return w.Flush()
 }
 
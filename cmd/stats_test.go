@@ -12,6 +12,9 @@ import (
 	"time"
 
 	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
 	"github.com/spf13/cobra"
 )
 
@@ -42,12 +45,12 @@ func insertStat(t *testing.T, db *sql.DB, path, host string, ts time.Time, s sta
 		staticInt = 1
 	}
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_stats (hour, year_day, year, path, host, page_views, is_static, bot_views)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host) DO UPDATE SET
+		INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host) DO UPDATE SET
 			page_views = page_views + ?,
 			bot_views = bot_views + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host,
+		bucket.Hour(ts), path, host,
 		s.PageViews, staticInt, s.BotViews,
 		s.PageViews, s.BotViews,
 	)
@@ -66,10 +69,10 @@ func insertDistinctVisitorDays(t *testing.T, db *sql.DB, path, host string, ts t
 	for i := range count {
 		hash := fmt.Sprintf("%s|%s|%d", host, path, i)
 		_, err := db.ExecContext(t.Context(), `
-			INSERT INTO visitor_days (hash, host, year, year_day, first_seen)
-			VALUES (?, ?, ?, ?, datetime('now'))
-			ON CONFLICT(hash, host, year, year_day) DO NOTHING`,
-			hash, host, ts.Year(), ts.YearDay(),
+			INSERT INTO visitor_days (hash, host, day, first_seen)
+			VALUES (?, ?, ?, datetime('now'))
+			ON CONFLICT(hash, host, day) DO NOTHING`,
+			hash, host, bucket.Day(ts),
 		)
 		if err != nil {
 			t.Fatalf("insert visitor day: %v", err)
@@ -148,7 +151,7 @@ func TestRenderTable(t *testing.T) {
 	r := &statsReport{}
 	r.Summary.Pageviews = 42
 
-	if err := renderTable(cmd, r, 7, ""); err != nil {
+	if err := renderTable(cmd, r, periodLabel(7, "", time.UTC)); err != nil {
 		t.Fatalf("renderTable: %v", err)
 	}
 
@@ -164,7 +167,7 @@ func TestRenderTable_EmptyData(t *testing.T) {
 	cmd, buf := newBufCmd()
 	r := &statsReport{}
 
-	if err := renderTable(cmd, r, 7, ""); err != nil {
+	if err := renderTable(cmd, r, periodLabel(7, "", time.UTC)); err != nil {
 		t.Fatalf("renderTable: %v", err)
 	}
 
@@ -173,11 +176,32 @@ func TestRenderTable_EmptyData(t *testing.T) {
 	}
 }
 
+func TestRenderTable_ParseFailures(t *testing.T) {
+	cmd, buf := newBufCmd()
+	r := &statsReport{ParseHealth: query.ParseHealth{
+		Reasons:     []query.ParseFailureStat{{Reason: "no_match", Count: 25}},
+		Failures:    25,
+		Lines:       100,
+		FailureRate: 0.25,
+	}}
+
+	if err := renderTable(cmd, r, periodLabel(7, "", time.UTC)); err != nil {
+		t.Fatalf("renderTable: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"Parse Failures", "25 of 100 (25.0%)", "no_match"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\ngot: %s", want, out)
+		}
+	}
+}
+
 func TestRenderTable_HostInPeriod(t *testing.T) {
 	cmd, buf := newBufCmd()
 	r := &statsReport{}
 
-	if err := renderTable(cmd, r, 30, "example.com"); err != nil {
+	if err := renderTable(cmd, r, periodLabel(30, "example.com", time.UTC)); err != nil {
 		t.Fatalf("renderTable: %v", err)
 	}
 
@@ -345,3 +369,131 @@ func TestCollectStats_Context(t *testing.T) {
 		t.Error("expected error with canceled context, got nil")
 	}
 }
+
+func TestStatsCmd_BrokenLinksSection(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	now := time.Now()
+	_, err := db.ExecContext(t.Context(), `
+		INSERT INTO hourly_broken_links (bucket, path, host, referrer, status_code, count)
+		VALUES (?, '/old-post', 'example.com', 'https://example.com/archive', 404, 4)`,
+		bucket.Hour(now))
+	if err != nil {
+		t.Fatalf("insert broken link: %v", err)
+	}
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	cmd := newStatsCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"--db-path", dbPath, "--section", "broken-links"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute: %v\noutput: %s", err, buf.String())
+	}
+
+	out := buf.String()
+	for _, want := range []string{"Broken Links", "/old-post", "https://example.com/archive", "internal"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\ngot: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "Top Paths") {
+		t.Errorf("--section broken-links should print only that section\ngot: %s", out)
+	}
+}
+
+func TestStatsCmd_RejectsUnknownSection(t *testing.T) {
+	cmd := newStatsCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--section", "nope"})
+
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected an error for an unknown --section, got nil")
+	}
+}
+
+func TestStatsCmd_TimezoneInPeriod(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	cmd := newStatsCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"--db-path", dbPath, "--tz", "Europe/Amsterdam"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute: %v\noutput: %s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "last 7 days in Europe/Amsterdam") {
+		t.Errorf("expected the display timezone in the period header\ngot: %s", buf.String())
+	}
+}
+
+func TestStatsCmd_RejectsUnknownTimezone(t *testing.T) {
+	cmd := newStatsCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--tz", "Mars/Olympus"})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "invalid --tz") {
+		t.Errorf("Execute error = %v, want an invalid --tz error", err)
+	}
+}
+
+// --tz wins over the config file, whose [host_timezones] entry wins over
+// its timezone for the host it names.
+func TestStatsLocation(t *testing.T) {
+	cfg := config.Config{
+		Timezone:      "America/New_York",
+		HostTimezones: map[string]string{"Example.com": "Europe/Amsterdam"},
+	}
+	for _, tt := range []struct {
+		name, tz, host string
+		cfg            config.Config
+		want           string
+	}{
+		{name: "no config", want: "UTC"},
+		{name: "config default", cfg: cfg, host: "other.com", want: "America/New_York"},
+		{name: "config host", cfg: cfg, host: "example.com", want: "Europe/Amsterdam"},
+		{name: "flag wins", cfg: cfg, tz: "Asia/Tokyo", host: "example.com", want: "Asia/Tokyo"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := statsLocation(tt.cfg, tt.tz, tt.host)
+			if err != nil {
+				t.Fatalf("statsLocation: %v", err)
+			}
+			if loc.String() != tt.want {
+				t.Errorf("statsLocation = %s, want %s", loc, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsCmd_ReadsWithoutMigrating(t *testing.T) {
+	db, dbPath := setupCmdTestDB(t)
+	if _, err := database.Rollback(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	database.Close(db) //nolint:errcheck // close before command reopens the same file
+
+	cmd := newStatsCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"--db-path", dbPath})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "is older than") {
+		t.Fatalf("stats on an older schema = %v, want a version error", err)
+	}
+
+	cmd = newStatsCmd()
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"--db-path", dbPath, "--migrate"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("stats --migrate: %v\noutput: %s", err, buf.String())
+	}
+}
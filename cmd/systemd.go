@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
+)
+
+// healthCheckTimeout bounds one serviceHealthy check, so a wedged database
+// shows up as a missed watchdog ping rather than a goroutine stuck forever.
+const healthCheckTimeout = 5 * time.Second
+
+// serviceListener returns the socket systemd passed in through socket
+// activation, or else binds addr. An activated TCP socket is held to the
+// same loopback-only rule as --addr; a unix socket is local by definition.
+// Binding up front, rather than inside the server, is what lets the caller
+// report READY only once connections can actually be accepted.
+func serviceListener(addr string) (net.Listener, error) {
+	activated, err := systemd.ListenersFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	switch len(activated) {
+	case 0:
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("listening on %s: %w", addr, err)
+		}
+		return l, nil
+	case 1:
+	default:
+		for _, l := range activated {
+			_ = l.Close() // close error is not actionable on the failure path
+		}
+		return nil, fmt.Errorf("systemd passed %d sockets: the socket unit must have exactly one Listen= line", len(activated))
+	}
+
+	l := activated[0]
+	if l.Addr().Network() == "tcp" {
+		if err := apiserver.ValidateLoopbackAddr(l.Addr().String()); err != nil {
+			_ = l.Close() // close error is not actionable on the failure path
+			return nil, fmt.Errorf("socket-activated listener: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// superviseService tells systemd the service is ready and, until the
+// returned stop is called, pings the watchdog every time healthy succeeds.
+// A failing check withholds the ping, so systemd restarts a service that's
+// still accepting connections but can no longer answer them. stop sends
+// STOPPING and waits for the pinging to end. Without NOTIFY_SOCKET all of
+// this is a no-op.
+func superviseService(ctx context.Context, notifier systemd.Notifier, status string, healthy func(context.Context) error) (stop func()) {
+	notify(notifier, systemd.Ready, systemd.Status(status))
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	if interval := systemd.PingInterval(notifier); interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					checkCtx, cancelCheck := context.WithTimeout(ctx, healthCheckTimeout)
+					err := healthy(checkCtx)
+					cancelCheck()
+					if err != nil {
+						log.Printf("Warning: health check failed, withholding watchdog ping: %v", err)
+						notify(notifier, systemd.Status("Unhealthy: "+err.Error()))
+						continue
+					}
+					notify(notifier, systemd.Watchdog, systemd.Status(status))
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+		notify(notifier, systemd.Stopping)
+	}
+}
+
+// notify sends states to systemd, logging rather than failing: a service
+// manager that can't be told about progress is no reason to stop serving.
+func notify(notifier systemd.Notifier, states ...string) {
+	if err := systemd.Notify(notifier, states...); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
+)
+
+// fakeNotifySocket stands in for systemd's notify socket.
+func fakeNotifySocket(t *testing.T) (systemd.Notifier, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen on fake notify socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return systemd.Notifier{Socket: path}, conn
+}
+
+// readNotification returns the next datagram that starts with prefix,
+// skipping any others.
+func readNotification(t *testing.T, conn *net.UnixConn, prefix string) string {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("waiting for a %q notification: %v", prefix, err)
+		}
+		if msg := string(buf[:n]); strings.HasPrefix(msg, prefix) {
+			return msg
+		}
+	}
+}
+
+func TestSuperviseService(t *testing.T) {
+	notifier, conn := fakeNotifySocket(t)
+	notifier.WatchdogInterval = 20 * time.Millisecond
+	var failing atomic.Bool
+	healthy := func(context.Context) error {
+		if failing.Load() {
+			return errors.New("database is locked")
+		}
+		return nil
+	}
+
+	stop := superviseService(t.Context(), notifier, "Serving on 127.0.0.1:8081", healthy)
+
+	if got, want := readNotification(t, conn, systemd.Ready), "READY=1\nSTATUS=Serving on 127.0.0.1:8081"; got != want {
+		t.Errorf("ready notification = %q, want %q", got, want)
+	}
+	readNotification(t, conn, systemd.Watchdog)
+
+	failing.Store(true)
+	if got := readNotification(t, conn, "STATUS=Unhealthy"); !strings.Contains(got, "database is locked") {
+		t.Errorf("unhealthy status = %q, want the check's error", got)
+	}
+
+	stop()
+	readNotification(t, conn, systemd.Stopping)
+}
+
+func TestServiceListener_BindsAddrWithoutActivation(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "")
+
+	l, err := serviceListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("serviceListener: %v", err)
+	}
+	defer l.Close() //nolint:errcheck // close error in defer is not actionable
+	if !strings.HasPrefix(l.Addr().String(), "127.0.0.1:") {
+		t.Errorf("listening on %s, want 127.0.0.1", l.Addr())
+	}
+}
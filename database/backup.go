@@ -0,0 +1,293 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// PreRestoreSuffix names the copy Restore keeps of the database it replaces.
+const PreRestoreSuffix = ".pre-restore"
+
+// backupPrefix and backupTimeLayout name the backups BackupName picks, so
+// PruneBackups can tell them apart from anything else in the directory and
+// sort them oldest first by name.
+const (
+	backupPrefix     = "theia-"
+	backupTimeLayout = "20060102T150405Z"
+)
+
+// gzipMagic starts every gzip stream, which is how Restore tells a compressed
+// backup from a plain one whatever it's named.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Backup writes a consistent snapshot of db to path, gzipped when compress
+// is set. VACUUM INTO reads the whole database in one transaction, so a
+// daemon writing in WAL mode meanwhile neither blocks it nor leaks half a
+// write into it. path is written in full or not at all.
+func Backup(ctx context.Context, db *sql.DB, path string, compress bool) error {
+	snapshot, err := tempFile(filepath.Dir(path), ".theia-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(snapshot) //nolint:errcheck // gone already once renamed into place
+
+	// VACUUM INTO only writes to a file that is missing or empty, which the
+	// temp file still is.
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, snapshot); err != nil {
+		return fmt.Errorf("could not snapshot database: %w", err)
+	}
+
+	if compress {
+		compressed, err := tempFile(filepath.Dir(path), ".theia-backup-*.gz")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(compressed) //nolint:errcheck // gone already once renamed into place
+		if err := gzipFile(snapshot, compressed); err != nil {
+			return err
+		}
+		snapshot = compressed
+	}
+
+	if err := os.Rename(snapshot, path); err != nil {
+		return fmt.Errorf("could not move backup into place: %w", err)
+	}
+	return nil
+}
+
+// BackupName is the file name of a backup taken at now in a backup
+// directory.
+func BackupName(now time.Time, compress bool) string {
+	name := backupPrefix + now.UTC().Format(backupTimeLayout) + ".db"
+	if compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// PruneBackups deletes all but the keep newest backups BackupName named in
+// dir, and returns the paths it deleted.
+func PruneBackups(dir string, keep int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list backups: %w", err)
+	}
+
+	var backups []string
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), backupPrefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ".db")
+		if _, err := time.Parse(backupTimeLayout, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, e.Name())
+	}
+	if len(backups) <= keep {
+		return nil, nil
+	}
+
+	slices.Sort(backups)
+	var pruned []string
+	for _, name := range backups[:len(backups)-keep] {
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil {
+			return pruned, fmt.Errorf("could not delete old backup: %w", err)
+		}
+		pruned = append(pruned, path)
+	}
+	return pruned, nil
+}
+
+// Restore replaces the database at dbPath with the backup at from, plain or
+// gzipped, and returns the backup's schema version. The backup is unpacked
+// and checked first: a backup with no theia schema, a dirty one, or one
+// from a newer theia than migrationsFS knows is refused and dbPath is left
+// alone. A database already at dbPath is checkpointed and kept beside it
+// with PreRestoreSuffix. Stop the daemon first: restoring fails when another
+// process is caught reading or writing the database, but an idle one goes
+// unnoticed and keeps writing to the file moved aside. A backup older than
+// migrationsFS is migrated up on its next open, as any database is.
+func Restore(ctx context.Context, from, dbPath string, migrationsFS embed.FS, migrationsPath string) (uint, error) {
+	restored, err := tempFile(filepath.Dir(dbPath), ".theia-restore-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(restored) //nolint:errcheck // gone already once renamed into place
+
+	if err := unpackBackup(from, restored); err != nil {
+		return 0, err
+	}
+	version, err := checkBackup(ctx, restored, migrationsFS, migrationsPath)
+	if err != nil {
+		return 0, err
+	}
+
+	release, err := AcquireMigrationLock(dbPath)
+	if err != nil {
+		return 0, fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer release() //nolint:errcheck // release error is not actionable here
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := checkpointForRestore(ctx, dbPath); err != nil {
+			return 0, err
+		}
+		if err := os.Rename(dbPath, dbPath+PreRestoreSuffix); err != nil {
+			return 0, fmt.Errorf("could not keep current database: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("could not check current database: %w", err)
+	}
+
+	// A WAL left beside dbPath belongs to the database just moved away;
+	// SQLite would replay it into the restored one.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, fmt.Errorf("could not remove old %s file: %w", suffix, err)
+		}
+	}
+	if err := os.Rename(restored, dbPath); err != nil {
+		return 0, fmt.Errorf("could not move restored database into place: %w", err)
+	}
+	return version, nil
+}
+
+// checkBackup opens the unpacked backup at path and returns its schema
+// version, or why it can't be restored.
+func checkBackup(ctx context.Context, path string, migrationsFS embed.FS, migrationsPath string) (uint, error) {
+	latest, err := LatestVersion(migrationsFS, migrationsPath)
+	if err != nil {
+		return 0, err
+	}
+
+	db, err := Open(ctx, path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open backup: %w", err)
+	}
+	defer Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	version, dirty, err := GetCurrentVersion(db, migrationsFS, migrationsPath)
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		return 0, errors.New("backup has no theia schema")
+	case err != nil:
+		return 0, fmt.Errorf("could not read backup schema version: %w", err)
+	case dirty:
+		return 0, fmt.Errorf("backup schema version %d is dirty: a migration failed before it was taken", version)
+	case version > latest:
+		return 0, fmt.Errorf("backup schema version %d is newer than this theia's %d: restore it with a newer theia", version, latest)
+	}
+	return version, nil
+}
+
+// checkpointForRestore folds dbPath's WAL into the file itself so the copy
+// Restore keeps is complete, and fails if another connection is reading or
+// writing it meanwhile.
+func checkpointForRestore(ctx context.Context, dbPath string) error {
+	db, err := Open(ctx, dbPath)
+	if err != nil {
+		return fmt.Errorf("could not open current database: %w", err)
+	}
+	defer Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	var busy, logPages, checkpointed int
+	if err := db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logPages, &checkpointed); err != nil {
+		return fmt.Errorf("could not checkpoint current database: %w", err)
+	}
+	if busy != 0 {
+		return errors.New("current database is in use: stop the daemon and anything else using it, then restore again")
+	}
+	return nil
+}
+
+// unpackBackup copies the backup at from to the empty file at to,
+// gunzipping it on the way when it's compressed.
+func unpackBackup(from, to string) error {
+	in, err := os.Open(from) //nolint:gosec // from is the operator-provided backup path
+	if err != nil {
+		return fmt.Errorf("could not open backup: %w", err)
+	}
+	defer in.Close() //nolint:errcheck // read-only file
+
+	r := bufio.NewReader(in)
+	var src io.Reader = r
+	if magic, _ := r.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("could not read compressed backup: %w", err)
+		}
+		defer zr.Close() //nolint:errcheck // close error is also returned by the last read
+		src = zr
+	}
+	return writeFile(to, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+// gzipFile compresses the file at from into the empty file at to.
+func gzipFile(from, to string) error {
+	in, err := os.Open(from) //nolint:gosec // from is a temp file Backup created
+	if err != nil {
+		return fmt.Errorf("could not open snapshot: %w", err)
+	}
+	defer in.Close() //nolint:errcheck // read-only file
+
+	return writeFile(to, func(w io.Writer) error {
+		zw := gzip.NewWriter(w)
+		if _, err := io.Copy(zw, in); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// writeFile fills the file at path with fill and syncs it to disk.
+func writeFile(path string, fill func(w io.Writer) error) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0) //nolint:gosec // path is a temp file created beside the destination
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	if err := fill(out); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("could not sync %s: %w", path, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("could not close %s: %w", path, err)
+	}
+	return nil
+}
+
+// tempFile creates an empty file matching pattern in dir, so it can be
+// renamed over its destination atomically, and returns its path.
+func tempFile(dir, pattern string) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("could not create temp file in %s: %w", dir, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("could not create temp file in %s: %w", dir, err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,165 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func openMigratedDB(t *testing.T, dbPath string) *sql.DB {
+	t.Helper()
+	db, err := database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		database.Close(db) //nolint:errcheck // cleanup only, test already failed
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	return db
+}
+
+func pageViews(t *testing.T, dbPath string) int {
+	t.Helper()
+	db, err := database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", dbPath, err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	var views int
+	if err := db.QueryRowContext(t.Context(), `SELECT COALESCE(SUM(page_views), 0) FROM hourly_stats`).Scan(&views); err != nil {
+		t.Fatalf("Failed to sum page views in %s: %v", dbPath, err)
+	}
+	return views
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		name := "plain"
+		if compress {
+			name = "gzip"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			dbPath := filepath.Join(dir, "theia.db")
+			db := openMigratedDB(t, dbPath)
+			if _, err := db.ExecContext(t.Context(), `INSERT INTO hourly_stats (bucket, path, host, page_views) VALUES (1, '/', 'example.com', 3)`); err != nil {
+				t.Fatalf("Failed to seed hourly_stats: %v", err)
+			}
+
+			backup := filepath.Join(dir, "backup.db")
+			if err := database.Backup(t.Context(), db, backup, compress); err != nil {
+				t.Fatalf("Backup: %v", err)
+			}
+			// Written after the backup, so restoring must drop it.
+			if _, err := db.ExecContext(t.Context(), `INSERT INTO hourly_stats (bucket, path, host, page_views) VALUES (2, '/', 'example.com', 4)`); err != nil {
+				t.Fatalf("Failed to seed hourly_stats: %v", err)
+			}
+			if err := database.Close(db); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			version, err := database.Restore(t.Context(), backup, dbPath, database.MigrationsFS, database.MigrationsPath)
+			if err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			latest, err := database.LatestVersion(database.MigrationsFS, database.MigrationsPath)
+			if err != nil {
+				t.Fatalf("LatestVersion: %v", err)
+			}
+			if version != latest {
+				t.Errorf("Restore version = %d, want %d", version, latest)
+			}
+			if got := pageViews(t, dbPath); got != 3 {
+				t.Errorf("restored page views = %d, want 3", got)
+			}
+			if got := pageViews(t, dbPath+database.PreRestoreSuffix); got != 7 {
+				t.Errorf("pre-restore page views = %d, want 7", got)
+			}
+		})
+	}
+}
+
+func TestRestoreRefusesUnusableBackups(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		prepare func(ctx context.Context, t *testing.T, path string)
+		wantErr string
+	}{
+		{
+			name: "newer schema",
+			prepare: func(ctx context.Context, t *testing.T, path string) {
+				db := openMigratedDB(t, path)
+				defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+				if _, err := db.ExecContext(ctx, `UPDATE schema_migrations SET version = 999`); err != nil {
+					t.Fatalf("Failed to bump schema version: %v", err)
+				}
+			},
+			wantErr: "backup schema version 999 is newer",
+		},
+		{
+			name: "no schema",
+			prepare: func(ctx context.Context, t *testing.T, path string) {
+				db, err := database.Open(ctx, path)
+				if err != nil {
+					t.Fatalf("Failed to open backup: %v", err)
+				}
+				database.Close(db) //nolint:errcheck // empty database, nothing to flush
+			},
+			wantErr: "backup has no theia schema",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			backup := filepath.Join(dir, "backup.db")
+			tt.prepare(t.Context(), t, backup)
+			dbPath := filepath.Join(dir, "theia.db")
+			database.Close(openMigratedDB(t, dbPath)) //nolint:errcheck // only the file is needed
+
+			_, err := database.Restore(t.Context(), backup, dbPath, database.MigrationsFS, database.MigrationsPath)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Restore error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(dbPath + database.PreRestoreSuffix); !os.IsNotExist(err) {
+				t.Errorf("refused restore moved the current database aside (stat: %v)", err)
+			}
+		})
+	}
+}
+
+func TestPruneBackupsKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, time.January, 2, 3, 0, 0, 0, time.UTC)
+	var names []string
+	for i := range 4 {
+		name := database.BackupName(start.Add(time.Duration(i)*time.Hour), i%2 == 0)
+		names = append(names, name)
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "theia-notes.txt"), nil, 0o600); err != nil {
+		t.Fatalf("Failed to write unrelated file: %v", err)
+	}
+
+	pruned, err := database.PruneBackups(dir, 2)
+	if err != nil {
+		t.Fatalf("PruneBackups: %v", err)
+	}
+	if len(pruned) != 2 || filepath.Base(pruned[0]) != names[0] || filepath.Base(pruned[1]) != names[1] {
+		t.Errorf("PruneBackups deleted %v, want the two oldest of %v", pruned, names)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("%d files left, want the 2 newest backups and the unrelated file", len(entries))
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CheckIntegrity runs SQLite's integrity_check and foreign_key_check on db
+// and returns what they found wrong, nothing when the file is sound.
+func CheckIntegrity(ctx context.Context, db *sql.DB) ([]string, error) {
+	problems, err := pragmaRows(ctx, db, `PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("could not check integrity: %w", err)
+	}
+	if len(problems) == 1 && problems[0] == "ok" {
+		problems = nil
+	}
+
+	rows, err := db.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, fmt.Errorf("could not check foreign keys: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+	for rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int64
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return nil, fmt.Errorf("could not check foreign keys: %w", err)
+		}
+		problems = append(problems, fmt.Sprintf("%s row %d references a missing %s row", table, rowid.Int64, parent))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not check foreign keys: %w", err)
+	}
+	return problems, nil
+}
+
+// pragmaRows runs a pragma that returns one text column and collects it.
+func pragmaRows(ctx context.Context, db *sql.DB, pragma string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
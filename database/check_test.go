@@ -0,0 +1,47 @@
+package database_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "check.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	problems, err := database.CheckIntegrity(t.Context(), db)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("problems on a fresh database = %v, want none", problems)
+	}
+
+	// Foreign keys aren't enforced on write, so a dangling reference goes in
+	// and only the check finds it.
+	for _, stmt := range []string{
+		`CREATE TABLE parent (id INTEGER PRIMARY KEY)`,
+		`CREATE TABLE child (parent_id INTEGER REFERENCES parent(id))`,
+		`INSERT INTO child (parent_id) VALUES (7)`,
+	} {
+		if _, err := db.ExecContext(t.Context(), stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	problems, err = database.CheckIntegrity(t.Context(), db)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "child row 1 references a missing parent row") {
+		t.Errorf("problems = %v, want the dangling child row", problems)
+	}
+}
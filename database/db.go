@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -42,7 +43,7 @@ func Open(ctx context.Context, dbPath string) (*sql.DB, error) {
 	if err := pingWithBusyRetry(ctx, db, busyRetryTimeout); err != nil {
 		closeErr := db.Close()
 		if closeErr != nil {
-			return nil, fmt.Errorf("could not close failing connection to database: %w", err)
+			return nil, fmt.Errorf("could not connect to database: %w (and closing the connection failed: %w)", err, closeErr)
 		}
 		return nil, fmt.Errorf("could not connect to database: %w", err)
 	}
@@ -110,6 +111,41 @@ func checkParentDir(dbPath string) error {
 	return nil
 }
 
+// OpenReadOnly opens the existing database at dbPath for reading only: SQLite
+// refuses writes on it (mode=ro) and so does every connection (query_only),
+// so nothing read through it can change the schema or the data, and it
+// works on a copy or backup of a live database as well as on the live one.
+// Unlike Open it neither creates a missing database nor switches it to WAL.
+func OpenReadOnly(ctx context.Context, dbPath string) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no database at %s: %w", dbPath, err)
+	}
+
+	// A relative path would read as the URI's host.
+	path, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %s: %w", dbPath, err)
+	}
+
+	// The file: URI is handed to SQLite whole, which reads mode=ro off it;
+	// the driver applies the _pragma params to every connection it opens.
+	dsn := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro&_pragma=busy_timeout(5000)&_pragma=query_only(1)"}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+
+	if err := pingWithBusyRetry(ctx, db, busyRetryTimeout); err != nil {
+		closeErr := db.Close()
+		if closeErr != nil {
+			return nil, fmt.Errorf("could not connect to database: %w (and closing the connection failed: %w)", err, closeErr)
+		}
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	return db, nil
+}
+
 func Close(db *sql.DB) error {
 	if err := db.Close(); err != nil {
 		return fmt.Errorf("error closing database: %w", err)
@@ -91,3 +91,79 @@ func TestOpenConcurrentSameDBPath(t *testing.T) {
 		t.Errorf("opener %d failed unexpectedly: %v", i, err)
 	}
 }
+
+func TestOpenReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ro.db")
+	writer, err := database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer database.Close(writer) //nolint:errcheck // close error in defer is not actionable
+	if err := database.RunMigrations(writer, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+
+	reader, err := database.OpenReadOnly(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer database.Close(reader) //nolint:errcheck // close error in defer is not actionable
+
+	if err := database.CheckSchemaVersion(t.Context(), reader, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Errorf("CheckSchemaVersion on a migrated database: %v", err)
+	}
+	if _, err := reader.ExecContext(t.Context(), `DELETE FROM hourly_stats`); err == nil {
+		t.Error("write through a read-only database succeeded, want an error")
+	}
+
+	// The daemon keeps writing while a reader has the database open.
+	if _, err := writer.ExecContext(t.Context(),
+		`INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (1, '/', 'example.com', 3, 0, 0)`); err != nil {
+		t.Fatalf("write beside a reader: %v", err)
+	}
+	var views int
+	if err := reader.QueryRowContext(t.Context(), `SELECT page_views FROM hourly_stats`).Scan(&views); err != nil || views != 3 {
+		t.Errorf("read after write = %d, %v; want 3", views, err)
+	}
+
+	t.Chdir(filepath.Dir(dbPath))
+	relative, err := database.OpenReadOnly(t.Context(), "ro.db")
+	if err != nil {
+		t.Fatalf("OpenReadOnly of a relative path: %v", err)
+	}
+	database.Close(relative) //nolint:errcheck // only opening it is under test
+
+	if _, err := database.OpenReadOnly(t.Context(), filepath.Join(t.TempDir(), "missing.db")); err == nil || !strings.Contains(err.Error(), "no database at") {
+		t.Errorf("OpenReadOnly of a missing file = %v, want a no database error", err)
+	}
+}
+
+func TestCheckSchemaVersionRefusesOtherVersions(t *testing.T) {
+	for _, tt := range []struct {
+		name, update, want string
+	}{
+		{"older", `UPDATE schema_migrations SET version = version - 1`, "is older than"},
+		{"newer", `UPDATE schema_migrations SET version = version + 2`, "is newer than"},
+		{"dirty", `UPDATE schema_migrations SET dirty = 1`, "schema is dirty"},
+		{"none", `DELETE FROM schema_migrations`, "not a theia database"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+			if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+				t.Fatalf("run migrations: %v", err)
+			}
+			if _, err := db.ExecContext(t.Context(), tt.update); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+
+			err = database.CheckSchemaVersion(t.Context(), db, database.MigrationsFS, database.MigrationsPath)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckSchemaVersion = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,34 +1,83 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"slices"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite"
-	_ "github.com/golang-migrate/migrate/v4/source/file" // registers the file:// source driver used by migrations_test.go's file://-based migrate instance
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
-func RunMigrations(db *sql.DB, migrationsFS embed.FS, migrationsPath string) error {
-	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+// ErrDirty is returned for a schema a migration failed partway through.
+var ErrDirty = errors.New("schema is dirty")
+
+// Migration is one schema change theia carries, named after its file.
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// SchemaStatus is where a database's schema stands against the migrations
+// theia carries. Version is 0 before the first migration runs.
+type SchemaStatus struct {
+	Version    uint
+	Dirty      bool
+	Migrations []Migration
+}
+
+// noTxMarker opens a migration file that can't run inside the transaction
+// golang-migrate wraps each migration in, such as one that VACUUMs.
+const noTxMarker = "-- theia:no-transaction"
+
+// newMigrate builds a migrator for db over migrationsFS. noTxWrap runs each
+// migration as it is instead of in a transaction; migrateTo picks it for
+// the files that start with noTxMarker.
+func newMigrate(db *sql.DB, migrationsFS embed.FS, migrationsPath string, noTxWrap bool) (*migrate.Migrate, error) {
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{NoTxWrap: noTxWrap})
 	if err != nil {
-		return fmt.Errorf("could not create migration driver: %w", err)
+		return nil, fmt.Errorf("could not create migration driver: %w", err)
 	}
 
 	sourceDriver, err := iofs.New(migrationsFS, migrationsPath)
 	if err != nil {
-		return fmt.Errorf("could not create iofs source: %w", err)
+		return nil, fmt.Errorf("could not create iofs source: %w", err)
 	}
 
 	m, err := migrate.NewWithInstance("iofs", sourceDriver, "sqlite", driver)
 	if err != nil {
-		return fmt.Errorf("could not create migrate instance: %w", err)
+		return nil, fmt.Errorf("could not create migrate instance: %w", err)
 	}
+	return m, nil
+}
+
+// DirtyError explains a schema left dirty at version and how to recover it.
+func DirtyError(version uint) error {
+	return fmt.Errorf("%w at version %d: a migration failed partway. Repair the schema by hand, mark the version it now matches with `theia db force`, then run `theia db migrate` (`theia db status` shows where it stands)", ErrDirty, version)
+}
+
+// migrateErr swaps golang-migrate's dirty error for dirtyError.
+func migrateErr(err error) error {
+	var dirty migrate.ErrDirty
+	if errors.As(err, &dirty) {
+		return DirtyError(uint(dirty.Version)) //nolint:gosec // G115: a dirty version is never the negative nil version
+	}
+	return err
+}
 
-	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+func RunMigrations(db *sql.DB, migrationsFS embed.FS, migrationsPath string) error {
+	latest, err := LatestVersion(migrationsFS, migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	if err := migrateTo(db, migrationsFS, migrationsPath, latest); err != nil {
 		return fmt.Errorf("could not run migrations: %w", err)
 	}
 
@@ -36,25 +85,259 @@ func RunMigrations(db *sql.DB, migrationsFS embed.FS, migrationsPath string) err
 }
 
 func GetCurrentVersion(db *sql.DB, migrationsFS embed.FS, migrationsPath string) (uint, bool, error) {
-	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	m, err := newMigrate(db, migrationsFS, migrationsPath, false)
+	if err != nil {
+		return 0, false, err
+	}
+
+	version, dirty, err := m.Version()
 	if err != nil {
-		return 0, false, fmt.Errorf("could not create migration driver: %w", err)
+		return 0, false, fmt.Errorf("could not get migration version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// CheckSchemaVersion refuses a database whose schema isn't the one
+// migrationsFS brings it to, for a reader that can't migrate it: one with
+// no theia schema, a dirty one, or one older or newer than migrationsFS. It
+// reads schema_migrations directly, as golang-migrate's driver writes to it
+// on setup.
+func CheckSchemaVersion(ctx context.Context, db *sql.DB, migrationsFS embed.FS, migrationsPath string) error {
+	latest, err := LatestVersion(migrationsFS, migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	var version uint
+	var dirty bool
+	err = db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations`).Scan(&version, &dirty)
+	switch {
+	case err != nil:
+		return fmt.Errorf("not a theia database, or one no theia has opened yet: %w", err)
+	case dirty:
+		return DirtyError(version)
+	case version < latest:
+		return fmt.Errorf("schema version %d is older than this theia's %d: restart the daemon or run `theia db migrate` to upgrade it", version, latest)
+	case version > latest:
+		return fmt.Errorf("schema version %d is newer than this theia's %d: read it with a newer theia", version, latest)
+	}
+	return nil
+}
+
+// GetSchemaStatus reads db's schema version and lists the migrations in
+// migrationsFS.
+func GetSchemaStatus(db *sql.DB, migrationsFS embed.FS, migrationsPath string) (SchemaStatus, error) {
+	migrations, err := ListMigrations(migrationsFS, migrationsPath)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	version, dirty, err := GetCurrentVersion(db, migrationsFS, migrationsPath)
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return SchemaStatus{}, err
+	}
+	return SchemaStatus{Version: version, Dirty: dirty, Migrations: migrations}, nil
+}
+
+// MigrateTo runs the up or down migrations that take db's schema to
+// version; version 0 runs every down migration, leaving no theia schema.
+// A dirty schema is refused with ErrDirty.
+func MigrateTo(db *sql.DB, migrationsFS embed.FS, migrationsPath string, version uint) error {
+	if version != 0 {
+		if err := checkVersion(migrationsFS, migrationsPath, version); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateTo(db, migrationsFS, migrationsPath, version); err != nil {
+		return fmt.Errorf("could not migrate to version %d: %w", version, err)
+	}
+	return nil
+}
+
+// Rollback runs the down migration of db's newest applied migration and
+// returns the version it leaves, 0 once none is left. A dirty schema is
+// refused with ErrDirty.
+func Rollback(db *sql.DB, migrationsFS embed.FS, migrationsPath string) (uint, error) {
+	status, err := GetSchemaStatus(db, migrationsFS, migrationsPath)
+	if err != nil {
+		return 0, err
+	}
+	if status.Dirty {
+		return 0, DirtyError(status.Version)
+	}
+	if status.Version == 0 {
+		return 0, errors.New("no migration to roll back")
+	}
+	i := slices.IndexFunc(status.Migrations, func(m Migration) bool { return m.Version == status.Version })
+	if i < 0 {
+		return 0, fmt.Errorf("could not roll back: no migration has version %d", status.Version)
 	}
 
+	var previous uint
+	if i > 0 {
+		previous = status.Migrations[i-1].Version
+	}
+	if err := migrateTo(db, migrationsFS, migrationsPath, previous); err != nil {
+		return 0, fmt.Errorf("could not roll back: %w", err)
+	}
+	return previous, nil
+}
+
+// migrateTo takes db's schema to target (0 for none) one migration at a
+// time, so each runs in a transaction unless its file starts with
+// noTxMarker. A dirty schema is refused with ErrDirty.
+func migrateTo(db *sql.DB, migrationsFS embed.FS, migrationsPath string, target uint) error {
+	migrations, err := ListMigrations(migrationsFS, migrationsPath)
+	if err != nil {
+		return err
+	}
+	wrapped, err := newMigrate(db, migrationsFS, migrationsPath, false)
+	if err != nil {
+		return err
+	}
+	bare, err := newMigrate(db, migrationsFS, migrationsPath, true)
+	if err != nil {
+		return err
+	}
+
+	for {
+		version, dirty, err := wrapped.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			version, err = 0, nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not get migration version: %w", err)
+		}
+		if dirty {
+			return DirtyError(version)
+		}
+		if version == target {
+			return nil
+		}
+
+		// i is the applied migration's index, -1 before the first.
+		i := slices.IndexFunc(migrations, func(m Migration) bool { return m.Version == version })
+		if i < 0 && version != 0 {
+			return fmt.Errorf("no migration has version %d, the schema's", version)
+		}
+		up := version < target
+		next, steps := i, -1
+		if up {
+			next, steps = i+1, 1
+		}
+
+		noTx, err := runsWithoutTx(migrationsFS, migrationsPath, migrations[next].Version, up)
+		if err != nil {
+			return err
+		}
+		m := wrapped
+		if noTx {
+			m = bare
+		}
+		if err := m.Steps(steps); err != nil {
+			return migrateErr(err)
+		}
+	}
+}
+
+// runsWithoutTx reports whether the up or down file of the migration at
+// version opts out of the transaction with noTxMarker.
+func runsWithoutTx(migrationsFS embed.FS, migrationsPath string, version uint, up bool) (bool, error) {
 	sourceDriver, err := iofs.New(migrationsFS, migrationsPath)
 	if err != nil {
-		return 0, false, fmt.Errorf("could not create iofs source: %w", err)
+		return false, fmt.Errorf("could not create iofs source: %w", err)
 	}
+	defer sourceDriver.Close() //nolint:errcheck // an embed.FS source has nothing to release
 
-	m, err := migrate.NewWithInstance("iofs", sourceDriver, "sqlite", driver)
+	read := sourceDriver.ReadDown
+	if up {
+		read = sourceDriver.ReadUp
+	}
+	r, _, err := read(version)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil // no down file: nothing runs
+	}
 	if err != nil {
-		return 0, false, fmt.Errorf("could not create migrate instance: %w", err)
+		return false, fmt.Errorf("could not read migration %d: %w", version, err)
 	}
+	defer r.Close() //nolint:errcheck // an embed.FS file has nothing to release
 
-	version, dirty, err := m.Version()
+	head := make([]byte, len(noTxMarker))
+	if _, err := io.ReadFull(r, head); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, fmt.Errorf("could not read migration %d: %w", version, err)
+	}
+	return string(head) == noTxMarker, nil
+}
+
+// ForceVersion records db's schema as being at version and clean, without
+// running any migration: the way out of a dirty schema once it's been
+// repaired by hand.
+func ForceVersion(db *sql.DB, migrationsFS embed.FS, migrationsPath string, version uint) error {
+	if err := checkVersion(migrationsFS, migrationsPath, version); err != nil {
+		return err
+	}
+	m, err := newMigrate(db, migrationsFS, migrationsPath, false)
 	if err != nil {
-		return 0, false, fmt.Errorf("could not get migration version: %w", err)
+		return err
 	}
 
-	return version, dirty, nil
+	if err := m.Force(int(version)); err != nil { //nolint:gosec // G115: checkVersion bounds version to a migration's
+		return fmt.Errorf("could not force version %d: %w", version, err)
+	}
+	return nil
+}
+
+// checkVersion refuses a version no migration in migrationsFS has.
+func checkVersion(migrationsFS embed.FS, migrationsPath string, version uint) error {
+	migrations, err := ListMigrations(migrationsFS, migrationsPath)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == version }) {
+		return fmt.Errorf("no migration has version %d: they run from %d to %d", version, migrations[0].Version, migrations[len(migrations)-1].Version)
+	}
+	return nil
+}
+
+// ListMigrations lists the migrations in migrationsFS, oldest first.
+func ListMigrations(migrationsFS embed.FS, migrationsPath string) ([]Migration, error) {
+	sourceDriver, err := iofs.New(migrationsFS, migrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not create iofs source: %w", err)
+	}
+	defer sourceDriver.Close() //nolint:errcheck // an embed.FS source has nothing to release
+
+	version, err := sourceDriver.First()
+	if err != nil {
+		return nil, fmt.Errorf("could not read first migration: %w", err)
+	}
+	var migrations []Migration
+	for {
+		r, name, err := sourceDriver.ReadUp(version)
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %d: %w", version, err)
+		}
+		_ = r.Close() // only the name is needed
+		migrations = append(migrations, Migration{Version: version, Name: name})
+
+		next, err := sourceDriver.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return migrations, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration after %d: %w", version, err)
+		}
+		version = next
+	}
+}
+
+// LatestVersion is the newest schema version in migrationsFS, the one
+// RunMigrations brings a database up to.
+func LatestVersion(migrationsFS embed.FS, migrationsPath string) (uint, error) {
+	migrations, err := ListMigrations(migrationsFS, migrationsPath)
+	if err != nil {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
 }
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS goal_visitor_days;
+DROP TABLE IF EXISTS hourly_goals;
+DROP TABLE IF EXISTS goals;
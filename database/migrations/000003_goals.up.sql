@@ -0,0 +1,27 @@
+CREATE TABLE goals (
+	name TEXT PRIMARY KEY,
+	method TEXT NOT NULL DEFAULT '',
+	path_pattern TEXT NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME
+);
+
+CREATE TABLE hourly_goals (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	host TEXT,
+	goal TEXT,
+	completions INTEGER DEFAULT 0,
+	unique_visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, host, goal)
+);
+
+CREATE TABLE goal_visitor_days (
+	goal TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	host TEXT NOT NULL,
+	year INTEGER NOT NULL,
+	year_day INTEGER NOT NULL,
+	PRIMARY KEY (goal, hash, host, year, year_day)
+);
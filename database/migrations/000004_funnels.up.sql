@@ -0,0 +1,21 @@
+CREATE TABLE funnels (
+	name TEXT PRIMARY KEY,
+	created_at DATETIME
+);
+
+CREATE TABLE funnel_steps (
+	funnel TEXT NOT NULL,
+	step INTEGER NOT NULL,
+	path_pattern TEXT NOT NULL,
+	PRIMARY KEY (funnel, step)
+);
+
+CREATE TABLE daily_funnel_steps (
+	year INTEGER,
+	year_day INTEGER,
+	host TEXT,
+	funnel TEXT,
+	step INTEGER,
+	visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (year, year_day, host, funnel, step)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE hourly_parse_failures (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	reason TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, reason)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE hourly_scans (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	host TEXT,
+	signature TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, host, signature)
+);
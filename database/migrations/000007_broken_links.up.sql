@@ -0,0 +1,11 @@
+CREATE TABLE hourly_broken_links (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	path TEXT,
+	host TEXT,
+	referrer TEXT,
+	status_code INTEGER,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, path, host, referrer, status_code)
+);
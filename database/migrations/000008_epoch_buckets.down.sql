@@ -0,0 +1,210 @@
+CREATE TABLE hourly_stats_old (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	path TEXT,
+	host TEXT,
+	page_views INTEGER DEFAULT 0,
+	is_static INTEGER DEFAULT 0,
+	bot_views INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, path, host)
+);
+
+INSERT INTO hourly_stats_old (hour, year_day, year, path, host, page_views, is_static, bot_views)
+SELECT
+	CAST(strftime('%H', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%Y', bucket * 3600, 'unixepoch') AS INTEGER),
+	path, host, page_views, is_static, bot_views
+FROM hourly_stats;
+
+DROP TABLE hourly_stats;
+ALTER TABLE hourly_stats_old RENAME TO hourly_stats;
+
+CREATE TABLE hourly_status_codes_old (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	path TEXT,
+	host TEXT,
+	status_code INTEGER,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, path, host, status_code)
+);
+
+INSERT INTO hourly_status_codes_old (hour, year_day, year, path, host, status_code, count)
+SELECT
+	CAST(strftime('%H', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%Y', bucket * 3600, 'unixepoch') AS INTEGER),
+	path, host, status_code, count
+FROM hourly_status_codes;
+
+DROP TABLE hourly_status_codes;
+ALTER TABLE hourly_status_codes_old RENAME TO hourly_status_codes;
+
+CREATE TABLE hourly_referrers_old (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	path TEXT,
+	host TEXT,
+	referrer TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, path, host, referrer)
+);
+
+INSERT INTO hourly_referrers_old (hour, year_day, year, path, host, referrer, count)
+SELECT
+	CAST(strftime('%H', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%Y', bucket * 3600, 'unixepoch') AS INTEGER),
+	path, host, referrer, count
+FROM hourly_referrers;
+
+DROP TABLE hourly_referrers;
+ALTER TABLE hourly_referrers_old RENAME TO hourly_referrers;
+
+CREATE TABLE hourly_goals_old (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	host TEXT,
+	goal TEXT,
+	completions INTEGER DEFAULT 0,
+	unique_visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, host, goal)
+);
+
+INSERT INTO hourly_goals_old (hour, year_day, year, host, goal, completions, unique_visitors)
+SELECT
+	CAST(strftime('%H', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%Y', bucket * 3600, 'unixepoch') AS INTEGER),
+	host, goal, completions, unique_visitors
+FROM hourly_goals;
+
+DROP TABLE hourly_goals;
+ALTER TABLE hourly_goals_old RENAME TO hourly_goals;
+
+CREATE TABLE hourly_parse_failures_old (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	reason TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, reason)
+);
+
+INSERT INTO hourly_parse_failures_old (hour, year_day, year, reason, count)
+SELECT
+	CAST(strftime('%H', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%Y', bucket * 3600, 'unixepoch') AS INTEGER),
+	reason, count
+FROM hourly_parse_failures;
+
+DROP TABLE hourly_parse_failures;
+ALTER TABLE hourly_parse_failures_old RENAME TO hourly_parse_failures;
+
+CREATE TABLE hourly_scans_old (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	host TEXT,
+	signature TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, host, signature)
+);
+
+INSERT INTO hourly_scans_old (hour, year_day, year, host, signature, count)
+SELECT
+	CAST(strftime('%H', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%Y', bucket * 3600, 'unixepoch') AS INTEGER),
+	host, signature, count
+FROM hourly_scans;
+
+DROP TABLE hourly_scans;
+ALTER TABLE hourly_scans_old RENAME TO hourly_scans;
+
+CREATE TABLE hourly_broken_links_old (
+	hour INTEGER,
+	year_day INTEGER,
+	year INTEGER,
+	path TEXT,
+	host TEXT,
+	referrer TEXT,
+	status_code INTEGER,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (hour, year_day, year, path, host, referrer, status_code)
+);
+
+INSERT INTO hourly_broken_links_old (hour, year_day, year, path, host, referrer, status_code, count)
+SELECT
+	CAST(strftime('%H', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', bucket * 3600, 'unixepoch') AS INTEGER),
+	CAST(strftime('%Y', bucket * 3600, 'unixepoch') AS INTEGER),
+	path, host, referrer, status_code, count
+FROM hourly_broken_links;
+
+DROP TABLE hourly_broken_links;
+ALTER TABLE hourly_broken_links_old RENAME TO hourly_broken_links;
+
+CREATE TABLE visitor_days_old (
+	hash TEXT NOT NULL,
+	host TEXT NOT NULL,
+	year INTEGER NOT NULL,
+	year_day INTEGER NOT NULL,
+	first_seen DATETIME,
+	PRIMARY KEY (hash, host, year, year_day)
+);
+
+INSERT INTO visitor_days_old (hash, host, first_seen, year, year_day)
+SELECT
+	hash, host, first_seen,
+	CAST(strftime('%Y', day * 86400, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', day * 86400, 'unixepoch') AS INTEGER)
+FROM visitor_days;
+
+DROP TABLE visitor_days;
+ALTER TABLE visitor_days_old RENAME TO visitor_days;
+
+CREATE TABLE goal_visitor_days_old (
+	goal TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	host TEXT NOT NULL,
+	year INTEGER NOT NULL,
+	year_day INTEGER NOT NULL,
+	PRIMARY KEY (goal, hash, host, year, year_day)
+);
+
+INSERT INTO goal_visitor_days_old (goal, hash, host, year, year_day)
+SELECT
+	goal, hash, host,
+	CAST(strftime('%Y', day * 86400, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', day * 86400, 'unixepoch') AS INTEGER)
+FROM goal_visitor_days;
+
+DROP TABLE goal_visitor_days;
+ALTER TABLE goal_visitor_days_old RENAME TO goal_visitor_days;
+
+CREATE TABLE daily_funnel_steps_old (
+	year INTEGER,
+	year_day INTEGER,
+	host TEXT,
+	funnel TEXT,
+	step INTEGER,
+	visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (year, year_day, host, funnel, step)
+);
+
+INSERT INTO daily_funnel_steps_old (host, funnel, step, visitors, year, year_day)
+SELECT
+	host, funnel, step, visitors,
+	CAST(strftime('%Y', day * 86400, 'unixepoch') AS INTEGER),
+	CAST(strftime('%j', day * 86400, 'unixepoch') AS INTEGER)
+FROM daily_funnel_steps;
+
+DROP TABLE daily_funnel_steps;
+ALTER TABLE daily_funnel_steps_old RENAME TO daily_funnel_steps;
@@ -0,0 +1,180 @@
+-- Replace the (year, year_day, hour) keys with one integer bucket, UTC hours
+-- (hourly tables) or days (daily tables) since the Unix epoch, so a time
+-- window is an index range scan. Existing rows are backfilled; hours logged
+-- before UTC bucketing keep the offset nginx logged them with.
+
+CREATE TABLE hourly_stats_new (
+	bucket INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	page_views INTEGER DEFAULT 0,
+	is_static INTEGER DEFAULT 0,
+	bot_views INTEGER DEFAULT 0,
+	PRIMARY KEY (bucket, path, host)
+);
+
+INSERT INTO hourly_stats_new (bucket, path, host, page_views, is_static, bot_views)
+SELECT (CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400) * 24 + hour, path, host, page_views, is_static, bot_views
+FROM hourly_stats;
+
+DROP TABLE hourly_stats;
+ALTER TABLE hourly_stats_new RENAME TO hourly_stats;
+
+CREATE INDEX idx_hourly_stats_host_bucket ON hourly_stats (host, bucket);
+
+CREATE TABLE hourly_status_codes_new (
+	bucket INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	status_code INTEGER,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (bucket, path, host, status_code)
+);
+
+INSERT INTO hourly_status_codes_new (bucket, path, host, status_code, count)
+SELECT (CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400) * 24 + hour, path, host, status_code, count
+FROM hourly_status_codes;
+
+DROP TABLE hourly_status_codes;
+ALTER TABLE hourly_status_codes_new RENAME TO hourly_status_codes;
+
+CREATE INDEX idx_hourly_status_codes_host_bucket ON hourly_status_codes (host, bucket);
+
+CREATE TABLE hourly_referrers_new (
+	bucket INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	referrer TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (bucket, path, host, referrer)
+);
+
+INSERT INTO hourly_referrers_new (bucket, path, host, referrer, count)
+SELECT (CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400) * 24 + hour, path, host, referrer, count
+FROM hourly_referrers;
+
+DROP TABLE hourly_referrers;
+ALTER TABLE hourly_referrers_new RENAME TO hourly_referrers;
+
+CREATE INDEX idx_hourly_referrers_host_bucket ON hourly_referrers (host, bucket);
+
+CREATE TABLE hourly_goals_new (
+	bucket INTEGER NOT NULL,
+	host TEXT,
+	goal TEXT,
+	completions INTEGER DEFAULT 0,
+	unique_visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (bucket, host, goal)
+);
+
+INSERT INTO hourly_goals_new (bucket, host, goal, completions, unique_visitors)
+SELECT (CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400) * 24 + hour, host, goal, completions, unique_visitors
+FROM hourly_goals;
+
+DROP TABLE hourly_goals;
+ALTER TABLE hourly_goals_new RENAME TO hourly_goals;
+
+CREATE INDEX idx_hourly_goals_host_bucket ON hourly_goals (host, bucket);
+
+CREATE TABLE hourly_parse_failures_new (
+	bucket INTEGER NOT NULL,
+	reason TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (bucket, reason)
+);
+
+INSERT INTO hourly_parse_failures_new (bucket, reason, count)
+SELECT (CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400) * 24 + hour, reason, count
+FROM hourly_parse_failures;
+
+DROP TABLE hourly_parse_failures;
+ALTER TABLE hourly_parse_failures_new RENAME TO hourly_parse_failures;
+
+CREATE TABLE hourly_scans_new (
+	bucket INTEGER NOT NULL,
+	host TEXT,
+	signature TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (bucket, host, signature)
+);
+
+INSERT INTO hourly_scans_new (bucket, host, signature, count)
+SELECT (CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400) * 24 + hour, host, signature, count
+FROM hourly_scans;
+
+DROP TABLE hourly_scans;
+ALTER TABLE hourly_scans_new RENAME TO hourly_scans;
+
+CREATE INDEX idx_hourly_scans_host_bucket ON hourly_scans (host, bucket);
+
+CREATE TABLE hourly_broken_links_new (
+	bucket INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	referrer TEXT,
+	status_code INTEGER,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (bucket, path, host, referrer, status_code)
+);
+
+INSERT INTO hourly_broken_links_new (bucket, path, host, referrer, status_code, count)
+SELECT (CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400) * 24 + hour, path, host, referrer, status_code, count
+FROM hourly_broken_links;
+
+DROP TABLE hourly_broken_links;
+ALTER TABLE hourly_broken_links_new RENAME TO hourly_broken_links;
+
+CREATE INDEX idx_hourly_broken_links_host_bucket ON hourly_broken_links (host, bucket);
+
+CREATE TABLE visitor_days_new (
+	hash TEXT NOT NULL,
+	host TEXT NOT NULL,
+	day INTEGER NOT NULL,
+	first_seen DATETIME,
+	PRIMARY KEY (hash, host, day)
+);
+
+INSERT INTO visitor_days_new (hash, host, day, first_seen)
+SELECT hash, host, CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400, first_seen
+FROM visitor_days;
+
+DROP TABLE visitor_days;
+ALTER TABLE visitor_days_new RENAME TO visitor_days;
+
+CREATE INDEX idx_visitor_days_host_day ON visitor_days (host, day);
+CREATE INDEX idx_visitor_days_day ON visitor_days (day);
+
+CREATE TABLE goal_visitor_days_new (
+	goal TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	host TEXT NOT NULL,
+	day INTEGER NOT NULL,
+	PRIMARY KEY (goal, hash, host, day)
+);
+
+INSERT INTO goal_visitor_days_new (goal, hash, host, day)
+SELECT goal, hash, host, CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400
+FROM goal_visitor_days;
+
+DROP TABLE goal_visitor_days;
+ALTER TABLE goal_visitor_days_new RENAME TO goal_visitor_days;
+
+CREATE INDEX idx_goal_visitor_days_day ON goal_visitor_days (day);
+
+CREATE TABLE daily_funnel_steps_new (
+	day INTEGER NOT NULL,
+	host TEXT,
+	funnel TEXT,
+	step INTEGER,
+	visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (day, host, funnel, step)
+);
+
+INSERT INTO daily_funnel_steps_new (day, host, funnel, step, visitors)
+SELECT CAST(strftime('%s', printf('%04d-01-01', year), printf('+%d days', year_day - 1)) AS INTEGER) / 86400, host, funnel, step, visitors
+FROM daily_funnel_steps;
+
+DROP TABLE daily_funnel_steps;
+ALTER TABLE daily_funnel_steps_new RENAME TO daily_funnel_steps;
+
+CREATE INDEX idx_daily_funnel_steps_host_day ON daily_funnel_steps (host, day);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS monthly_visitors;
+DROP TABLE IF EXISTS monthly_referrers;
+DROP TABLE IF EXISTS monthly_status_codes;
+DROP TABLE IF EXISTS monthly_stats;
+DROP TABLE IF EXISTS daily_visitors;
+DROP TABLE IF EXISTS daily_referrers;
+DROP TABLE IF EXISTS daily_status_codes;
+DROP TABLE IF EXISTS daily_stats;
@@ -0,0 +1,83 @@
+-- Coarser tiers the periodic cleanup rolls expiring rows into instead of
+-- deleting them: hourly rows become daily ones, and daily rows later become
+-- monthly ones. day is the UTC day and month the UTC month since the Unix
+-- epoch. The *_visitors tables hold per-host unique visitor counts, taken
+-- from visitor_days before its rows are purged.
+
+CREATE TABLE daily_stats (
+	day INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	page_views INTEGER DEFAULT 0,
+	is_static INTEGER DEFAULT 0,
+	bot_views INTEGER DEFAULT 0,
+	PRIMARY KEY (day, path, host)
+);
+CREATE INDEX idx_daily_stats_host_day ON daily_stats (host, day);
+
+CREATE TABLE daily_status_codes (
+	day INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	status_code INTEGER,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (day, path, host, status_code)
+);
+CREATE INDEX idx_daily_status_codes_host_day ON daily_status_codes (host, day);
+
+CREATE TABLE daily_referrers (
+	day INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	referrer TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (day, path, host, referrer)
+);
+CREATE INDEX idx_daily_referrers_host_day ON daily_referrers (host, day);
+
+CREATE TABLE daily_visitors (
+	day INTEGER NOT NULL,
+	host TEXT NOT NULL,
+	visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (day, host)
+);
+CREATE INDEX idx_daily_visitors_host_day ON daily_visitors (host, day);
+
+CREATE TABLE monthly_stats (
+	month INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	page_views INTEGER DEFAULT 0,
+	is_static INTEGER DEFAULT 0,
+	bot_views INTEGER DEFAULT 0,
+	PRIMARY KEY (month, path, host)
+);
+CREATE INDEX idx_monthly_stats_host_month ON monthly_stats (host, month);
+
+CREATE TABLE monthly_status_codes (
+	month INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	status_code INTEGER,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (month, path, host, status_code)
+);
+CREATE INDEX idx_monthly_status_codes_host_month ON monthly_status_codes (host, month);
+
+CREATE TABLE monthly_referrers (
+	month INTEGER NOT NULL,
+	path TEXT,
+	host TEXT,
+	referrer TEXT,
+	count INTEGER DEFAULT 0,
+	PRIMARY KEY (month, path, host, referrer)
+);
+CREATE INDEX idx_monthly_referrers_host_month ON monthly_referrers (host, month);
+
+CREATE TABLE monthly_visitors (
+	month INTEGER NOT NULL,
+	host TEXT NOT NULL,
+	visitors INTEGER DEFAULT 0,
+	PRIMARY KEY (month, host)
+);
+CREATE INDEX idx_monthly_visitors_host_month ON monthly_visitors (host, month);
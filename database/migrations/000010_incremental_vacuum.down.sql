@@ -0,0 +1,4 @@
+-- theia:no-transaction
+-- See the up migration for why this runs outside a transaction.
+PRAGMA auto_vacuum = NONE;
+VACUUM;
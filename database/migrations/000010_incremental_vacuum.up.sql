@@ -0,0 +1,11 @@
+-- theia:no-transaction
+-- Switch the database to incremental auto-vacuum, so the pages the daemon's
+-- cleanup frees can be handed back to the filesystem (PRAGMA
+-- incremental_vacuum) instead of the file only ever growing.
+--
+-- An existing database only changes auto_vacuum mode when VACUUM rebuilds
+-- it, and VACUUM can't run inside a transaction, so the marker above has
+-- this migration run outside the one the others get. The VACUUM rewrites
+-- the whole file once.
+PRAGMA auto_vacuum = INCREMENTAL;
+VACUUM;
@@ -0,0 +1,8 @@
+-- merge_sources lists the databases `theia db merge` last combined into this
+-- one. A database with any is a merge target: every merge replaces its
+-- stats with the sum of its sources', so a repeat run counts nothing twice.
+CREATE TABLE merge_sources (
+	path TEXT PRIMARY KEY,
+	schema_version INTEGER NOT NULL,
+	merged_at DATETIME NOT NULL
+);
@@ -11,8 +11,6 @@ import (
 	"testing"
 
 	"github.com/Elysium-Labs-EU/theia/database"
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/sqlite"
 )
 
 //go:embed migrations/*.sql
@@ -55,7 +53,7 @@ func TestMigrations(t *testing.T) {
 	t.Log("Data insertion successful")
 
 	t.Log("Running down migration...")
-	if migrationsErr := runDownMigration(db, "migrations"); migrationsErr != nil {
+	if migrationsErr := database.MigrateTo(db, testMigrationsFS, "migrations", 0); migrationsErr != nil {
 		t.Fatalf("Failed to run down migration: %v", migrationsErr)
 	}
 	t.Log("Down migration successful")
@@ -228,37 +226,40 @@ func verifyTablesRemoved(t *testing.T, db *sql.DB) {
 	}
 }
 
+// jan1st2024Day is 2024-01-01 as UTC days since the Unix epoch.
+const jan1st2024Day = 19723
+
 func testDataInsertion(t *testing.T, db *sql.DB) {
 	t.Helper()
 
 	_, err := db.Exec(`
-		INSERT INTO visitor_days (hash, host, year, year_day, first_seen)
-		VALUES (?, ?, ?, ?, datetime('now'))
-	`, "test_hash_123", "example.com", 2024, 1)
+		INSERT INTO visitor_days (hash, host, day, first_seen)
+		VALUES (?, ?, ?, datetime('now'))
+	`, "test_hash_123", "example.com", jan1st2024Day)
 	if err != nil {
 		t.Fatalf("Failed to insert into visitor_days: %v", err)
 	}
 
 	_, err = db.Exec(`
-		INSERT INTO hourly_stats (hour, year_day, year, path, host, page_views, is_static, bot_views)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, 14, 1, 2024, "/test", "example.com", 1, 0, 0)
+		INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, jan1st2024Day*24+14, "/test", "example.com", 1, 0, 0)
 	if err != nil {
 		t.Fatalf("Failed to insert into hourly_stats: %v", err)
 	}
 
 	_, err = db.Exec(`
-		INSERT INTO hourly_status_codes (hour, year_day, year, path, host, status_code, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, 14, 1, 2024, "/test", "example.com", 200, 1)
+		INSERT INTO hourly_status_codes (bucket, path, host, status_code, count)
+		VALUES (?, ?, ?, ?, ?)
+	`, jan1st2024Day*24+14, "/test", "example.com", 200, 1)
 	if err != nil {
 		t.Fatalf("Failed to insert into hourly_status_codes: %v", err)
 	}
 
 	_, err = db.Exec(`
-		INSERT INTO hourly_referrers (hour, year_day, year, path, host, referrer, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, 14, 1, 2024, "/test", "example.com", "https://google.com", 1)
+		INSERT INTO hourly_referrers (bucket, path, host, referrer, count)
+		VALUES (?, ?, ?, ?, ?)
+	`, jan1st2024Day*24+14, "/test", "example.com", "https://google.com", 1)
 	if err != nil {
 		t.Fatalf("Failed to insert into hourly_referrers: %v", err)
 	}
@@ -273,25 +274,167 @@ func testDataInsertion(t *testing.T, db *sql.DB) {
 	}
 }
 
-func runDownMigration(db *sql.DB, migrationsPath string) error {
-	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+// TestEpochBucketMigrationBackfill checks that 000008 converts existing
+// hour/year_day/year rows into epoch buckets and that its down migration
+// converts them back, so upgrading keeps the history already collected.
+func TestEpochBucketMigrationBackfill(t *testing.T) {
+	db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "backfill.db"))
 	if err != nil {
-		return err
+		t.Fatalf("Failed to open test database: %v", err)
 	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
 
-	// Now create the migrate instance with our driver
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://"+migrationsPath,
-		"sqlite",
-		driver,
-	)
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 7); err != nil {
+		t.Fatalf("Failed to migrate to version 7: %v", err)
+	}
+
+	// 2024-12-24 is day 359 of 2024 and 20081 days after the epoch.
+	const wantDay = 20081
+	_, err = db.Exec(`
+		INSERT INTO visitor_days (hash, host, year, year_day, first_seen) VALUES ('h', 'example.com', 2024, 359, datetime('now'));
+		INSERT INTO hourly_stats (hour, year_day, year, path, host, page_views, is_static, bot_views) VALUES (10, 359, 2024, '/', 'example.com', 3, 0, 1);
+		INSERT INTO daily_funnel_steps (year, year_day, host, funnel, step, visitors) VALUES (2024, 359, 'example.com', 'checkout', 1, 7);
+	`)
 	if err != nil {
-		return err
+		t.Fatalf("Failed to seed version 7 rows: %v", err)
 	}
 
-	if err := m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return err
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 8); err != nil {
+		t.Fatalf("Failed to migrate to version 8: %v", err)
+	}
+	var day, funnelDay, hourBucket int64
+	if err := db.QueryRow(`SELECT day FROM visitor_days`).Scan(&day); err != nil {
+		t.Fatalf("Failed to read visitor_days: %v", err)
+	}
+	if err := db.QueryRow(`SELECT day FROM daily_funnel_steps`).Scan(&funnelDay); err != nil {
+		t.Fatalf("Failed to read daily_funnel_steps: %v", err)
+	}
+	if err := db.QueryRow(`SELECT bucket FROM hourly_stats`).Scan(&hourBucket); err != nil {
+		t.Fatalf("Failed to read hourly_stats: %v", err)
+	}
+	if day != wantDay || funnelDay != wantDay {
+		t.Errorf("visitor_days day = %d, daily_funnel_steps day = %d, want %d", day, funnelDay, wantDay)
+	}
+	if hourBucket != wantDay*24+10 {
+		t.Errorf("hourly_stats bucket = %d, want %d", hourBucket, wantDay*24+10)
+	}
+
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 7); err != nil {
+		t.Fatalf("Failed to migrate back to version 7: %v", err)
+	}
+	var hour, yearDay, year int
+	err = db.QueryRow(`SELECT hour, year_day, year FROM hourly_stats`).Scan(&hour, &yearDay, &year)
+	if err != nil {
+		t.Fatalf("Failed to read hourly_stats after down migration: %v", err)
+	}
+	if hour != 10 || yearDay != 359 || year != 2024 {
+		t.Errorf("hourly_stats after down migration = (%d, %d, %d), want (10, 359, 2024)", hour, yearDay, year)
+	}
+}
+
+func TestIncrementalVacuumMigration(t *testing.T) {
+	db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "vacuum.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	autoVacuum := func() int {
+		t.Helper()
+		var mode int
+		if err := db.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+			t.Fatalf("Failed to read auto_vacuum: %v", err)
+		}
+		return mode
+	}
+
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 9); err != nil {
+		t.Fatalf("Failed to migrate to version 9: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO hourly_stats (bucket, path, host, page_views) VALUES (1, '/', 'example.com', 3)`); err != nil {
+		t.Fatalf("Failed to seed hourly_stats: %v", err)
+	}
+	if mode := autoVacuum(); mode != 0 {
+		t.Fatalf("auto_vacuum before migration = %d, want 0 (none)", mode)
+	}
+
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 10); err != nil {
+		t.Fatalf("Failed to migrate to version 10: %v", err)
+	}
+	if mode := autoVacuum(); mode != 2 {
+		t.Errorf("auto_vacuum = %d, want 2 (incremental)", mode)
+	}
+	var views int
+	if err := db.QueryRow(`SELECT page_views FROM hourly_stats`).Scan(&views); err != nil || views != 3 {
+		t.Errorf("hourly_stats page_views = %d (%v), want the row kept", views, err)
 	}
 
-	return nil
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 9); err != nil {
+		t.Fatalf("Failed to migrate back to version 9: %v", err)
+	}
+	if mode := autoVacuum(); mode != 0 {
+		t.Errorf("auto_vacuum after down migration = %d, want 0 (none)", mode)
+	}
+}
+
+func TestRollbackMigrateToAndForce(t *testing.T) {
+	db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	if err := database.RunMigrations(db, testMigrationsFS, "migrations"); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	latest := getExpectedVersion(t, "migrations")
+
+	version, err := database.Rollback(db, testMigrationsFS, "migrations")
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if version != latest-1 {
+		t.Errorf("version after rollback = %d, want %d", version, latest-1)
+	}
+
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 2); err != nil {
+		t.Fatalf("MigrateTo(2): %v", err)
+	}
+	status, err := database.GetSchemaStatus(db, testMigrationsFS, "migrations")
+	if err != nil {
+		t.Fatalf("GetSchemaStatus: %v", err)
+	}
+	if status.Version != 2 || status.Dirty || uint(len(status.Migrations)) != latest {
+		t.Errorf("status = version %d dirty %v with %d migrations, want version 2 clean with %d", status.Version, status.Dirty, len(status.Migrations), latest)
+	}
+	if status.Migrations[1].Name != "visitor_days" {
+		t.Errorf("migration 2 name = %q, want visitor_days", status.Migrations[1].Name)
+	}
+
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 0); err != nil {
+		t.Fatalf("MigrateTo(0): %v", err)
+	}
+	if status, err := database.GetSchemaStatus(db, testMigrationsFS, "migrations"); err != nil || status.Version != 0 {
+		t.Fatalf("status after MigrateTo(0) = version %d, %v; want version 0", status.Version, err)
+	}
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", 2); err != nil {
+		t.Fatalf("MigrateTo(2) from 0: %v", err)
+	}
+
+	if err := database.MigrateTo(db, testMigrationsFS, "migrations", latest+1); err == nil {
+		t.Error("MigrateTo a version no migration has: want an error")
+	}
+
+	if _, err := db.ExecContext(t.Context(), `UPDATE schema_migrations SET dirty = 1`); err != nil {
+		t.Fatalf("mark dirty: %v", err)
+	}
+	if err := database.RunMigrations(db, testMigrationsFS, "migrations"); !errors.Is(err, database.ErrDirty) {
+		t.Errorf("RunMigrations on a dirty schema = %v, want ErrDirty", err)
+	}
+	if err := database.ForceVersion(db, testMigrationsFS, "migrations", 2); err != nil {
+		t.Fatalf("ForceVersion: %v", err)
+	}
+	if err := database.RunMigrations(db, testMigrationsFS, "migrations"); err != nil {
+		t.Fatalf("RunMigrations after force: %v", err)
+	}
+	verifyTablesExist(t, db)
 }
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// incrementalVacuumQuery frees at most 1024 pages (4MiB at the default page
+// size) per round. PRAGMA arguments can't be bound, so the round size is part
+// of the statement.
+const incrementalVacuumQuery = `PRAGMA incremental_vacuum(1024)`
+
+// IncrementalVacuum hands the database file's free pages back to the
+// filesystem, a round at a time so the write lock is released between
+// rounds, and returns how many bytes the file shrank by. A database without
+// auto_vacuum=INCREMENTAL doesn't shrink, so that returns 0.
+func IncrementalVacuum(ctx context.Context, db *sql.DB) (int64, error) {
+	before, err := pragmaInt(ctx, db, "PRAGMA page_count")
+	if err != nil {
+		return 0, err
+	}
+
+	free, err := pragmaInt(ctx, db, "PRAGMA freelist_count")
+	if err != nil {
+		return 0, err
+	}
+	for free > 0 {
+		// Each step of the pragma frees one page, so it has to be read to
+		// the end rather than executed.
+		rows, err := db.QueryContext(ctx, incrementalVacuumQuery)
+		if err != nil {
+			return 0, fmt.Errorf("could not vacuum free pages: %w", err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Close(); err != nil {
+			return 0, fmt.Errorf("could not vacuum free pages: %w", err)
+		}
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("could not vacuum free pages: %w", err)
+		}
+
+		left, err := pragmaInt(ctx, db, "PRAGMA freelist_count")
+		if err != nil {
+			return 0, err
+		}
+		if left >= free {
+			break
+		}
+		free = left
+	}
+
+	after, err := pragmaInt(ctx, db, "PRAGMA page_count")
+	if err != nil {
+		return 0, err
+	}
+	pageSize, err := pragmaInt(ctx, db, "PRAGMA page_size")
+	if err != nil {
+		return 0, err
+	}
+	return (before - after) * pageSize, nil
+}
+
+// Space is how the database file's pages are used.
+type Space struct {
+	PageSize  int64
+	Pages     int64
+	FreePages int64
+	// AutoVacuum is "none", "full" or "incremental".
+	AutoVacuum string
+	// Tables are listed by name, each with the pages of its indexes.
+	Tables []TableSpace
+}
+
+// TableSpace is one table's share of the database file.
+type TableSpace struct {
+	Name  string
+	Rows  int64
+	Bytes int64
+}
+
+// GetSpace reports the database file's size, its free pages and each
+// table's row count and size on disk.
+func GetSpace(ctx context.Context, db *sql.DB) (Space, error) {
+	var s Space
+	var err error
+	if s.PageSize, err = pragmaInt(ctx, db, "PRAGMA page_size"); err != nil {
+		return Space{}, err
+	}
+	if s.Pages, err = pragmaInt(ctx, db, "PRAGMA page_count"); err != nil {
+		return Space{}, err
+	}
+	if s.FreePages, err = pragmaInt(ctx, db, "PRAGMA freelist_count"); err != nil {
+		return Space{}, err
+	}
+	mode, err := pragmaInt(ctx, db, "PRAGMA auto_vacuum")
+	if err != nil {
+		return Space{}, err
+	}
+	switch mode {
+	case 1:
+		s.AutoVacuum = "full"
+	case 2:
+		s.AutoVacuum = "incremental"
+	default:
+		s.AutoVacuum = "none"
+	}
+
+	if s.Tables, err = tableSpaces(ctx, db); err != nil {
+		return Space{}, err
+	}
+	for i := range s.Tables {
+		t := &s.Tables[i]
+		// Table names come from sqlite_schema, not from input, and are
+		// quoted as identifiers.
+		query := `SELECT COUNT(*) FROM "` + strings.ReplaceAll(t.Name, `"`, `""`) + `"`
+		if err := db.QueryRowContext(ctx, query).Scan(&t.Rows); err != nil { //nolint:gosec // a quoted sqlite_schema name, not input
+			return Space{}, fmt.Errorf("could not count %s rows: %w", t.Name, err)
+		}
+	}
+	return s, nil
+}
+
+// tableSpaces sums every table's pages from the dbstat virtual table, its
+// indexes included.
+func tableSpaces(ctx context.Context, db *sql.DB) ([]TableSpace, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT s.tbl_name, COALESCE(SUM(d.pgsize), 0)
+	FROM sqlite_schema s
+	LEFT JOIN dbstat d ON d.name = s.name
+	WHERE s.type IN ('table', 'index') AND s.tbl_name NOT LIKE 'sqlite_%'
+	GROUP BY s.tbl_name
+	ORDER BY s.tbl_name`)
+	if err != nil {
+		return nil, fmt.Errorf("could not read table sizes: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	var tables []TableSpace
+	for rows.Next() {
+		var t TableSpace
+		if err := rows.Scan(&t.Name, &t.Bytes); err != nil {
+			return nil, fmt.Errorf("could not read table sizes: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read table sizes: %w", err)
+	}
+	return tables, nil
+}
+
+// pragmaInt reads a pragma whose value is a single integer.
+func pragmaInt(ctx context.Context, db *sql.DB, pragma string) (int64, error) {
+	var n int64
+	if err := db.QueryRowContext(ctx, pragma).Scan(&n); err != nil {
+		return 0, fmt.Errorf("could not read %s: %w", strings.ToLower(pragma), err)
+	}
+	return n, nil
+}
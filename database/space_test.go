@@ -0,0 +1,96 @@
+package database_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func TestIncrementalVacuumShrinksFile(t *testing.T) {
+	db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "space.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	padding := strings.Repeat("x", 500)
+	for i := range 2000 {
+		if _, err := db.ExecContext(t.Context(),
+			`INSERT INTO hourly_stats (bucket, path, host, page_views) VALUES (?, ?, 'example.com', 1)`, i, padding); err != nil {
+			t.Fatalf("Failed to seed hourly_stats: %v", err)
+		}
+	}
+	if _, err := db.ExecContext(t.Context(), `DELETE FROM hourly_stats`); err != nil {
+		t.Fatalf("Failed to delete hourly_stats: %v", err)
+	}
+
+	before, err := database.GetSpace(t.Context(), db)
+	if err != nil {
+		t.Fatalf("GetSpace: %v", err)
+	}
+	if before.FreePages == 0 {
+		t.Fatalf("FreePages after delete = 0, want some")
+	}
+
+	reclaimed, err := database.IncrementalVacuum(t.Context(), db)
+	if err != nil {
+		t.Fatalf("IncrementalVacuum: %v", err)
+	}
+	if want := before.FreePages * before.PageSize; reclaimed != want {
+		t.Errorf("IncrementalVacuum reclaimed %d bytes, want %d", reclaimed, want)
+	}
+
+	after, err := database.GetSpace(t.Context(), db)
+	if err != nil {
+		t.Fatalf("GetSpace: %v", err)
+	}
+	if after.FreePages != 0 {
+		t.Errorf("FreePages after vacuum = %d, want 0", after.FreePages)
+	}
+	if after.AutoVacuum != "incremental" {
+		t.Errorf("AutoVacuum = %q, want incremental", after.AutoVacuum)
+	}
+}
+
+func TestGetSpaceListsTables(t *testing.T) {
+	db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "space.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	for _, path := range []string{"/", "/about"} {
+		if _, err := db.ExecContext(t.Context(),
+			`INSERT INTO hourly_stats (bucket, path, host, page_views) VALUES (1, ?, 'example.com', 1)`, path); err != nil {
+			t.Fatalf("Failed to seed hourly_stats: %v", err)
+		}
+	}
+
+	space, err := database.GetSpace(t.Context(), db)
+	if err != nil {
+		t.Fatalf("GetSpace: %v", err)
+	}
+	var found bool
+	for _, table := range space.Tables {
+		if table.Name != "hourly_stats" {
+			continue
+		}
+		found = true
+		if table.Rows != 2 {
+			t.Errorf("hourly_stats Rows = %d, want 2", table.Rows)
+		}
+		if table.Bytes < space.PageSize {
+			t.Errorf("hourly_stats Bytes = %d, want at least a page", table.Bytes)
+		}
+	}
+	if !found {
+		t.Errorf("Tables = %+v, want hourly_stats among them", space.Tables)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/Elysium-Labs-EU/theia
 go 1.26.5
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/charmbracelet/lipgloss v1.1.0
 	github.com/golang-migrate/migrate/v4 v4.19.1
 	github.com/mattn/go-isatty v0.0.24
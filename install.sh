@@ -357,6 +357,21 @@ configure_nginx_multidomain() {
     cp "$nginx_conf" "$backup_file"
     success "Backup created: ${backup_file}"
 
+    # The installed binary parses nginx.conf with its includes, so it sees
+    # every access_log and whether theia can read its format. Ask it before
+    # changing anything: an access_log theia already reads is left alone.
+    local theia_bin="${INSTALL_DIR}/${BINARY_NAME}"
+    local can_inspect=false
+    local inspect_json=""
+    if [ -x "$theia_bin" ] && inspect_json=$("$theia_bin" nginx inspect --nginx-conf "$nginx_conf" --format json 2>/dev/null); then
+        can_inspect=true
+    fi
+    # Readable logs are the ones reported with a layout (omitted otherwise).
+    local has_readable_log=false
+    if [ "$can_inspect" = true ] && grep -q '"layout"' <<< "$inspect_json"; then
+        has_readable_log=true
+    fi
+
     if grep -q "log_format theia_combined" "$nginx_conf"; then
         info "log_format 'theia_combined' already exists — skipping addition"
     else
@@ -370,7 +385,9 @@ configure_nginx_multidomain() {
         success "Added log_format theia_combined to nginx.conf"
     fi
 
-    if grep -q "^\s*access_log.*;" "$nginx_conf"; then
+    if [ "$has_readable_log" = true ]; then
+        info "theia can already read an access_log nginx writes — leaving access_log as it is"
+    elif grep -q "^\s*access_log.*;" "$nginx_conf"; then
         sed -i 's|^\(\s*\)access_log\s\+[^;]*;|\1access_log /var/log/nginx/access.log theia_combined;|' "$nginx_conf"
         success "Updated default access_log to use theia_combined format"
     else
@@ -383,25 +400,37 @@ configure_nginx_multidomain() {
     echo ""
     info "Checking for sites with custom access_log directives..."
 
-    local sites_with_overrides=()
-    if [ -d "/etc/nginx/sites-available" ]; then
-        while IFS= read -r site_file; do
-            if grep -q "^\s*access_log" "$site_file"; then
-                sites_with_overrides+=("$(basename "$site_file")")
-            fi
-        done < <(find /etc/nginx/sites-available -type f)
-    fi
-
-    if [ ${#sites_with_overrides[@]} -eq 0 ]; then
-        success "No sites with custom access_log — all will use theia_combined automatically"
-    else
-        warn "Found ${#sites_with_overrides[@]} site(s) with custom access_log directives:"
-        for site in "${sites_with_overrides[@]}"; do
-            dim "  - $site"
-        done
+    # Report the config as it now stands; fall back to grepping
+    # sites-available when the binary can't read it. The report is only
+    # printed once inspect succeeds, so a failure doesn't print both.
+    local inspect_report=""
+    if [ "$can_inspect" = true ] && inspect_report=$("$theia_bin" nginx inspect --nginx-conf "$nginx_conf" 2>/dev/null); then
+        printf '%s\n' "$inspect_report"
         echo ""
-        dim "  These override the default. Update them manually to use theia_combined:"
+        dim "  Logs marked 'standard' are counted under one default host. To track"
+        dim "  their domains separately, switch them to theia_combined:"
         dim "    access_log /var/log/nginx/example.log theia_combined;"
+    else
+        local sites_with_overrides=()
+        if [ -d "/etc/nginx/sites-available" ]; then
+            while IFS= read -r site_file; do
+                if grep -q "^\s*access_log" "$site_file"; then
+                    sites_with_overrides+=("$(basename "$site_file")")
+                fi
+            done < <(find /etc/nginx/sites-available -type f)
+        fi
+
+        if [ ${#sites_with_overrides[@]} -eq 0 ]; then
+            success "No sites with custom access_log — all will use theia_combined automatically"
+        else
+            warn "Found ${#sites_with_overrides[@]} site(s) with custom access_log directives:"
+            for site in "${sites_with_overrides[@]}"; do
+                dim "  - $site"
+            done
+            echo ""
+            dim "  These override the default. Update them manually to use theia_combined:"
+            dim "    access_log /var/log/nginx/example.log theia_combined;"
+        fi
     fi
 
     echo ""
@@ -438,10 +467,17 @@ Description=theia - Privacy-First Server-Side Analytics
 After=network.target nginx.service
 
 [Service]
-Type=simple
+Type=notify
+NotifyAccess=main
+# READY comes after schema migrations, and one that rebuilds or vacuums a
+# large database can outlast the default start timeout; killing the daemon
+# mid-migration would leave the schema dirty.
+TimeoutStartSec=infinity
+WatchdogSec=120
 User=root
 WorkingDirectory=${DATA_DIR}
 ExecStart=${INSTALL_DIR}/${BINARY_NAME} daemon
+ExecReload=/bin/kill -HUP \$MAINPID
 Restart=always
 RestartSec=10
 
@@ -4,15 +4,25 @@ import (
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
 	"github.com/Elysium-Labs-EU/theia/internal/query"
 )
 
+// dateRange echoes the days a response covers and the timezone they were
+// read in.
 type dateRange struct {
-	From string `json:"from"`
-	To   string `json:"to"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone"`
+}
+
+func rangeOf(from, to time.Time) dateRange {
+	return dateRange{From: from.Format(dateLayout), To: to.Format(dateLayout), Timezone: from.Location().String()}
 }
 
 type statsResponse struct {
@@ -56,9 +66,38 @@ type statusCodesResponse struct {
 	StatusCodes []statusCodeEntry `json:"status_codes"`
 }
 
-func handleStats(db *sql.DB) http.HandlerFunc {
+type goalsResponse struct {
+	Host  string           `json:"host"`
+	Range dateRange        `json:"range"`
+	Goals []query.GoalStat `json:"goals"`
+}
+
+type brokenLinksResponse struct {
+	Host        string             `json:"host"`
+	Range       dateRange          `json:"range"`
+	BrokenLinks []query.BrokenLink `json:"broken_links"`
+}
+
+// parseFailuresResponse has no host: rejected lines never got as far as
+// having one parsed out.
+type parseFailuresResponse struct {
+	Range       dateRange                `json:"range"`
+	Lines       int                      `json:"lines"`
+	Failures    int                      `json:"failures"`
+	FailureRate float64                  `json:"failure_rate"`
+	Reasons     []query.ParseFailureStat `json:"reasons"`
+}
+
+type funnelResponse struct {
+	Funnel string             `json:"funnel"`
+	Host   string             `json:"host"`
+	Range  dateRange          `json:"range"`
+	Steps  []query.FunnelStep `json:"steps"`
+}
+
+func handleStats(db *sql.DB, tz query.Timezones) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params, err := parseStatsParams(r.URL.Query())
+		params, err := parseStatsParams(r.URL.Query(), tz)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
@@ -76,16 +115,16 @@ func handleStats(db *sql.DB) http.HandlerFunc {
 		}
 		writeJSON(w, statsResponse{
 			Host:    params.Host,
-			Range:   dateRange{From: params.From.Format(dateLayout), To: params.To.Format(dateLayout)},
+			Range:   rangeOf(params.From, params.To),
 			GroupBy: params.GroupBy,
 			Series:  series,
 		})
 	}
 }
 
-func handlePaths(db *sql.DB) http.HandlerFunc {
+func handlePaths(db *sql.DB, tz query.Timezones) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params, err := parseBreakdownParams(r.URL.Query())
+		params, err := parseBreakdownParams(r.URL.Query(), tz)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
@@ -108,15 +147,15 @@ func handlePaths(db *sql.DB) http.HandlerFunc {
 		}
 		writeJSON(w, pathsResponse{
 			Host:  params.Host,
-			Range: dateRange{From: params.From.Format(dateLayout), To: params.To.Format(dateLayout)},
+			Range: rangeOf(params.From, params.To),
 			Paths: entries,
 		})
 	}
 }
 
-func handleReferrers(db *sql.DB) http.HandlerFunc {
+func handleReferrers(db *sql.DB, tz query.Timezones) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params, err := parseBreakdownParams(r.URL.Query())
+		params, err := parseBreakdownParams(r.URL.Query(), tz)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
@@ -139,15 +178,15 @@ func handleReferrers(db *sql.DB) http.HandlerFunc {
 		}
 		writeJSON(w, referrersResponse{
 			Host:      params.Host,
-			Range:     dateRange{From: params.From.Format(dateLayout), To: params.To.Format(dateLayout)},
+			Range:     rangeOf(params.From, params.To),
 			Referrers: entries,
 		})
 	}
 }
 
-func handleStatusCodes(db *sql.DB) http.HandlerFunc {
+func handleStatusCodes(db *sql.DB, tz query.Timezones) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params, err := parseBreakdownParams(r.URL.Query())
+		params, err := parseBreakdownParams(r.URL.Query(), tz)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
@@ -170,12 +209,129 @@ func handleStatusCodes(db *sql.DB) http.HandlerFunc {
 		}
 		writeJSON(w, statusCodesResponse{
 			Host:        params.Host,
-			Range:       dateRange{From: params.From.Format(dateLayout), To: params.To.Format(dateLayout)},
+			Range:       rangeOf(params.From, params.To),
 			StatusCodes: entries,
 		})
 	}
 }
 
+func handleGoals(db *sql.DB, tz query.Timezones) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseBreakdownParams(r.URL.Query(), tz)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		stats, err := query.GetGoalStatsRange(r.Context(), db, params.From, params.To, params.Host)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if params.Format == "csv" {
+			writeGoalsCSV(w, stats)
+			return
+		}
+		writeJSON(w, goalsResponse{
+			Host:  params.Host,
+			Range: rangeOf(params.From, params.To),
+			Goals: stats,
+		})
+	}
+}
+
+func handleBrokenLinks(db *sql.DB, tz query.Timezones) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseBreakdownParams(r.URL.Query(), tz)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		links, err := query.GetBrokenLinksRange(r.Context(), db, params.From, params.To, params.Host, params.Top)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if params.Format == "csv" {
+			writeBrokenLinksCSV(w, links)
+			return
+		}
+		writeJSON(w, brokenLinksResponse{
+			Host:        params.Host,
+			Range:       rangeOf(params.From, params.To),
+			BrokenLinks: links,
+		})
+	}
+}
+
+func handleParseFailures(db *sql.DB, tz query.Timezones) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseBreakdownParams(r.URL.Query(), tz)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		health, err := query.GetParseHealthRange(r.Context(), db, params.From, params.To)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if params.Format == "csv" {
+			writeParseFailuresCSV(w, health.Reasons)
+			return
+		}
+		writeJSON(w, parseFailuresResponse{
+			Range:       rangeOf(params.From, params.To),
+			Lines:       health.Lines,
+			Failures:    health.Failures,
+			FailureRate: health.FailureRate,
+			Reasons:     health.Reasons,
+		})
+	}
+}
+
+func handleFunnel(db *sql.DB, tz query.Timezones) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseBreakdownParams(r.URL.Query(), tz)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		name := r.PathValue("name")
+		if _, err := funnels.Get(r.Context(), db, name); err != nil {
+			if errors.Is(err, funnels.ErrNotFound) {
+				writeError(w, http.StatusNotFound, err)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		steps, err := query.GetFunnelSteps(r.Context(), db, name, params.From, params.To, params.Host)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if params.Format == "csv" {
+			writeFunnelCSV(w, steps)
+			return
+		}
+		writeJSON(w, funnelResponse{
+			Funnel: name,
+			Host:   params.Host,
+			Range:  rangeOf(params.From, params.To),
+			Steps:  steps,
+		})
+	}
+}
+
 const contentTypeHeader = "Content-Type"
 
 func writeJSON(w http.ResponseWriter, v any) {
@@ -230,6 +386,61 @@ func writeStatusCodesCSV(w http.ResponseWriter, entries []statusCodeEntry) {
 	cw.Flush()
 }
 
+func writeGoalsCSV(w http.ResponseWriter, stats []query.GoalStat) {
+	cw := newCSVWriter(w)
+	_ = cw.Write([]string{"goal", "completions", "unique_visitors", "conversion_rate"})
+	for _, g := range stats {
+		_ = cw.Write([]string{
+			g.Goal,
+			strconv.Itoa(g.Completions),
+			strconv.Itoa(g.UniqueVisitors),
+			strconv.FormatFloat(g.ConversionRate, 'f', 4, 64),
+		})
+	}
+	cw.Flush()
+}
+
+func writeBrokenLinksCSV(w http.ResponseWriter, links []query.BrokenLink) {
+	cw := newCSVWriter(w)
+	_ = cw.Write([]string{"path", "host", "referrer", "status_code", "count", "internal"})
+	for _, l := range links {
+		_ = cw.Write([]string{
+			l.Path,
+			l.Host,
+			l.Referrer,
+			strconv.Itoa(l.StatusCode),
+			strconv.Itoa(l.Count),
+			strconv.FormatBool(l.Internal),
+		})
+	}
+	cw.Flush()
+}
+
+func writeParseFailuresCSV(w http.ResponseWriter, stats []query.ParseFailureStat) {
+	cw := newCSVWriter(w)
+	_ = cw.Write([]string{"reason", "count"})
+	for _, f := range stats {
+		_ = cw.Write([]string{f.Reason, strconv.Itoa(f.Count)})
+	}
+	cw.Flush()
+}
+
+func writeFunnelCSV(w http.ResponseWriter, steps []query.FunnelStep) {
+	cw := newCSVWriter(w)
+	_ = cw.Write([]string{"step", "path_pattern", "visitors", "drop_off", "from_previous", "from_start"})
+	for _, s := range steps {
+		_ = cw.Write([]string{
+			strconv.Itoa(s.Step),
+			s.PathPattern,
+			strconv.Itoa(s.Visitors),
+			strconv.Itoa(s.DropOff),
+			strconv.FormatFloat(s.FromPrevious, 'f', 4, 64),
+			strconv.FormatFloat(s.FromStart, 'f', 4, 64),
+		})
+	}
+	cw.Flush()
+}
+
 // newCSVWriter sets the CSV content type and returns a writer over w. Writes
 // are best-effort: a client that disconnects mid-stream isn't actionable,
 // and csv.Writer surfaces that same error again from Flush/Error if it
@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"strconv"
 	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/query"
 )
 
 const dateLayout = "2006-01-02"
@@ -33,8 +35,12 @@ type breakdownParams struct {
 	Top    int
 }
 
-func parseStatsParams(q url.Values) (statsParams, error) {
-	from, to, err := parseDateRange(q)
+func parseStatsParams(q url.Values, tz query.Timezones) (statsParams, error) {
+	loc, err := parseTimezone(q, tz)
+	if err != nil {
+		return statsParams{}, err
+	}
+	from, to, err := parseDateRange(q, loc)
 	if err != nil {
 		return statsParams{}, err
 	}
@@ -46,15 +52,19 @@ func parseStatsParams(q url.Values) (statsParams, error) {
 	if groupBy == "" {
 		groupBy = "day"
 	}
-	if groupBy != "day" && groupBy != "hour" {
-		return statsParams{}, fmt.Errorf("invalid group_by %q: must be \"day\" or \"hour\"", groupBy)
+	if groupBy != "day" && groupBy != "hour" && groupBy != "month" {
+		return statsParams{}, fmt.Errorf("invalid group_by %q: must be \"hour\", \"day\" or \"month\"", groupBy)
 	}
 
 	return statsParams{Host: q.Get("host"), From: from, To: to, GroupBy: groupBy, Format: format}, nil
 }
 
-func parseBreakdownParams(q url.Values) (breakdownParams, error) {
-	from, to, err := parseDateRange(q)
+func parseBreakdownParams(q url.Values, tz query.Timezones) (breakdownParams, error) {
+	loc, err := parseTimezone(q, tz)
+	if err != nil {
+		return breakdownParams{}, err
+	}
+	from, to, err := parseDateRange(q, loc)
 	if err != nil {
 		return breakdownParams{}, err
 	}
@@ -70,10 +80,27 @@ func parseBreakdownParams(q url.Values) (breakdownParams, error) {
 	return breakdownParams{Host: q.Get("host"), From: from, To: to, Format: format, Top: top}, nil
 }
 
-func parseDateRange(q url.Values) (from, to time.Time, err error) {
-	to = time.Now()
+// parseTimezone reads the tz param, an IANA name such as
+// "Europe/Amsterdam", falling back to the configured zone for the requested
+// host.
+func parseTimezone(q url.Values, tz query.Timezones) (*time.Location, error) {
+	name := q.Get("tz")
+	if name == "" {
+		return query.DisplayLocation(tz, q.Get("host")), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz %q: want an IANA timezone such as Europe/Amsterdam", name)
+	}
+	return loc, nil
+}
+
+// parseDateRange reads from and to as calendar days in loc, which is what
+// the query layer buckets by.
+func parseDateRange(q url.Values, loc *time.Location) (from, to time.Time, err error) {
+	to = time.Now().In(loc)
 	if s := q.Get("to"); s != "" {
-		t, parseErr := time.Parse(dateLayout, s)
+		t, parseErr := time.ParseInLocation(dateLayout, s, loc)
 		if parseErr != nil {
 			return time.Time{}, time.Time{}, fmt.Errorf("invalid to date %q: want YYYY-MM-DD", s)
 		}
@@ -82,7 +109,7 @@ func parseDateRange(q url.Values) (from, to time.Time, err error) {
 
 	from = to.AddDate(0, 0, -defaultLookbackDays)
 	if s := q.Get("from"); s != "" {
-		t, parseErr := time.Parse(dateLayout, s)
+		t, parseErr := time.ParseInLocation(dateLayout, s, loc)
 		if parseErr != nil {
 			return time.Time{}, time.Time{}, fmt.Errorf("invalid from date %q: want YYYY-MM-DD", s)
 		}
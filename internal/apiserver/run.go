@@ -21,7 +21,7 @@ func Run(ctx context.Context, db *sql.DB, cfg Config) error {
 
 	errCh := make(chan error, 1)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := listenAndServe(srv, cfg.Listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errCh <- fmt.Errorf("stats API server: %w", err)
 			return
 		}
@@ -45,6 +45,14 @@ func Run(ctx context.Context, db *sql.DB, cfg Config) error {
 	}
 }
 
+// listenAndServe serves srv on l, or on srv.Addr when l is nil.
+func listenAndServe(srv *http.Server, l net.Listener) error {
+	if l == nil {
+		return srv.ListenAndServe()
+	}
+	return srv.Serve(l)
+}
+
 // ValidateLoopbackAddr rejects any address whose host isn't 127.0.0.1 or
 // localhost — theia's stats API must never be reachable except through an
 // explicit reverse proxy on the same machine.
@@ -4,9 +4,12 @@ import (
 	"crypto/subtle"
 	"database/sql"
 	"errors"
+	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/query"
 )
 
 var errUnauthorized = errors.New("unauthorized")
@@ -16,6 +19,12 @@ var errUnauthorized = errors.New("unauthorized")
 type Config struct {
 	Addr  string
 	Token string
+	// Listener, when set, is served instead of listening on Addr — e.g. a
+	// socket systemd passed in through socket activation.
+	Listener net.Listener
+	// Timezones are the display timezones used when a request has no tz
+	// param.
+	Timezones query.Timezones
 }
 
 // NewServer builds the stats API's http.Server: every route requires a
@@ -23,10 +32,14 @@ type Config struct {
 // caller controls the accept loop and shutdown, per Config.Addr.
 func NewServer(db *sql.DB, cfg Config) *http.Server {
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /api/v1/stats", withAuth(cfg.Token, handleStats(db)))
-	mux.HandleFunc("GET /api/v1/stats/paths", withAuth(cfg.Token, handlePaths(db)))
-	mux.HandleFunc("GET /api/v1/stats/referrers", withAuth(cfg.Token, handleReferrers(db)))
-	mux.HandleFunc("GET /api/v1/stats/status-codes", withAuth(cfg.Token, handleStatusCodes(db)))
+	mux.HandleFunc("GET /api/v1/stats", withAuth(cfg.Token, handleStats(db, cfg.Timezones)))
+	mux.HandleFunc("GET /api/v1/stats/paths", withAuth(cfg.Token, handlePaths(db, cfg.Timezones)))
+	mux.HandleFunc("GET /api/v1/stats/referrers", withAuth(cfg.Token, handleReferrers(db, cfg.Timezones)))
+	mux.HandleFunc("GET /api/v1/stats/status-codes", withAuth(cfg.Token, handleStatusCodes(db, cfg.Timezones)))
+	mux.HandleFunc("GET /api/v1/stats/goals", withAuth(cfg.Token, handleGoals(db, cfg.Timezones)))
+	mux.HandleFunc("GET /api/v1/stats/broken-links", withAuth(cfg.Token, handleBrokenLinks(db, cfg.Timezones)))
+	mux.HandleFunc("GET /api/v1/stats/parse-failures", withAuth(cfg.Token, handleParseFailures(db, cfg.Timezones)))
+	mux.HandleFunc("GET /api/v1/funnels/{name}", withAuth(cfg.Token, handleFunnel(db, cfg.Timezones)))
 
 	return &http.Server{
 		Addr:              cfg.Addr,
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/Elysium-Labs-EU/theia/database"
 	"github.com/Elysium-Labs-EU/theia/internal/apiserver"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
 )
 
 const testToken = "test-token-123"
@@ -43,12 +46,12 @@ type statSeed struct {
 func insertHourlyStat(t *testing.T, db *sql.DB, path, host string, ts time.Time, s statSeed) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_stats (hour, year_day, year, path, host, page_views, is_static, bot_views)
-		VALUES (?, ?, ?, ?, ?, ?, 0, ?)
-		ON CONFLICT(hour, year_day, year, path, host) DO UPDATE SET
+		INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views)
+		VALUES (?, ?, ?, ?, 0, ?)
+		ON CONFLICT(bucket, path, host) DO UPDATE SET
 			page_views = page_views + ?,
 			bot_views = bot_views + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host,
+		bucket.Hour(ts), path, host,
 		s.PageViews, s.BotViews,
 		s.PageViews, s.BotViews,
 	)
@@ -59,10 +62,10 @@ func insertHourlyStat(t *testing.T, db *sql.DB, path, host string, ts time.Time,
 	for i := range s.UniqueVisitors {
 		hash := path + host + string(rune('a'+i))
 		_, err := db.ExecContext(t.Context(), `
-			INSERT INTO visitor_days (hash, host, year, year_day, first_seen)
-			VALUES (?, ?, ?, ?, datetime('now'))
-			ON CONFLICT(hash, host, year, year_day) DO NOTHING`,
-			hash, host, ts.Year(), ts.YearDay(),
+			INSERT INTO visitor_days (hash, host, day, first_seen)
+			VALUES (?, ?, ?, datetime('now'))
+			ON CONFLICT(hash, host, day) DO NOTHING`,
+			hash, host, bucket.Day(ts),
 		)
 		if err != nil {
 			t.Fatalf("insert visitor day: %v", err)
@@ -73,10 +76,10 @@ func insertHourlyStat(t *testing.T, db *sql.DB, path, host string, ts time.Time,
 func insertReferrer(t *testing.T, db *sql.DB, path, host, referrer string, ts time.Time, count int) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_referrers (hour, year_day, year, path, host, referrer, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, referrer) DO UPDATE SET count = count + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host, referrer, count, count,
+		INSERT INTO hourly_referrers (bucket, path, host, referrer, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, referrer) DO UPDATE SET count = count + ?`,
+		bucket.Hour(ts), path, host, referrer, count, count,
 	)
 	if err != nil {
 		t.Fatalf("insert referrer: %v", err)
@@ -86,10 +89,10 @@ func insertReferrer(t *testing.T, db *sql.DB, path, host, referrer string, ts ti
 func insertStatusCode(t *testing.T, db *sql.DB, path, host string, ts time.Time, statusCode, count int) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_status_codes (hour, year_day, year, path, host, status_code, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, status_code) DO UPDATE SET count = count + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host, statusCode, count, count,
+		INSERT INTO hourly_status_codes (bucket, path, host, status_code, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, status_code) DO UPDATE SET count = count + ?`,
+		bucket.Hour(ts), path, host, statusCode, count, count,
 	)
 	if err != nil {
 		t.Fatalf("insert status code: %v", err)
@@ -238,6 +241,7 @@ func TestStats_BadParams(t *testing.T) {
 		{"from after to", "/api/v1/stats?from=2026-07-14&to=2026-06-01"},
 		{"invalid group_by", "/api/v1/stats?group_by=week"},
 		{"invalid format", "/api/v1/stats?format=xml"},
+		{"unknown tz", "/api/v1/stats?tz=Mars/Olympus"},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			rec := doRequest(t, srv.Handler, tt.path, testToken)
@@ -248,6 +252,69 @@ func TestStats_BadParams(t *testing.T) {
 	}
 }
 
+// Buckets are stored in UTC; tz moves the day boundaries. 22:30 and 23:30
+// UTC are the same UTC day but either side of midnight in Amsterdam.
+func TestStats_Timezone(t *testing.T) {
+	db := setupTestDB(t)
+	insertHourlyStat(t, db, "/", "example.com", time.Date(2026, 3, 10, 22, 30, 0, 0, time.UTC), statSeed{PageViews: 2})
+	insertHourlyStat(t, db, "/", "example.com", time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC), statSeed{PageViews: 5})
+
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	srv := apiserver.NewServer(db, apiserver.Config{Token: testToken, Timezones: query.Timezones{
+		Default: newYork,
+		Hosts:   map[string]*time.Location{"example.com": amsterdam},
+	}})
+
+	for _, tt := range []struct {
+		name         string
+		path         string
+		wantTimezone string
+		want         map[string]int
+	}{
+		{"tz param", "/api/v1/stats?from=2026-03-10&to=2026-03-11&tz=UTC", "UTC", map[string]int{"2026-03-10": 7}},
+		{"tz param overrides config", "/api/v1/stats?host=example.com&from=2026-03-10&to=2026-03-11&tz=Asia/Tokyo", "Asia/Tokyo", map[string]int{"2026-03-11": 7}},
+		{"host zone from config", "/api/v1/stats?host=example.com&from=2026-03-10&to=2026-03-11", "Europe/Amsterdam", map[string]int{"2026-03-10": 2, "2026-03-11": 5}},
+		{"default zone from config", "/api/v1/stats?from=2026-03-10&to=2026-03-11", "America/New_York", map[string]int{"2026-03-10": 7}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, srv.Handler, tt.path, testToken)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status: got %d, want 200, body: %s", rec.Code, rec.Body.String())
+			}
+
+			var got struct {
+				Range struct {
+					Timezone string `json:"timezone"`
+				} `json:"range"`
+				Series []struct {
+					Date      string `json:"date"`
+					PageViews int    `json:"page_views"`
+				} `json:"series"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("unmarshal: %v\nbody: %s", err, rec.Body.String())
+			}
+			if got.Range.Timezone != tt.wantTimezone {
+				t.Errorf("range.timezone: got %q, want %q", got.Range.Timezone, tt.wantTimezone)
+			}
+			series := make(map[string]int, len(got.Series))
+			for _, p := range got.Series {
+				series[p.Date] = p.PageViews
+			}
+			if !maps.Equal(series, tt.want) {
+				t.Errorf("series: got %v, want %v", series, tt.want)
+			}
+		})
+	}
+}
+
 func TestPaths_JSON(t *testing.T) {
 	db := setupTestDB(t)
 	now := time.Now()
@@ -327,6 +394,167 @@ func TestStatusCodes_JSON(t *testing.T) {
 	}
 }
 
+func TestGoals_JSON(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+	insertHourlyStat(t, db, "/", "example.com", now, statSeed{PageViews: 4, UniqueVisitors: 4})
+	_, err := db.ExecContext(t.Context(), `INSERT INTO goals (name, method, path_pattern, status_code) VALUES ('signup', 'POST', '/register', 302)`)
+	if err != nil {
+		t.Fatalf("insert goal: %v", err)
+	}
+	_, err = db.ExecContext(t.Context(), `
+		INSERT INTO hourly_goals (bucket, host, goal, completions, unique_visitors)
+		VALUES (?, 'example.com', 'signup', 3, 1)`,
+		bucket.Hour(now))
+	if err != nil {
+		t.Fatalf("insert hourly goal: %v", err)
+	}
+
+	srv := apiserver.NewServer(db, apiserver.Config{Token: testToken})
+	rec := doRequest(t, srv.Handler, "/api/v1/stats/goals?host=example.com", testToken)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var got struct {
+		Goals []struct {
+			Goal           string  `json:"goal"`
+			Completions    int     `json:"completions"`
+			UniqueVisitors int     `json:"unique_visitors"`
+			ConversionRate float64 `json:"conversion_rate"`
+		} `json:"goals"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Goals) != 1 || got.Goals[0].Goal != "signup" || got.Goals[0].Completions != 3 || got.Goals[0].ConversionRate != 0.25 {
+		t.Fatalf("expected signup with 3 completions at 0.25 conversion, got %+v", got.Goals)
+	}
+}
+
+func TestBrokenLinks_JSON(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+	_, err := db.ExecContext(t.Context(), `
+		INSERT INTO hourly_broken_links (bucket, path, host, referrer, status_code, count)
+		VALUES (?, '/old-post', 'example.com', 'https://example.com/archive', 404, 4),
+		       (?, '/old-post', 'example.com', 'https://twitter.com/', 404, 300)`,
+		bucket.Hour(now), bucket.Hour(now))
+	if err != nil {
+		t.Fatalf("insert broken links: %v", err)
+	}
+
+	srv := apiserver.NewServer(db, apiserver.Config{Token: testToken})
+	rec := doRequest(t, srv.Handler, "/api/v1/stats/broken-links?host=example.com", testToken)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var got struct {
+		BrokenLinks []struct {
+			Referrer string `json:"referrer"`
+			Count    int    `json:"count"`
+			Internal bool   `json:"internal"`
+		} `json:"broken_links"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.BrokenLinks) != 2 || got.BrokenLinks[0].Count != 300 || got.BrokenLinks[0].Internal || !got.BrokenLinks[1].Internal {
+		t.Fatalf("expected twitter (external, 300) then the archive page (internal, 4), got %+v", got.BrokenLinks)
+	}
+}
+
+func TestParseFailures_JSON(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+	insertHourlyStat(t, db, "/", "example.com", now, statSeed{PageViews: 9})
+	_, err := db.ExecContext(t.Context(), `
+		INSERT INTO hourly_parse_failures (bucket, reason, count)
+		VALUES (?, 'no_match', 1)`,
+		bucket.Hour(now))
+	if err != nil {
+		t.Fatalf("insert parse failures: %v", err)
+	}
+
+	srv := apiserver.NewServer(db, apiserver.Config{Token: testToken})
+	rec := doRequest(t, srv.Handler, "/api/v1/stats/parse-failures", testToken)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var got struct {
+		Lines       int     `json:"lines"`
+		Failures    int     `json:"failures"`
+		FailureRate float64 `json:"failure_rate"`
+		Reasons     []struct {
+			Reason string `json:"reason"`
+			Count  int    `json:"count"`
+		} `json:"reasons"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Lines != 10 || got.Failures != 1 || got.FailureRate != 0.1 || len(got.Reasons) != 1 || got.Reasons[0].Reason != "no_match" {
+		t.Fatalf("expected 1 no_match failure of 10 lines, got %+v", got)
+	}
+}
+
+func TestFunnel_JSON(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+	for _, stmt := range []string{
+		`INSERT INTO funnels (name) VALUES ('checkout')`,
+		`INSERT INTO funnel_steps (funnel, step, path_pattern) VALUES ('checkout', 1, '/pricing'), ('checkout', 2, '/signup')`,
+	} {
+		if _, err := db.ExecContext(t.Context(), stmt); err != nil {
+			t.Fatalf("seed funnel: %v", err)
+		}
+	}
+	_, err := db.ExecContext(t.Context(), `
+		INSERT INTO daily_funnel_steps (day, host, funnel, step, visitors)
+		VALUES (?, 'example.com', 'checkout', 1, 10), (?, 'example.com', 'checkout', 2, 4)`,
+		bucket.Day(now), bucket.Day(now))
+	if err != nil {
+		t.Fatalf("seed funnel steps: %v", err)
+	}
+
+	srv := apiserver.NewServer(db, apiserver.Config{Token: testToken})
+	rec := doRequest(t, srv.Handler, "/api/v1/funnels/checkout", testToken)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var got struct {
+		Funnel string `json:"funnel"`
+		Steps  []struct {
+			Step     int `json:"step"`
+			Visitors int `json:"visitors"`
+			DropOff  int `json:"drop_off"`
+		} `json:"steps"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Funnel != "checkout" || len(got.Steps) != 2 || got.Steps[1].Visitors != 4 || got.Steps[1].DropOff != 6 {
+		t.Fatalf("expected checkout with 10 -> 4 visitors, got %+v", got)
+	}
+}
+
+func TestFunnel_NotFound(t *testing.T) {
+	db := setupTestDB(t)
+	srv := apiserver.NewServer(db, apiserver.Config{Token: testToken})
+
+	rec := doRequest(t, srv.Handler, "/api/v1/funnels/nope", testToken)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status: got %d, want 404, body: %s", rec.Code, rec.Body.String())
+	}
+}
+
 func TestBreakdown_BadTop(t *testing.T) {
 	db := setupTestDB(t)
 	srv := apiserver.NewServer(db, apiserver.Config{Token: testToken})
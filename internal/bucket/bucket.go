@@ -0,0 +1,80 @@
+// Package bucket numbers the time buckets theia aggregates into: hours, days
+// and months counted from the Unix epoch, in UTC. The hourly_* tables are
+// keyed by an hour bucket, the daily ones by a day bucket and the monthly
+// ones by a month bucket, so every time window is a plain integer range the
+// database can answer from an index.
+package bucket
+
+import "time"
+
+const (
+	secondsPerHour = 60 * 60
+	hoursPerDay    = 24
+	secondsPerDay  = hoursPerDay * secondsPerHour
+)
+
+// Hour is the hour bucket t falls in.
+func Hour(t time.Time) int64 {
+	return floorDiv(t.Unix(), secondsPerHour)
+}
+
+// Day is the day bucket t falls in: the UTC day, whatever t's location.
+func Day(t time.Time) int64 {
+	return floorDiv(t.Unix(), secondsPerDay)
+}
+
+// CalendarDay is the day bucket with the same date as t's calendar day in
+// t's own location. The daily tables only know UTC days, so a day in another
+// timezone is matched to them by date rather than by instant.
+func CalendarDay(t time.Time) int64 {
+	return Day(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// Month is the month bucket t falls in: the UTC month, whatever t's
+// location.
+func Month(t time.Time) int64 {
+	t = t.UTC()
+	return int64(t.Year()-1970)*12 + int64(t.Month()-1)
+}
+
+// CalendarMonth is the month bucket with the same year and month as t in
+// t's own location, the monthly counterpart of CalendarDay.
+func CalendarMonth(t time.Time) int64 {
+	return int64(t.Year()-1970)*12 + int64(t.Month()-1)
+}
+
+// FirstHour is the hour bucket day starts with.
+func FirstHour(day int64) int64 {
+	return day * hoursPerDay
+}
+
+// HourStart is the UTC time hour starts at.
+func HourStart(hour int64) time.Time {
+	return time.Unix(hour*secondsPerHour, 0).UTC()
+}
+
+// DayStart is the UTC midnight day starts at.
+func DayStart(day int64) time.Time {
+	return time.Unix(day*secondsPerDay, 0).UTC()
+}
+
+// FirstDay is the day bucket month starts with.
+func FirstDay(month int64) int64 {
+	return Day(MonthStart(month))
+}
+
+// MonthStart is the UTC midnight month starts at.
+func MonthStart(month int64) time.Time {
+	// time.Date normalizes a month past December into the following years.
+	return time.Date(1970, time.Month(month+1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// floorDiv rounds toward negative infinity, so an instant before the epoch
+// still lands in the bucket that starts before it.
+func floorDiv(n, d int64) int64 {
+	q := n / d
+	if n%d < 0 {
+		q--
+	}
+	return q
+}
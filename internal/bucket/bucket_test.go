@@ -0,0 +1,96 @@
+package bucket_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+func TestHourAndDay(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		t         time.Time
+		hour, day int64
+	}{
+		{"epoch", time.Unix(0, 0), 0, 0},
+		{"last second of the first hour", time.Unix(3599, 0), 0, 0},
+		{"second day", time.Date(1970, 1, 2, 1, 30, 0, 0, time.UTC), 25, 1},
+		{"before the epoch", time.Unix(-1, 0), -1, -1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bucket.Hour(tt.t); got != tt.hour {
+				t.Errorf("Hour(%v) = %d, want %d", tt.t, got, tt.hour)
+			}
+			if got := bucket.Day(tt.t); got != tt.day {
+				t.Errorf("Day(%v) = %d, want %d", tt.t, got, tt.day)
+			}
+		})
+	}
+}
+
+// Buckets are UTC: 00:30 in Amsterdam is 23:30 UTC the day before.
+func TestHourAndDayIgnoreLocation(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	local := time.Date(2026, 3, 11, 0, 30, 0, 0, amsterdam)
+	utc := time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC)
+	if bucket.Hour(local) != bucket.Hour(utc) || bucket.Day(local) != bucket.Day(utc) {
+		t.Errorf("Hour/Day(%v) = %d/%d, want %d/%d", local, bucket.Hour(local), bucket.Day(local), bucket.Hour(utc), bucket.Day(utc))
+	}
+}
+
+func TestCalendarDay(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	local := time.Date(2026, 3, 11, 0, 30, 0, 0, amsterdam)
+	want := bucket.Day(time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC))
+	if got := bucket.CalendarDay(local); got != want {
+		t.Errorf("CalendarDay(%v) = %d, want %d, the UTC day with the same date", local, got, want)
+	}
+}
+
+func TestStartsRoundTrip(t *testing.T) {
+	ts := time.Date(2026, 7, 19, 23, 30, 0, 0, time.UTC)
+
+	hour := bucket.Hour(ts)
+	if got, want := bucket.HourStart(hour), time.Date(2026, 7, 19, 23, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("HourStart = %v, want %v", got, want)
+	}
+	day := bucket.Day(ts)
+	if got, want := bucket.DayStart(day), time.Date(2026, 7, 19, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("DayStart = %v, want %v", got, want)
+	}
+	if got := bucket.FirstHour(day); got != bucket.Hour(bucket.DayStart(day)) {
+		t.Errorf("FirstHour(%d) = %d, want the hour bucket of its midnight", day, got)
+	}
+	month := bucket.Month(ts)
+	if got, want := bucket.MonthStart(month), time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("MonthStart = %v, want %v", got, want)
+	}
+	if got := bucket.FirstDay(month); got != bucket.Day(bucket.MonthStart(month)) {
+		t.Errorf("FirstDay(%d) = %d, want the day bucket of its first midnight", month, got)
+	}
+}
+
+func TestMonth(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	// 00:30 on 1 March in Amsterdam is still February in UTC.
+	local := time.Date(2026, 3, 1, 0, 30, 0, 0, amsterdam)
+	if got, want := bucket.Month(local), int64(56*12+1); got != want {
+		t.Errorf("Month(%v) = %d, want %d (February 2026)", local, got, want)
+	}
+	if got, want := bucket.CalendarMonth(local), int64(56*12+2); got != want {
+		t.Errorf("CalendarMonth(%v) = %d, want %d (March 2026)", local, got, want)
+	}
+	if got := bucket.Month(time.Unix(0, 0)); got != 0 {
+		t.Errorf("Month(epoch) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,606 @@
+// Package config reads theia's optional TOML config file, e.g.
+// /etc/theia/theia.toml. The file only supplies defaults: the commands
+// that read it let their flags and THEIA_* env vars override every
+// setting, so a config file never changes what an explicit invocation does.
+package config
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultPath is where commands look for a config file when --config isn't
+// passed. A missing file there is not an error.
+const DefaultPath = "/etc/theia/theia.toml"
+
+// Config is the parsed config file. A zero field means the file leaves
+// that setting unset, so the command's own default applies.
+type Config struct {
+	// DBPath is the sqlite database shared by every command.
+	DBPath string
+	// DefaultHost buckets log lines that carry no host, like
+	// THEIA_DEFAULT_HOST (which overrides it).
+	DefaultHost string
+	// Timezone is the IANA zone reports are shown in, e.g.
+	// "Europe/Amsterdam". Storage is always UTC; empty reports in UTC.
+	Timezone string
+	// HostTimezones is the [host_timezones] table, overriding Timezone for
+	// the hosts it names.
+	HostTimezones map[string]string
+	Daemon        DaemonConfig
+	Serve         ServeConfig
+	Metrics       MetricsConfig
+	Stats         StatsConfig
+	Rules         RulesConfig
+	Pipeline      PipelineConfig
+	Retention     RetentionConfig
+}
+
+// DaemonConfig is the [daemon] table, read by `theia daemon`.
+type DaemonConfig struct {
+	LogPath               string
+	LiveAddr              string
+	MetricsAddr           string
+	DeadLetterPath        string
+	ParseFailureThreshold float64
+}
+
+// ServeConfig is the [serve] table, read by `theia serve`. The token itself
+// is deliberately not configurable here: it belongs in its own file with
+// tighter permissions than a config file that is often world-readable.
+type ServeConfig struct {
+	Addr      string
+	TokenFile string
+}
+
+// MetricsConfig is the [metrics] table, read by `theia serve-metrics`.
+type MetricsConfig struct {
+	Addr string
+	Top  int
+}
+
+// StatsConfig is the [stats] table, read by `theia stats`.
+type StatsConfig struct {
+	Days int
+	Host string
+	Top  int
+}
+
+// RulesConfig is the [rules] table: which requests the daemon ignores and
+// how it normalizes the rest. Unlike the other settings it is re-read when
+// the daemon receives SIGHUP.
+type RulesConfig struct {
+	ExcludePaths       []string
+	ExcludeUserAgents  []string
+	ExcludeHosts       []string
+	StripQuery         bool
+	StripTrailingSlash bool
+	// HostAliases is the [rules.host_aliases] table, mapping a host to the
+	// one it's counted as.
+	HostAliases map[string]string
+}
+
+// PipelineConfig is the [pipeline] table: the order of the stages every
+// parsed log line runs through in the daemon.
+type PipelineConfig struct {
+	// Stages names the stages to run, in order; empty keeps the built-in
+	// order.
+	Stages []string
+}
+
+// RetentionConfig is the [retention] table, read by `theia daemon` and
+// `theia retention show`: how many days the cleanup keeps each table's rows.
+// Each [retention.hosts."<host>"] table overrides it for that host.
+type RetentionConfig struct {
+	Default RetentionDays
+	Hosts   map[string]RetentionDays
+}
+
+// RetentionDays is one set of retention horizons, in days. A host's unset
+// fields fall back to [retention], and those to the daemon's defaults.
+type RetentionDays struct {
+	HourlyStats int
+	StatusCodes int
+	Referrers   int
+	VisitorDays int
+	Daily       int
+	Monthly     int
+}
+
+// Load reads and parses the config file at path. A missing file is
+// reported with an error wrapping fs.ErrNotExist, so callers can decide
+// whether that's fatal.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is an operator-supplied flag, not request input
+	if err != nil {
+		return Config{}, fmt.Errorf("reading config file: %w", err)
+	}
+	cfg, err := Parse(string(data))
+	if err != nil {
+		return Config{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes and validates a config file's contents. Unknown keys are
+// errors, so a typo like "log_pth" is reported instead of silently ignored.
+func Parse(src string) (Config, error) {
+	// Decoding into a map rather than into Config keeps the keys the file
+	// actually sets, which is how decode tells unknown keys from unset ones
+	// and names them in its errors.
+	var doc map[string]any
+	if _, err := toml.Decode(src, &doc); err != nil {
+		return Config{}, err
+	}
+	cfg, err := decode(doc)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := Validate(cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the settings that can be judged without knowing which
+// command reads them. Zero values are unset and always valid.
+func Validate(cfg Config) error {
+	if t := cfg.Daemon.ParseFailureThreshold; t < 0 || t > 1 {
+		return fmt.Errorf("daemon.parse_failure_threshold %v: must be greater than 0 and at most 1", t)
+	}
+	if cfg.Metrics.Top < 0 {
+		return fmt.Errorf("metrics.top %d: must be a positive integer", cfg.Metrics.Top)
+	}
+	if cfg.Stats.Top < 0 {
+		return fmt.Errorf("stats.top %d: must be a positive integer", cfg.Stats.Top)
+	}
+	if cfg.Stats.Days < 0 {
+		return fmt.Errorf("stats.days %d: must be a positive integer", cfg.Stats.Days)
+	}
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("timezone %q: %w", cfg.Timezone, err)
+		}
+	}
+	for _, host := range slices.Sorted(maps.Keys(cfg.HostTimezones)) {
+		if _, err := time.LoadLocation(cfg.HostTimezones[host]); err != nil {
+			return fmt.Errorf("host_timezones.%s %q: %w", host, cfg.HostTimezones[host], err)
+		}
+	}
+	if err := validateRetentionDays(cfg.Retention.Default, "retention"); err != nil {
+		return err
+	}
+	for _, host := range slices.Sorted(maps.Keys(cfg.Retention.Hosts)) {
+		if err := validateRetentionDays(cfg.Retention.Hosts[host], "retention.hosts."+host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRetentionDays only rejects negative horizons: how the horizons
+// relate to each other is judged once a host's are merged with the
+// defaults.
+func validateRetentionDays(r RetentionDays, section string) error {
+	for _, f := range []struct {
+		key  string
+		days int
+	}{
+		{"hourly_stats", r.HourlyStats},
+		{"status_codes", r.StatusCodes},
+		{"referrers", r.Referrers},
+		{"visitor_days", r.VisitorDays},
+		{"daily", r.Daily},
+		{"monthly", r.Monthly},
+	} {
+		if f.days < 0 {
+			return fmt.Errorf("%s %d: must be a positive number of days", qualify(section, f.key), f.days)
+		}
+	}
+	return nil
+}
+
+func decode(doc map[string]any) (Config, error) {
+	if err := rejectUnknown(doc, "", "db_path", "default_host", "timezone", "host_timezones", "daemon", "serve", "metrics", "stats", "rules", "pipeline", "retention"); err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	var err error
+	if cfg.DBPath, err = stringField(doc, "", "db_path"); err != nil {
+		return Config{}, err
+	}
+	if cfg.DefaultHost, err = stringField(doc, "", "default_host"); err != nil {
+		return Config{}, err
+	}
+	if cfg.Timezone, err = stringField(doc, "", "timezone"); err != nil {
+		return Config{}, err
+	}
+	if cfg.HostTimezones, err = stringMapField(doc, "", "host_timezones"); err != nil {
+		return Config{}, err
+	}
+	if cfg.Daemon, err = decodeDaemon(doc); err != nil {
+		return Config{}, err
+	}
+	if cfg.Serve, err = decodeServe(doc); err != nil {
+		return Config{}, err
+	}
+	if cfg.Metrics, err = decodeMetrics(doc); err != nil {
+		return Config{}, err
+	}
+	if cfg.Stats, err = decodeStats(doc); err != nil {
+		return Config{}, err
+	}
+	if cfg.Rules, err = decodeRules(doc); err != nil {
+		return Config{}, err
+	}
+	if cfg.Pipeline, err = decodePipeline(doc); err != nil {
+		return Config{}, err
+	}
+	if cfg.Retention, err = decodeRetention(doc); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func decodeDaemon(doc map[string]any) (DaemonConfig, error) {
+	t, err := tableField(doc, "", "daemon")
+	if err != nil {
+		return DaemonConfig{}, err
+	}
+	if err := rejectUnknown(t, "daemon", "log_path", "live_addr", "metrics_addr", "dead_letter_path", "parse_failure_threshold"); err != nil {
+		return DaemonConfig{}, err
+	}
+
+	var d DaemonConfig
+	if d.LogPath, err = stringField(t, "daemon", "log_path"); err != nil {
+		return DaemonConfig{}, err
+	}
+	if d.LiveAddr, err = stringField(t, "daemon", "live_addr"); err != nil {
+		return DaemonConfig{}, err
+	}
+	if d.MetricsAddr, err = stringField(t, "daemon", "metrics_addr"); err != nil {
+		return DaemonConfig{}, err
+	}
+	if d.DeadLetterPath, err = stringField(t, "daemon", "dead_letter_path"); err != nil {
+		return DaemonConfig{}, err
+	}
+	if d.ParseFailureThreshold, err = floatField(t, "daemon", "parse_failure_threshold"); err != nil {
+		return DaemonConfig{}, err
+	}
+	return d, nil
+}
+
+func decodeServe(doc map[string]any) (ServeConfig, error) {
+	t, err := tableField(doc, "", "serve")
+	if err != nil {
+		return ServeConfig{}, err
+	}
+	if _, ok := t["token"]; ok {
+		return ServeConfig{}, fmt.Errorf("serve.token is not supported: put the token in its own file and set serve.token_file")
+	}
+	if err := rejectUnknown(t, "serve", "addr", "token_file"); err != nil {
+		return ServeConfig{}, err
+	}
+
+	var s ServeConfig
+	if s.Addr, err = stringField(t, "serve", "addr"); err != nil {
+		return ServeConfig{}, err
+	}
+	if s.TokenFile, err = stringField(t, "serve", "token_file"); err != nil {
+		return ServeConfig{}, err
+	}
+	return s, nil
+}
+
+func decodeMetrics(doc map[string]any) (MetricsConfig, error) {
+	t, err := tableField(doc, "", "metrics")
+	if err != nil {
+		return MetricsConfig{}, err
+	}
+	if err := rejectUnknown(t, "metrics", "addr", "top"); err != nil {
+		return MetricsConfig{}, err
+	}
+
+	var m MetricsConfig
+	if m.Addr, err = stringField(t, "metrics", "addr"); err != nil {
+		return MetricsConfig{}, err
+	}
+	if m.Top, err = intField(t, "metrics", "top"); err != nil {
+		return MetricsConfig{}, err
+	}
+	return m, nil
+}
+
+func decodeStats(doc map[string]any) (StatsConfig, error) {
+	t, err := tableField(doc, "", "stats")
+	if err != nil {
+		return StatsConfig{}, err
+	}
+	if err := rejectUnknown(t, "stats", "days", "host", "top"); err != nil {
+		return StatsConfig{}, err
+	}
+
+	var s StatsConfig
+	if s.Days, err = intField(t, "stats", "days"); err != nil {
+		return StatsConfig{}, err
+	}
+	if s.Host, err = stringField(t, "stats", "host"); err != nil {
+		return StatsConfig{}, err
+	}
+	if s.Top, err = intField(t, "stats", "top"); err != nil {
+		return StatsConfig{}, err
+	}
+	return s, nil
+}
+
+func decodeRules(doc map[string]any) (RulesConfig, error) {
+	t, err := tableField(doc, "", "rules")
+	if err != nil {
+		return RulesConfig{}, err
+	}
+	if err := rejectUnknown(t, "rules", "exclude_paths", "exclude_user_agents", "exclude_hosts", "strip_query", "strip_trailing_slash", "host_aliases"); err != nil {
+		return RulesConfig{}, err
+	}
+
+	var r RulesConfig
+	if r.ExcludePaths, err = stringListField(t, "rules", "exclude_paths"); err != nil {
+		return RulesConfig{}, err
+	}
+	if r.ExcludeUserAgents, err = stringListField(t, "rules", "exclude_user_agents"); err != nil {
+		return RulesConfig{}, err
+	}
+	if r.ExcludeHosts, err = stringListField(t, "rules", "exclude_hosts"); err != nil {
+		return RulesConfig{}, err
+	}
+	if r.StripQuery, err = boolField(t, "rules", "strip_query"); err != nil {
+		return RulesConfig{}, err
+	}
+	if r.StripTrailingSlash, err = boolField(t, "rules", "strip_trailing_slash"); err != nil {
+		return RulesConfig{}, err
+	}
+	if r.HostAliases, err = stringMapField(t, "rules", "host_aliases"); err != nil {
+		return RulesConfig{}, err
+	}
+	return r, nil
+}
+
+func decodePipeline(doc map[string]any) (PipelineConfig, error) {
+	t, err := tableField(doc, "", "pipeline")
+	if err != nil {
+		return PipelineConfig{}, err
+	}
+	if err := rejectUnknown(t, "pipeline", "stages"); err != nil {
+		return PipelineConfig{}, err
+	}
+
+	var p PipelineConfig
+	if p.Stages, err = stringListField(t, "pipeline", "stages"); err != nil {
+		return PipelineConfig{}, err
+	}
+	return p, nil
+}
+
+func decodeRetention(doc map[string]any) (RetentionConfig, error) {
+	t, err := tableField(doc, "", "retention")
+	if err != nil {
+		return RetentionConfig{}, err
+	}
+
+	var r RetentionConfig
+	if r.Default, err = decodeRetentionDays(t, "retention", "hosts"); err != nil {
+		return RetentionConfig{}, err
+	}
+	hosts, err := tableField(t, "retention", "hosts")
+	if err != nil {
+		return RetentionConfig{}, err
+	}
+	for _, host := range slices.Sorted(maps.Keys(hosts)) {
+		section := "retention.hosts." + host
+		ht, ok := hosts[host].(map[string]any)
+		if !ok {
+			return RetentionConfig{}, fmt.Errorf("%s: expected a table, got %s", section, typeName(hosts[host]))
+		}
+		days, err := decodeRetentionDays(ht, section)
+		if err != nil {
+			return RetentionConfig{}, err
+		}
+		if r.Hosts == nil {
+			r.Hosts = make(map[string]RetentionDays, len(hosts))
+		}
+		r.Hosts[host] = days
+	}
+	return r, nil
+}
+
+// decodeRetentionDays reads the horizon keys of a retention table, allowing
+// the extra keys it nests, like [retention]'s hosts.
+func decodeRetentionDays(t map[string]any, section string, nested ...string) (RetentionDays, error) {
+	known := append([]string{"hourly_stats", "status_codes", "referrers", "visitor_days", "daily", "monthly"}, nested...)
+	if err := rejectUnknown(t, section, known...); err != nil {
+		return RetentionDays{}, err
+	}
+
+	var d RetentionDays
+	var err error
+	if d.HourlyStats, err = intField(t, section, "hourly_stats"); err != nil {
+		return RetentionDays{}, err
+	}
+	if d.StatusCodes, err = intField(t, section, "status_codes"); err != nil {
+		return RetentionDays{}, err
+	}
+	if d.Referrers, err = intField(t, section, "referrers"); err != nil {
+		return RetentionDays{}, err
+	}
+	if d.VisitorDays, err = intField(t, section, "visitor_days"); err != nil {
+		return RetentionDays{}, err
+	}
+	if d.Daily, err = intField(t, section, "daily"); err != nil {
+		return RetentionDays{}, err
+	}
+	if d.Monthly, err = intField(t, section, "monthly"); err != nil {
+		return RetentionDays{}, err
+	}
+	return d, nil
+}
+
+// qualify names key the way an operator would look for it in the file,
+// e.g. "daemon.log_path".
+func qualify(section, key string) string {
+	if section == "" {
+		return key
+	}
+	return section + "." + key
+}
+
+func rejectUnknown(t map[string]any, section string, known ...string) error {
+	var unknown []string
+	for key := range t {
+		if !slices.Contains(known, key) {
+			unknown = append(unknown, qualify(section, key))
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+	return fmt.Errorf("unknown key(s): %s", strings.Join(unknown, ", "))
+}
+
+func tableField(t map[string]any, section, key string) (map[string]any, error) {
+	v, ok := t[key]
+	if !ok {
+		return map[string]any{}, nil
+	}
+	table, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a table, got %s", qualify(section, key), typeName(v))
+	}
+	return table, nil
+}
+
+func stringField(t map[string]any, section, key string) (string, error) {
+	v, ok := t[key]
+	if !ok {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: expected a string, got %s", qualify(section, key), typeName(v))
+	}
+	return s, nil
+}
+
+func intField(t map[string]any, section, key string) (int, error) {
+	v, ok := t[key]
+	if !ok {
+		return 0, nil
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%s: expected an integer, got %s", qualify(section, key), typeName(v))
+	}
+	return int(i), nil
+}
+
+func boolField(t map[string]any, section, key string) (bool, error) {
+	v, ok := t[key]
+	if !ok {
+		return false, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s: expected a boolean, got %s", qualify(section, key), typeName(v))
+	}
+	return b, nil
+}
+
+func stringListField(t map[string]any, section, key string) ([]string, error) {
+	v, ok := t[key]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an array of strings, got %s", qualify(section, key), typeName(v))
+	}
+	out := make([]string, 0, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d]: expected a string, got %s", qualify(section, key), i, typeName(item))
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// stringMapField reads a table whose values are all strings, such as
+// [rules.host_aliases].
+func stringMapField(t map[string]any, section, key string) (map[string]string, error) {
+	table, err := tableField(t, section, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(table))
+	for k, v := range table {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: expected a string, got %s", qualify(section, key), k, typeName(v))
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+// floatField accepts integers too, so "threshold = 1" isn't a type error.
+// TOML's inf and nan are refused: no setting means anything by them, and
+// nan would slip past every range check.
+func floatField(t map[string]any, section, key string) (float64, error) {
+	v, ok := t[key]
+	if !ok {
+		return 0, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return 0, fmt.Errorf("%s: expected a finite number, got %v", qualify(section, key), n)
+		}
+		return n, nil
+	case int64:
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("%s: expected a number, got %s", qualify(section, key), typeName(v))
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "a table"
+	case []map[string]any:
+		return "an array of tables"
+	case time.Time:
+		return "a date"
+	}
+	return fmt.Sprintf("%T", v)
+}
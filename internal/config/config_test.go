@@ -0,0 +1,152 @@
+package config_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/internal/config"
+)
+
+func TestParse_FullFile(t *testing.T) {
+	src := `
+# theia config
+db_path = "/var/lib/theia/theia.db"
+default_host = 'example.com'
+timezone = "Europe/Amsterdam"
+
+[host_timezones]
+"shop.example.com" = "America/New_York"
+
+[daemon]
+log_path = "/var/log/nginx/access.log" # trailing comment
+live_addr = "127.0.0.1:9000"
+metrics_addr = "127.0.0.1:9001"
+parse_failure_threshold = 0.1
+
+[serve]
+addr = "127.0.0.1:8081"
+token_file = "/etc/theia/api-token"
+
+[metrics]
+top = 1_000
+
+[stats]
+days = 30
+host = "Example.com"
+
+[rules]
+exclude_paths = ["/healthz", "/admin/*"]
+strip_query = true
+
+[rules.host_aliases]
+"www.example.com" = "example.com"
+
+[pipeline]
+stages = ["bot", "rules"]
+
+[retention]
+referrers = 30
+monthly = 1825
+
+[retention.hosts."secure.example.com"]
+hourly_stats = 14
+visitor_days = 14
+`
+	cfg, err := config.Parse(src)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := config.Config{
+		DBPath:      "/var/lib/theia/theia.db",
+		DefaultHost: "example.com",
+		Timezone:    "Europe/Amsterdam",
+		HostTimezones: map[string]string{
+			"shop.example.com": "America/New_York",
+		},
+		Daemon: config.DaemonConfig{
+			LogPath:               "/var/log/nginx/access.log",
+			LiveAddr:              "127.0.0.1:9000",
+			MetricsAddr:           "127.0.0.1:9001",
+			ParseFailureThreshold: 0.1,
+		},
+		Serve:   config.ServeConfig{Addr: "127.0.0.1:8081", TokenFile: "/etc/theia/api-token"},
+		Metrics: config.MetricsConfig{Top: 1000},
+		Stats:   config.StatsConfig{Days: 30, Host: "Example.com"},
+		Rules: config.RulesConfig{
+			ExcludePaths: []string{"/healthz", "/admin/*"},
+			StripQuery:   true,
+			HostAliases:  map[string]string{"www.example.com": "example.com"},
+		},
+		Pipeline: config.PipelineConfig{Stages: []string{"bot", "rules"}},
+		Retention: config.RetentionConfig{
+			Default: config.RetentionDays{Referrers: 30, Monthly: 1825},
+			Hosts: map[string]config.RetentionDays{
+				"secure.example.com": {HourlyStats: 14, VisitorDays: 14},
+			},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Parse =\n%+v\nwant\n%+v", cfg, want)
+	}
+}
+
+func TestParse_EmptyFileIsZeroConfig(t *testing.T) {
+	cfg, err := config.Parse("# nothing configured yet\n")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, config.Config{}) {
+		t.Errorf("Parse = %+v, want zero Config", cfg)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := map[string]struct {
+		src  string
+		want string
+	}{
+		"unknown key":        {src: "[daemon]\nlog_pth = \"x\"\n", want: "daemon.log_pth"},
+		"unknown table":      {src: "[deamon]\n", want: "deamon"},
+		"wrong type":         {src: "[stats]\ndays = \"7\"\n", want: "stats.days: expected an integer"},
+		"unquoted string":    {src: "db_path = /var/lib/theia.db\n", want: "line 1"},
+		"duplicate key":      {src: "db_path = \"a\"\ndb_path = \"b\"\n", want: "line 2"},
+		"duplicate table":    {src: "[stats]\n[stats]\n", want: "line 2"},
+		"threshold range":    {src: "[daemon]\nparse_failure_threshold = 2\n", want: "parse_failure_threshold"},
+		"negative top":       {src: "[metrics]\ntop = -1\n", want: "metrics.top"},
+		"inline token":       {src: "[serve]\ntoken = \"secret\"\n", want: "token_file"},
+		"unterminated":       {src: "db_path = \"abc\n", want: "line 1"},
+		"garbage after val":  {src: "db_path = \"a\" \"b\"\n", want: "line 1"},
+		"date for a string":  {src: "db_path = 2024-01-01\n", want: "db_path: expected a string"},
+		"nan threshold":      {src: "[daemon]\nparse_failure_threshold = nan\n", want: "expected a finite number"},
+		"non-string list":    {src: "[rules]\nexclude_paths = [1]\n", want: "rules.exclude_paths[0]"},
+		"non-string alias":   {src: "[rules.host_aliases]\n\"www.example.com\" = true\n", want: "rules.host_aliases.www.example.com"},
+		"unknown timezone":   {src: "timezone = \"Europe/Amsterdm\"\n", want: "timezone \"Europe/Amsterdm\""},
+		"unknown host zone":  {src: "[host_timezones]\n\"example.com\" = \"Mars/Olympus\"\n", want: "host_timezones.example.com"},
+		"unknown retention":  {src: "[retention]\nhourly = 7\n", want: "retention.hourly"},
+		"negative retention": {src: "[retention.hosts.\"example.com\"]\ndaily = -1\n", want: "retention.hosts.example.com.daily -1"},
+		"retention host key": {src: "[retention.hosts]\n\"example.com\" = 14\n", want: "retention.hosts.example.com: expected a table"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := config.Parse(tc.src)
+			if err == nil {
+				t.Fatalf("Parse succeeded, want error containing %q", tc.want)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoad_MissingFileWrapsNotExist(t *testing.T) {
+	_, err := config.Load(filepath.Join(t.TempDir(), "missing.toml"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load error = %v, want one wrapping fs.ErrNotExist", err)
+	}
+}
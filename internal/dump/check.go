@@ -0,0 +1,218 @@
+package dump
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Problems FindAnomalies reports.
+const (
+	// ProblemNegativeCount is a count column below zero, which no write
+	// theia makes can leave. Repair clamps it to zero.
+	ProblemNegativeCount = "negative count"
+	// ProblemHostNotNormalized is a host the daemon would have written
+	// differently, which stats for the normalized host miss. Repair adds the
+	// rows onto the normalized host's, as a merge would.
+	ProblemHostNotNormalized = "host not normalized"
+	// ProblemMoreVisitorsThanViews is a host's day with more visitor_days
+	// rows than page and bot views, when every view records its visitor. It
+	// can't be told which side is wrong, so it isn't repaired.
+	ProblemMoreVisitorsThanViews = "more visitors than views"
+)
+
+// Anomaly is Rows rows of Table that break what theia writes, as Problem
+// says. Repairable ones are those Repair fixes.
+type Anomaly struct {
+	Table      string
+	Problem    string
+	Rows       int64
+	Repairable bool
+}
+
+// FindAnomalies looks through db's tables for rows theia wouldn't have
+// written: negative counts, hosts normalizeHost changes, and days with more
+// visitors than views. It changes nothing.
+func FindAnomalies(ctx context.Context, db *sql.DB, normalizeHost func(string) string) ([]Anomaly, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not start check: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // read-only, nothing to undo
+
+	var anomalies []Anomaly
+	add := func(t, problem string, rows int64, repairable bool) {
+		if rows > 0 {
+			anomalies = append(anomalies, Anomaly{Table: t, Problem: problem, Rows: rows, Repairable: repairable})
+		}
+	}
+
+	for _, t := range tables() {
+		if counts := sumColumns(&t); len(counts) > 0 {
+			var rows int64
+			if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+t.name+negativeWhere(counts)).Scan(&rows); err != nil { //nolint:gosec // built from the fixed table list, not input
+				return nil, fmt.Errorf("could not check %s counts: %w", t.name, err)
+			}
+			add(t.name, ProblemNegativeCount, rows, true)
+		}
+
+		if !hosted(&t) {
+			continue
+		}
+		hosts, err := unnormalizedHosts(ctx, tx, &t, normalizeHost)
+		if err != nil {
+			return nil, err
+		}
+		var rows int64
+		for _, host := range hosts {
+			var n int64
+			if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+t.name+` WHERE host = ?`, host).Scan(&n); err != nil { //nolint:gosec // built from the fixed table list, not input
+				return nil, fmt.Errorf("could not check %s hosts: %w", t.name, err)
+			}
+			rows += n
+		}
+		add(t.name, ProblemHostNotNormalized, rows, true)
+	}
+
+	var rows int64
+	if err := tx.QueryRowContext(ctx, visitorsOverViewsSQL).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("could not check visitors against views: %w", err)
+	}
+	add("visitor_days", ProblemMoreVisitorsThanViews, rows, false)
+
+	return anomalies, nil
+}
+
+// Repair fixes the repairable anomalies FindAnomalies reports, in one
+// transaction, and returns what it fixed.
+func Repair(ctx context.Context, db *sql.DB, normalizeHost func(string) string) ([]Anomaly, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not start repair: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rollback after commit is a no-op
+
+	var fixed []Anomaly
+	for _, t := range tables() {
+		if counts := sumColumns(&t); len(counts) > 0 {
+			sets := make([]string, len(counts))
+			for i, c := range counts {
+				sets[i] = c + " = MAX(" + c + ", 0)"
+			}
+			res, err := tx.ExecContext(ctx, `UPDATE `+t.name+` SET `+strings.Join(sets, ", ")+negativeWhere(counts)) //nolint:gosec // built from the fixed table list, not input
+			if err != nil {
+				return nil, fmt.Errorf("could not clamp %s counts: %w", t.name, err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				fixed = append(fixed, Anomaly{Table: t.name, Problem: ProblemNegativeCount, Rows: n, Repairable: true})
+			}
+		}
+
+		if !hosted(&t) {
+			continue
+		}
+		hosts, err := unnormalizedHosts(ctx, tx, &t, normalizeHost)
+		if err != nil {
+			return nil, err
+		}
+		var moved int64
+		for _, host := range hosts {
+			n, err := rehost(ctx, tx, &t, host, normalizeHost(host))
+			if err != nil {
+				return nil, fmt.Errorf("could not normalize %s host %q: %w", t.name, host, err)
+			}
+			moved += n
+		}
+		if moved > 0 {
+			fixed = append(fixed, Anomaly{Table: t.name, Problem: ProblemHostNotNormalized, Rows: moved, Repairable: true})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit repair: %w", err)
+	}
+	return fixed, nil
+}
+
+// visitorsOverViewsSQL counts the visitor_days rows of the host days that
+// have more of them than page and bot views, hourly or rolled up into
+// daily_stats. Tiers expire whole days, so a host's views are known day by
+// day from its oldest hourly or daily row on; days before that only live on
+// in monthly_stats, if anywhere, and a policy keeping visitor_days longer
+// leaves them nothing to compare against, so they're skipped.
+const visitorsOverViewsSQL = `
+	SELECT COALESCE(SUM(v.visitors), 0) FROM (
+		SELECT host, day, COUNT(*) AS visitors FROM visitor_days GROUP BY host, day
+	) v
+	JOIN (
+		SELECT host, MIN(day) AS day FROM (
+			SELECT host, MIN(bucket) / 24 AS day FROM hourly_stats GROUP BY host
+			UNION ALL
+			SELECT host, MIN(day) FROM daily_stats GROUP BY host
+		) GROUP BY host
+	) covered ON covered.host = v.host AND v.day >= covered.day
+	WHERE v.visitors >
+		COALESCE((SELECT SUM(page_views + bot_views) FROM hourly_stats
+			WHERE host = v.host AND bucket >= v.day * 24 AND bucket < (v.day + 1) * 24), 0) +
+		COALESCE((SELECT SUM(page_views + bot_views) FROM daily_stats
+			WHERE host = v.host AND day = v.day), 0)`
+
+// sumColumns names t's count columns.
+func sumColumns(t *table) []string {
+	var names []string
+	for _, c := range t.columns {
+		if c.merge == mergeSum {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+// negativeWhere selects the rows with any of columns below zero.
+func negativeWhere(columns []string) string {
+	return " WHERE " + strings.Join(columns, " < 0 OR ") + " < 0"
+}
+
+// unnormalizedHosts lists t's hosts normalizeHost would change.
+func unnormalizedHosts(ctx context.Context, tx *sql.Tx, t *table, normalizeHost func(string) string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT host FROM `+t.name+` WHERE host IS NOT NULL`) //nolint:gosec // built from the fixed table list, not input
+	if err != nil {
+		return nil, fmt.Errorf("could not check %s hosts: %w", t.name, err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, fmt.Errorf("could not check %s hosts: %w", t.name, err)
+		}
+		if normalizeHost(host) != host {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, rows.Err()
+}
+
+// rehost merges t's rows for host into to's, as Import would, and returns
+// how many it moved.
+func rehost(ctx context.Context, tx *sql.Tx, t *table, host, to string) (int64, error) {
+	names := columnNames(t)
+	selected := make([]string, len(names))
+	for i, name := range names {
+		selected[i] = name
+		if name == "host" {
+			selected[i] = "?"
+		}
+	}
+	insert := "INSERT INTO " + t.name + " (" + strings.Join(names, ", ") + ") SELECT " + strings.Join(selected, ", ") + " FROM " + t.name + " WHERE host = ?" + onConflict(t)
+	if _, err := tx.ExecContext(ctx, insert, to, host); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM `+t.name+` WHERE host = ?`, host) //nolint:gosec // built from the fixed table list, not input
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package dump_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/dump"
+)
+
+func TestFindAnomaliesAndRepair(t *testing.T) {
+	db := setupDB(t)
+	exec(t, db, `INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (?, '/', 'example.com', 3, 0, 0)`, bucket.Hour(hour))
+	exec(t, db, `INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (?, '/', 'Example.COM', 2, 1, 0)`, bucket.Hour(hour))
+	exec(t, db, `INSERT INTO hourly_referrers (bucket, path, host, referrer, count) VALUES (?, '/', 'example.com', 'a.test', -4)`, bucket.Hour(hour))
+	for _, hash := range []string{"a", "b", "c", "d", "e", "f"} {
+		exec(t, db, `INSERT INTO visitor_days (hash, host, day, first_seen) VALUES (?, 'example.com', ?, NULL)`, hash, bucket.Day(hour))
+	}
+
+	anomalies, err := dump.FindAnomalies(t.Context(), db, strings.ToLower)
+	if err != nil {
+		t.Fatalf("FindAnomalies: %v", err)
+	}
+	want := []dump.Anomaly{
+		{Table: "hourly_stats", Problem: dump.ProblemHostNotNormalized, Rows: 1, Repairable: true},
+		{Table: "hourly_referrers", Problem: dump.ProblemNegativeCount, Rows: 1, Repairable: true},
+		{Table: "visitor_days", Problem: dump.ProblemMoreVisitorsThanViews, Rows: 6},
+	}
+	if len(anomalies) != len(want) {
+		t.Fatalf("anomalies = %+v, want %+v", anomalies, want)
+	}
+	for i := range want {
+		if anomalies[i] != want[i] {
+			t.Errorf("anomaly %d = %+v, want %+v", i, anomalies[i], want[i])
+		}
+	}
+
+	fixed, err := dump.Repair(t.Context(), db, strings.ToLower)
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if len(fixed) != 2 {
+		t.Errorf("fixed = %+v, want the negative count and the host", fixed)
+	}
+	if got := queryInt(t, db, `SELECT page_views FROM hourly_stats WHERE host = 'example.com'`); got != 5 {
+		t.Errorf("example.com page_views = %d, want the two hosts' 5", got)
+	}
+	if got := queryInt(t, db, `SELECT is_static FROM hourly_stats WHERE host = 'example.com'`); got != 1 {
+		t.Errorf("example.com is_static = %d, want the flag kept", got)
+	}
+	if got := queryInt(t, db, `SELECT count FROM hourly_referrers`); got != 0 {
+		t.Errorf("referrer count = %d, want it clamped to 0", got)
+	}
+
+	// Five views against six visitors still doesn't add up, and isn't
+	// repaired.
+	anomalies, err = dump.FindAnomalies(t.Context(), db, strings.ToLower)
+	if err != nil {
+		t.Fatalf("FindAnomalies: %v", err)
+	}
+	if len(anomalies) != 1 || anomalies[0].Problem != dump.ProblemMoreVisitorsThanViews {
+		t.Errorf("anomalies after repair = %+v, want only the visitors", anomalies)
+	}
+}
+
+// A host whose daily stats expire before its visitor_days has visitor days
+// with nothing but monthly_stats left to compare against; they're not
+// anomalies.
+func TestFindAnomaliesSkipsDaysPastTheViews(t *testing.T) {
+	db := setupDB(t)
+	old := hour.AddDate(0, 0, -40)
+	exec(t, db, `INSERT INTO monthly_stats (month, path, host, page_views, is_static, bot_views) VALUES (?, '/', 'example.com', 9, 0, 0)`, bucket.Month(old))
+	exec(t, db, `INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (?, '/', 'example.com', 2, 0, 0)`, bucket.Hour(hour))
+	for _, v := range []struct {
+		hash string
+		day  int64
+	}{{"a", bucket.Day(old)}, {"b", bucket.Day(old)}, {"c", bucket.Day(hour)}, {"d", bucket.Day(hour)}} {
+		exec(t, db, `INSERT INTO visitor_days (hash, host, day, first_seen) VALUES (?, 'example.com', ?, NULL)`, v.hash, v.day)
+	}
+
+	anomalies, err := dump.FindAnomalies(t.Context(), db, strings.ToLower)
+	if err != nil {
+		t.Fatalf("FindAnomalies: %v", err)
+	}
+	if len(anomalies) != 0 {
+		t.Errorf("anomalies = %+v, want none", anomalies)
+	}
+}
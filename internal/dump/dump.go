@@ -0,0 +1,336 @@
+// Package dump writes theia's tables out as a portable dump, a directory of
+// one NDJSON or CSV file per table plus a manifest, and loads one back into
+// another database, adding its counts onto what's there. A dump's layout is
+// versioned by Version rather than by the database schema: bucket columns
+// are written as dates and times, not as the integers they're stored as, so
+// a dump stays readable across migrations. Merge combines whole databases
+// the same way, straight from their files, and FindAnomalies and Repair use
+// the same knowledge of each table to check a database's rows.
+package dump
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+// Version is the dump layout Export writes and the newest Import reads. It
+// changes when a table, column or encoding in a dump does.
+const Version = 1
+
+// ManifestFile is the manifest's name within a dump directory. Export
+// writes it last, so a dump without one is incomplete.
+const ManifestFile = "manifest.json"
+
+// Format is how a dump's table files are encoded.
+type Format string
+
+const (
+	// FormatNDJSON writes one JSON object per row, keyed by column.
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV writes a header of column names, then one record per row.
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat checks s names a Format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatNDJSON, FormatCSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown dump format %q: want ndjson or csv", s)
+	}
+}
+
+// Manifest describes a dump: the layout and schema it was written from, the
+// hosts and dates its rows cover, and each table's file and row count.
+type Manifest struct {
+	Version int `json:"version"`
+	// SchemaVersion is the database's migration version at export, for
+	// reference; Import goes by Version.
+	SchemaVersion uint      `json:"schema_version"`
+	Format        Format    `json:"format"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Hosts are the hosts found in the dump's rows, sorted.
+	Hosts []string `json:"hosts"`
+	// From and To are the first and last UTC dates the dump's hourly, daily
+	// and monthly rows cover, as YYYY-MM-DD; empty when it has none.
+	From   string      `json:"from,omitempty"`
+	To     string      `json:"to,omitempty"`
+	Tables []TableFile `json:"tables"`
+}
+
+// TableFile is one table's file in a dump, relative to the dump directory.
+type TableFile struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Rows int64  `json:"rows"`
+}
+
+// kind is how a column's value is written in a dump.
+type kind int
+
+const (
+	kindText kind = iota
+	kindInt
+	// kindHour, kindDay and kindMonth are bucket numbers, written as the
+	// RFC 3339 UTC hour, the YYYY-MM-DD date and the YYYY-MM month they
+	// start.
+	kindHour
+	kindDay
+	kindMonth
+	// kindTimestamp is SQLite datetime text, written as an RFC 3339 time;
+	// NULL is written as an empty CSV field or a JSON null.
+	kindTimestamp
+)
+
+// merge is what Import does with a column when the row's key is already in
+// the database.
+type merge int
+
+const (
+	// mergeKey columns identify the row.
+	mergeKey merge = iota
+	// mergeSum columns are counts, added onto the existing ones.
+	mergeSum
+	// mergeMax columns are flags, set when either row has them set.
+	mergeMax
+	// mergeKeep columns keep the existing row's value.
+	mergeKeep
+)
+
+type column struct {
+	name  string
+	kind  kind
+	merge merge
+}
+
+// table is one table a dump carries.
+type table struct {
+	name    string
+	columns []column
+}
+
+func key(name string, k kind) column  { return column{name: name, kind: k, merge: mergeKey} }
+func sum(name string) column          { return column{name: name, kind: kindInt, merge: mergeSum} }
+func flag(name string) column         { return column{name: name, kind: kindInt, merge: mergeMax} }
+func keep(name string, k kind) column { return column{name: name, kind: k, merge: mergeKeep} }
+
+// tables lists every table a dump carries, in the order Import loads them:
+// definitions before the counts recorded under them.
+func tables() []table {
+	return []table{
+		{"hourly_stats", []column{key("bucket", kindHour), key("path", kindText), key("host", kindText), sum("page_views"), flag("is_static"), sum("bot_views")}},
+		{"hourly_status_codes", []column{key("bucket", kindHour), key("path", kindText), key("host", kindText), key("status_code", kindInt), sum("count")}},
+		{"hourly_referrers", []column{key("bucket", kindHour), key("path", kindText), key("host", kindText), key("referrer", kindText), sum("count")}},
+		{"visitor_days", []column{key("hash", kindText), key("host", kindText), key("day", kindDay), keep("first_seen", kindTimestamp)}},
+		{"daily_stats", []column{key("day", kindDay), key("path", kindText), key("host", kindText), sum("page_views"), flag("is_static"), sum("bot_views")}},
+		{"daily_status_codes", []column{key("day", kindDay), key("path", kindText), key("host", kindText), key("status_code", kindInt), sum("count")}},
+		{"daily_referrers", []column{key("day", kindDay), key("path", kindText), key("host", kindText), key("referrer", kindText), sum("count")}},
+		{"daily_visitors", []column{key("day", kindDay), key("host", kindText), sum("visitors")}},
+		{"monthly_stats", []column{key("month", kindMonth), key("path", kindText), key("host", kindText), sum("page_views"), flag("is_static"), sum("bot_views")}},
+		{"monthly_status_codes", []column{key("month", kindMonth), key("path", kindText), key("host", kindText), key("status_code", kindInt), sum("count")}},
+		{"monthly_referrers", []column{key("month", kindMonth), key("path", kindText), key("host", kindText), key("referrer", kindText), sum("count")}},
+		{"monthly_visitors", []column{key("month", kindMonth), key("host", kindText), sum("visitors")}},
+		{"goals", []column{key("name", kindText), keep("method", kindText), keep("path_pattern", kindText), keep("status_code", kindInt), keep("created_at", kindTimestamp)}},
+		{"hourly_goals", []column{key("bucket", kindHour), key("host", kindText), key("goal", kindText), sum("completions"), sum("unique_visitors")}},
+		{"goal_visitor_days", []column{key("goal", kindText), key("hash", kindText), key("host", kindText), key("day", kindDay)}},
+		{"funnels", []column{key("name", kindText), keep("created_at", kindTimestamp)}},
+		{"funnel_steps", []column{key("funnel", kindText), key("step", kindInt), keep("path_pattern", kindText)}},
+		{"daily_funnel_steps", []column{key("day", kindDay), key("host", kindText), key("funnel", kindText), key("step", kindInt), sum("visitors")}},
+		{"hourly_parse_failures", []column{key("bucket", kindHour), key("reason", kindText), sum("count")}},
+		{"hourly_scans", []column{key("bucket", kindHour), key("host", kindText), key("signature", kindText), sum("count")}},
+		{"hourly_broken_links", []column{key("bucket", kindHour), key("path", kindText), key("host", kindText), key("referrer", kindText), key("status_code", kindInt), sum("count")}},
+	}
+}
+
+// hosted reports whether t has a host column, so a dump limited to some
+// hosts can carry its rows.
+func hosted(t *table) bool {
+	for _, c := range t.columns {
+		if c.name == "host" {
+			return true
+		}
+	}
+	return false
+}
+
+func columnNames(t *table) []string {
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// selectSQL reads t's rows in key order, limited to a JSON array of hosts
+// when limited is set. NULLs outside timestamps are read as the zero value,
+// as nothing theia writes leaves them.
+func selectSQL(t *table, limited bool) string {
+	cols := make([]string, len(t.columns))
+	var keys []string
+	for i, c := range t.columns {
+		switch c.kind {
+		case kindTimestamp:
+			cols[i] = c.name
+		case kindText:
+			cols[i] = "COALESCE(" + c.name + ", '')"
+		default:
+			cols[i] = "COALESCE(" + c.name + ", 0)"
+		}
+		if c.merge == mergeKey {
+			keys = append(keys, c.name)
+		}
+	}
+	query := "SELECT " + strings.Join(cols, ", ") + " FROM " + t.name
+	if limited {
+		query += " WHERE host IN (SELECT value FROM json_each(?))"
+	}
+	return query + " ORDER BY " + strings.Join(keys, ", ")
+}
+
+// insertSQL writes one of t's rows, merging it into an existing row with
+// the same key as each column's merge says.
+func insertSQL(t *table) string {
+	names := columnNames(t)
+	return "INSERT INTO " + t.name + " (" + strings.Join(names, ", ") + ") VALUES (?" + strings.Repeat(", ?", len(names)-1) + ")" + onConflict(t)
+}
+
+// onConflict is the upsert clause that merges a row into an existing one
+// with the same key as each of t's column's merge says.
+func onConflict(t *table) string {
+	var keys, sets []string
+	for _, c := range t.columns {
+		switch c.merge {
+		case mergeKey:
+			keys = append(keys, c.name)
+		case mergeSum:
+			sets = append(sets, c.name+" = "+c.name+" + excluded."+c.name)
+		case mergeMax:
+			sets = append(sets, c.name+" = MAX("+c.name+", excluded."+c.name+")")
+		case mergeKeep:
+		}
+	}
+
+	clause := " ON CONFLICT(" + strings.Join(keys, ", ") + ") DO "
+	if len(sets) == 0 {
+		return clause + "NOTHING"
+	}
+	return clause + "UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// scanDest is where a column of kind k is scanned into.
+func scanDest(k kind) any {
+	switch k {
+	case kindText:
+		return new(string)
+	case kindTimestamp:
+		return new(sql.NullTime)
+	default:
+		return new(int64)
+	}
+}
+
+// encode writes the value scanned into dest, a column of kind k, the way a
+// dump carries it. null reports a NULL timestamp.
+func encode(k kind, dest any) (value string, null bool) {
+	switch k {
+	case kindText:
+		return *dest.(*string), false
+	case kindTimestamp:
+		t := dest.(*sql.NullTime)
+		if !t.Valid {
+			return "", true
+		}
+		return t.Time.UTC().Format(time.RFC3339), false
+	case kindHour:
+		return bucket.HourStart(*dest.(*int64)).Format(time.RFC3339), false
+	case kindDay:
+		return bucket.DayStart(*dest.(*int64)).Format(time.DateOnly), false
+	case kindMonth:
+		return bucket.MonthStart(*dest.(*int64)).Format("2006-01"), false
+	default:
+		return strconv.FormatInt(*dest.(*int64), 10), false
+	}
+}
+
+// decode turns a value as a dump carries it back into what a column of
+// kind k stores.
+func decode(k kind, value string) (any, error) {
+	switch k {
+	case kindText:
+		return value, nil
+	case kindTimestamp:
+		if value == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("time %q: want an RFC 3339 time", value)
+		}
+		return t.UTC().Format(time.DateTime), nil
+	case kindHour:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("hour %q: want an RFC 3339 time", value)
+		}
+		return bucket.Hour(t), nil
+	case kindDay:
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, fmt.Errorf("day %q: want YYYY-MM-DD", value)
+		}
+		return bucket.Day(t), nil
+	case kindMonth:
+		t, err := time.Parse("2006-01", value)
+		if err != nil {
+			return nil, fmt.Errorf("month %q: want YYYY-MM", value)
+		}
+		return bucket.Month(t), nil
+	default:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q: want an integer", value)
+		}
+		return n, nil
+	}
+}
+
+// span is the first and last days a dump's bucketed rows cover.
+type span struct {
+	from, to int64
+	set      bool
+}
+
+// cover widens s to the days the bucket in dest, a column of kind k, spans.
+func cover(s *span, k kind, dest any) {
+	var first, last int64
+	switch k {
+	case kindHour:
+		first = bucket.Day(bucket.HourStart(*dest.(*int64)))
+		last = first
+	case kindDay:
+		first = *dest.(*int64)
+		last = first
+	case kindMonth:
+		month := *dest.(*int64)
+		first = bucket.FirstDay(month)
+		last = bucket.FirstDay(month+1) - 1
+	default:
+		return
+	}
+	if !s.set {
+		*s = span{from: first, to: last, set: true}
+		return
+	}
+	s.from = min(s.from, first)
+	s.to = max(s.to, last)
+}
+
+// errIncomplete marks a dump whose files don't match its manifest.
+var errIncomplete = errors.New("dump doesn't match its manifest")
@@ -0,0 +1,242 @@
+package dump_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/dump"
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+)
+
+var (
+	hour  = time.Date(2026, time.March, 3, 10, 0, 0, 0, time.UTC)
+	month = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func setupDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := database.Open(t.Context(), filepath.Join(t.TempDir(), "theia.db"))
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	return db
+}
+
+func exec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.ExecContext(t.Context(), query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func queryInt(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRowContext(t.Context(), query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// seed writes one host's rows across the tiers: an hour, a visitor day and
+// a month, plus a goal definition and a parse failure.
+func seed(t *testing.T, db *sql.DB, host string, views int, static bool) {
+	t.Helper()
+	exec(t, db, `INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (?, '/', ?, ?, ?, 1)`,
+		bucket.Hour(hour), host, views, static)
+	exec(t, db, `INSERT INTO visitor_days (hash, host, day, first_seen) VALUES ('abc', ?, ?, ?)`,
+		host, bucket.Day(hour), hour.Format("2006-01-02 15:04:05"))
+	exec(t, db, `INSERT INTO monthly_visitors (month, host, visitors) VALUES (?, ?, 5)`, bucket.Month(month), host)
+	exec(t, db, `INSERT INTO hourly_parse_failures (bucket, reason, count) VALUES (?, 'no_match', 2)
+		ON CONFLICT(bucket, reason) DO UPDATE SET count = count + excluded.count`, bucket.Hour(hour))
+}
+
+func export(t *testing.T, db *sql.DB, format dump.Format, hosts ...string) (string, dump.Manifest) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "dump")
+	manifest, err := dump.Export(t.Context(), db, dir, dump.ExportOptions{
+		Format:        format,
+		Hosts:         hosts,
+		SchemaVersion: 10,
+		CreatedAt:     hour,
+	})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	return dir, manifest
+}
+
+func TestExportImportMergesCounts(t *testing.T) {
+	for _, format := range []dump.Format{dump.FormatNDJSON, dump.FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			src := setupDB(t)
+			seed(t, src, "example.com", 3, true)
+			if err := goals.Add(t.Context(), src, goals.Goal{Name: "signup", PathPattern: "/signup"}); err != nil {
+				t.Fatalf("goals.Add: %v", err)
+			}
+			dir, manifest := export(t, src, format)
+
+			if manifest.Version != dump.Version || manifest.SchemaVersion != 10 || manifest.Format != format {
+				t.Errorf("manifest = %+v, want version %d, schema 10, format %s", manifest, dump.Version, format)
+			}
+			if manifest.From != "2024-01-01" || manifest.To != "2026-03-03" {
+				t.Errorf("manifest dates = %s to %s, want 2024-01-01 to 2026-03-03", manifest.From, manifest.To)
+			}
+			if len(manifest.Hosts) != 1 || manifest.Hosts[0] != "example.com" {
+				t.Errorf("manifest hosts = %v, want [example.com]", manifest.Hosts)
+			}
+
+			dst := setupDB(t)
+			seed(t, dst, "example.com", 4, false)
+			if err := goals.Add(t.Context(), dst, goals.Goal{Name: "signup", PathPattern: "/register"}); err != nil {
+				t.Fatalf("goals.Add: %v", err)
+			}
+			if _, err := dump.Import(t.Context(), dst, dir); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+
+			h := bucket.Hour(hour)
+			if got := queryInt(t, dst, `SELECT page_views FROM hourly_stats WHERE bucket = ?`, h); got != 7 {
+				t.Errorf("page_views = %d, want 7", got)
+			}
+			if got := queryInt(t, dst, `SELECT bot_views FROM hourly_stats WHERE bucket = ?`, h); got != 2 {
+				t.Errorf("bot_views = %d, want 2", got)
+			}
+			if got := queryInt(t, dst, `SELECT is_static FROM hourly_stats WHERE bucket = ?`, h); got != 1 {
+				t.Errorf("is_static = %d, want 1", got)
+			}
+			if got := queryInt(t, dst, `SELECT COUNT(*) FROM visitor_days`); got != 1 {
+				t.Errorf("visitor_days rows = %d, want the same visitor once", got)
+			}
+			if got := queryInt(t, dst, `SELECT visitors FROM monthly_visitors WHERE month = ?`, bucket.Month(month)); got != 10 {
+				t.Errorf("monthly visitors = %d, want 10", got)
+			}
+			if got := queryInt(t, dst, `SELECT count FROM hourly_parse_failures`); got != 4 {
+				t.Errorf("parse failures = %d, want 4", got)
+			}
+			var pattern string
+			if err := dst.QueryRowContext(t.Context(), `SELECT path_pattern FROM goals WHERE name = 'signup'`).Scan(&pattern); err != nil {
+				t.Fatalf("read goal: %v", err)
+			}
+			if pattern != "/register" {
+				t.Errorf("goal path_pattern = %q, want the existing /register kept", pattern)
+			}
+		})
+	}
+}
+
+// A visitor who converted both in the dump and in the database it's
+// imported into is one converting visitor that day, as in a merge.
+func TestImportCountsSharedConvertingVisitorsOnce(t *testing.T) {
+	src := setupDB(t)
+	convertIn(t, src, "shared", 0)
+	convertIn(t, src, "dump-only", 0)
+	dir, _ := export(t, src, dump.FormatNDJSON)
+
+	dst := setupDB(t)
+	convertIn(t, dst, "shared", 2*time.Hour)
+	if _, err := dump.Import(t.Context(), dst, dir); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if got := queryInt(t, dst, `SELECT SUM(completions) FROM hourly_goals`); got != 3 {
+		t.Errorf("completions = %d, want 3", got)
+	}
+	if got := queryInt(t, dst, `SELECT SUM(unique_visitors) FROM hourly_goals`); got != 2 {
+		t.Errorf("converting visitors = %d, want the shared one counted once", got)
+	}
+	if got := queryInt(t, dst, `SELECT unique_visitors FROM hourly_goals WHERE bucket = ?`, bucket.Hour(hour.Add(2*time.Hour))); got != 0 {
+		t.Errorf("converting visitors at the later hour = %d, want the shared visitor taken off", got)
+	}
+}
+
+func TestExportLimitedToHosts(t *testing.T) {
+	src := setupDB(t)
+	seed(t, src, "example.com", 3, false)
+	seed(t, src, "other.example.org", 5, false)
+
+	dir, manifest := export(t, src, dump.FormatNDJSON, "example.com")
+	if len(manifest.Hosts) != 1 || manifest.Hosts[0] != "example.com" {
+		t.Errorf("manifest hosts = %v, want [example.com]", manifest.Hosts)
+	}
+	for _, file := range manifest.Tables {
+		if file.Name == "hourly_parse_failures" || file.Name == "goals" {
+			t.Errorf("host-limited dump includes %s", file.Name)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hourly_stats.ndjson"))
+	if err != nil {
+		t.Fatalf("read hourly_stats: %v", err)
+	}
+	want := `{"bucket":"2026-03-03T10:00:00Z","path":"/","host":"example.com","page_views":3,"is_static":0,"bot_views":1}` + "\n"
+	if string(data) != want {
+		t.Errorf("hourly_stats.ndjson = %s, want %s", data, want)
+	}
+}
+
+func TestImportRefusesMismatchedDump(t *testing.T) {
+	src := setupDB(t)
+	seed(t, src, "example.com", 3, false)
+	dir, _ := export(t, src, dump.FormatCSV)
+
+	// Drop the last row, as a truncated copy would.
+	path := filepath.Join(dir, "hourly_stats.csv")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read hourly_stats: %v", err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(path, []byte(lines[0]), 0o600); err != nil {
+		t.Fatalf("truncate hourly_stats: %v", err)
+	}
+
+	dst := setupDB(t)
+	_, err = dump.Import(t.Context(), dst, dir)
+	if err == nil || !strings.Contains(err.Error(), "hourly_stats.csv has 0 rows, the manifest says 1") {
+		t.Fatalf("Import error = %v, want a row count mismatch", err)
+	}
+	if got := queryInt(t, dst, `SELECT COUNT(*) FROM visitor_days`); got != 0 {
+		t.Errorf("visitor_days rows = %d, want nothing imported", got)
+	}
+}
+
+func TestImportRefusesNewerDumpVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, dump.ManifestFile), []byte(`{"version": 99, "format": "ndjson", "tables": []}`), 0o600); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	_, err := dump.Import(t.Context(), setupDB(t), dir)
+	if err == nil || !strings.Contains(err.Error(), "dump version 99 is newer") {
+		t.Fatalf("Import error = %v, want a version error", err)
+	}
+}
+
+func TestImportKeepsTimestamps(t *testing.T) {
+	src := setupDB(t)
+	seed(t, src, "example.com", 3, false)
+	dir, _ := export(t, src, dump.FormatNDJSON)
+
+	dst := setupDB(t)
+	if _, err := dump.Import(t.Context(), dst, dir); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	var firstSeen time.Time
+	if err := dst.QueryRowContext(t.Context(), `SELECT first_seen FROM visitor_days`).Scan(&firstSeen); err != nil {
+		t.Fatalf("read first_seen: %v", err)
+	}
+	if !firstSeen.Equal(hour) {
+		t.Errorf("first_seen = %v, want %v", firstSeen, hour)
+	}
+}
@@ -0,0 +1,199 @@
+package dump
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+// ExportOptions is what Export writes: every host's rows unless Hosts names
+// some, in Format. SchemaVersion and CreatedAt go into the manifest as they
+// are.
+type ExportOptions struct {
+	Format        Format
+	Hosts         []string
+	SchemaVersion uint
+	CreatedAt     time.Time
+}
+
+// Export writes db's tables to the directory dir, creating it, and returns
+// the manifest it wrote last. Every table is read in one transaction, so the
+// dump is consistent while the daemon writes. Limited to some hosts, the
+// dump leaves out the tables without a host column: parse failures, goal
+// and funnel definitions.
+func Export(ctx context.Context, db *sql.DB, dir string, opts ExportOptions) (Manifest, error) {
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+		return Manifest{}, fmt.Errorf("%s already holds a dump", dir)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, fmt.Errorf("could not check %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Manifest{}, fmt.Errorf("could not create dump directory: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("could not start export: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // read-only, nothing to undo
+
+	var hostsArg string
+	if len(opts.Hosts) > 0 {
+		hosts, _ := json.Marshal(opts.Hosts) // a []string always marshals
+		hostsArg = string(hosts)
+	}
+
+	manifest := Manifest{
+		Version:       Version,
+		SchemaVersion: opts.SchemaVersion,
+		Format:        opts.Format,
+		CreatedAt:     opts.CreatedAt.UTC(),
+		Tables:        []TableFile{},
+	}
+	hosts := map[string]bool{}
+	var days span
+	for _, t := range tables() {
+		if hostsArg != "" && !hosted(&t) {
+			continue
+		}
+		file := TableFile{Name: t.name, File: t.name + "." + string(opts.Format)}
+		file.Rows, err = exportTable(ctx, tx, &t, hostsArg, filepath.Join(dir, file.File), opts.Format, hosts, &days)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("could not export %s: %w", t.name, err)
+		}
+		manifest.Tables = append(manifest.Tables, file)
+	}
+
+	manifest.Hosts = slices.Sorted(maps.Keys(hosts))
+	if days.set {
+		manifest.From = bucket.DayStart(days.from).Format(time.DateOnly)
+		manifest.To = bucket.DayStart(days.to).Format(time.DateOnly)
+	}
+	if err := writeManifest(filepath.Join(dir, ManifestFile), &manifest); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// exportTable writes t's rows to path in format and returns how many it
+// wrote, adding the hosts and days they cover to hosts and days.
+func exportTable(ctx context.Context, tx *sql.Tx, t *table, hostsArg, path string, format Format, hosts map[string]bool, days *span) (int64, error) {
+	var args []any
+	if hostsArg != "" {
+		args = append(args, hostsArg)
+	}
+	rows, err := tx.QueryContext(ctx, selectSQL(t, hostsArg != ""), args...) //nolint:gosec // built from the fixed table list, not input
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec // path is the operator-provided dump directory
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close() //nolint:errcheck // the explicit Close below reports the error that matters
+
+	buf := bufio.NewWriter(f)
+	var cw *csv.Writer
+	if format == FormatCSV {
+		cw = csv.NewWriter(buf)
+		if err := cw.Write(columnNames(t)); err != nil {
+			return 0, err
+		}
+	}
+
+	dests := make([]any, len(t.columns))
+	for i, c := range t.columns {
+		dests[i] = scanDest(c.kind)
+	}
+	values := make([]string, len(t.columns))
+	nulls := make([]bool, len(t.columns))
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(dests...); err != nil {
+			return 0, err
+		}
+		for i, c := range t.columns {
+			values[i], nulls[i] = encode(c.kind, dests[i])
+			cover(days, c.kind, dests[i])
+			if c.name == "host" && values[i] != "" {
+				hosts[values[i]] = true
+			}
+		}
+		if cw != nil {
+			err = cw.Write(values)
+		} else {
+			err = writeNDJSONRow(buf, t.columns, values, nulls)
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return 0, err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return n, f.Close()
+}
+
+// writeNDJSONRow writes one row as a JSON object with its columns in order.
+func writeNDJSONRow(w *bufio.Writer, columns []column, values []string, nulls []bool) error {
+	_ = w.WriteByte('{')
+	for i, c := range columns {
+		if i > 0 {
+			_ = w.WriteByte(',')
+		}
+		name, _ := json.Marshal(c.name) // a string always marshals
+		_, _ = w.Write(name)
+		_ = w.WriteByte(':')
+		switch {
+		case nulls[i]:
+			_, _ = w.WriteString("null")
+		case c.kind == kindInt:
+			_, _ = w.WriteString(values[i])
+		default:
+			value, _ := json.Marshal(values[i]) // a string always marshals
+			_, _ = w.Write(value)
+		}
+	}
+	// bufio.Writer keeps its first error, so checking the last write is
+	// enough.
+	_, err := w.WriteString("}\n")
+	return err
+}
+
+func writeManifest(path string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("could not write manifest: %w", err)
+	}
+	return nil
+}
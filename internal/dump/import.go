@@ -0,0 +1,216 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ReadManifest reads the manifest of the dump in dir and checks this theia
+// can import it.
+func ReadManifest(dir string) (Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile)) //nolint:gosec // dir is the operator-provided dump directory
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, fmt.Errorf("%s has no %s: not a dump, or an incomplete one", dir, ManifestFile)
+	}
+	if err != nil {
+		return Manifest{}, fmt.Errorf("could not read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("could not parse manifest: %w", err)
+	}
+	switch {
+	case manifest.Version < 1:
+		return Manifest{}, errors.New("manifest has no dump version")
+	case manifest.Version > Version:
+		return Manifest{}, fmt.Errorf("dump version %d is newer than this theia reads (%d): import it with a newer theia", manifest.Version, Version)
+	}
+	if _, err := ParseFormat(string(manifest.Format)); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// Import loads the dump in dir into db, adding its counts onto the rows
+// already there and keeping existing goal and funnel definitions, and
+// returns its manifest. A visitor who converted on a goal both in the dump
+// and in db counts as one converting visitor, as in Merge. The whole dump
+// goes in one transaction: a dump that doesn't match its manifest changes
+// nothing. Importing the same dump twice counts its views twice.
+func Import(ctx context.Context, db *sql.DB, dir string) (Manifest, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	known := tables()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("could not start import: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rollback after commit is a no-op
+
+	for _, file := range manifest.Tables {
+		i := slices.IndexFunc(known, func(t table) bool { return t.name == file.Name })
+		if i < 0 {
+			return Manifest{}, fmt.Errorf("%w: unknown table %q", errIncomplete, file.Name)
+		}
+		if filepath.Base(file.File) != file.File {
+			return Manifest{}, fmt.Errorf("%w: %s file %q is outside the dump", errIncomplete, file.Name, file.File)
+		}
+
+		rows, err := importTable(ctx, tx, &known[i], filepath.Join(dir, file.File), manifest.Format)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("could not import %s: %w", file.Name, err)
+		}
+		if rows != file.Rows {
+			return Manifest{}, fmt.Errorf("%w: %s has %d rows, the manifest says %d", errIncomplete, file.File, rows, file.Rows)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, goalVisitorsSQL); err != nil {
+		return Manifest{}, fmt.Errorf("could not count converting visitors: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Manifest{}, fmt.Errorf("could not commit import: %w", err)
+	}
+	return manifest, nil
+}
+
+// importTable merges every row of the file at path into t and returns how
+// many it read.
+func importTable(ctx context.Context, tx *sql.Tx, t *table, path string, format Format) (int64, error) {
+	f, err := os.Open(path) //nolint:gosec // path is a file the operator's dump manifest names
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+
+	stmt, err := tx.PrepareContext(ctx, insertSQL(t)) //nolint:gosec // built from the fixed table list, not input
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close() //nolint:errcheck // close error in defer is not actionable
+
+	next := ndjsonRows(f, t)
+	if format == FormatCSV {
+		next, err = csvRows(f, t)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	args := make([]any, len(t.columns))
+	var n int64
+	for {
+		values, nulls, err := next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("row %d: %w", n+1, err)
+		}
+		for i, c := range t.columns {
+			if nulls[i] {
+				args[i] = nil
+				continue
+			}
+			if args[i], err = decode(c.kind, values[i]); err != nil {
+				return 0, fmt.Errorf("row %d: %s: %w", n+1, c.name, err)
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return 0, fmt.Errorf("row %d: %w", n+1, err)
+		}
+		n++
+	}
+}
+
+// rowReader returns a table file's next row as a dump carries it, in the
+// table's column order, or io.EOF after the last one.
+type rowReader func() (values []string, nulls []bool, err error)
+
+// csvRows reads a CSV table file, whose header must name t's columns in
+// order.
+func csvRows(r io.Reader, t *table) (rowReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(t.columns)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+	if want := columnNames(t); !slices.Equal(header, want) {
+		return nil, fmt.Errorf("header %s, want %s", strings.Join(header, ","), strings.Join(want, ","))
+	}
+
+	nulls := make([]bool, len(t.columns))
+	return func() ([]string, []bool, error) {
+		record, err := cr.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, c := range t.columns {
+			nulls[i] = c.kind == kindTimestamp && record[i] == ""
+		}
+		return record, nulls, nil
+	}, nil
+}
+
+// ndjsonRows reads an NDJSON table file, each of whose objects must have
+// exactly t's columns.
+func ndjsonRows(r io.Reader, t *table) rowReader {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	values := make([]string, len(t.columns))
+	nulls := make([]bool, len(t.columns))
+	return func() ([]string, []bool, error) {
+		var object map[string]json.RawMessage
+		if err := dec.Decode(&object); err != nil {
+			return nil, nil, err
+		}
+		if len(object) != len(t.columns) {
+			return nil, nil, fmt.Errorf("has %d fields, want %s", len(object), strings.Join(columnNames(t), ","))
+		}
+		for i, c := range t.columns {
+			raw, ok := object[c.name]
+			if !ok {
+				return nil, nil, fmt.Errorf("missing %s", c.name)
+			}
+			var err error
+			values[i], nulls[i], err = jsonValue(raw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", c.name, err)
+			}
+		}
+		return values, nulls, nil
+	}
+}
+
+// jsonValue is raw, a JSON string, number or null, as a dump's value.
+func jsonValue(raw json.RawMessage) (value string, null bool, err error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return "", true, nil
+	case len(raw) > 0 && raw[0] == '"':
+		err = json.Unmarshal(raw, &value)
+		return value, false, err
+	case len(raw) > 0 && (raw[0] == '-' || raw[0] >= '0' && raw[0] <= '9'):
+		return string(raw), false, nil
+	default:
+		return "", false, fmt.Errorf("%s: want a string, number or null", raw)
+	}
+}
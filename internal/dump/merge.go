@@ -0,0 +1,207 @@
+package dump
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMergeSources is how many databases one merge reads: SQLite attaches at
+// most ten to a connection.
+const maxMergeSources = 10
+
+// TableRows is how many rows a table holds.
+type TableRows struct {
+	Name string
+	Rows int64
+}
+
+// Merge replaces every table a dump carries in db with the sum of the same
+// tables in the theia databases at sources, and returns each table's rows
+// afterwards. Counts are added up and visitor_days and goal_visitor_days
+// are unioned, so a visitor seen by several sources on a day still counts
+// once, as does a visitor converting on several. Funnel progress is never
+// stored per visitor, so daily_funnel_steps.visitors, like the rolled-up
+// daily and monthly unique visitors, is summed per source. Because db's
+// tables are rebuilt from the sources each time, merging again after the
+// sources grew counts nothing twice.
+//
+// db must be a merge target: empty, or only ever written by Merge, which
+// records its sources in merge_sources. Every source must be at db's schema
+// version; they're opened read-only. It all happens in one transaction, so
+// readers of db see the old totals or the new ones, never a mix.
+func Merge(ctx context.Context, db *sql.DB, sources []string, now time.Time) ([]TableRows, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no databases to merge")
+	}
+	if len(sources) > maxMergeSources {
+		return nil, fmt.Errorf("%d databases to merge, at most %d at once", len(sources), maxMergeSources)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not start merge: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck // returns the connection to the pool
+
+	// A transaction can ATTACH but not DETACH, so the sources are detached
+	// once it's over, before the connection goes back to the pool.
+	var attached []string
+	defer func() {
+		for _, alias := range attached {
+			_, _ = conn.ExecContext(context.WithoutCancel(ctx), `DETACH DATABASE `+alias) //nolint:gosec // an alias Merge picked
+		}
+	}()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not start merge: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rollback after commit is a no-op
+
+	version, err := schemaVersion(ctx, tx, "main")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMergeTarget(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, len(sources))
+	for i, source := range sources {
+		if paths[i], err = filepath.Abs(source); err != nil {
+			return nil, fmt.Errorf("could not resolve %s: %w", source, err)
+		}
+		alias := "source" + strconv.Itoa(i)
+		uri := url.URL{Scheme: "file", Path: paths[i], RawQuery: "mode=ro"}
+		if _, err := tx.ExecContext(ctx, `ATTACH DATABASE ? AS `+alias, uri.String()); err != nil { //nolint:gosec // an alias Merge picked
+			return nil, fmt.Errorf("could not open %s: %w", source, err)
+		}
+		attached = append(attached, alias)
+
+		sourceVersion, err := schemaVersion(ctx, tx, alias)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if sourceVersion != version {
+			return nil, fmt.Errorf("%s is at schema version %d, the target at %d: open it with this theia first (e.g. theia db stats) to migrate it", source, sourceVersion, version)
+		}
+	}
+
+	for _, t := range tables() {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main.`+t.name); err != nil { //nolint:gosec // built from the fixed table list, not input
+			return nil, fmt.Errorf("could not clear %s: %w", t.name, err)
+		}
+		for i, alias := range attached {
+			if _, err := tx.ExecContext(ctx, mergeSQL(&t, alias)); err != nil { //nolint:gosec // built from the fixed table list, not input
+				return nil, fmt.Errorf("could not merge %s from %s: %w", t.name, sources[i], err)
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, goalVisitorsSQL); err != nil {
+		return nil, fmt.Errorf("could not count converting visitors: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM main.merge_sources`); err != nil {
+		return nil, fmt.Errorf("could not record merge sources: %w", err)
+	}
+	for _, path := range paths {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO main.merge_sources (path, schema_version, merged_at) VALUES (?, ?, ?)`,
+			path, version, now.UTC().Format(time.DateTime)); err != nil {
+			return nil, fmt.Errorf("could not record merge sources: %w", err)
+		}
+	}
+
+	var counts []TableRows
+	for _, t := range tables() {
+		row := TableRows{Name: t.name}
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM main.`+t.name).Scan(&row.Rows); err != nil { //nolint:gosec // built from the fixed table list, not input
+			return nil, fmt.Errorf("could not count %s: %w", t.name, err)
+		}
+		counts = append(counts, row)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit merge: %w", err)
+	}
+	return counts, nil
+}
+
+// goalVisitorsSQL takes visitors who converted on more than one source (a
+// merged node, or a dump and the database it's imported into) off the
+// summed hourly_goals.unique_visitors. Each source counts a converting
+// visitor once a day, in the hour of their first completion there; the
+// unioned goal_visitor_days holds the day's true total, and whatever the
+// hours add up to beyond it comes off the day's latest hours first. Days
+// goal_visitor_days no longer covers are left as summed.
+const goalVisitorsSQL = `
+WITH visitors AS (
+	SELECT goal, host, day, COUNT(*) AS visitors
+	FROM main.goal_visitor_days
+	GROUP BY goal, host, day
+),
+hours AS (
+	SELECT h.bucket, h.host, h.goal,
+		SUM(h.unique_visitors) OVER day - v.visitors AS excess,
+		COALESCE(SUM(h.unique_visitors) OVER (day ORDER BY h.bucket DESC ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING), 0) AS later
+	FROM main.hourly_goals h
+	JOIN visitors v ON v.goal = h.goal AND v.host = h.host AND v.day = h.bucket / 24
+	WINDOW day AS (PARTITION BY h.goal, h.host, h.bucket / 24)
+)
+UPDATE main.hourly_goals
+SET unique_visitors = unique_visitors - MIN(unique_visitors, hours.excess - hours.later)
+FROM hours
+WHERE hourly_goals.bucket = hours.bucket AND hourly_goals.host = hours.host AND hourly_goals.goal = hours.goal
+	AND hours.excess > hours.later`
+
+// mergeSQL adds t's rows in the attached database alias onto t's in main.
+// The WHERE keeps SQLite from reading ON CONFLICT as part of a join.
+func mergeSQL(t *table, alias string) string {
+	names := strings.Join(columnNames(t), ", ")
+	return "INSERT INTO main." + t.name + " (" + names + ") SELECT " + names + " FROM " + alias + "." + t.name + " WHERE true" + onConflict(t)
+}
+
+// schemaVersion is the migration version of the database attached as
+// schema.
+func schemaVersion(ctx context.Context, tx *sql.Tx, schema string) (uint, error) {
+	var version uint
+	var dirty bool
+	err := tx.QueryRowContext(ctx, `SELECT version, dirty FROM `+schema+`.schema_migrations`).Scan(&version, &dirty) //nolint:gosec // schema is main or an alias Merge picked
+	if err != nil {
+		return 0, fmt.Errorf("not a theia database: %w", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema version %d is dirty: a migration failed partway", version)
+	}
+	return version, nil
+}
+
+// checkMergeTarget refuses a target that holds stats Merge didn't put
+// there, which it would otherwise delete.
+func checkMergeTarget(ctx context.Context, tx *sql.Tx) error {
+	var merged bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM main.merge_sources)`).Scan(&merged); err != nil {
+		return fmt.Errorf("could not read merge sources: %w", err)
+	}
+	if merged {
+		return nil
+	}
+
+	for _, t := range tables() {
+		var rows bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM main.`+t.name+`)`).Scan(&rows); err != nil { //nolint:gosec // built from the fixed table list, not input
+			return fmt.Errorf("could not check %s: %w", t.name, err)
+		}
+		if rows {
+			return fmt.Errorf("the target has %s rows of its own, which merging would replace: merge into a new database", t.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package dump_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/dump"
+)
+
+// setupSource migrates a database at a path of its own, seeds it like a
+// node serving host and closes it, as merge sources are files.
+func setupSource(t *testing.T, name, hash string, views int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	db, err := database.Open(t.Context(), path)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	exec(t, db, `INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (?, '/', 'example.com', ?, 0, 0)`,
+		bucket.Hour(hour), views)
+	exec(t, db, `INSERT INTO visitor_days (hash, host, day, first_seen) VALUES (?, 'example.com', ?, ?)`,
+		hash, bucket.Day(hour), hour.Format("2006-01-02 15:04:05"))
+	return path
+}
+
+func TestMergeSumsSourcesOnRepeatRuns(t *testing.T) {
+	a := setupSource(t, "a.db", "shared", 3)
+	b := setupSource(t, "b.db", "shared", 4)
+	c := setupSource(t, "c.db", "other", 5)
+	target := setupDB(t)
+
+	for range 2 {
+		counts, err := dump.Merge(t.Context(), target, []string{a, b, c}, hour)
+		if err != nil {
+			t.Fatalf("Merge: %v", err)
+		}
+		for _, table := range counts {
+			if table.Name == "visitor_days" && table.Rows != 2 {
+				t.Errorf("visitor_days rows = %d, want 2", table.Rows)
+			}
+		}
+		if got := queryInt(t, target, `SELECT page_views FROM hourly_stats`); got != 12 {
+			t.Errorf("page_views = %d, want 12", got)
+		}
+		if got := queryInt(t, target, `SELECT COUNT(*) FROM visitor_days`); got != 2 {
+			t.Errorf("visitor_days rows = %d, want the shared visitor once", got)
+		}
+	}
+	if got := queryInt(t, target, `SELECT COUNT(*) FROM merge_sources`); got != 3 {
+		t.Errorf("merge_sources rows = %d, want 3", got)
+	}
+}
+
+// convert records hash completing the signup goal at offset past hour on
+// the source at path, as the daemon does, and the funnel step it reached.
+func convert(t *testing.T, path, hash string, offset time.Duration) {
+	t.Helper()
+	db, err := database.Open(t.Context(), path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	convertIn(t, db, hash, offset)
+}
+
+// convertIn records a signup by hash offset past hour, as the daemon does:
+// the visitor's day, and the hour's completion and converting visitor.
+func convertIn(t *testing.T, db *sql.DB, hash string, offset time.Duration) {
+	t.Helper()
+	at := hour.Add(offset)
+	exec(t, db, `INSERT INTO goal_visitor_days (goal, hash, host, day) VALUES ('signup', ?, 'example.com', ?)`, hash, bucket.Day(at))
+	exec(t, db, `INSERT INTO hourly_goals (bucket, host, goal, completions, unique_visitors) VALUES (?, 'example.com', 'signup', 1, 1)
+		ON CONFLICT (bucket, host, goal) DO UPDATE SET completions = completions + 1, unique_visitors = unique_visitors + 1`, bucket.Hour(at))
+	exec(t, db, `INSERT INTO daily_funnel_steps (day, host, funnel, step, visitors) VALUES (?, 'example.com', 'checkout', 1, 1)
+		ON CONFLICT (day, host, funnel, step) DO UPDATE SET visitors = visitors + 1`, bucket.Day(at))
+}
+
+// A visitor converting on two nodes is one converting visitor, taken off the
+// day's latest hour; funnel steps have no visitors to union, so they're
+// summed per node.
+func TestMergeCountsSharedConvertingVisitorsOnce(t *testing.T) {
+	a := setupSource(t, "a.db", "shared", 3)
+	convert(t, a, "shared", 0)
+	convert(t, a, "a-only", 0)
+	b := setupSource(t, "b.db", "shared", 4)
+	convert(t, b, "b-only", 2*time.Hour)
+	convert(t, b, "shared", 4*time.Hour)
+	target := setupDB(t)
+
+	if _, err := dump.Merge(t.Context(), target, []string{a, b}, hour); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	if got := queryInt(t, target, `SELECT SUM(completions) FROM hourly_goals`); got != 4 {
+		t.Errorf("completions = %d, want 4", got)
+	}
+	if got := queryInt(t, target, `SELECT SUM(unique_visitors) FROM hourly_goals`); got != 3 {
+		t.Errorf("converting visitors = %d, want the shared one counted once", got)
+	}
+	if got := queryInt(t, target, `SELECT unique_visitors FROM hourly_goals WHERE bucket = ?`, bucket.Hour(hour)); got != 2 {
+		t.Errorf("converting visitors at the first hour = %d, want 2", got)
+	}
+	if got := queryInt(t, target, `SELECT unique_visitors FROM hourly_goals WHERE bucket = ?`, bucket.Hour(hour.Add(4*time.Hour))); got != 0 {
+		t.Errorf("converting visitors at the last hour = %d, want the shared visitor taken off", got)
+	}
+	if got := queryInt(t, target, `SELECT visitors FROM daily_funnel_steps`); got != 4 {
+		t.Errorf("funnel visitors = %d, want 4 summed per node", got)
+	}
+}
+
+func TestMergeRefusesTargetWithItsOwnStats(t *testing.T) {
+	a := setupSource(t, "a.db", "a", 3)
+	target := setupDB(t)
+	seed(t, target, "example.com", 1, false)
+
+	_, err := dump.Merge(t.Context(), target, []string{a}, hour)
+	if err == nil || !strings.Contains(err.Error(), "the target has hourly_stats rows of its own") {
+		t.Fatalf("Merge error = %v, want a refusal", err)
+	}
+	if got := queryInt(t, target, `SELECT page_views FROM hourly_stats`); got != 1 {
+		t.Errorf("page_views = %d, want the target's own row kept", got)
+	}
+}
+
+func TestMergeRefusesOtherSchemaVersion(t *testing.T) {
+	a := setupSource(t, "a.db", "a", 3)
+	db, err := database.Open(t.Context(), a)
+	if err != nil {
+		t.Fatalf("open a.db: %v", err)
+	}
+	exec(t, db, `UPDATE schema_migrations SET version = version - 1`)
+	database.Close(db) //nolint:errcheck // only the file is needed
+
+	_, err = dump.Merge(t.Context(), setupDB(t), []string{a}, hour)
+	if err == nil || !strings.Contains(err.Error(), "is at schema version") {
+		t.Fatalf("Merge error = %v, want a schema version error", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Package funnels defines multi-step funnels — an ordered list of path
+// patterns a visitor is expected to walk through on the same day, e.g.
+// /pricing → /signup → /welcome — and their persistence.
+package funnels
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+)
+
+const (
+	minSteps = 2
+	// maxSteps bounds the per-visitor state the ingest evaluator keeps
+	// (a step index) and the rows written per funnel per day.
+	maxSteps = 10
+)
+
+// Funnel is a named, ordered list of path patterns. Steps use the same glob
+// syntax as goal path patterns (see goals.MatchPath).
+type Funnel struct {
+	Name  string   `json:"name"`
+	Steps []string `json:"steps"`
+}
+
+// String renders f as "name: /a > /b > /c".
+func (f Funnel) String() string {
+	return f.Name + ": " + strings.Join(f.Steps, " > ")
+}
+
+// Validate checks a funnel's name and steps before it's stored or evaluated.
+func Validate(f Funnel) error {
+	if f.Name == "" {
+		return fmt.Errorf("invalid funnel: name must not be empty")
+	}
+	if strings.ContainsAny(f.Name, " \t\n/") {
+		return fmt.Errorf("invalid funnel name %q: must not contain whitespace or '/'", f.Name)
+	}
+	if len(f.Steps) < minSteps || len(f.Steps) > maxSteps {
+		return fmt.Errorf("invalid funnel %q: must have between %d and %d steps, got %d", f.Name, minSteps, maxSteps, len(f.Steps))
+	}
+	for i, step := range f.Steps {
+		if err := goals.ValidatePattern(step); err != nil {
+			return fmt.Errorf("invalid funnel %q step %d: %w", f.Name, i+1, err)
+		}
+	}
+	return nil
+}
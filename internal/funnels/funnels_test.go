@@ -0,0 +1,97 @@
+package funnels_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		funnel  funnels.Funnel
+		wantErr bool
+	}{
+		{"valid", funnels.Funnel{Name: "checkout", Steps: []string{"/pricing", "/signup", "/welcome"}}, false},
+		{"glob steps", funnels.Funnel{Name: "docs", Steps: []string{"/docs/*", "/download"}}, false},
+		{"empty name", funnels.Funnel{Steps: []string{"/a", "/b"}}, true},
+		{"name with slash", funnels.Funnel{Name: "a/b", Steps: []string{"/a", "/b"}}, true},
+		{"single step", funnels.Funnel{Name: "one", Steps: []string{"/a"}}, true},
+		{"too many steps", funnels.Funnel{Name: "long", Steps: slices.Repeat([]string{"/a"}, 11)}, true},
+		{"relative step", funnels.Funnel{Name: "rel", Steps: []string{"/a", "b"}}, true},
+		{"malformed glob", funnels.Funnel{Name: "bad", Steps: []string{"/a", "/b["}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := funnels.Validate(tt.funnel); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%s) error = %v, wantErr %v", tt.funnel, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddGetListRemove(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := t.Context()
+
+	checkout := funnels.Funnel{Name: "checkout", Steps: []string{"/pricing", "/signup", "/welcome"}}
+	docs := funnels.Funnel{Name: "docs", Steps: []string{"/docs/*", "/download"}}
+	for _, f := range []funnels.Funnel{checkout, docs} {
+		if err := funnels.Add(ctx, db, f); err != nil {
+			t.Fatalf("Add(%s): %v", f, err)
+		}
+	}
+
+	got, err := funnels.Get(ctx, db, "checkout")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !slices.Equal(got.Steps, checkout.Steps) {
+		t.Errorf("Get steps = %q, want %q (in order)", got.Steps, checkout.Steps)
+	}
+
+	all, err := funnels.List(ctx, db)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "checkout" || all[1].Name != "docs" {
+		t.Fatalf("List = %+v, want [checkout docs]", all)
+	}
+
+	if err := funnels.Add(ctx, db, checkout); !errors.Is(err, funnels.ErrExists) {
+		t.Errorf("duplicate Add error = %v, want ErrExists", err)
+	}
+
+	if err := funnels.Remove(ctx, db, "checkout"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := funnels.Get(ctx, db, "checkout"); !errors.Is(err, funnels.ErrNotFound) {
+		t.Errorf("Get after Remove error = %v, want ErrNotFound", err)
+	}
+	if err := funnels.Remove(ctx, db, "checkout"); !errors.Is(err, funnels.ErrNotFound) {
+		t.Errorf("second Remove error = %v, want ErrNotFound", err)
+	}
+}
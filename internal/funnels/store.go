@@ -0,0 +1,122 @@
+package funnels
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrExists is returned by Add when a funnel with the same name is already
+// defined.
+var ErrExists = errors.New("funnel already exists")
+
+// ErrNotFound is returned by Get and Remove when no funnel has the given name.
+var ErrNotFound = errors.New("funnel not found")
+
+// Add stores f and its steps.
+func Add(ctx context.Context, db *sql.DB, f Funnel) error {
+	if err := Validate(f); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("adding funnel %q: %w", f.Name, err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rollback after commit is a no-op
+
+	var exists int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM funnels WHERE name = ?`, f.Name).Scan(&exists); err != nil {
+		return fmt.Errorf("checking for funnel %q: %w", f.Name, err)
+	}
+	if exists > 0 {
+		return fmt.Errorf("adding funnel %q: %w", f.Name, ErrExists)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO funnels (name, created_at) VALUES (?, datetime('now'))`, f.Name); err != nil {
+		return fmt.Errorf("adding funnel %q: %w", f.Name, err)
+	}
+	for i, step := range f.Steps {
+		_, err := tx.ExecContext(ctx, `INSERT INTO funnel_steps (funnel, step, path_pattern) VALUES (?, ?, ?)`, f.Name, i+1, step)
+		if err != nil {
+			return fmt.Errorf("adding funnel %q step %d: %w", f.Name, i+1, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("adding funnel %q: %w", f.Name, err)
+	}
+	return nil
+}
+
+// List returns every defined funnel ordered by name, steps in order.
+func List(ctx context.Context, db *sql.DB) ([]Funnel, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT f.name, s.path_pattern
+	FROM funnels f
+	JOIN funnel_steps s ON s.funnel = f.name
+	ORDER BY f.name, s.step`)
+	if err != nil {
+		return nil, fmt.Errorf("querying funnels: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	results := []Funnel{}
+	for rows.Next() {
+		var name, step string
+		if err := rows.Scan(&name, &step); err != nil {
+			return nil, fmt.Errorf("scanning funnel step: %w", err)
+		}
+		if len(results) == 0 || results[len(results)-1].Name != name {
+			results = append(results, Funnel{Name: name})
+		}
+		last := &results[len(results)-1]
+		last.Steps = append(last.Steps, step)
+	}
+	return results, rows.Err()
+}
+
+// Get returns the funnel called name.
+func Get(ctx context.Context, db *sql.DB, name string) (Funnel, error) {
+	all, err := List(ctx, db)
+	if err != nil {
+		return Funnel{}, err
+	}
+	for _, f := range all {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return Funnel{}, fmt.Errorf("funnel %q: %w", name, ErrNotFound)
+}
+
+// Remove deletes the funnel called name, its steps, and the step counts
+// recorded for it.
+func Remove(ctx context.Context, db *sql.DB, name string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("removing funnel %q: %w", name, err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rollback after commit is a no-op
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM funnels WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("removing funnel %q: %w", name, err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("removing funnel %q: %w", name, ErrNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM funnel_steps WHERE funnel = ?`, name); err != nil {
+		return fmt.Errorf("removing steps for funnel %q: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM daily_funnel_steps WHERE funnel = ?`, name); err != nil {
+		return fmt.Errorf("removing counts for funnel %q: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("removing funnel %q: %w", name, err)
+	}
+	return nil
+}
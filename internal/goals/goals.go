@@ -0,0 +1,143 @@
+// Package goals defines conversion goals — named request patterns such as
+// "signup = POST /register with 302" — and matches page views against them,
+// so conversions can be measured from the access log alone.
+package goals
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Goal is one operator-defined conversion: a request matching PathPattern
+// (a path.Match glob, evaluated against the path without its query string)
+// and, when set, Method and StatusCode. An empty Method or a zero StatusCode
+// matches any value.
+type Goal struct {
+	Name        string `json:"name"`
+	Method      string `json:"method,omitempty"`
+	PathPattern string `json:"path_pattern"`
+	StatusCode  int    `json:"status_code,omitempty"`
+}
+
+// String renders g in the same "name = METHOD /path with CODE" form Parse
+// accepts, so `theia goals list` output can be pasted back into `goals add`.
+func (g Goal) String() string {
+	var b strings.Builder
+	b.WriteString(g.Name)
+	b.WriteString(" = ")
+	if g.Method != "" {
+		b.WriteString(g.Method)
+		b.WriteString(" ")
+	}
+	b.WriteString(g.PathPattern)
+	if g.StatusCode != 0 {
+		b.WriteString(" with ")
+		b.WriteString(strconv.Itoa(g.StatusCode))
+	}
+	return b.String()
+}
+
+// Parse reads a goal definition of the form
+//
+//	name = [METHOD] /path/pattern [with STATUS]
+//
+// e.g. "signup = POST /register with 302" or "docs = /docs/*".
+func Parse(def string) (Goal, error) {
+	name, rule, ok := strings.Cut(def, "=")
+	if !ok {
+		return Goal{}, fmt.Errorf("invalid goal %q: want \"name = [METHOD] /path [with STATUS]\"", def)
+	}
+
+	g := Goal{Name: strings.TrimSpace(name)}
+	if err := validateName(g.Name); err != nil {
+		return Goal{}, err
+	}
+
+	fields := strings.Fields(rule)
+	if len(fields) > 0 && !strings.HasPrefix(fields[0], "/") {
+		g.Method = strings.ToUpper(fields[0])
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return Goal{}, fmt.Errorf("invalid goal %q: missing path pattern", def)
+	}
+	g.PathPattern = fields[0]
+	fields = fields[1:]
+
+	if len(fields) > 0 {
+		if len(fields) != 2 || fields[0] != "with" {
+			return Goal{}, fmt.Errorf("invalid goal %q: unexpected %q after path pattern", def, strings.Join(fields, " "))
+		}
+		code, err := strconv.Atoi(fields[1])
+		if err != nil || code < 100 || code > 599 {
+			return Goal{}, fmt.Errorf("invalid goal %q: status %q must be an HTTP status code", def, fields[1])
+		}
+		g.StatusCode = code
+	}
+
+	if err := Validate(g); err != nil {
+		return Goal{}, err
+	}
+	return g, nil
+}
+
+// Validate checks a goal built outside Parse (e.g. read back from the
+// database) before it's used for matching.
+func Validate(g Goal) error {
+	if err := validateName(g.Name); err != nil {
+		return err
+	}
+	if err := ValidatePattern(g.PathPattern); err != nil {
+		return fmt.Errorf("invalid goal %q: %w", g.Name, err)
+	}
+	return nil
+}
+
+// ValidatePattern checks that pattern is an absolute path.Match glob usable
+// with MatchPath.
+func ValidatePattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("path pattern %q must start with /", pattern)
+	}
+	// path.Match only reports a malformed pattern when it's actually
+	// evaluated, so probe it once here rather than failing silently on
+	// every page view later.
+	if _, err := path.Match(pattern, "/"); err != nil {
+		return fmt.Errorf("path pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("invalid goal: name must not be empty")
+	}
+	if strings.ContainsAny(name, " \t\n=") {
+		return fmt.Errorf("invalid goal name %q: must not contain whitespace or '='", name)
+	}
+	return nil
+}
+
+// Matches reports whether a request with the given method, raw request path
+// and response status completes g.
+func Matches(g Goal, method, requestPath string, statusCode int) bool {
+	if g.Method != "" && !strings.EqualFold(g.Method, method) {
+		return false
+	}
+	if g.StatusCode != 0 && g.StatusCode != statusCode {
+		return false
+	}
+	return MatchPath(g.PathPattern, requestPath)
+}
+
+// MatchPath reports whether requestPath, with any query string dropped,
+// matches the path.Match glob pattern. A malformed pattern never matches.
+func MatchPath(pattern, requestPath string) bool {
+	if i := strings.IndexByte(requestPath, '?'); i >= 0 {
+		requestPath = requestPath[:i]
+	}
+	ok, err := path.Match(pattern, requestPath)
+	return err == nil && ok
+}
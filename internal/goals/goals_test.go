@@ -0,0 +1,87 @@
+package goals_test
+
+import (
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     string
+		want    goals.Goal
+		wantErr bool
+	}{
+		{"method path and status", "signup = POST /register with 302", goals.Goal{Name: "signup", Method: "POST", PathPattern: "/register", StatusCode: 302}, false},
+		{"path only", "docs = /docs/*", goals.Goal{Name: "docs", PathPattern: "/docs/*"}, false},
+		{"lowercase method is normalized", "signup=post /register", goals.Goal{Name: "signup", Method: "POST", PathPattern: "/register"}, false},
+		{"path and status", "thanks = /thank-you with 200", goals.Goal{Name: "thanks", PathPattern: "/thank-you", StatusCode: 200}, false},
+		{"missing equals", "signup POST /register", goals.Goal{}, true},
+		{"empty name", " = /register", goals.Goal{}, true},
+		{"name with space", "sign up = /register", goals.Goal{}, true},
+		{"missing path", "signup = POST", goals.Goal{}, true},
+		{"relative path", "signup = POST register", goals.Goal{}, true},
+		{"bad status", "signup = /register with abc", goals.Goal{}, true},
+		{"out of range status", "signup = /register with 42", goals.Goal{}, true},
+		{"trailing garbage", "signup = /register with 302 extra", goals.Goal{}, true},
+		{"malformed glob", "signup = /register[", goals.Goal{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := goals.Parse(tt.def)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.def, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.def, got, tt.want)
+			}
+		})
+	}
+}
+
+// String must render a definition Parse accepts back into the same goal, so
+// `goals list` output can be pasted into `goals add`.
+func TestStringRoundTrips(t *testing.T) {
+	for _, def := range []string{"signup = POST /register with 302", "docs = /docs/*", "thanks = /thank-you with 200"} {
+		g, err := goals.Parse(def)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", def, err)
+		}
+		if got := g.String(); got != def {
+			t.Errorf("String() = %q, want %q", got, def)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	signup := goals.Goal{Name: "signup", Method: "POST", PathPattern: "/register", StatusCode: 302}
+	docs := goals.Goal{Name: "docs", PathPattern: "/docs/*"}
+
+	tests := []struct {
+		name   string
+		goal   goals.Goal
+		method string
+		path   string
+		status int
+		want   bool
+	}{
+		{"exact match", signup, "POST", "/register", 302, true},
+		{"query string ignored", signup, "POST", "/register?ref=nav", 302, true},
+		{"method mismatch", signup, "GET", "/register", 302, false},
+		{"status mismatch", signup, "POST", "/register", 200, false},
+		{"path mismatch", signup, "POST", "/register/extra", 302, false},
+		{"glob within segment", docs, "GET", "/docs/install", 200, true},
+		{"glob does not cross segments", docs, "GET", "/docs/a/b", 200, false},
+		{"any method and status", docs, "HEAD", "/docs/x", 404, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := goals.Matches(tt.goal, tt.method, tt.path, tt.status); got != tt.want {
+				t.Errorf("Matches(%s, %s %s %d) = %v, want %v", tt.goal, tt.method, tt.path, tt.status, got, tt.want)
+			}
+		})
+	}
+}
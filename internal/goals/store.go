@@ -0,0 +1,90 @@
+package goals
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrExists is returned by Add when a goal with the same name is already
+// defined; goals are keyed by name, and silently redefining one would mix
+// counts recorded under the old rule with the new one.
+var ErrExists = errors.New("goal already exists")
+
+// ErrNotFound is returned by Remove when no goal has the given name.
+var ErrNotFound = errors.New("goal not found")
+
+// Add stores g in the goals table.
+func Add(ctx context.Context, db *sql.DB, g Goal) error {
+	if err := Validate(g); err != nil {
+		return err
+	}
+
+	var exists int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM goals WHERE name = ?`, g.Name).Scan(&exists); err != nil {
+		return fmt.Errorf("checking for goal %q: %w", g.Name, err)
+	}
+	if exists > 0 {
+		return fmt.Errorf("adding goal %q: %w", g.Name, ErrExists)
+	}
+
+	_, err := db.ExecContext(ctx, `
+	INSERT INTO goals (name, method, path_pattern, status_code, created_at)
+	VALUES (?, ?, ?, ?, datetime('now'))`,
+		g.Name, g.Method, g.PathPattern, g.StatusCode)
+	if err != nil {
+		return fmt.Errorf("adding goal %q: %w", g.Name, err)
+	}
+	return nil
+}
+
+// List returns every defined goal ordered by name.
+func List(ctx context.Context, db *sql.DB) ([]Goal, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, method, path_pattern, status_code FROM goals ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying goals: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	results := []Goal{}
+	for rows.Next() {
+		var g Goal
+		if err := rows.Scan(&g.Name, &g.Method, &g.PathPattern, &g.StatusCode); err != nil {
+			return nil, fmt.Errorf("scanning goal: %w", err)
+		}
+		results = append(results, g)
+	}
+	return results, rows.Err()
+}
+
+// Remove deletes the goal called name together with the completions recorded
+// for it, so re-adding a goal under the same name with a different rule
+// starts from zero instead of inheriting counts it never matched.
+func Remove(ctx context.Context, db *sql.DB, name string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("removing goal %q: %w", name, err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rollback after commit is a no-op
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM goals WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("removing goal %q: %w", name, err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("removing goal %q: %w", name, ErrNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM hourly_goals WHERE goal = ?`, name); err != nil {
+		return fmt.Errorf("removing completions for goal %q: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM goal_visitor_days WHERE goal = ?`, name); err != nil {
+		return fmt.Errorf("removing converting visitors for goal %q: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("removing goal %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package goals_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	if err := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestAddListRemove(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := t.Context()
+
+	signup := goals.Goal{Name: "signup", Method: "POST", PathPattern: "/register", StatusCode: 302}
+	docs := goals.Goal{Name: "docs", PathPattern: "/docs/*"}
+
+	for _, g := range []goals.Goal{signup, docs} {
+		if err := goals.Add(ctx, db, g); err != nil {
+			t.Fatalf("Add(%s): %v", g, err)
+		}
+	}
+
+	got, err := goals.List(ctx, db)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 2 || got[0] != docs || got[1] != signup {
+		t.Fatalf("List = %+v, want [docs signup] ordered by name", got)
+	}
+
+	if err := goals.Remove(ctx, db, "signup"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	got, err = goals.List(ctx, db)
+	if err != nil {
+		t.Fatalf("List after Remove: %v", err)
+	}
+	if len(got) != 1 || got[0] != docs {
+		t.Errorf("List after Remove = %+v, want [docs]", got)
+	}
+}
+
+func TestAddDuplicateName(t *testing.T) {
+	db := setupTestDB(t)
+	g := goals.Goal{Name: "signup", PathPattern: "/register"}
+
+	if err := goals.Add(t.Context(), db, g); err != nil {
+		t.Fatalf("first Add: %v", err)
+	}
+	err := goals.Add(t.Context(), db, goals.Goal{Name: "signup", PathPattern: "/join"})
+	if !errors.Is(err, goals.ErrExists) {
+		t.Errorf("second Add error = %v, want ErrExists", err)
+	}
+}
+
+// Removing a goal must also drop its recorded completions, so a later goal
+// re-added under the same name doesn't inherit counts from the old rule.
+func TestRemoveDropsCompletions(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := t.Context()
+
+	if err := goals.Add(ctx, db, goals.Goal{Name: "signup", PathPattern: "/register"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO hourly_goals (bucket, host, goal, completions, unique_visitors)
+		VALUES (492562, 'example.com', 'signup', 5, 3)`)
+	if err != nil {
+		t.Fatalf("seed hourly_goals: %v", err)
+	}
+
+	if err := goals.Remove(ctx, db, "signup"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	var remaining int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM hourly_goals`).Scan(&remaining); err != nil {
+		t.Fatalf("count hourly_goals: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("expected hourly_goals rows for removed goal to be deleted, %d remain", remaining)
+	}
+}
+
+func TestRemoveMissingGoal(t *testing.T) {
+	db := setupTestDB(t)
+
+	err := goals.Remove(t.Context(), db, "nope")
+	if !errors.Is(err, goals.ErrNotFound) {
+		t.Errorf("Remove error = %v, want ErrNotFound", err)
+	}
+}
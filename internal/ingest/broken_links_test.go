@@ -0,0 +1,52 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+// Only error responses to people are recorded, keyed by the page that
+// linked to them; redirects, 304s and bots never are.
+func TestProcessPageviewsRecordsBrokenLinks(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	ts := time.Now()
+	pageViews := make(chan PageView, 10)
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/old-post", Referrer: "https://twitter.com/", StatusCode: 404, IDHash: "a"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/old-post", Referrer: "https://twitter.com/", StatusCode: 404, IDHash: "b"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/", Referrer: "https://twitter.com/", StatusCode: 200, IDHash: "a"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/", Referrer: "-", StatusCode: 304, IDHash: "a"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/blog", Referrer: "https://twitter.com/", StatusCode: 301, IDHash: "a"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/login", Referrer: "-", StatusCode: 302, IDHash: "b"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/missing", Referrer: "-", StatusCode: 404, IDHash: "crawler", IsBot: true}
+	close(pageViews)
+
+	processPageviews(t.Context(), db, pageViews, newConversionSwitch(conversionRules{}), newRecentWindows(), newDaemonMetrics("test", time.Now()))
+
+	rows, err := db.QueryContext(t.Context(), `SELECT path, referrer, status_code, count FROM hourly_broken_links`)
+	if err != nil {
+		t.Fatalf("query hourly_broken_links: %v", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	var got []string
+	for rows.Next() {
+		var path, referrer string
+		var status, count int
+		if err := rows.Scan(&path, &referrer, &status, &count); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		if path != "/old-post" || referrer != "https://twitter.com/" || status != 404 || count != 2 {
+			t.Errorf("unexpected broken link row: %s %s %d %d", path, referrer, status, count)
+		}
+		got = append(got, path)
+	}
+	if len(got) != 1 {
+		t.Errorf("expected exactly one broken link row, got %v", got)
+	}
+}
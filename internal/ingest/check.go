@@ -0,0 +1,182 @@
+package ingest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Log formats a line can match, named after the parser's two patterns.
+const (
+	// FormatWithHost is nginx's combined format with "$host" appended
+	// (regexWithHost).
+	FormatWithHost = "with_host"
+	// FormatStandard is plain combined format (regexStandard); such lines
+	// are attributed to the default host.
+	FormatStandard = "standard"
+)
+
+// CheckConfig is the part of the daemon's configuration that decides how a
+// line is read.
+type CheckConfig struct {
+	DefaultHost string
+	Rules       Rules
+	StageOrder  []string
+}
+
+// LineCheck is how the daemon would read one log line.
+type LineCheck struct {
+	// Number is the 1-based line number in the input.
+	Number int
+	// Format is FormatWithHost, FormatStandard, or empty when neither
+	// pattern matched.
+	Format string
+	// Reason is why the line was rejected (ReasonNoMatch, ...); empty when
+	// it parsed.
+	Reason string
+	// Sample is the rejected line, redacted the same way as in the
+	// dead-letter file; empty when the line parsed.
+	Sample string
+	// PageView is the line as it would be counted, after the pipeline
+	// stages. When a stage dropped it, it's the page view as parsed.
+	PageView PageView
+	// Dropped is set when the line parsed but a pipeline stage (usually an
+	// exclusion rule) dropped it.
+	Dropped bool
+}
+
+// CheckLines reads up to limit lines from r (0 for all of them) and reports
+// how the daemon would handle each, without counting anything.
+func CheckLines(r io.Reader, cfg CheckConfig, limit int) ([]LineCheck, error) {
+	rules, err := compileRules(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	stages, err := buildPipeline(cfg.StageOrder, nil, newRuleSwitch(rules))
+	if err != nil {
+		return nil, err
+	}
+	defaultHost := resolveDefaultHost(cfg.DefaultHost)
+
+	checks := []LineCheck{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineSize*2)
+	scanner.Split(splitLinesSkippingOverlong(maxLogLineSize, func(int) {
+		checks = append(checks, LineCheck{Number: len(checks) + 1, Reason: ReasonOverlong})
+	}))
+
+	for (limit <= 0 || len(checks) < limit) && scanner.Scan() {
+		checks = append(checks, checkLine(len(checks)+1, scanner.Text(), defaultHost, stages))
+	}
+	if err := scanner.Err(); err != nil {
+		return checks, err
+	}
+	if limit > 0 && len(checks) > limit {
+		// An overlong line skipped while scanning for the last one can
+		// overshoot by one.
+		checks = checks[:limit]
+	}
+	return checks, nil
+}
+
+func checkLine(number int, line, defaultHost string, stages []NamedStage) LineCheck {
+	check := LineCheck{Number: number}
+	if _, withHost, err := determineMatchingPattern(line); err == nil {
+		check.Format = FormatStandard
+		if withHost {
+			check.Format = FormatWithHost
+		}
+	}
+
+	parsed, err := parseNginxLog(line, defaultHost)
+	if err != nil {
+		check.Reason = rejectReason(err)
+		check.Sample = redactLine(line)
+		return check
+	}
+
+	counted, keep := runPipeline(stages, parsed)
+	if !keep {
+		parsed.RemoteIP = ""
+		check.PageView = parsed
+		check.Dropped = true
+		return check
+	}
+	check.PageView = counted
+	return check
+}
+
+// logFormatVariable matches an nginx variable in a log_format definition,
+// "$name" or "${name}".
+var logFormatVariable = regexp.MustCompile(`\$(\w+|\{\w+\})`)
+
+// sampleHost is what host variables expand to in LogFormatLayout's sample
+// line, so the field the parser takes as the host can be recognized.
+const sampleHost = "example.com"
+
+// sampleLogValue is a plausible value for an nginx variable when rendering
+// a sample line; variables theia doesn't read get a placeholder.
+func sampleLogValue(variable string) string {
+	switch variable {
+	case "remote_addr", "realip_remote_addr":
+		return "203.0.113.7"
+	case "remote_user":
+		return "-"
+	case "time_local":
+		return "20/Jul/2026:10:00:00 +0000"
+	case "request":
+		return "GET / HTTP/1.1"
+	case "request_method":
+		return "GET"
+	case "request_uri", "uri":
+		return "/"
+	case "server_protocol":
+		return "HTTP/1.1"
+	case "status":
+		return "200"
+	case "body_bytes_sent", "bytes_sent":
+		return "512"
+	case "http_referer":
+		return "-"
+	case "http_user_agent":
+		return "Mozilla/5.0"
+	case "host", "http_host", "server_name":
+		return sampleHost
+	default:
+		return "x"
+	}
+}
+
+// LogFormatLayout reports which of the parser's formats lines written with
+// the nginx log_format definition format would match: FormatWithHost or
+// FormatStandard. It returns an error saying why when the lines would be
+// rejected, or when the field read as the host isn't a host variable.
+func LogFormatLayout(format string) (string, error) {
+	if format == "" {
+		return "", errors.New("empty log_format")
+	}
+	sample := logFormatVariable.ReplaceAllStringFunc(format, func(v string) string {
+		name := v[1:]
+		if name[0] == '{' {
+			name = name[1 : len(name)-1]
+		}
+		return sampleLogValue(name)
+	})
+
+	matches, withHost, err := determineMatchingPattern(sample)
+	if err != nil {
+		return "", errors.New(`lines don't start with the combined fields ($remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent")`)
+	}
+	if _, err := parseNginxLog(sample, ""); err != nil {
+		return "", fmt.Errorf("lines would be rejected as %s: %w", rejectReason(err), err)
+	}
+	if !withHost {
+		return FormatStandard, nil
+	}
+	if matches[9] != sampleHost {
+		return "", errors.New(`the quoted field after "$http_user_agent" would be read as the host, but it isn't $host`)
+	}
+	return FormatWithHost, nil
+}
@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	checkLineWithHost = `203.0.113.7 - - [20/Jul/2026:10:00:00 +0000] "GET /about/ HTTP/1.1" 200 512 "-" "Mozilla/5.0" "WWW.Example.com"`
+	checkLineStandard = `203.0.113.7 - - [20/Jul/2026:10:00:00 +0000] "GET /style.css HTTP/1.1" 200 512 "-" "Googlebot/2.1"`
+	checkLineBadTime  = `203.0.113.7 - - [not a time] "GET / HTTP/1.1" 200 512 "-" "Mozilla/5.0" "example.com"`
+	checkLineGarbage  = `203.0.113.7 GET /secret?token=abc`
+)
+
+func TestCheckLines_ReportsFormatAndClassification(t *testing.T) {
+	t.Setenv("THEIA_DEFAULT_HOST", "")
+	input := strings.Join([]string{checkLineWithHost, checkLineStandard, checkLineBadTime, checkLineGarbage}, "\n")
+
+	checks, err := CheckLines(strings.NewReader(input), CheckConfig{DefaultHost: "fallback.example"}, 0)
+	if err != nil {
+		t.Fatalf("CheckLines: %v", err)
+	}
+	if len(checks) != 4 {
+		t.Fatalf("got %d checks, want 4", len(checks))
+	}
+
+	withHost := checks[0]
+	if withHost.Number != 1 || withHost.Format != FormatWithHost || withHost.Reason != "" || withHost.Dropped {
+		t.Errorf("line 1 = %+v, want a counted with_host line", withHost)
+	}
+	if withHost.PageView.Host != "www.example.com" || withHost.PageView.Path != "/about/" {
+		t.Errorf("line 1 host/path = %q %q", withHost.PageView.Host, withHost.PageView.Path)
+	}
+	if withHost.PageView.RemoteIP != "" {
+		t.Errorf("line 1 RemoteIP = %q, want it cleared", withHost.PageView.RemoteIP)
+	}
+
+	standard := checks[1]
+	if standard.Format != FormatStandard || standard.PageView.Host != "fallback.example" {
+		t.Errorf("line 2 = %+v, want standard format on the default host", standard)
+	}
+	if !standard.PageView.IsBot || !standard.PageView.IsStatic {
+		t.Errorf("line 2 bot/static = %v/%v, want both set", standard.PageView.IsBot, standard.PageView.IsStatic)
+	}
+
+	if checks[2].Reason != ReasonBadTimestamp || checks[2].Format != FormatWithHost {
+		t.Errorf("line 3 = %+v, want bad_timestamp on a with_host line", checks[2])
+	}
+
+	garbage := checks[3]
+	if garbage.Reason != ReasonNoMatch || garbage.Format != "" {
+		t.Errorf("line 4 = %+v, want no_match with no format", garbage)
+	}
+	if strings.Contains(garbage.Sample, "203.0.113.7") || strings.Contains(garbage.Sample, "token=abc") {
+		t.Errorf("line 4 sample %q is not redacted", garbage.Sample)
+	}
+}
+
+func TestCheckLines_AppliesRules(t *testing.T) {
+	cfg := CheckConfig{Rules: Rules{
+		HostAliases:        map[string]string{"www.example.com": "example.com"},
+		ExcludePaths:       []string{"/style.css"},
+		StripTrailingSlash: true,
+	}}
+	input := checkLineWithHost + "\n" + checkLineStandard + "\n"
+
+	checks, err := CheckLines(strings.NewReader(input), cfg, 0)
+	if err != nil {
+		t.Fatalf("CheckLines: %v", err)
+	}
+	if got := checks[0].PageView; got.Host != "example.com" || got.Path != "/about" || checks[0].Dropped {
+		t.Errorf("line 1 = %+v, want aliased host and stripped slash", checks[0])
+	}
+	if !checks[1].Dropped || checks[1].Reason != "" || checks[1].PageView.Path != "/style.css" {
+		t.Errorf("line 2 = %+v, want dropped by the path rule with the parsed page view kept", checks[1])
+	}
+}
+
+func TestCheckLines_Limit(t *testing.T) {
+	input := strings.Repeat(checkLineWithHost+"\n", 10)
+
+	checks, err := CheckLines(strings.NewReader(input), CheckConfig{}, 3)
+	if err != nil {
+		t.Fatalf("CheckLines: %v", err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("got %d checks, want 3", len(checks))
+	}
+}
+
+func TestCheckLines_Overlong(t *testing.T) {
+	input := strings.Repeat("x", maxLogLineSize+10) + "\n" + checkLineWithHost + "\n"
+
+	checks, err := CheckLines(strings.NewReader(input), CheckConfig{}, 0)
+	if err != nil {
+		t.Fatalf("CheckLines: %v", err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("got %d checks, want 2", len(checks))
+	}
+	if checks[0].Reason != ReasonOverlong || checks[0].Number != 1 {
+		t.Errorf("line 1 = %+v, want overlong", checks[0])
+	}
+	if checks[1].Number != 2 || checks[1].Reason != "" {
+		t.Errorf("line 2 = %+v, want a parsed line numbered 2", checks[1])
+	}
+}
+
+func TestCheckLines_InvalidConfig(t *testing.T) {
+	if _, err := CheckLines(strings.NewReader(""), CheckConfig{StageOrder: []string{"nope"}}, 0); err == nil {
+		t.Error("want an error for an unknown stage")
+	}
+	if _, err := CheckLines(strings.NewReader(""), CheckConfig{Rules: Rules{ExcludePaths: []string{"no-slash"}}}, 0); err == nil {
+		t.Error("want an error for an invalid rule")
+	}
+}
+
+func TestLogFormatLayout(t *testing.T) {
+	const combined = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+	tests := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"combined", combined, FormatStandard, false},
+		{"combined with host", combined + ` "$host"`, FormatWithHost, false},
+		{"combined with braced server_name", combined + ` "${server_name}"`, FormatWithHost, false},
+		{"combined with extra unquoted fields", combined + ` $request_time`, FormatStandard, false},
+		{"split request", `$remote_addr - $remote_user [$time_local] "$request_method $request_uri $server_protocol" $status $bytes_sent "$http_referer" "$http_user_agent"`, FormatStandard, false},
+		{"quoted non-host field", combined + ` "$request_time"`, "", true},
+		{"iso timestamp", `$remote_addr - $remote_user [$time_iso8601] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`, "", true},
+		{"json", `{"ip":"$remote_addr","status":$status}`, "", true},
+		{"empty", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LogFormatLayout(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LogFormatLayout error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LogFormatLayout = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+const (
+	// DefaultParseFailureThreshold is the share of log lines that may fail to
+	// parse before the daemon warns. A handful of junk lines (scanners sending
+	// raw bytes, truncated writes) is normal; a log_format mismatch shows up
+	// as nearly every line failing.
+	DefaultParseFailureThreshold = 0.05
+
+	// maxDeadLetterSamplesPerHour caps how many rejected lines are written
+	// to the dead-letter file per hour: enough to diagnose a log_format
+	// mismatch, without the file tracking every line of a busy site.
+	maxDeadLetterSamplesPerHour = 100
+
+	// maxDeadLetterBytes is the size at which the dead-letter file is
+	// rotated to "<path>.1", so at most twice this is kept on disk.
+	maxDeadLetterBytes = 1 << 20 // 1 MiB
+
+	// maxDeadLetterLineSize truncates each sample; the start of a line is
+	// what shows which format it's in.
+	maxDeadLetterLineSize = 2048
+
+	// failureCheckLines and failureCheckInterval bound the window the failure
+	// ratio is judged over: whichever is reached first, provided at least
+	// minFailureCheckLines were seen so a single bad line on a quiet site
+	// doesn't read as a 100% failure rate.
+	failureCheckLines    = 500
+	failureCheckInterval = 5 * time.Minute
+	minFailureCheckLines = 20
+)
+
+var (
+	ipv4Pattern  = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	queryPattern = regexp.MustCompile(`\?[^\s"]*`)
+	// ipv6Candidate matches anything shaped like an IPv6 address; the
+	// timestamp's "2024:10:30:45" does too, so each match is confirmed
+	// with net.ParseIP before it's redacted.
+	ipv6Candidate = regexp.MustCompile(`[0-9A-Fa-f.]*:[0-9A-Fa-f:.]*`)
+)
+
+// lineRecorder is told the outcome of every line tailLog reads, so rejected
+// lines are counted and sampled instead of silently dropped.
+type lineRecorder interface {
+	accepted()
+	rejected(line, reason string)
+}
+
+// rejectLog is the daemon's lineRecorder: it persists per-reason failure
+// counts to hourly_parse_failures, keeps a capped, redacted sample of
+// rejected lines in a rotating dead-letter file, and warns when the share of
+// failing lines crosses threshold. It's only used from tailLog's goroutine.
+type rejectLog struct {
+	ctx        context.Context
+	db         *sql.DB
+	reasons    map[string]int
+	sampleHour time.Time
+	windowFrom time.Time
+	path       string
+	threshold  float64
+	samples    int
+	lines      int
+	failures   int
+}
+
+// newRejectLog returns a rejectLog writing samples to path (empty disables
+// the dead-letter file) and warning above threshold (0 or less uses
+// DefaultParseFailureThreshold).
+func newRejectLog(ctx context.Context, db *sql.DB, path string, threshold float64) *rejectLog {
+	if threshold <= 0 {
+		threshold = DefaultParseFailureThreshold
+	}
+	return &rejectLog{
+		ctx:        ctx,
+		db:         db,
+		path:       path,
+		threshold:  threshold,
+		reasons:    map[string]int{},
+		windowFrom: time.Now(),
+	}
+}
+
+func (r *rejectLog) accepted() {
+	r.lines++
+	r.checkFailureRatio(time.Now())
+}
+
+func (r *rejectLog) rejected(line, reason string) {
+	now := time.Now().UTC()
+
+	hourlyParseFailuresUpdateQuery := `
+	INSERT INTO hourly_parse_failures (bucket, reason, count)
+	VALUES (?, ?, ?)
+	ON CONFLICT(bucket, reason) DO UPDATE SET
+		count = count + ?
+	`
+	_, err := r.db.ExecContext(r.ctx, hourlyParseFailuresUpdateQuery,
+		bucket.Hour(now),
+		reason,
+		1,
+		1)
+	if err != nil {
+		fmt.Printf("Unable to write hourly parse failures into database, got: %v\n", err)
+	}
+
+	if line != "" {
+		r.sample(now, line, reason)
+	}
+
+	r.lines++
+	r.failures++
+	r.reasons[reason]++
+	r.checkFailureRatio(now)
+}
+
+// sample appends a redacted copy of line to the dead-letter file, unless the
+// hourly sample budget is spent.
+func (r *rejectLog) sample(now time.Time, line, reason string) {
+	if r.path == "" {
+		return
+	}
+	if hour := now.Truncate(time.Hour); !hour.Equal(r.sampleHour) {
+		r.sampleHour = hour
+		r.samples = 0
+	}
+	if r.samples >= maxDeadLetterSamplesPerHour {
+		return
+	}
+	r.samples++
+
+	entry := fmt.Sprintf("%s\t%s\t%s\n", now.UTC().Format(time.RFC3339), reason, redactLine(line))
+	if err := appendDeadLetter(r.path, entry); err != nil {
+		fmt.Printf("Unable to write dead-letter sample, got: %v\n", err)
+	}
+}
+
+// checkFailureRatio warns once per window if the window's share of failing
+// lines is above threshold, then starts a new window.
+func (r *rejectLog) checkFailureRatio(now time.Time) {
+	full := r.lines >= failureCheckLines
+	expired := now.Sub(r.windowFrom) >= failureCheckInterval && r.lines >= minFailureCheckLines
+	if !full && !expired {
+		return
+	}
+
+	if ratio := float64(r.failures) / float64(r.lines); ratio > r.threshold {
+		msg := fmt.Sprintf("WARNING: %d of the last %d log lines (%.0f%%) could not be parsed, mostly %s; "+
+			"check that nginx's log_format is the combined format theia expects",
+			r.failures, r.lines, ratio*100, mostCommonReason(r.reasons))
+		if r.path != "" {
+			msg += " (redacted samples in " + r.path + ")"
+		}
+		log.Print(msg)
+	}
+
+	r.windowFrom = now
+	r.lines = 0
+	r.failures = 0
+	clear(r.reasons)
+}
+
+func mostCommonReason(reasons map[string]int) string {
+	best, bestCount := "", 0
+	for reason, count := range reasons {
+		if count > bestCount || (count == bestCount && reason < best) {
+			best, bestCount = reason, count
+		}
+	}
+	return best
+}
+
+// redactLine strips what could identify a visitor from a rejected line
+// before it's written to disk: the leading client address field, any other
+// IPv4 or IPv6 address (e.g. an X-Forwarded-For value), and query strings,
+// which often carry tokens or emails. The rest of the line is kept as-is —
+// its shape is what shows how the log_format differs from what theia
+// expects.
+func redactLine(line string) string {
+	if _, rest, ok := strings.Cut(line, " "); ok {
+		line = "[ip] " + rest
+	}
+	line = ipv6Candidate.ReplaceAllStringFunc(line, func(candidate string) string {
+		if net.ParseIP(candidate) == nil {
+			return candidate
+		}
+		return "[ip]"
+	})
+	line = ipv4Pattern.ReplaceAllString(line, "[ip]")
+	line = queryPattern.ReplaceAllString(line, "?[redacted]")
+	if len(line) > maxDeadLetterLineSize {
+		line = strings.ToValidUTF8(line[:maxDeadLetterLineSize], "") + "…"
+	}
+	return line
+}
+
+// appendDeadLetter appends entry to the file at path, first rotating it to
+// "<path>.1" (replacing any previous one) if the entry would push it past
+// maxDeadLetterBytes.
+func appendDeadLetter(path, entry string) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.Size()+int64(len(entry)) > maxDeadLetterBytes:
+		if err := os.Rename(path, path+".1"); err != nil {
+			return fmt.Errorf("rotating dead-letter file %q: %w", path, err)
+		}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("checking dead-letter file %q: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:gosec // path is an operator-provided flag, not user input
+	if err != nil {
+		return fmt.Errorf("opening dead-letter file %q: %w", path, err)
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		_ = f.Close() // the write error is the one worth reporting
+		return fmt.Errorf("writing dead-letter file %q: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing dead-letter file %q: %w", path, err)
+	}
+	return nil
+}
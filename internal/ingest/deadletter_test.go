@@ -0,0 +1,166 @@
+package ingest
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func TestTailLogRecordsParseFailures(t *testing.T) {
+	db, tempDir := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	logPath := filepath.Join(tempDir, "access.log")
+	deadLetterPath := filepath.Join(tempDir, "rejected.log")
+	createTestLogFile(t, logPath, []string{
+		accessLogLine("/ok"),
+		`203.0.113.9 [24/Dec/2024:10:30:45 +0000] GET /login?token=secret 200`,
+		`203.0.113.9 - - [99/Nope/2024:10:30:45 +0000] "GET / HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+	})
+
+	pageViews := make(chan PageView, 100)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go processPageviewsWithWaitGroup(t.Context(), db, pageViews, &wg)
+
+	rejects := newRejectLog(t.Context(), db, deadLetterPath, 0)
+	if err := tailLog(t.Context(), []string{"-n", "+1", logPath}, testParseSettings(t), pageViews, rejects); err != nil {
+		t.Errorf("tailLog returned unexpected error: %v", err)
+	}
+	close(pageViews)
+	wg.Wait()
+
+	counts := map[string]int{}
+	rows, err := db.QueryContext(t.Context(), `SELECT reason, count FROM hourly_parse_failures`)
+	if err != nil {
+		t.Fatalf("query parse failures: %v", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+	for rows.Next() {
+		var reason string
+		var count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		counts[reason] = count
+	}
+	if counts[ReasonNoMatch] != 1 || counts[ReasonBadTimestamp] != 1 || len(counts) != 2 {
+		t.Errorf("expected one no_match and one bad_timestamp failure, got %v", counts)
+	}
+
+	samples, err := os.ReadFile(deadLetterPath)
+	if err != nil {
+		t.Fatalf("read dead-letter file: %v", err)
+	}
+	if got := strings.Count(string(samples), "\n"); got != 2 {
+		t.Errorf("expected 2 dead-letter samples, got %d:\n%s", got, samples)
+	}
+	for _, leaked := range []string{"203.0.113.9", "token=secret"} {
+		if strings.Contains(string(samples), leaked) {
+			t.Errorf("dead-letter file leaks %q:\n%s", leaked, samples)
+		}
+	}
+}
+
+func TestRedactLine(t *testing.T) {
+	got := redactLine(`2001:db8::1 - - [24/Dec/2024:10:30:45 +0000] "GET /reset?email=a@b.c HTTP/1.1" 200 1 "-" "Mozilla/5.0" "10.0.0.7"`)
+	want := `[ip] - - [24/Dec/2024:10:30:45 +0000] "GET /reset?[redacted] HTTP/1.1" 200 1 "-" "Mozilla/5.0" "[ip]"`
+	if got != want {
+		t.Errorf("redactLine() =\n%s\nwant\n%s", got, want)
+	}
+
+	got = redactLine(`198.51.100.1 - - [24/Dec/2024:10:30:45 +0000] "GET / HTTP/1.1" 200 1 "-" "Mozilla/5.0" "2001:db8::7, ::ffff:198.51.100.4"`)
+	want = `[ip] - - [24/Dec/2024:10:30:45 +0000] "GET / HTTP/1.1" 200 1 "-" "Mozilla/5.0" "[ip], [ip]"`
+	if got != want {
+		t.Errorf("redactLine() with an IPv6 X-Forwarded-For =\n%s\nwant\n%s", got, want)
+	}
+
+	long := redactLine("x " + strings.Repeat("a", maxDeadLetterLineSize*2))
+	if len(long) > maxDeadLetterLineSize+len("…") {
+		t.Errorf("expected sample truncated to %d bytes, got %d", maxDeadLetterLineSize, len(long))
+	}
+}
+
+func TestAppendDeadLetterRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rejected.log")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), maxDeadLetterBytes-1), 0o600); err != nil {
+		t.Fatalf("seed dead-letter file: %v", err)
+	}
+
+	if err := appendDeadLetter(path, "new entry\n"); err != nil {
+		t.Fatalf("appendDeadLetter: %v", err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read current file: %v", err)
+	}
+	if string(current) != "new entry\n" {
+		t.Errorf("expected a fresh file holding only the new entry, got %d bytes", len(current))
+	}
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() != maxDeadLetterBytes-1 {
+		t.Errorf("expected the full file rotated to %s.1, got %v", path, err)
+	}
+}
+
+func TestRejectLogSamplesAreCappedPerHour(t *testing.T) {
+	db, tempDir := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	path := filepath.Join(tempDir, "rejected.log")
+
+	rejects := newRejectLog(t.Context(), db, path, 1)
+	for range maxDeadLetterSamplesPerHour + 5 {
+		rejects.rejected("garbage", ReasonNoMatch)
+	}
+
+	samples, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read dead-letter file: %v", err)
+	}
+	// The hour can roll over mid-loop, which resets the budget; either way
+	// the file must stay within two hours' worth of samples.
+	if got := strings.Count(string(samples), "\n"); got < maxDeadLetterSamplesPerHour || got > 2*maxDeadLetterSamplesPerHour {
+		t.Errorf("expected about %d samples, got %d", maxDeadLetterSamplesPerHour, got)
+	}
+}
+
+func TestRejectLogWarnsAboveThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	healthy := newRejectLog(t.Context(), db, "", 0.5)
+	for i := range failureCheckLines {
+		if i%10 == 0 {
+			healthy.rejected("", ReasonNoMatch)
+		} else {
+			healthy.accepted()
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no warning at a 10%% failure rate with a 50%% threshold, got: %s", buf.String())
+	}
+
+	broken := newRejectLog(t.Context(), db, "", 0.5)
+	for range failureCheckLines {
+		broken.rejected("", ReasonNoMatch)
+	}
+	if !strings.Contains(buf.String(), "could not be parsed, mostly no_match") {
+		t.Errorf("expected a parse failure warning, got: %q", buf.String())
+	}
+}
@@ -0,0 +1,150 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+)
+
+// maxFunnelVisitors bounds how many (funnel, host, visitor, day) entries the
+// funnel evaluator keeps in memory. Each entry is a visitor hash plus a step
+// index, so the cap keeps the evaluator to a few tens of MiB no matter how
+// much traffic (or scanner noise) arrives in one day.
+const maxFunnelVisitors = 100_000
+
+// funnelVisitorKey identifies one visitor's progress through one funnel on
+// one day. Visitor hashes rotate daily, so progress never carries across days.
+type funnelVisitorKey struct {
+	funnel string
+	host   string
+	hash   string
+	day    int64
+}
+
+// funnelTracker is the bounded, in-memory state behind funnel evaluation:
+// the highest step (0-based) each visitor has reached today. Only the
+// aggregate step counts derived from it are persisted — visitor progress is
+// never written to disk, so there's nothing to resume from after a restart:
+// a visitor partway through a funnel starts over, counting at step 1 again
+// if they come back to it, and can't reach later steps until they do.
+type funnelTracker struct {
+	progress  map[funnelVisitorKey]int
+	funnels   []funnels.Funnel
+	latestDay int64
+	full      bool
+}
+
+func newFunnelTracker(defs []funnels.Funnel) *funnelTracker {
+	return &funnelTracker{progress: map[funnelVisitorKey]int{}, funnels: defs}
+}
+
+// setFunnels swaps in reloaded funnel definitions. Progress through a funnel
+// whose steps are unchanged carries over; progress through one that was
+// removed or redefined is dropped, as it no longer means the same thing.
+func (t *funnelTracker) setFunnels(defs []funnels.Funnel) {
+	unchanged := map[string]bool{}
+	for _, f := range defs {
+		for _, old := range t.funnels {
+			if old.Name == f.Name && slices.Equal(old.Steps, f.Steps) {
+				unchanged[f.Name] = true
+			}
+		}
+	}
+	for key := range t.progress {
+		if !unchanged[key.funnel] {
+			delete(t.progress, key)
+		}
+	}
+	t.funnels = defs
+}
+
+// funnelStepHit is a visitor reaching a funnel step (1-based) for the first
+// time on a given day.
+type funnelStepHit struct {
+	funnel string
+	step   int
+}
+
+// advance records pageView against every funnel and returns the steps it
+// newly reached. A visitor only advances to step N+1 by requesting a path
+// matching it after reaching step N on the same day; repeat visits to a step
+// already reached count nothing.
+func (t *funnelTracker) advance(pageView PageView) []funnelStepHit {
+	if len(t.funnels) == 0 || pageView.IsBot {
+		return nil
+	}
+
+	day := bucket.Day(pageView.Timestamp)
+	t.evictBefore(day)
+
+	var hits []funnelStepHit
+	for _, f := range t.funnels {
+		key := funnelVisitorKey{funnel: f.Name, host: pageView.Host, hash: pageView.IDHash, day: day}
+		reached, tracked := t.progress[key]
+
+		next := 0
+		if tracked {
+			next = reached + 1
+		}
+		if next >= len(f.Steps) || !goals.MatchPath(f.Steps[next], pageView.Path) {
+			continue
+		}
+
+		if !tracked && len(t.progress) >= maxFunnelVisitors {
+			if !t.full {
+				log.Printf("Warning: funnel evaluator tracking %d visitors, not starting new ones until the day rolls over", maxFunnelVisitors)
+				t.full = true
+			}
+			continue
+		}
+
+		t.progress[key] = next
+		hits = append(hits, funnelStepHit{funnel: f.Name, step: next + 1})
+	}
+	return hits
+}
+
+// evictBefore drops progress older than the day before day once a newer day
+// starts. Yesterday is kept so lines logged just before midnight but
+// processed after it can still advance.
+func (t *funnelTracker) evictBefore(day int64) {
+	if day <= t.latestDay {
+		return
+	}
+	t.latestDay = day
+	t.full = false
+	for key := range t.progress {
+		if key.day < day-1 {
+			delete(t.progress, key)
+		}
+	}
+}
+
+func recordFunnelProgress(ctx context.Context, db *sql.DB, tracker *funnelTracker, pageView PageView, metrics *daemonMetrics) {
+	for _, hit := range tracker.advance(pageView) {
+		dailyFunnelStepsUpdateQuery := `
+		INSERT INTO daily_funnel_steps (day, host, funnel, step, visitors)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(day, host, funnel, step) DO UPDATE SET
+			visitors = visitors + ?
+		`
+
+		_, err := db.ExecContext(ctx, dailyFunnelStepsUpdateQuery,
+			bucket.Day(pageView.Timestamp),
+			pageView.Host,
+			hit.funnel,
+			hit.step,
+			1,
+			1)
+		if err != nil {
+			fmt.Printf("Unable to write daily funnel steps into database, got: %v\n", err)
+			metrics.writeFailed("daily_funnel_steps")
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
+)
+
+var checkoutFunnel = funnels.Funnel{Name: "checkout", Steps: []string{"/pricing", "/signup", "/welcome"}}
+
+func funnelPageView(ts time.Time, hash, path string) PageView {
+	return PageView{Timestamp: ts, Host: "example.com", Method: "GET", Path: path, StatusCode: 200, IDHash: hash}
+}
+
+func TestFunnelTrackerAdvancesInOrderOnly(t *testing.T) {
+	tracker := newFunnelTracker([]funnels.Funnel{checkoutFunnel})
+	ts := time.Date(2026, time.July, 20, 10, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		hash, path string
+		wantStep   int // 0 = no new step reached
+	}{
+		{"alice", "/signup", 0},         // skipping step 1 doesn't count
+		{"alice", "/pricing", 1},        // step 1
+		{"alice", "/pricing?plan=x", 0}, // repeat of a reached step
+		{"alice", "/welcome", 0},        // skipping step 2 doesn't count
+		{"alice", "/signup", 2},         // step 2
+		{"alice", "/welcome", 3},        // step 3
+		{"bob", "/pricing", 1},          // a second visitor starts independently
+	}
+
+	for i, s := range steps {
+		hits := tracker.advance(funnelPageView(ts, s.hash, s.path))
+		got := 0
+		if len(hits) == 1 {
+			got = hits[0].step
+		}
+		if len(hits) > 1 || got != s.wantStep {
+			t.Errorf("step %d (%s %s): hits = %+v, want step %d", i, s.hash, s.path, hits, s.wantStep)
+		}
+	}
+}
+
+func TestFunnelTrackerIgnoresBotsAndResetsDaily(t *testing.T) {
+	tracker := newFunnelTracker([]funnels.Funnel{checkoutFunnel})
+	day1 := time.Date(2026, time.July, 20, 23, 0, 0, 0, time.UTC)
+	day3 := day1.AddDate(0, 0, 2)
+
+	bot := funnelPageView(day1, "crawler", "/pricing")
+	bot.IsBot = true
+	if hits := tracker.advance(bot); len(hits) != 0 {
+		t.Errorf("bot page view advanced the funnel: %+v", hits)
+	}
+
+	tracker.advance(funnelPageView(day1, "alice", "/pricing"))
+	if hits := tracker.advance(funnelPageView(day3, "alice", "/signup")); len(hits) != 0 {
+		t.Errorf("progress carried across days: %+v", hits)
+	}
+	if len(tracker.progress) != 0 {
+		t.Errorf("expected day-old progress to be evicted, %d entries remain", len(tracker.progress))
+	}
+}
+
+func TestProcessPageviewsRecordsFunnelSteps(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	ts := time.Date(2026, time.July, 20, 10, 0, 0, 0, time.UTC)
+	pageViews := make(chan PageView, 10)
+	for _, pv := range []PageView{
+		funnelPageView(ts, "alice", "/pricing"),
+		funnelPageView(ts, "alice", "/signup"),
+		funnelPageView(ts, "alice", "/welcome"),
+		funnelPageView(ts, "bob", "/pricing"),
+		funnelPageView(ts, "bob", "/signup"),
+		funnelPageView(ts, "carol", "/pricing"),
+	} {
+		pageViews <- pv
+	}
+	close(pageViews)
+
+	processPageviews(t.Context(), db, pageViews, newConversionSwitch(conversionRules{Funnels: []funnels.Funnel{checkoutFunnel}}), newRecentWindows(), newDaemonMetrics("test", time.Now()))
+
+	rows, err := db.QueryContext(t.Context(), `SELECT step, visitors FROM daily_funnel_steps WHERE funnel = 'checkout' ORDER BY step`)
+	if err != nil {
+		t.Fatalf("query daily_funnel_steps: %v", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	var got []int
+	for rows.Next() {
+		var step, visitors int
+		if err := rows.Scan(&step, &visitors); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, visitors)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+
+	want := []int{3, 2, 1}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("visitors per step = %v, want %v", got, want)
+	}
+}
+
+// A reload keeps progress through funnels it leaves alone and drops it for
+// ones it redefines or removes.
+func TestFunnelTrackerSetFunnelsKeepsUnchangedProgress(t *testing.T) {
+	trial := funnels.Funnel{Name: "trial", Steps: []string{"/pricing", "/trial"}}
+	tracker := newFunnelTracker([]funnels.Funnel{checkoutFunnel, trial})
+	ts := time.Date(2026, time.July, 20, 10, 0, 0, 0, time.UTC)
+	tracker.advance(funnelPageView(ts, "alice", "/pricing"))
+
+	tracker.setFunnels([]funnels.Funnel{checkoutFunnel, {Name: "trial", Steps: []string{"/pricing", "/start"}}})
+
+	hits := tracker.advance(funnelPageView(ts, "alice", "/signup"))
+	if len(hits) != 1 || hits[0].funnel != "checkout" || hits[0].step != 2 {
+		t.Errorf("hits = %+v, want checkout step 2 carried over the reload", hits)
+	}
+	if hits := tracker.advance(funnelPageView(ts, "alice", "/start")); len(hits) != 0 {
+		t.Errorf("hits = %+v, want progress through the redefined funnel dropped", hits)
+	}
+}
+
+// SIGHUP picks up goals and funnels added since the daemon started.
+func TestReloadConversionRulesReadsTheDatabase(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	conversions := newConversionSwitch(conversionRules{})
+	if err := funnels.Add(t.Context(), db, checkoutFunnel); err != nil {
+		t.Fatalf("funnels.Add: %v", err)
+	}
+	reloadConversionRules(t.Context(), db, conversions)
+
+	if got := conversions.load().Funnels; len(got) != 1 || got[0].Name != "checkout" {
+		t.Errorf("funnels after reload = %+v, want checkout", got)
+	}
+}
@@ -0,0 +1,46 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+)
+
+// A visitor completing the same goal twice on one day counts as two
+// completions but one converting visitor; bots and non-matching requests
+// never count.
+func TestProcessPageviewsRecordsGoalCompletions(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	ts := time.Date(2026, time.July, 20, 10, 0, 0, 0, time.UTC)
+	signup := goals.Goal{Name: "signup", Method: "POST", PathPattern: "/register", StatusCode: 302}
+
+	pageViews := make(chan PageView, 10)
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Method: "POST", Path: "/register", StatusCode: 302, IDHash: "alice"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Method: "POST", Path: "/register?again=1", StatusCode: 302, IDHash: "alice"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Method: "POST", Path: "/register", StatusCode: 302, IDHash: "bob"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Method: "POST", Path: "/register", StatusCode: 200, IDHash: "carol"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Method: "POST", Path: "/register", StatusCode: 302, IDHash: "crawler", IsBot: true}
+	close(pageViews)
+
+	processPageviews(t.Context(), db, pageViews, newConversionSwitch(conversionRules{Goals: []goals.Goal{signup}}), newRecentWindows(), newDaemonMetrics("test", time.Now()))
+
+	var completions, uniqueVisitors int
+	err := db.QueryRowContext(t.Context(),
+		`SELECT SUM(completions), SUM(unique_visitors) FROM hourly_goals WHERE goal = 'signup'`,
+	).Scan(&completions, &uniqueVisitors)
+	if err != nil {
+		t.Fatalf("query hourly_goals: %v", err)
+	}
+	if completions != 3 {
+		t.Errorf("completions = %d, want 3", completions)
+	}
+	if uniqueVisitors != 2 {
+		t.Errorf("unique_visitors = %d, want 2", uniqueVisitors)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
 )
 
 // expectedHourlyStat describes the expected shape of a single hourly_stats row
@@ -53,7 +54,7 @@ func runIngestScenario(t *testing.T, logLines []string) *sql.DB {
 	go processPageviewsWithWaitGroup(t.Context(), db, pageViews, &wg)
 
 	tailArgs := []string{"-n", "+1", logPath}
-	if err := tailLog(t.Context(), tailArgs, pageViews); err != nil {
+	if err := tailLog(t.Context(), tailArgs, testParseSettings(t), pageViews, newRejectLog(t.Context(), db, "", 0)); err != nil {
 		t.Errorf("tailLog returned unexpected error: %v", err)
 	}
 	close(pageViews)
@@ -76,11 +77,12 @@ func assertVisitorDayCount(t *testing.T, db *sql.DB) []VisitorDay {
 	return visitorDays
 }
 
-func assertAllVisitorDaysOnYearDay(t *testing.T, visitorDays []VisitorDay, year, yearDay int) {
+func assertAllVisitorDaysOn(t *testing.T, visitorDays []VisitorDay, day time.Time) {
 	t.Helper()
+	want := bucket.Day(day)
 	for _, visitorDay := range visitorDays {
-		if visitorDay.Year != year || visitorDay.YearDay != yearDay {
-			t.Errorf("Expected all the entries to be recorded on year_day %d of %d", yearDay, year)
+		if visitorDay.Day != want {
+			t.Errorf("Expected all the entries to be recorded on day %d (%s), got %d", want, day.Format(time.DateOnly), visitorDay.Day)
 			return
 		}
 	}
@@ -197,7 +199,7 @@ func TestRunSameDayInThePast(t *testing.T) {
 	db := runIngestScenario(t, testLogLines)
 
 	visitorDays := assertVisitorDayCount(t, db)
-	assertAllVisitorDaysOnYearDay(t, visitorDays, 2024, 359)
+	assertAllVisitorDaysOn(t, visitorDays, time.Date(2024, time.December, 24, 0, 0, 0, 0, time.UTC))
 	assertHourlyStats(t, db, []expectedHourlyStat{
 		{Pageviews: 1, BotViews: 0, IsStatic: false},
 		{Pageviews: 0, BotViews: 1, IsStatic: false},
@@ -273,7 +275,7 @@ func TestTailLogSkipsOverlongLineAndContinues(t *testing.T) {
 	go processPageviewsWithWaitGroup(t.Context(), db, pageViews, &wg)
 
 	tailArgs := []string{"-n", "+1", logPath}
-	if err := tailLog(t.Context(), tailArgs, pageViews); err != nil {
+	if err := tailLog(t.Context(), tailArgs, testParseSettings(t), pageViews, newRejectLog(t.Context(), db, "", 0)); err != nil {
 		t.Errorf("tailLog returned unexpected error: %v", err)
 	}
 	close(pageViews)
@@ -305,7 +307,7 @@ func TestRunStopsOnContextCancellation(t *testing.T) {
 
 	done := make(chan error, 1)
 	go func() {
-		done <- Run(ctx, dbPath, logPath)
+		done <- Run(ctx, Config{DBPath: dbPath, LogPath: logPath})
 	}()
 
 	// Give "tail -f" time to start before simulating the shutdown signal.
@@ -332,7 +334,7 @@ func TestRun_ReturnsErrorForMissingLogFile(t *testing.T) {
 	dbPath := filepath.Join(tempDir, "test.db")
 	logPath := filepath.Join(tempDir, "does-not-exist.log")
 
-	err := Run(t.Context(), dbPath, logPath)
+	err := Run(t.Context(), Config{DBPath: dbPath, LogPath: logPath})
 	if err == nil {
 		t.Fatal("expected Run to return an error for a missing log file, got nil")
 	}
@@ -364,7 +366,7 @@ func TestRun_ReturnsErrorForUnreadableLogFile(t *testing.T) {
 		_ = os.Chmod(logPath, 0o600)
 	})
 
-	err := Run(t.Context(), dbPath, logPath)
+	err := Run(t.Context(), Config{DBPath: dbPath, LogPath: logPath})
 	if err == nil {
 		t.Fatal("expected Run to return an error for an unreadable log file, got nil")
 	}
@@ -408,12 +410,12 @@ func setupTestDB(t *testing.T) (*sql.DB, string) {
 
 func processPageviewsWithWaitGroup(ctx context.Context, db *sql.DB, pageViews <-chan PageView, wg *sync.WaitGroup) {
 	defer wg.Done()
-	processPageviews(ctx, db, pageViews)
+	processPageviews(ctx, db, pageViews, newConversionSwitch(conversionRules{}), newRecentWindows(), newDaemonMetrics("test", time.Now()))
 }
 
 func runPeriodicCleanupsWithWaitGroup(ctx context.Context, db *sql.DB, ticker *time.Ticker, wg *sync.WaitGroup) {
 	defer wg.Done()
-	runPeriodicCleanup(ctx, context.WithoutCancel(ctx), db, ticker)
+	runPeriodicCleanup(ctx, context.WithoutCancel(ctx), db, RetentionPolicy{Default: DefaultRetention()}, ticker, newDaemonMetrics("test", time.Now()))
 }
 
 func createTestLogFile(t *testing.T, logPath string, logLines []string) {
@@ -454,8 +456,7 @@ func getVisitorDays(t *testing.T, db *sql.DB) []VisitorDay {
 		err := visitorRows.Scan(
 			&visitorDay.Hash,
 			&visitorDay.Host,
-			&visitorDay.Year,
-			&visitorDay.YearDay,
+			&visitorDay.Day,
 			&visitorDay.FirstSeen,
 		)
 		if err != nil {
@@ -482,9 +483,7 @@ func getHourlyStats(t *testing.T, db *sql.DB) []HourlyStats {
 	for hourlyStatsRows.Next() {
 		var hourlyStat HourlyStats
 		err := hourlyStatsRows.Scan(
-			&hourlyStat.Hour,
-			&hourlyStat.YearDay,
-			&hourlyStat.Year,
+			&hourlyStat.Bucket,
 			&hourlyStat.Path,
 			&hourlyStat.Host,
 			&hourlyStat.Pageviews,
@@ -518,9 +517,7 @@ func getHourlyStatusCodes(t *testing.T, db *sql.DB) []HourlyStatusCodes {
 	for hourlyStatusCodesRows.Next() {
 		var hourlyStatusCode HourlyStatusCodes
 		err := hourlyStatusCodesRows.Scan(
-			&hourlyStatusCode.Hour,
-			&hourlyStatusCode.YearDay,
-			&hourlyStatusCode.Year,
+			&hourlyStatusCode.Bucket,
 			&hourlyStatusCode.Path,
 			&hourlyStatusCode.Host,
 			&hourlyStatusCode.StatusCode,
@@ -548,9 +545,7 @@ func getHourlyReferrers(t *testing.T, db *sql.DB) []HourlyReferrers {
 	for hourlyReferrersRows.Next() {
 		var hourlyReferrer HourlyReferrers
 		err := hourlyReferrersRows.Scan(
-			&hourlyReferrer.Hour,
-			&hourlyReferrer.YearDay,
-			&hourlyReferrer.Year,
+			&hourlyReferrer.Bucket,
 			&hourlyReferrer.Path,
 			&hourlyReferrer.Host,
 			&hourlyReferrer.Referrer,
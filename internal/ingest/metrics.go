@@ -0,0 +1,180 @@
+package ingest
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/promsink"
+)
+
+// dbWriteBuckets are the upper bounds, in seconds, of the page-view write
+// latency histogram: from the sub-millisecond upserts of a healthy local
+// disk up to the seconds a locked or saturated database takes.
+func dbWriteBuckets() []float64 {
+	return []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+}
+
+// daemonMetrics are the counters behind the daemon's own metrics endpoint.
+// The reader, writer and cleanup goroutines all update them, so every field
+// is atomic or guarded by mu.
+type daemonMetrics struct {
+	input string
+	start time.Time
+
+	linesRead   atomic.Uint64
+	linesParsed atomic.Uint64
+	dropped     atomic.Uint64
+	rotations   atomic.Uint64
+	// lastLineRead and lastIngested are Unix nanoseconds, 0 before the
+	// first line or page view.
+	lastLineRead atomic.Int64
+	lastIngested atomic.Int64
+
+	mu          sync.Mutex
+	failed      map[string]uint64
+	filtered    map[string]uint64
+	writeErrors map[string]uint64
+	writeBounds []float64
+	// writeCounts has one entry per bound plus a last one for slower
+	// writes; unlike promsink.Bucket, the counts are not cumulative.
+	writeCounts     []uint64
+	writeSum        time.Duration
+	cleanupRuns     uint64
+	cleanupTotal    time.Duration
+	lastCleanup     time.Time
+	lastCleanupTook time.Duration
+	reclaimedBytes  int64
+}
+
+func newDaemonMetrics(input string, start time.Time) *daemonMetrics {
+	bounds := dbWriteBuckets()
+	return &daemonMetrics{
+		input:       input,
+		start:       start,
+		failed:      map[string]uint64{},
+		filtered:    map[string]uint64{},
+		writeErrors: map[string]uint64{},
+		writeBounds: bounds,
+		writeCounts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (m *daemonMetrics) lineRead(now time.Time) {
+	m.linesRead.Add(1)
+	m.lastLineRead.Store(now.UnixNano())
+}
+
+func (m *daemonMetrics) lineFailed(reason string) {
+	m.mu.Lock()
+	m.failed[reason]++
+	m.mu.Unlock()
+}
+
+func (m *daemonMetrics) lineFiltered(stage string) {
+	m.mu.Lock()
+	m.filtered[stage]++
+	m.mu.Unlock()
+}
+
+func (m *daemonMetrics) writeFailed(table string) {
+	m.mu.Lock()
+	m.writeErrors[table]++
+	m.mu.Unlock()
+}
+
+// pageViewWritten records that the writer finished with a page view, and
+// how long that took.
+func (m *daemonMetrics) pageViewWritten(now time.Time, took time.Duration) {
+	m.lastIngested.Store(now.UnixNano())
+
+	seconds := took.Seconds()
+	bucket := len(m.writeBounds)
+	for i, bound := range m.writeBounds {
+		if seconds <= bound {
+			bucket = i
+			break
+		}
+	}
+	m.mu.Lock()
+	m.writeCounts[bucket]++
+	m.writeSum += took
+	m.mu.Unlock()
+}
+
+func (m *daemonMetrics) cleanupFinished(now time.Time, took time.Duration, reclaimed int64) {
+	m.mu.Lock()
+	m.cleanupRuns++
+	m.cleanupTotal += took
+	m.lastCleanup = now
+	m.lastCleanupTook = took
+	m.reclaimedBytes += reclaimed
+	m.mu.Unlock()
+}
+
+// snapshot copies the counters for one scrape. The queue is passed in
+// because it belongs to Run, not to the metrics.
+func (m *daemonMetrics) snapshot(queueDepth, queueCapacity int) promsink.DaemonSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	histogram := promsink.Histogram{Sum: m.writeSum.Seconds()}
+	for i, bound := range m.writeBounds {
+		histogram.Count += m.writeCounts[i]
+		histogram.Buckets = append(histogram.Buckets, promsink.Bucket{UpperBound: bound, Count: histogram.Count})
+	}
+	histogram.Count += m.writeCounts[len(m.writeBounds)]
+
+	return promsink.DaemonSnapshot{
+		Input:               m.input,
+		StartTime:           m.start,
+		LinesRead:           m.linesRead.Load(),
+		LinesParsed:         m.linesParsed.Load(),
+		LinesFailed:         maps.Clone(m.failed),
+		LinesFiltered:       maps.Clone(m.filtered),
+		PageViewsDropped:    m.dropped.Load(),
+		DBWriteErrors:       maps.Clone(m.writeErrors),
+		DBWriteDuration:     histogram,
+		QueueDepth:          queueDepth,
+		QueueCapacity:       queueCapacity,
+		Rotations:           m.rotations.Load(),
+		CleanupRuns:         m.cleanupRuns,
+		CleanupSeconds:      m.cleanupTotal.Seconds(),
+		LastCleanupDuration: m.lastCleanupTook,
+		LastCleanup:         m.lastCleanup,
+		ReclaimedBytes:      m.reclaimedBytes,
+		LastLineRead:        unixNanoTime(m.lastLineRead.Load()),
+		LastIngested:        unixNanoTime(m.lastIngested.Load()),
+	}
+}
+
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// meteredLines is the lineRecorder Run hands the reader: every outcome is
+// counted in metrics and then passed on to lines.
+type meteredLines struct {
+	lines   lineRecorder
+	metrics *daemonMetrics
+}
+
+func (l meteredLines) accepted() {
+	l.metrics.lineRead(time.Now())
+	l.metrics.linesParsed.Add(1)
+	l.lines.accepted()
+}
+
+func (l meteredLines) rejected(line, reason string) {
+	l.metrics.lineRead(time.Now())
+	l.metrics.lineFailed(reason)
+	l.lines.rejected(line, reason)
+}
+
+func (l meteredLines) filtered(stage string) { l.metrics.lineFiltered(stage) }
+
+func (l meteredLines) rotated() { l.metrics.rotations.Add(1) }
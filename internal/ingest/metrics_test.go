@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonMetrics_CountsLineOutcomes(t *testing.T) {
+	metrics := newDaemonMetrics("/var/log/nginx/access.log", time.Now())
+	settings := parseSettings{
+		DefaultHost: "default",
+		Stages: []NamedStage{{Name: "no-admin", Stage: StageFunc(func(pv PageView) (PageView, bool) {
+			return pv, pv.Path != "/admin"
+		})}},
+	}
+	input := strings.Join([]string{
+		accessLogLine("/"),
+		"not an access log line",
+		accessLogLine("/admin"),
+		accessLogLine("/about"),
+	}, "\n")
+
+	pageViews := make(chan PageView, 10)
+	if err := scanLogLines(strings.NewReader(input), settings, pageViews, meteredLines{lines: discardLines{}, metrics: metrics}); err != nil {
+		t.Fatalf("scanLogLines: %v", err)
+	}
+
+	snap := metrics.snapshot(len(pageViews), cap(pageViews))
+	if snap.LinesRead != 4 || snap.LinesParsed != 3 {
+		t.Errorf("read/parsed = %d/%d, want 4/3", snap.LinesRead, snap.LinesParsed)
+	}
+	if !reflect.DeepEqual(snap.LinesFailed, map[string]uint64{ReasonNoMatch: 1}) {
+		t.Errorf("LinesFailed = %v", snap.LinesFailed)
+	}
+	if !reflect.DeepEqual(snap.LinesFiltered, map[string]uint64{"no-admin": 1}) {
+		t.Errorf("LinesFiltered = %v", snap.LinesFiltered)
+	}
+	if snap.QueueDepth != 2 || snap.QueueCapacity != 10 {
+		t.Errorf("queue = %d/%d, want 2/10", snap.QueueDepth, snap.QueueCapacity)
+	}
+	if snap.LastLineRead.IsZero() || !snap.LastIngested.IsZero() {
+		t.Errorf("LastLineRead = %v, LastIngested = %v; want only a line read", snap.LastLineRead, snap.LastIngested)
+	}
+}
+
+func TestDaemonMetrics_WriteHistogramIsCumulative(t *testing.T) {
+	metrics := newDaemonMetrics("access.log", time.Now())
+	now := time.Now()
+	metrics.pageViewWritten(now, 300*time.Microsecond)
+	metrics.pageViewWritten(now, 4*time.Millisecond)
+	metrics.pageViewWritten(now, 10*time.Second)
+	metrics.writeFailed("hourly_stats")
+
+	snap := metrics.snapshot(0, 100)
+	h := snap.DBWriteDuration
+	if h.Count != 3 {
+		t.Errorf("Count = %d, want 3", h.Count)
+	}
+	if len(h.Buckets) != len(dbWriteBuckets()) {
+		t.Fatalf("got %d buckets, want %d", len(h.Buckets), len(dbWriteBuckets()))
+	}
+	wantAt := map[float64]uint64{0.0005: 1, 0.0025: 1, 0.005: 2, 2.5: 2}
+	for _, b := range h.Buckets {
+		if want, ok := wantAt[b.UpperBound]; ok && b.Count != want {
+			t.Errorf("bucket le=%v = %d, want %d", b.UpperBound, b.Count, want)
+		}
+	}
+	if !snap.LastIngested.Equal(now) {
+		t.Errorf("LastIngested = %v, want %v", snap.LastIngested, now)
+	}
+	if !reflect.DeepEqual(snap.DBWriteErrors, map[string]uint64{"hourly_stats": 1}) {
+		t.Errorf("DBWriteErrors = %v", snap.DBWriteErrors)
+	}
+}
+
+func TestDaemonMetrics_SnapshotIsACopy(t *testing.T) {
+	metrics := newDaemonMetrics("access.log", time.Now())
+	metrics.lineFailed(ReasonNoMatch)
+	snap := metrics.snapshot(0, 0)
+	metrics.lineFailed(ReasonNoMatch)
+
+	if snap.LinesFailed[ReasonNoMatch] != 1 {
+		t.Errorf("snapshot changed after the fact: %v", snap.LinesFailed)
+	}
+}
+
+func TestRotationWatcher(t *testing.T) {
+	var rotations int
+	w := &rotationWatcher{onRotate: func() { rotations++ }}
+
+	// Notices can arrive split across writes; only complete lines count.
+	writes := []string{
+		"tail: '/var/log/nginx/access.log' has been replaced;  following new file\n",
+		"tail: /var/log/nginx/access.log: file trun",
+		"cated\ntail: cannot open '/var/log/nginx/access.log' for reading: No such file or directory\n",
+		"tail: '/var/log/nginx/access.log' has appeared;  following new file\n",
+	}
+	for _, s := range writes {
+		if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	if rotations != 3 {
+		t.Errorf("rotations = %d, want 3", rotations)
+	}
+}
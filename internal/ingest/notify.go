@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
+)
+
+// statusInterval is how often the daemon refreshes its systemd STATUS line
+// when the unit has no watchdog to set the pace.
+const statusInterval = 30 * time.Second
+
+// queueState reports how many page views wait for the writer, out of how
+// many the queue holds.
+type queueState func() (depth, capacity int)
+
+// superviseIngest keeps systemd's view of the daemon current until ctx is
+// canceled: a STATUS line with what has been ingested so far and, when the
+// unit sets WatchdogSec, a watchdog ping whenever ingestion is making
+// progress. Progress means the writer is keeping up — the queue is empty or
+// a page view was written since the last check — so a quiet log with no
+// traffic stays healthy, while a writer wedged on the database with lines
+// piling up stops the pings and gets the daemon restarted.
+func superviseIngest(ctx context.Context, notifier systemd.Notifier, metrics *daemonMetrics, queue queueState) {
+	interval := systemd.PingInterval(notifier)
+	if interval == 0 {
+		interval = statusInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastIngested := metrics.lastIngested.Load()
+	for {
+		select {
+		case <-ticker.C:
+			depth, capacity := queue()
+			ingested := metrics.lastIngested.Load()
+			stalled := depth > 0 && ingested == lastIngested
+			lastIngested = ingested
+
+			status := ingestStatus(metrics, depth, capacity)
+			switch {
+			case stalled:
+				log.Printf("Warning: no page view written since the last check with %d queued, withholding watchdog ping", depth)
+				notifyIngest(notifier, systemd.Status("Stalled: "+status))
+			case notifier.WatchdogInterval > 0:
+				notifyIngest(notifier, systemd.Watchdog, systemd.Status(status))
+			default:
+				notifyIngest(notifier, systemd.Status(status))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func ingestStatus(metrics *daemonMetrics, depth, capacity int) string {
+	return fmt.Sprintf("Reading %s: %d lines read, %d parsed, queue %d/%d",
+		metrics.input, metrics.linesRead.Load(), metrics.linesParsed.Load(), depth, capacity)
+}
+
+// notifyIngest sends states to systemd, logging rather than failing:
+// ingestion matters more than telling the service manager about it.
+func notifyIngest(notifier systemd.Notifier, states ...string) {
+	if err := systemd.Notify(notifier, states...); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
+)
+
+// fakeNotifySocket stands in for systemd's notify socket; see
+// readNotification for reading what was sent to it.
+func fakeNotifySocket(t *testing.T) (systemd.Notifier, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen on fake notify socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return systemd.Notifier{Socket: path}, conn
+}
+
+// readNotification returns the next datagram that starts with prefix,
+// skipping any others, or fails the test after a few seconds.
+func readNotification(t *testing.T, conn *net.UnixConn, prefix string) string {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("waiting for a %q notification: %v", prefix, err)
+		}
+		if msg := string(buf[:n]); strings.HasPrefix(msg, prefix) {
+			return msg
+		}
+	}
+}
+
+func TestRun_NotifiesReadyAndStopping(t *testing.T) {
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "access.fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Fatalf("mkfifo: %v", err)
+	}
+	notifier, conn := fakeNotifySocket(t)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(t.Context(), Config{DBPath: filepath.Join(dir, "test.db"), LogPath: fifo, Notifier: notifier})
+	}()
+
+	if got, want := readNotification(t, conn, systemd.Ready), "READY=1\nSTATUS=Reading "+fifo; got != want {
+		t.Errorf("ready notification = %q, want %q", got, want)
+	}
+
+	// Ending the stream ends Run, which must tell systemd it's stopping.
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open fifo for writing: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+	readNotification(t, conn, systemd.Stopping)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after the pipe's writer closed")
+	}
+}
+
+func TestSuperviseIngest_WatchdogFollowsWriterProgress(t *testing.T) {
+	notifier, conn := fakeNotifySocket(t)
+	notifier.WatchdogInterval = 20 * time.Millisecond
+	metrics := newDaemonMetrics("access.log", time.Now())
+	var depth atomic.Int64
+
+	ctx, cancel := context.WithCancel(t.Context())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		superviseIngest(ctx, notifier, metrics, func() (int, int) { return int(depth.Load()), 100 })
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	// An idle log with an empty queue is healthy.
+	readNotification(t, conn, systemd.Watchdog)
+
+	// Page views queued with none written since the last check: stalled.
+	depth.Store(5)
+	if got := readNotification(t, conn, "STATUS=Stalled"); !strings.Contains(got, "queue 5/100") {
+		t.Errorf("stalled status = %q, want it to show the queue", got)
+	}
+
+	// The writer catching up again resumes the pings.
+	metrics.pageViewWritten(time.Now(), time.Millisecond)
+	readNotification(t, conn, systemd.Watchdog)
+}
@@ -3,7 +3,7 @@ package ingest
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
+	"errors"
 	"os"
 	"regexp"
 	"strconv"
@@ -24,10 +24,48 @@ func NormalizeHost(host string) string {
 	return strings.ToLower(host)
 }
 
-func getDefaultHost() string {
+// Reasons a log line is rejected, as recorded in hourly_parse_failures.
+const (
+	// ReasonNoMatch means the line isn't in the combined format (with or
+	// without a trailing host field) at all — almost always a log_format
+	// mismatch.
+	ReasonNoMatch      = "no_match"
+	ReasonBadTimestamp = "bad_timestamp"
+	ReasonBadStatus    = "bad_status"
+	ReasonBadBytes     = "bad_bytes"
+	// ReasonOverlong means the line exceeded maxLogLineSize and was
+	// discarded before parsing.
+	ReasonOverlong = "overlong"
+)
+
+// parseError is a parseNginxLog failure tagged with why the line was
+// rejected, so failures can be counted per reason.
+type parseError struct {
+	reason string
+	msg    string
+}
+
+func (e *parseError) Error() string { return e.msg }
+
+// rejectReason returns the reason err was tagged with by parseNginxLog.
+func rejectReason(err error) string {
+	var pe *parseError
+	if errors.As(err, &pe) {
+		return pe.reason
+	}
+	return ReasonNoMatch
+}
+
+// resolveDefaultHost picks the host that log lines without one are
+// bucketed under: THEIA_DEFAULT_HOST, then the configured value, then
+// "default".
+func resolveDefaultHost(configured string) string {
 	if host := os.Getenv("THEIA_DEFAULT_HOST"); host != "" {
 		return host
 	}
+	if configured != "" {
+		return configured
+	}
 	return "default"
 }
 
@@ -43,10 +81,10 @@ func determineMatchingPattern(line string) (matches []string, withHost bool, err
 		return matchesStandard, false, nil
 	}
 
-	return nil, false, fmt.Errorf("failed to parse log line")
+	return nil, false, &parseError{reason: ReasonNoMatch, msg: "failed to parse log line"}
 }
 
-func parseNginxLog(line string) (PageView, error) {
+func parseNginxLog(line, defaultHost string) (PageView, error) {
 	matches, withHost, err := determineMatchingPattern(line)
 	if err != nil {
 		return PageView{}, err
@@ -54,6 +92,7 @@ func parseNginxLog(line string) (PageView, error) {
 
 	ip := matches[1]
 	timestamp := matches[2]
+	method := matches[3]
 	path := matches[4]
 	statusCode := matches[5]
 	bytesSent := matches[6]
@@ -63,42 +102,44 @@ func parseNginxLog(line string) (PageView, error) {
 	if withHost {
 		host = matches[9]
 	} else {
-		host = getDefaultHost()
+		host = defaultHost
 	}
 	host = NormalizeHost(host)
 
 	parsedTimestamp, err := time.Parse("02/Jan/2006:15:04:05 -0700", timestamp)
 	if err != nil {
-		return PageView{}, fmt.Errorf("failed to parse timestamp")
+		return PageView{}, &parseError{reason: ReasonBadTimestamp, msg: "failed to parse timestamp"}
 	}
 	statusCodeAsInt, err := strconv.Atoi(statusCode)
 	if err != nil {
-		return PageView{}, fmt.Errorf("failed to parse statuscode")
+		return PageView{}, &parseError{reason: ReasonBadStatus, msg: "failed to parse statuscode"}
 	}
 	bytesSentAsInt, err := strconv.Atoi(bytesSent)
 	if err != nil {
-		return PageView{}, fmt.Errorf("failed to parse bytes sent")
+		return PageView{}, &parseError{reason: ReasonBadBytes, msg: "failed to parse bytes sent"}
 	}
 
-	hashInput := ip + userAgent + time.Now().Format("2006-01-02")
+	// The salt rotates at UTC midnight, the same day boundary visitor_days
+	// counts in.
+	hashInput := ip + userAgent + time.Now().UTC().Format("2006-01-02")
 	hashedID := sha256.Sum256([]byte(hashInput))
 	hashedIDString := hex.EncodeToString(hashedID[:])
 
-	isBot := detectBot(userAgent)
-
-	isStatic := isStaticAsset(path)
-
+	// Classification (bot, static, scanner) is left to the pipeline stages,
+	// so it can be reordered, disabled or extended without touching parsing.
 	return PageView{
-		Timestamp:  parsedTimestamp,
+		// Every table is bucketed in UTC whatever offset nginx logged in;
+		// reports shift to a display timezone at query time.
+		Timestamp:  parsedTimestamp.UTC(),
 		Host:       host,
+		Method:     method,
 		Path:       path,
 		StatusCode: statusCodeAsInt,
 		BytesSent:  bytesSentAsInt,
 		Referrer:   referrer,
 		UserAgent:  userAgent,
 		IDHash:     hashedIDString,
-		IsBot:      isBot,
-		IsStatic:   isStatic,
+		RemoteIP:   ip,
 	}, nil
 }
 
@@ -1,6 +1,9 @@
 package ingest
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestNormalizeHost(t *testing.T) {
 	cases := map[string]string{
@@ -23,7 +26,7 @@ func TestParseNginxLogLowercasesHost(t *testing.T) {
 	variants := []string{"Example.com", "example.com", "EXAMPLE.COM"}
 	for _, h := range variants {
 		line := `127.0.0.1 - - [20/Jul/2026:10:00:00 +0000] "GET / HTTP/1.1" 200 512 "-" "Mozilla/5.0" "` + h + `"`
-		pv, err := parseNginxLog(line)
+		pv, err := parseNginxLog(line, "default")
 		if err != nil {
 			t.Fatalf("parseNginxLog(%q) error: %v", h, err)
 		}
@@ -32,3 +35,43 @@ func TestParseNginxLogLowercasesHost(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveDefaultHost(t *testing.T) {
+	t.Setenv("THEIA_DEFAULT_HOST", "")
+	if got := resolveDefaultHost(""); got != "default" {
+		t.Errorf("resolveDefaultHost(\"\") = %q, want %q", got, "default")
+	}
+	if got := resolveDefaultHost("example.com"); got != "example.com" {
+		t.Errorf("resolveDefaultHost(config) = %q, want the configured host", got)
+	}
+
+	t.Setenv("THEIA_DEFAULT_HOST", "env.example")
+	if got := resolveDefaultHost("example.com"); got != "env.example" {
+		t.Errorf("resolveDefaultHost with env = %q, want the env var to win", got)
+	}
+}
+
+func TestParseNginxLogUsesDefaultHostWithoutHostField(t *testing.T) {
+	line := `127.0.0.1 - - [20/Jul/2026:10:00:00 +0000] "GET / HTTP/1.1" 200 512 "-" "Mozilla/5.0"`
+	pv, err := parseNginxLog(line, "Example.com")
+	if err != nil {
+		t.Fatalf("parseNginxLog: %v", err)
+	}
+	if pv.Host != "example.com" {
+		t.Errorf("Host = %q, want the normalized default host", pv.Host)
+	}
+}
+
+// Buckets are keyed in UTC, so a line logged with a +0200 offset must land
+// in the same hour as the equivalent UTC line.
+func TestParseNginxLogNormalizesTimestampToUTC(t *testing.T) {
+	line := `127.0.0.1 - - [20/Jul/2026:01:30:00 +0200] "GET / HTTP/1.1" 200 512 "-" "Mozilla/5.0"`
+	pv, err := parseNginxLog(line, "default")
+	if err != nil {
+		t.Fatalf("parseNginxLog: %v", err)
+	}
+	want := time.Date(2026, 7, 19, 23, 30, 0, 0, time.UTC)
+	if !pv.Timestamp.Equal(want) || pv.Timestamp.Location() != time.UTC {
+		t.Errorf("Timestamp = %v, want %v", pv.Timestamp, want)
+	}
+}
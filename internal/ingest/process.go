@@ -4,37 +4,110 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"sync/atomic"
 	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+	"github.com/Elysium-Labs-EU/theia/internal/live"
 )
 
-func processPageviews(ctx context.Context, db *sql.DB, pageViews <-chan PageView) {
+// conversionRules are the operator-defined goals and funnels every page view
+// is evaluated against.
+type conversionRules struct {
+	Goals   []goals.Goal
+	Funnels []funnels.Funnel
+}
+
+// conversionSwitch holds the goals and funnels currently in force. Like
+// ruleSwitch, a reload swaps them whole; the writer notices the swap before
+// its next page view.
+type conversionSwitch struct {
+	current atomic.Pointer[conversionRules]
+}
+
+func newConversionSwitch(r conversionRules) *conversionSwitch {
+	s := &conversionSwitch{}
+	s.current.Store(&r)
+	return s
+}
+
+func (s *conversionSwitch) load() *conversionRules { return s.current.Load() }
+
+// reloadConversionRules re-reads the goals and funnels from db into s. One
+// that fails to load is logged and discarded, leaving the previous ones
+// active.
+func reloadConversionRules(ctx context.Context, db *sql.DB, s *conversionSwitch) {
+	r, err := loadConversionRules(ctx, db)
+	if err != nil {
+		log.Printf("Goal and funnel reload failed, keeping previous ones: %v", err)
+		return
+	}
+	s.current.Store(&r)
+	log.Printf("Goals and funnels reloaded: %d goal(s), %d funnel(s)", len(r.Goals), len(r.Funnels))
+}
+
+// recentWindows are the short-lived, in-memory views of traffic the live
+// endpoint serves.
+type recentWindows struct {
+	Visitors *live.Window
+	Scanners *live.Scanners
+}
+
+func newRecentWindows() recentWindows {
+	return recentWindows{Visitors: live.NewWindow(), Scanners: live.NewScanners()}
+}
+
+func processPageviews(ctx context.Context, db *sql.DB, pageViews <-chan PageView, conversions *conversionSwitch, recent recentWindows, metrics *daemonMetrics) {
+	// Funnel progress is per-visitor state, so it lives only as long as this
+	// loop and is owned by it alone.
+	rules := conversions.load()
+	tracker := newFunnelTracker(rules.Funnels)
+
 	for {
 		pageView, ok := <-pageViews
 
 		if !ok {
 			break
 		}
+		started := time.Now()
+
+		if reloaded := conversions.load(); reloaded != rules {
+			rules = reloaded
+			tracker.setFunnels(rules.Funnels)
+		}
+
+		// Scanner probes aren't visits: counting them as page views (and
+		// their 404s as status codes) would bury real traffic under noise.
+		if pageView.ScanSignature != "" {
+			recordScan(ctx, db, recent.Scanners, pageView, metrics)
+			metrics.pageViewWritten(time.Now(), time.Since(started))
+			continue
+		}
 
 		visitorDaysUpsertQuery := `
-		INSERT INTO visitor_days (hash, host, year, year_day, first_seen)
-		VALUES (?, ?, ?, ?, ?)
-		ON CONFLICT(hash, host, year, year_day) DO NOTHING
+		INSERT INTO visitor_days (hash, host, day, first_seen)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(hash, host, day) DO NOTHING
 		`
 
 		_, err := db.ExecContext(ctx, visitorDaysUpsertQuery,
 			pageView.IDHash,
 			pageView.Host,
-			pageView.Timestamp.Year(),
-			pageView.Timestamp.YearDay(),
+			bucket.Day(pageView.Timestamp),
 			pageView.Timestamp.Format("2006-01-02 15:04:05"))
 		if err != nil {
 			fmt.Printf("Unable to write visitor day into database, got: %v\n", err)
+			metrics.writeFailed("visitor_days")
 		}
 
 		hourlyStatsUpdateQuery := `
-		INSERT INTO hourly_stats (hour, year_day, year, path, host, page_views, is_static, bot_views)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host) DO UPDATE SET
+		INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host) DO UPDATE SET
 			page_views = page_views + ?,
 			bot_views = bot_views + ?
 		`
@@ -48,9 +121,7 @@ func processPageviews(ctx context.Context, db *sql.DB, pageViews <-chan PageView
 		}
 
 		_, err = db.ExecContext(ctx, hourlyStatsUpdateQuery,
-			pageView.Timestamp.Hour(),
-			pageView.Timestamp.YearDay(),
-			pageView.Timestamp.Year(),
+			bucket.Hour(pageView.Timestamp),
 			pageView.Path,
 			pageView.Host,
 			pageViewIncrement,
@@ -60,18 +131,18 @@ func processPageviews(ctx context.Context, db *sql.DB, pageViews <-chan PageView
 			botViewIncrement)
 		if err != nil {
 			fmt.Printf("Unable to write hourly stats into database, got: %v\n", err)
+			metrics.writeFailed("hourly_stats")
+			metrics.dropped.Add(1)
 		}
 
 		hourlyStatusCodesUpdateQuery := `
-		INSERT INTO hourly_status_codes (hour, year_day, year, path, host, status_code, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, status_code) DO UPDATE SET
+		INSERT INTO hourly_status_codes (bucket, path, host, status_code, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, status_code) DO UPDATE SET
 			count = count + ?
 		`
 		_, err = db.ExecContext(ctx, hourlyStatusCodesUpdateQuery,
-			pageView.Timestamp.Hour(),
-			pageView.Timestamp.YearDay(),
-			pageView.Timestamp.Year(),
+			bucket.Hour(pageView.Timestamp),
 			pageView.Path,
 			pageView.Host,
 			pageView.StatusCode,
@@ -79,19 +150,18 @@ func processPageviews(ctx context.Context, db *sql.DB, pageViews <-chan PageView
 			1)
 		if err != nil {
 			fmt.Printf("Unable to write hourly status codes into database, got: %v\n", err)
+			metrics.writeFailed("hourly_status_codes")
 		}
 
 		hourlyReferrersUpdateQuery := `
-		INSERT INTO hourly_referrers (hour, year_day, year, path, host, referrer, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, referrer) DO UPDATE SET
+		INSERT INTO hourly_referrers (bucket, path, host, referrer, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, referrer) DO UPDATE SET
 			count = count + ?
 		`
 
 		_, err = db.ExecContext(ctx, hourlyReferrersUpdateQuery,
-			pageView.Timestamp.Hour(),
-			pageView.Timestamp.YearDay(),
-			pageView.Timestamp.Year(),
+			bucket.Hour(pageView.Timestamp),
 			pageView.Path,
 			pageView.Host,
 			pageView.Referrer,
@@ -99,108 +169,172 @@ func processPageviews(ctx context.Context, db *sql.DB, pageViews <-chan PageView
 			1)
 		if err != nil {
 			fmt.Printf("Unable to write hourly referrers into database, got: %v\n", err)
+			metrics.writeFailed("hourly_referrers")
 		}
-	}
-}
 
-func performAllCleanups(ctx context.Context, db *sql.DB) {
-	if deleted, err := dbCleanUpOldHourlyStats(ctx, db); err != nil {
-		fmt.Printf("Hourly stats cleanup error: %v\n", err)
-	} else {
-		fmt.Printf("Cleaned up %d old hourly stats records\n", deleted)
+		recordBrokenLink(ctx, db, pageView, metrics)
+		recordGoalCompletions(ctx, db, pageView, rules.Goals, metrics)
+		recordFunnelProgress(ctx, db, tracker, pageView, metrics)
+		recordLive(recent.Visitors, pageView)
+		metrics.pageViewWritten(time.Now(), time.Since(started))
 	}
+}
 
-	if deleted, err := dbCleanUpOldHourlyStatusCodes(ctx, db); err != nil {
-		fmt.Printf("Status codes cleanup error: %v\n", err)
-	} else {
-		fmt.Printf("Cleaned up %d old status code records\n", deleted)
+// recordLive feeds pageView into the realtime window. Bots and static assets
+// are left out so "visitors right now" counts people reading pages, the same
+// population the unique visitor totals describe.
+func recordLive(window *live.Window, pageView PageView) {
+	if pageView.IsBot || pageView.IsStatic {
+		return
 	}
+	window.Record(live.Hit{
+		Time:     pageView.Timestamp,
+		Host:     pageView.Host,
+		Path:     pageView.Path,
+		Referrer: pageView.Referrer,
+		Visitor:  pageView.IDHash,
+	})
+}
 
-	if deleted, err := dbCleanUpOldHourlyReferrer(ctx, db); err != nil {
-		fmt.Printf("Referrers cleanup error: %v\n", err)
-	} else {
-		fmt.Printf("Cleaned up %d old referrer records\n", deleted)
+// recordBrokenLink counts an error response (4xx or 5xx) against the (path,
+// referrer, status) it happened for — the join hourly_status_codes and
+// hourly_referrers can't express, since each counts its dimension
+// independently. Redirects and 304 Not Modified still get the visitor to a
+// page, so they aren't broken, and bot requests are left out so the report
+// reflects what people ran into.
+func recordBrokenLink(ctx context.Context, db *sql.DB, pageView PageView, metrics *daemonMetrics) {
+	if pageView.IsBot || pageView.StatusCode < 400 {
+		return
 	}
 
-	if deleted, err := dbCleanUpOldVisitorDays(ctx, db); err != nil {
-		fmt.Printf("Visitor days cleanup error: %v\n", err)
-	} else {
-		fmt.Printf("Cleaned up %d old visitor day records\n", deleted)
+	hourlyBrokenLinksUpdateQuery := `
+	INSERT INTO hourly_broken_links (bucket, path, host, referrer, status_code, count)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(bucket, path, host, referrer, status_code) DO UPDATE SET
+		count = count + ?
+	`
+	_, err := db.ExecContext(ctx, hourlyBrokenLinksUpdateQuery,
+		bucket.Hour(pageView.Timestamp),
+		pageView.Path,
+		pageView.Host,
+		pageView.Referrer,
+		pageView.StatusCode,
+		1,
+		1)
+	if err != nil {
+		fmt.Printf("Unable to write hourly broken links into database, got: %v\n", err)
+		metrics.writeFailed("hourly_broken_links")
 	}
 }
 
-func dbCleanUpOldHourlyStats(ctx context.Context, db *sql.DB) (int64, error) {
-	cutoffDate := time.Now().AddDate(0, 0, -60)
-	cutoffYear := cutoffDate.Year()
-	cutoffYearDay := cutoffDate.YearDay()
-
-	query := `
-	DELETE FROM hourly_stats
-	WHERE year < ?
-	   OR (year = ? AND year_day < ?)`
-
-	result, err := db.ExecContext(ctx, query, cutoffYear, cutoffYear, cutoffYearDay)
+// recordScan counts pageView against its scanner signature and remembers the
+// offending IP in memory only.
+func recordScan(ctx context.Context, db *sql.DB, scanners *live.Scanners, pageView PageView, metrics *daemonMetrics) {
+	hourlyScansUpdateQuery := `
+	INSERT INTO hourly_scans (bucket, host, signature, count)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(bucket, host, signature) DO UPDATE SET
+		count = count + ?
+	`
+	_, err := db.ExecContext(ctx, hourlyScansUpdateQuery,
+		bucket.Hour(pageView.Timestamp),
+		pageView.Host,
+		pageView.ScanSignature,
+		1,
+		1)
 	if err != nil {
-		return 0, fmt.Errorf("could not delete old hourly stats records, %w", err)
+		fmt.Printf("Unable to write hourly scans into database, got: %v\n", err)
+		metrics.writeFailed("hourly_scans")
 	}
 
-	rowsDeleted, _ := result.RowsAffected()
-	return rowsDeleted, nil
+	if pageView.ClientIP != "" {
+		scanners.Record(live.ScanHit{Time: pageView.Timestamp, IP: pageView.ClientIP, Signature: pageView.ScanSignature})
+	}
 }
 
-func dbCleanUpOldHourlyStatusCodes(ctx context.Context, db *sql.DB) (int64, error) {
-	cutoffDate := time.Now().AddDate(0, 0, -60)
-	cutoffYear := cutoffDate.Year()
-	cutoffYearDay := cutoffDate.YearDay()
+// recordGoalCompletions counts pageView against every goal it completes.
+// Bot traffic never converts. A visitor is counted as a unique converter in
+// the hour of their first completion of that goal on that day — visitor
+// hashes rotate daily, the same granularity visitor_days uses — so summing
+// hourly unique_visitors over a range gives distinct converting visitors per
+// day, comparable with the unique visitor totals from visitor_days.
+func recordGoalCompletions(ctx context.Context, db *sql.DB, pageView PageView, goalDefs []goals.Goal, metrics *daemonMetrics) {
+	if pageView.IsBot {
+		return
+	}
 
-	query := `
-	DELETE FROM hourly_status_codes
-	WHERE year < ?
-	   OR (year = ? AND year_day < ?)`
+	for _, goal := range goalDefs {
+		if !goals.Matches(goal, pageView.Method, pageView.Path, pageView.StatusCode) {
+			continue
+		}
 
-	result, err := db.ExecContext(ctx, query, cutoffYear, cutoffYear, cutoffYearDay)
-	if err != nil {
-		return 0, fmt.Errorf("could not delete old hourly status codes records, %w", err)
-	}
+		goalVisitorDayInsertQuery := `
+		INSERT INTO goal_visitor_days (goal, hash, host, day)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(goal, hash, host, day) DO NOTHING
+		`
 
-	rowsDeleted, _ := result.RowsAffected()
-	return rowsDeleted, nil
-}
+		result, err := db.ExecContext(ctx, goalVisitorDayInsertQuery,
+			goal.Name,
+			pageView.IDHash,
+			pageView.Host,
+			bucket.Day(pageView.Timestamp))
+		if err != nil {
+			fmt.Printf("Unable to write goal visitor day into database, got: %v\n", err)
+			metrics.writeFailed("goal_visitor_days")
+			continue
+		}
 
-func dbCleanUpOldHourlyReferrer(ctx context.Context, db *sql.DB) (int64, error) {
-	cutoffDate := time.Now().AddDate(0, 0, -60)
-	cutoffYear := cutoffDate.Year()
-	cutoffYearDay := cutoffDate.YearDay()
+		uniqueIncrement := 0
+		if inserted, _ := result.RowsAffected(); inserted > 0 {
+			uniqueIncrement = 1
+		}
 
-	query := `
-	DELETE FROM hourly_referrers
-	WHERE year < ?
-	   OR (year = ? AND year_day < ?)`
+		hourlyGoalsUpdateQuery := `
+		INSERT INTO hourly_goals (bucket, host, goal, completions, unique_visitors)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, host, goal) DO UPDATE SET
+			completions = completions + ?,
+			unique_visitors = unique_visitors + ?
+		`
 
-	result, err := db.ExecContext(ctx, query, cutoffYear, cutoffYear, cutoffYearDay)
-	if err != nil {
-		return 0, fmt.Errorf("could not delete old hourly referrer records, %w", err)
+		_, err = db.ExecContext(ctx, hourlyGoalsUpdateQuery,
+			bucket.Hour(pageView.Timestamp),
+			pageView.Host,
+			goal.Name,
+			1,
+			uniqueIncrement,
+			1,
+			uniqueIncrement)
+		if err != nil {
+			fmt.Printf("Unable to write hourly goals into database, got: %v\n", err)
+			metrics.writeFailed("hourly_goals")
+		}
 	}
-
-	rowsDeleted, _ := result.RowsAffected()
-	return rowsDeleted, nil
 }
 
-func dbCleanUpOldVisitorDays(ctx context.Context, db *sql.DB) (int64, error) {
-	cutoffDate := time.Now().AddDate(0, 0, -60)
-	cutoffYear := cutoffDate.Year()
-	cutoffYearDay := cutoffDate.YearDay()
+// retentionDays is how many UTC days of rows the tables outside the
+// retention policy keep before they're deleted (goals, funnel steps, parse
+// failures, scans and broken links), and the policy's default for the
+// finest tier of stats, status codes, referrers and visitor_days.
+const retentionDays = 60
 
-	query := `
-	DELETE FROM visitor_days
-	WHERE year < ?
-	   OR (year = ? AND year_day < ?)`
+// cleanupCutoff is the oldest day bucket those tables keep at now: every
+// row from before it is deleted.
+func cleanupCutoff(now time.Time) int64 {
+	return bucket.Day(now.UTC().AddDate(0, 0, -retentionDays))
+}
+
+// PerformCleanup applies policy, then hands the pages it freed back to the
+// filesystem and returns how many bytes that reclaimed. The daemon runs it
+// every CleanupInterval; it prints what it rolls up and deletes.
+func PerformCleanup(ctx context.Context, db *sql.DB, policy RetentionPolicy) int64 {
+	runCleanup(ctx, db, planCleanup(policy, time.Now()), cleanupBatchSize)
 
-	result, err := db.ExecContext(ctx, query, cutoffYear, cutoffYear, cutoffYearDay)
+	reclaimed, err := database.IncrementalVacuum(ctx, db)
 	if err != nil {
-		return 0, fmt.Errorf("could not delete old visitor day records, %w", err)
+		fmt.Printf("Vacuum error: %v\n", err)
+		return 0
 	}
-
-	rowsDeleted, _ := result.RowsAffected()
-	return rowsDeleted, nil
+	return reclaimed
 }
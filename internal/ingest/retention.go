@@ -0,0 +1,243 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+// Retention is how many days the cleanup keeps each table's rows before
+// rolling them into the next tier or, once no coarser tier would keep them
+// longer, deleting them. HourlyStats, StatusCodes, Referrers and VisitorDays
+// cover the finest tier's tables; Daily and Monthly every daily_* and
+// monthly_* table.
+type Retention struct {
+	HourlyStats int
+	StatusCodes int
+	Referrers   int
+	VisitorDays int
+	Daily       int
+	Monthly     int
+}
+
+// DefaultRetention keeps the finest tier retentionDays, days two years and
+// months ten.
+func DefaultRetention() Retention {
+	return Retention{
+		HourlyStats: retentionDays,
+		StatusCodes: retentionDays,
+		Referrers:   retentionDays,
+		VisitorDays: retentionDays,
+		Daily:       2 * 365,
+		Monthly:     10 * 365,
+	}
+}
+
+// RetentionPolicy is the retention every host gets unless Hosts, keyed by
+// normalized host name, has one of its own.
+type RetentionPolicy struct {
+	Default Retention
+	Hosts   map[string]Retention
+}
+
+// ValidateRetention rejects a retention the cleanup couldn't honor: every
+// table must keep a positive number of days, and a tier must keep its rows
+// at least as long as the finer one they're rolled up from.
+func ValidateRetention(r Retention) error {
+	for _, f := range []struct {
+		name string
+		days int
+	}{
+		{"hourly_stats", r.HourlyStats},
+		{"status_codes", r.StatusCodes},
+		{"referrers", r.Referrers},
+		{"visitor_days", r.VisitorDays},
+		{"daily", r.Daily},
+		{"monthly", r.Monthly},
+	} {
+		if f.days <= 0 {
+			return fmt.Errorf("%s retention must be a positive number of days, got %d", f.name, f.days)
+		}
+	}
+
+	if finest := max(r.HourlyStats, r.StatusCodes, r.Referrers, r.VisitorDays); r.Daily < finest {
+		return fmt.Errorf("daily retention (%d days) must be at least the longest hourly one (%d days)", r.Daily, finest)
+	}
+	if r.Monthly < r.Daily {
+		return fmt.Errorf("monthly retention (%d days) must be at least the daily one (%d days)", r.Monthly, r.Daily)
+	}
+	return nil
+}
+
+func validateRetentionPolicy(policy RetentionPolicy) error {
+	if err := ValidateRetention(policy.Default); err != nil {
+		return fmt.Errorf("invalid retention: %w", err)
+	}
+	for _, host := range slices.Sorted(maps.Keys(policy.Hosts)) {
+		if err := ValidateRetention(policy.Hosts[host]); err != nil {
+			return fmt.Errorf("invalid retention for host %q: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// retentionScope is the hosts one Retention applies to: hosts itself when
+// include is set, every other host otherwise. name is the single host it's
+// limited to, empty when it isn't.
+type retentionScope struct {
+	name      string
+	hosts     []string
+	include   bool
+	retention Retention
+}
+
+// retentionScopes splits policy into one scope per host override, then the
+// default for every host without one.
+func retentionScopes(policy RetentionPolicy) []retentionScope {
+	overridden := slices.Sorted(maps.Keys(policy.Hosts))
+	scopes := make([]retentionScope, 0, len(overridden)+1)
+	for _, host := range overridden {
+		scopes = append(scopes, retentionScope{name: host, hosts: []string{host}, include: true, retention: policy.Hosts[host]})
+	}
+	return append(scopes, retentionScope{hosts: overridden, retention: policy.Default})
+}
+
+// cleanupStep expires table's rows before cutoff within scope: it rolls them
+// into table.into when rollUp is set, and deletes them otherwise. before is
+// when the first bucket kept starts.
+type cleanupStep struct {
+	table  tierTable
+	scope  retentionScope
+	rollUp bool
+	cutoff int64
+	before time.Time
+}
+
+// stepArgs are the bind values step's statements take.
+func stepArgs(step *cleanupStep) []any {
+	if !step.table.scoped {
+		return []any{step.cutoff}
+	}
+	// json_each('null') is a single NULL, which matches no host either way,
+	// so an empty list has to be encoded as one. A []string always marshals.
+	hosts, _ := json.Marshal(append([]string{}, step.scope.hosts...))
+	return []any{step.cutoff, string(hosts), step.scope.include}
+}
+
+// planCleanup lists what a cleanup at now does under policy, finest tier
+// first so a row past several retentions passes through every tier in one
+// run. A tier's rows are rolled up only when the coarser tiers keep them
+// longer than it does. The daily tier rolls whole months, so a month is
+// never split between the daily and monthly tables.
+func planCleanup(policy RetentionPolicy, now time.Time) []cleanupStep {
+	now = now.UTC()
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+	scopes := retentionScopes(policy)
+
+	var steps []cleanupStep
+	for _, scope := range scopes {
+		r := scope.retention
+		for _, t := range hourlyTables() {
+			cutoff := bucket.Day(daysAgo(t.keep(r)))
+			steps = append(steps, cleanupStep{table: t, scope: scope, rollUp: r.Monthly > t.keep(r), cutoff: cutoff, before: bucket.DayStart(cutoff)})
+		}
+	}
+	for _, scope := range scopes {
+		r := scope.retention
+		rollUp := r.Monthly > r.Daily
+		cutoff := bucket.Day(daysAgo(r.Daily))
+		if rollUp {
+			cutoff = bucket.FirstDay(bucket.Month(daysAgo(r.Daily)))
+		}
+		for _, t := range dailyTables() {
+			steps = append(steps, cleanupStep{table: t, scope: scope, rollUp: rollUp, cutoff: cutoff, before: bucket.DayStart(cutoff)})
+		}
+	}
+	for _, scope := range scopes {
+		cutoff := bucket.Month(daysAgo(scope.retention.Monthly))
+		for _, t := range monthlyTables() {
+			steps = append(steps, cleanupStep{table: t, scope: scope, cutoff: cutoff, before: bucket.MonthStart(cutoff)})
+		}
+	}
+
+	cutoff := cleanupCutoff(now)
+	for _, t := range fixedTables() {
+		steps = append(steps, cleanupStep{table: t, cutoff: cutoff, before: bucket.DayStart(cutoff)})
+	}
+	return steps
+}
+
+// CleanupInterval is how often the daemon runs its cleanup, so how long a
+// row can outlive its retention.
+const CleanupInterval = 12 * time.Hour
+
+// cleanupBatchSize is the most rows one cleanup transaction rolls up or
+// deletes.
+const cleanupBatchSize = 5000
+
+// runCleanup runs every step in order, batch rows at a time. A failing step
+// is reported and skipped: the rows it left are picked up by the next run.
+func runCleanup(ctx context.Context, db *sql.DB, steps []cleanupStep, batch int) {
+	for i := range steps {
+		step := &steps[i]
+		scope := ""
+		if step.scope.name != "" {
+			scope = " for " + step.scope.name
+		}
+
+		if step.rollUp {
+			if moved, err := dbRollUp(ctx, db, &step.table, stepArgs(step), batch); err != nil {
+				fmt.Printf("Rollup error: %v\n", err)
+			} else {
+				fmt.Printf("Rolled up %d old %s records%s into %s\n", moved, step.table.table, scope, step.table.into)
+			}
+			continue
+		}
+
+		if deleted, err := dbExpire(ctx, db, &step.table, stepArgs(step), batch); err != nil {
+			fmt.Printf("Cleanup error: %v\n", err)
+		} else {
+			fmt.Printf("Cleaned up %d old %s records%s\n", deleted, step.table.table, scope)
+		}
+	}
+}
+
+// CleanupPreview is what one step of a cleanup would do: roll Rows rows of
+// Table from before Before into Into or, when Into is empty, delete them.
+// Scope is the host the step is limited to, empty when it isn't limited to
+// one.
+type CleanupPreview struct {
+	Table  string
+	Into   string
+	Scope  string
+	Before time.Time
+	Rows   int64
+}
+
+// PreviewCleanup counts what a cleanup at now would roll up or delete under
+// policy, without changing anything. Rows a step would first receive from a
+// finer tier in the same run aren't counted.
+func PreviewCleanup(ctx context.Context, db *sql.DB, policy RetentionPolicy, now time.Time) ([]CleanupPreview, error) {
+	steps := planCleanup(policy, now)
+	previews := make([]CleanupPreview, 0, len(steps))
+	for i := range steps {
+		step := &steps[i]
+		rows, err := dbCountExpiring(ctx, db, &step.table, stepArgs(step))
+		if err != nil {
+			return nil, err
+		}
+
+		preview := CleanupPreview{Table: step.table.table, Scope: step.scope.name, Before: step.before, Rows: rows}
+		if step.rollUp {
+			preview.Into = step.table.into
+		}
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
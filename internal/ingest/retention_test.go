@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+// compliantRetention keeps everything 14 days and rolls nothing up.
+func compliantRetention() Retention {
+	return Retention{HourlyStats: 14, StatusCodes: 14, Referrers: 14, VisitorDays: 14, Daily: 14, Monthly: 14}
+}
+
+// A host override expires only that host's rows, and the default leaves
+// them alone.
+func TestCleanupAppliesHostRetention(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -20)
+
+	seedRollupRows(t, db, "example.com", old, "a")
+	seedRollupRows(t, db, "secure.example.com", old, "b")
+
+	policy := RetentionPolicy{
+		Default: DefaultRetention(),
+		Hosts:   map[string]Retention{"secure.example.com": compliantRetention()},
+	}
+	runCleanup(t.Context(), db, planCleanup(policy, now), cleanupBatchSize)
+
+	for _, table := range []string{"hourly_stats", "hourly_status_codes", "hourly_referrers", "visitor_days"} {
+		if got := queryInt(t, db, `SELECT COUNT(*) FROM `+table+` WHERE host = 'example.com'`); got != 1 {
+			t.Errorf("%s rows for example.com = %d, want 1", table, got)
+		}
+		if got := queryInt(t, db, `SELECT COUNT(*) FROM `+table+` WHERE host = 'secure.example.com'`); got != 0 {
+			t.Errorf("%s rows for secure.example.com = %d, want 0", table, got)
+		}
+	}
+	// Nothing keeps the host's rows longer than 14 days, so they're deleted
+	// rather than rolled up.
+	for _, table := range []string{"daily_stats", "daily_visitors", "monthly_stats", "monthly_visitors"} {
+		if got := queryInt(t, db, `SELECT COUNT(*) FROM `+table); got != 0 {
+			t.Errorf("%s rows = %d, want 0", table, got)
+		}
+	}
+}
+
+// Each table of the finest tier expires on its own horizon.
+func TestCleanupAppliesTableRetention(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -20)
+	seedRollupRows(t, db, "example.com", old, "a")
+
+	retention := DefaultRetention()
+	retention.Referrers = 7
+	runCleanup(t.Context(), db, planCleanup(RetentionPolicy{Default: retention}, now), cleanupBatchSize)
+
+	if got := queryInt(t, db, `SELECT COUNT(*) FROM hourly_referrers`); got != 0 {
+		t.Errorf("hourly_referrers rows = %d, want 0", got)
+	}
+	if got := queryInt(t, db, `SELECT count FROM daily_referrers WHERE day = ?`, bucket.Day(old)); got != 2 {
+		t.Errorf("daily_referrers count = %d, want 2", got)
+	}
+	if got := queryInt(t, db, `SELECT COUNT(*) FROM hourly_stats`); got != 1 {
+		t.Errorf("hourly_stats rows = %d, want 1", got)
+	}
+}
+
+func TestPreviewCleanupCountsWithoutChanging(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	seedRollupRows(t, db, "example.com", now.AddDate(0, 0, -20), "a")
+	seedRollupRows(t, db, "secure.example.com", now.AddDate(0, 0, -20), "b")
+	seedRollupRows(t, db, "secure.example.com", now.AddDate(0, 0, -21), "c")
+
+	policy := RetentionPolicy{
+		Default: DefaultRetention(),
+		Hosts:   map[string]Retention{"secure.example.com": compliantRetention()},
+	}
+	previews, err := PreviewCleanup(t.Context(), db, policy, now)
+	if err != nil {
+		t.Fatalf("PreviewCleanup: %v", err)
+	}
+
+	rows := map[string]int64{}
+	for _, p := range previews {
+		rows[p.Scope+" "+p.Table] += p.Rows
+		if p.Scope == "secure.example.com" && p.Into != "" {
+			t.Errorf("preview rolls %s into %s for a host that keeps nothing past 14 days", p.Table, p.Into)
+		}
+	}
+	if got := rows["secure.example.com hourly_stats"]; got != 2 {
+		t.Errorf("secure.example.com hourly_stats rows = %d, want 2", got)
+	}
+	if got := rows[" hourly_stats"]; got != 0 {
+		t.Errorf("default hourly_stats rows = %d, want 0", got)
+	}
+	if got := queryInt(t, db, `SELECT COUNT(*) FROM hourly_stats`); got != 3 {
+		t.Errorf("hourly_stats rows after preview = %d, want 3", got)
+	}
+}
+
+func TestValidateRetention(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		edit    func(r *Retention)
+		wantErr string
+	}{
+		{"defaults", func(*Retention) {}, ""},
+		{"zero days", func(r *Retention) { r.VisitorDays = 0 }, "visitor_days retention must be a positive number of days"},
+		{"daily shorter than hourly", func(r *Retention) { r.Daily = 30 }, "daily retention (30 days) must be at least the longest hourly one (60 days)"},
+		{"monthly shorter than daily", func(r *Retention) { r.Monthly = 100 }, "monthly retention (100 days) must be at least the daily one (730 days)"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := DefaultRetention()
+			tt.edit(&r)
+			err := ValidateRetention(r)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateRetention: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateRetention error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// tierTable is one table the cleanup expires rows from. Its rows before a
+// cutoff are counted by count, moved into the coarser table into by roll
+// (tables without one leave both empty) and deleted by remove, so a rollup
+// never drops what it hasn't counted.
+//
+// A scoped table's statements take the cutoff, a JSON array of hosts and
+// whether the rows of those hosts are the ones meant (1) or the ones left
+// alone (0); see hostScope. An unscoped table's take only the cutoff. roll
+// and remove then take a batch size: each handles at most that many of the
+// oldest matching rows, so a large cleanup never holds the write lock long.
+type tierTable struct {
+	table, into string
+	// keep is how many days the policy keeps table's rows; nil for the
+	// fixed tables.
+	keep   func(Retention) int
+	scoped bool
+	roll   string
+	remove string
+	count  string
+}
+
+func retainDaily(r Retention) int   { return r.Daily }
+func retainMonthly(r Retention) int { return r.Monthly }
+
+// hostScope limits a statement to the rows of the hosts in a JSON array, or
+// to every other host's.
+const hostScope = ` AND (host IN (SELECT value FROM json_each(?))) = ?`
+
+// The cutoff predicates, one per bucket kind. Hour buckets are cut at a
+// day bucket too, so every tier expires whole days.
+const (
+	hourBefore  = ` WHERE bucket < ? * 24`
+	dayBefore   = ` WHERE day < ?`
+	monthBefore = ` WHERE month < ?`
+)
+
+// inBatch closes a rowid subquery over one cutoff predicate, taking the
+// batch size as its last bind value. The oldest rows come first, and they
+// are the ones a cutoff selects.
+const inBatch = ` ORDER BY rowid LIMIT ?)`
+
+// monthOfDay is the month bucket of a day bucket column, see bucket.Month.
+const monthOfDay = `(CAST(strftime('%Y', day * 86400, 'unixepoch') AS INTEGER) - 1970) * 12 + CAST(strftime('%m', day * 86400, 'unixepoch') AS INTEGER) - 1`
+
+// hourlyTables hold the finest tier, rolled into the daily tables. roll adds
+// onto rows already there rather than replacing them, since a late log line
+// can land in a bucket that was rolled up before. Hashes rotate daily, so a
+// day's distinct hashes are all a later query could ever count for visitor_days.
+func hourlyTables() []tierTable {
+	return []tierTable{
+		{
+			table:  "hourly_stats",
+			into:   "daily_stats",
+			keep:   func(r Retention) int { return r.HourlyStats },
+			scoped: true,
+			roll: `
+			INSERT INTO daily_stats (day, path, host, page_views, is_static, bot_views)
+			SELECT bucket / 24, path, host, SUM(page_views), MAX(is_static), SUM(bot_views)
+			FROM hourly_stats WHERE rowid IN (SELECT rowid FROM hourly_stats` + hourBefore + hostScope + inBatch + `
+			GROUP BY bucket / 24, path, host
+			ON CONFLICT(day, path, host) DO UPDATE SET
+				page_views = page_views + excluded.page_views,
+				bot_views = bot_views + excluded.bot_views`,
+			remove: `DELETE FROM hourly_stats WHERE rowid IN (SELECT rowid FROM hourly_stats` + hourBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM hourly_stats` + hourBefore + hostScope,
+		},
+		{
+			table:  "hourly_status_codes",
+			into:   "daily_status_codes",
+			keep:   func(r Retention) int { return r.StatusCodes },
+			scoped: true,
+			roll: `
+			INSERT INTO daily_status_codes (day, path, host, status_code, count)
+			SELECT bucket / 24, path, host, status_code, SUM(count)
+			FROM hourly_status_codes WHERE rowid IN (SELECT rowid FROM hourly_status_codes` + hourBefore + hostScope + inBatch + `
+			GROUP BY bucket / 24, path, host, status_code
+			ON CONFLICT(day, path, host, status_code) DO UPDATE SET count = count + excluded.count`,
+			remove: `DELETE FROM hourly_status_codes WHERE rowid IN (SELECT rowid FROM hourly_status_codes` + hourBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM hourly_status_codes` + hourBefore + hostScope,
+		},
+		{
+			table:  "hourly_referrers",
+			into:   "daily_referrers",
+			keep:   func(r Retention) int { return r.Referrers },
+			scoped: true,
+			roll: `
+			INSERT INTO daily_referrers (day, path, host, referrer, count)
+			SELECT bucket / 24, path, host, referrer, SUM(count)
+			FROM hourly_referrers WHERE rowid IN (SELECT rowid FROM hourly_referrers` + hourBefore + hostScope + inBatch + `
+			GROUP BY bucket / 24, path, host, referrer
+			ON CONFLICT(day, path, host, referrer) DO UPDATE SET count = count + excluded.count`,
+			remove: `DELETE FROM hourly_referrers WHERE rowid IN (SELECT rowid FROM hourly_referrers` + hourBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM hourly_referrers` + hourBefore + hostScope,
+		},
+		{
+			table:  "visitor_days",
+			into:   "daily_visitors",
+			keep:   func(r Retention) int { return r.VisitorDays },
+			scoped: true,
+			roll: `
+			INSERT INTO daily_visitors (day, host, visitors)
+			SELECT day, host, COUNT(*)
+			FROM visitor_days WHERE rowid IN (SELECT rowid FROM visitor_days` + dayBefore + hostScope + inBatch + `
+			GROUP BY day, host
+			ON CONFLICT(day, host) DO UPDATE SET visitors = visitors + excluded.visitors`,
+			remove: `DELETE FROM visitor_days WHERE rowid IN (SELECT rowid FROM visitor_days` + dayBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM visitor_days` + dayBefore + hostScope,
+		},
+	}
+}
+
+// dailyTables hold the days rolled up from the hourly tier.
+func dailyTables() []tierTable {
+	return []tierTable{
+		{
+			table:  "daily_stats",
+			into:   "monthly_stats",
+			keep:   retainDaily,
+			scoped: true,
+			roll: `
+			INSERT INTO monthly_stats (month, path, host, page_views, is_static, bot_views)
+			SELECT ` + monthOfDay + `, path, host, SUM(page_views), MAX(is_static), SUM(bot_views)
+			FROM daily_stats WHERE rowid IN (SELECT rowid FROM daily_stats` + dayBefore + hostScope + inBatch + `
+			GROUP BY 1, path, host
+			ON CONFLICT(month, path, host) DO UPDATE SET
+				page_views = page_views + excluded.page_views,
+				bot_views = bot_views + excluded.bot_views`,
+			remove: `DELETE FROM daily_stats WHERE rowid IN (SELECT rowid FROM daily_stats` + dayBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM daily_stats` + dayBefore + hostScope,
+		},
+		{
+			table:  "daily_status_codes",
+			into:   "monthly_status_codes",
+			keep:   retainDaily,
+			scoped: true,
+			roll: `
+			INSERT INTO monthly_status_codes (month, path, host, status_code, count)
+			SELECT ` + monthOfDay + `, path, host, status_code, SUM(count)
+			FROM daily_status_codes WHERE rowid IN (SELECT rowid FROM daily_status_codes` + dayBefore + hostScope + inBatch + `
+			GROUP BY 1, path, host, status_code
+			ON CONFLICT(month, path, host, status_code) DO UPDATE SET count = count + excluded.count`,
+			remove: `DELETE FROM daily_status_codes WHERE rowid IN (SELECT rowid FROM daily_status_codes` + dayBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM daily_status_codes` + dayBefore + hostScope,
+		},
+		{
+			table:  "daily_referrers",
+			into:   "monthly_referrers",
+			keep:   retainDaily,
+			scoped: true,
+			roll: `
+			INSERT INTO monthly_referrers (month, path, host, referrer, count)
+			SELECT ` + monthOfDay + `, path, host, referrer, SUM(count)
+			FROM daily_referrers WHERE rowid IN (SELECT rowid FROM daily_referrers` + dayBefore + hostScope + inBatch + `
+			GROUP BY 1, path, host, referrer
+			ON CONFLICT(month, path, host, referrer) DO UPDATE SET count = count + excluded.count`,
+			remove: `DELETE FROM daily_referrers WHERE rowid IN (SELECT rowid FROM daily_referrers` + dayBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM daily_referrers` + dayBefore + hostScope,
+		},
+		{
+			table:  "daily_visitors",
+			into:   "monthly_visitors",
+			keep:   retainDaily,
+			scoped: true,
+			roll: `
+			INSERT INTO monthly_visitors (month, host, visitors)
+			SELECT ` + monthOfDay + `, host, SUM(visitors)
+			FROM daily_visitors WHERE rowid IN (SELECT rowid FROM daily_visitors` + dayBefore + hostScope + inBatch + `
+			GROUP BY 1, host
+			ON CONFLICT(month, host) DO UPDATE SET visitors = visitors + excluded.visitors`,
+			remove: `DELETE FROM daily_visitors WHERE rowid IN (SELECT rowid FROM daily_visitors` + dayBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM daily_visitors` + dayBefore + hostScope,
+		},
+	}
+}
+
+// monthlyTables are the last tier; their rows are only ever deleted.
+func monthlyTables() []tierTable {
+	return []tierTable{
+		{table: "monthly_stats", scoped: true, keep: retainMonthly,
+			remove: `DELETE FROM monthly_stats WHERE rowid IN (SELECT rowid FROM monthly_stats` + monthBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM monthly_stats` + monthBefore + hostScope},
+		{table: "monthly_status_codes", scoped: true, keep: retainMonthly,
+			remove: `DELETE FROM monthly_status_codes WHERE rowid IN (SELECT rowid FROM monthly_status_codes` + monthBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM monthly_status_codes` + monthBefore + hostScope},
+		{table: "monthly_referrers", scoped: true, keep: retainMonthly,
+			remove: `DELETE FROM monthly_referrers WHERE rowid IN (SELECT rowid FROM monthly_referrers` + monthBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM monthly_referrers` + monthBefore + hostScope},
+		{table: "monthly_visitors", scoped: true, keep: retainMonthly,
+			remove: `DELETE FROM monthly_visitors WHERE rowid IN (SELECT rowid FROM monthly_visitors` + monthBefore + hostScope + inBatch,
+			count:  `SELECT COUNT(*) FROM monthly_visitors` + monthBefore + hostScope},
+	}
+}
+
+// fixedTables keep retentionDays whatever the policy says and are never
+// rolled up.
+func fixedTables() []tierTable {
+	return []tierTable{
+		{table: "hourly_goals",
+			remove: `DELETE FROM hourly_goals WHERE rowid IN (SELECT rowid FROM hourly_goals` + hourBefore + inBatch,
+			count:  `SELECT COUNT(*) FROM hourly_goals` + hourBefore},
+		{table: "goal_visitor_days",
+			remove: `DELETE FROM goal_visitor_days WHERE rowid IN (SELECT rowid FROM goal_visitor_days` + dayBefore + inBatch,
+			count:  `SELECT COUNT(*) FROM goal_visitor_days` + dayBefore},
+		{table: "daily_funnel_steps",
+			remove: `DELETE FROM daily_funnel_steps WHERE rowid IN (SELECT rowid FROM daily_funnel_steps` + dayBefore + inBatch,
+			count:  `SELECT COUNT(*) FROM daily_funnel_steps` + dayBefore},
+		{table: "hourly_parse_failures",
+			remove: `DELETE FROM hourly_parse_failures WHERE rowid IN (SELECT rowid FROM hourly_parse_failures` + hourBefore + inBatch,
+			count:  `SELECT COUNT(*) FROM hourly_parse_failures` + hourBefore},
+		{table: "hourly_scans",
+			remove: `DELETE FROM hourly_scans WHERE rowid IN (SELECT rowid FROM hourly_scans` + hourBefore + inBatch,
+			count:  `SELECT COUNT(*) FROM hourly_scans` + hourBefore},
+		{table: "hourly_broken_links",
+			remove: `DELETE FROM hourly_broken_links WHERE rowid IN (SELECT rowid FROM hourly_broken_links` + hourBefore + inBatch,
+			count:  `SELECT COUNT(*) FROM hourly_broken_links` + hourBefore},
+	}
+}
+
+// dbRollUp moves the rows args select from t.table into t.into, batch rows
+// at a time. Each batch is its own transaction, so a row is never counted in
+// both tables or in neither, and the write lock is released between batches
+// for the daemon's ingest to get in. It returns how many rows were moved out
+// of t.table.
+func dbRollUp(ctx context.Context, db *sql.DB, t *tierTable, args []any, batch int) (int64, error) {
+	batchArgs := append(slices.Clone(args), batch)
+	var total int64
+	for {
+		moved, err := dbRollUpBatch(ctx, db, t, batchArgs)
+		if err != nil {
+			return total, err
+		}
+		total += moved
+		if moved < int64(batch) {
+			return total, nil
+		}
+	}
+}
+
+func dbRollUpBatch(ctx context.Context, db *sql.DB, t *tierTable, args []any) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not roll %s into %s, %w", t.table, t.into, err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rollback after commit is a no-op
+
+	if _, err := tx.ExecContext(ctx, t.roll, args...); err != nil {
+		return 0, fmt.Errorf("could not roll %s into %s, %w", t.table, t.into, err)
+	}
+	result, err := tx.ExecContext(ctx, t.remove, args...)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete rolled up %s records, %w", t.table, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("could not roll %s into %s, %w", t.table, t.into, err)
+	}
+
+	rowsMoved, _ := result.RowsAffected()
+	return rowsMoved, nil
+}
+
+// dbExpire deletes the rows args select from t.table, batch rows at a time
+// for the same reason as dbRollUp.
+func dbExpire(ctx context.Context, db *sql.DB, t *tierTable, args []any, batch int) (int64, error) {
+	batchArgs := append(slices.Clone(args), batch)
+	var total int64
+	for {
+		result, err := db.ExecContext(ctx, t.remove, batchArgs...)
+		if err != nil {
+			return total, fmt.Errorf("could not delete old %s records, %w", t.table, err)
+		}
+		rowsDeleted, _ := result.RowsAffected()
+		total += rowsDeleted
+		if rowsDeleted < int64(batch) {
+			return total, nil
+		}
+	}
+}
+
+// dbCountExpiring counts the rows args select from t.table.
+func dbCountExpiring(ctx context.Context, db *sql.DB, t *tierTable, args []any) (int64, error) {
+	var n int64
+	if err := db.QueryRowContext(ctx, t.count, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("could not count old %s records, %w", t.table, err)
+	}
+	return n, nil
+}
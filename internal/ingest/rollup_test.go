@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+func seedRollupRows(t *testing.T, db *sql.DB, host string, ts time.Time, hash string) {
+	t.Helper()
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views) VALUES (?, '/', ?, 2, 0, 1)`, []any{bucket.Hour(ts), host}},
+		{`INSERT INTO hourly_status_codes (bucket, path, host, status_code, count) VALUES (?, '/', ?, 200, 2)`, []any{bucket.Hour(ts), host}},
+		{`INSERT INTO hourly_referrers (bucket, path, host, referrer, count) VALUES (?, '/', ?, 'https://google.com', 2)`, []any{bucket.Hour(ts), host}},
+		{`INSERT INTO visitor_days (hash, host, day, first_seen) VALUES (?, ?, ?, ?)`, []any{hash, host, bucket.Day(ts), ts}},
+	}
+	for _, s := range statements {
+		if _, err := db.ExecContext(t.Context(), s.query, s.args...); err != nil {
+			t.Fatalf("seed %q: %v", s.query, err)
+		}
+	}
+}
+
+func queryInt(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRowContext(t.Context(), query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// Hours past the hourly retention become one daily row per day, with the
+// day's unique visitors counted before visitor_days is purged. Recent hours
+// stay where they are.
+func TestPerformRollupsRollsExpiredHoursIntoDays(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -retentionDays-5)
+
+	seedRollupRows(t, db, "example.com", old, "a")
+	seedRollupRows(t, db, "example.com", old.Add(time.Hour), "b")
+	seedRollupRows(t, db, "example.com", now, "c")
+
+	runCleanup(t.Context(), db, planCleanup(RetentionPolicy{Default: DefaultRetention()}, now), cleanupBatchSize)
+
+	if got := queryInt(t, db, `SELECT COUNT(*) FROM hourly_stats`); got != 1 {
+		t.Errorf("hourly_stats rows = %d, want only the recent one", got)
+	}
+	if got := queryInt(t, db, `SELECT COUNT(*) FROM visitor_days`); got != 1 {
+		t.Errorf("visitor_days rows = %d, want only the recent one", got)
+	}
+	day := bucket.Day(old)
+	if got := queryInt(t, db, `SELECT page_views FROM daily_stats WHERE day = ?`, day); got != 4 {
+		t.Errorf("daily_stats page_views = %d, want 4", got)
+	}
+	if got := queryInt(t, db, `SELECT bot_views FROM daily_stats WHERE day = ?`, day); got != 2 {
+		t.Errorf("daily_stats bot_views = %d, want 2", got)
+	}
+	if got := queryInt(t, db, `SELECT count FROM daily_status_codes WHERE day = ?`, day); got != 4 {
+		t.Errorf("daily_status_codes count = %d, want 4", got)
+	}
+	if got := queryInt(t, db, `SELECT count FROM daily_referrers WHERE day = ?`, day); got != 4 {
+		t.Errorf("daily_referrers count = %d, want 4", got)
+	}
+	if got := queryInt(t, db, `SELECT visitors FROM daily_visitors WHERE day = ?`, day); got != 2 {
+		t.Errorf("daily_visitors visitors = %d, want 2", got)
+	}
+}
+
+// A late log line for an hour already rolled up is added onto its daily row
+// on the next run, not written over it.
+func TestPerformRollupsAddsOntoRolledUpDays(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -retentionDays-5)
+
+	seedRollupRows(t, db, "example.com", old, "a")
+	runCleanup(t.Context(), db, planCleanup(RetentionPolicy{Default: DefaultRetention()}, now), cleanupBatchSize)
+	seedRollupRows(t, db, "example.com", old, "b")
+	runCleanup(t.Context(), db, planCleanup(RetentionPolicy{Default: DefaultRetention()}, now), cleanupBatchSize)
+
+	if got := queryInt(t, db, `SELECT page_views FROM daily_stats WHERE day = ?`, bucket.Day(old)); got != 4 {
+		t.Errorf("daily_stats page_views = %d, want 4", got)
+	}
+	if got := queryInt(t, db, `SELECT visitors FROM daily_visitors WHERE day = ?`, bucket.Day(old)); got != 2 {
+		t.Errorf("daily_visitors visitors = %d, want 2", got)
+	}
+}
+
+// Rows past the daily retention pass through the daily tier into one
+// monthly row, and monthly rows past theirs are deleted.
+func TestPerformRollupsRollsExpiredDaysIntoMonths(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	old := time.Date(2024, time.March, 3, 10, 0, 0, 0, time.UTC)
+	ancient := now.AddDate(-11, 0, 0)
+
+	seedRollupRows(t, db, "example.com", old, "a")
+	seedRollupRows(t, db, "example.com", old.AddDate(0, 0, 10), "b")
+	seedRollupRows(t, db, "example.com", ancient, "c")
+
+	runCleanup(t.Context(), db, planCleanup(RetentionPolicy{Default: DefaultRetention()}, now), cleanupBatchSize)
+
+	for _, table := range []string{"hourly_stats", "daily_stats", "daily_visitors"} {
+		if got := queryInt(t, db, `SELECT COUNT(*) FROM `+table); got != 0 {
+			t.Errorf("%s rows = %d, want 0", table, got)
+		}
+	}
+	month := bucket.Month(old)
+	if got := queryInt(t, db, `SELECT page_views FROM monthly_stats WHERE month = ?`, month); got != 4 {
+		t.Errorf("monthly_stats page_views = %d, want 4", got)
+	}
+	if got := queryInt(t, db, `SELECT visitors FROM monthly_visitors WHERE month = ?`, month); got != 2 {
+		t.Errorf("monthly_visitors visitors = %d, want 2", got)
+	}
+	if got := queryInt(t, db, `SELECT COUNT(*) FROM monthly_stats`); got != 1 {
+		t.Errorf("monthly_stats rows = %d, want the ancient month deleted", got)
+	}
+}
+
+func TestDailyCutoffIsAWholeMonth(t *testing.T) {
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	want := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+	for _, step := range planCleanup(RetentionPolicy{Default: DefaultRetention()}, now) {
+		if step.table.table == "daily_stats" && !step.before.Equal(want) {
+			t.Errorf("daily_stats at %v keeps from %v, want %v", now, step.before, want)
+		}
+	}
+}
+
+// Rolling up a row at a time adds up to the same daily totals as rolling up
+// a whole day at once.
+func TestPerformRollupsInBatches(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+	now := time.Date(2026, time.July, 20, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -retentionDays-5)
+
+	seedRollupRows(t, db, "example.com", old, "a")
+	seedRollupRows(t, db, "example.com", old.Add(time.Hour), "b")
+	seedRollupRows(t, db, "example.com", old.Add(2*time.Hour), "c")
+
+	runCleanup(t.Context(), db, planCleanup(RetentionPolicy{Default: DefaultRetention()}, now), 1)
+
+	if got := queryInt(t, db, `SELECT COUNT(*) FROM hourly_stats`); got != 0 {
+		t.Errorf("hourly_stats rows = %d, want 0", got)
+	}
+	day := bucket.Day(old)
+	if got := queryInt(t, db, `SELECT page_views FROM daily_stats WHERE day = ?`, day); got != 6 {
+		t.Errorf("daily_stats page_views = %d, want 6", got)
+	}
+	if got := queryInt(t, db, `SELECT visitors FROM daily_visitors WHERE day = ?`, day); got != 3 {
+		t.Errorf("daily_visitors visitors = %d, want 3", got)
+	}
+}
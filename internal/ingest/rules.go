@@ -0,0 +1,187 @@
+package ingest
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// Rules are the operator-configured exclusion, normalization and host rules
+// applied to every parsed page view before it's counted. The zero value
+// keeps every page view unchanged.
+type Rules struct {
+	// ExcludePaths are path.Match globs, matched against the path without
+	// its query string, e.g. "/healthz" or "/admin/*".
+	ExcludePaths []string
+	// ExcludeUserAgents are case-insensitive substrings, e.g. "UptimeRobot".
+	ExcludeUserAgents []string
+	// ExcludeHosts drops every request for these hosts, e.g. a staging vhost
+	// that shares the access log. Matched after HostAliases are applied.
+	ExcludeHosts []string
+	// HostAliases maps a host to the one it should be counted as, e.g.
+	// "www.example.com" to "example.com".
+	HostAliases map[string]string
+	// StripQuery drops the query string from paths, so "/post?utm_source=x"
+	// and "/post" are one path.
+	StripQuery bool
+	// StripTrailingSlash counts "/about/" as "/about". The root path is
+	// left alone.
+	StripTrailingSlash bool
+}
+
+// compiledRules is Rules validated and normalized for matching.
+type compiledRules struct {
+	excludePaths       []string
+	excludeUserAgents  []string
+	excludeHosts       map[string]bool
+	hostAliases        map[string]string
+	stripQuery         bool
+	stripTrailingSlash bool
+}
+
+// ValidateRules reports the first malformed rule, so a bad config can be
+// rejected before it reaches a running daemon.
+func ValidateRules(r Rules) error {
+	_, err := compileRules(r)
+	return err
+}
+
+func compileRules(r Rules) (compiledRules, error) {
+	c := compiledRules{
+		excludeHosts:       map[string]bool{},
+		hostAliases:        map[string]string{},
+		stripQuery:         r.StripQuery,
+		stripTrailingSlash: r.StripTrailingSlash,
+	}
+	for _, pattern := range r.ExcludePaths {
+		if !strings.HasPrefix(pattern, "/") {
+			return compiledRules{}, fmt.Errorf("invalid excluded path %q: must start with /", pattern)
+		}
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return compiledRules{}, fmt.Errorf("invalid excluded path %q: %w", pattern, err)
+		}
+		c.excludePaths = append(c.excludePaths, pattern)
+	}
+	for _, ua := range r.ExcludeUserAgents {
+		if ua == "" {
+			return compiledRules{}, fmt.Errorf("invalid excluded user agent: must not be empty")
+		}
+		c.excludeUserAgents = append(c.excludeUserAgents, strings.ToLower(ua))
+	}
+	for _, host := range r.ExcludeHosts {
+		if host == "" {
+			return compiledRules{}, fmt.Errorf("invalid excluded host: must not be empty")
+		}
+		c.excludeHosts[NormalizeHost(host)] = true
+	}
+	for alias, host := range r.HostAliases {
+		if alias == "" || host == "" {
+			return compiledRules{}, fmt.Errorf("invalid host alias %q = %q: neither side may be empty", alias, host)
+		}
+		c.hostAliases[NormalizeHost(alias)] = NormalizeHost(host)
+	}
+	return c, nil
+}
+
+// applyRules returns pageView as the rules would count it, and false if the
+// rules exclude it altogether.
+func applyRules(c compiledRules, pageView PageView) (PageView, bool) {
+	if canonical, ok := c.hostAliases[pageView.Host]; ok {
+		pageView.Host = canonical
+	}
+	if c.excludeHosts[pageView.Host] {
+		return PageView{}, false
+	}
+
+	pathOnly, _, _ := strings.Cut(pageView.Path, "?")
+	for _, pattern := range c.excludePaths {
+		if matched, _ := path.Match(pattern, pathOnly); matched {
+			return PageView{}, false
+		}
+	}
+	if len(c.excludeUserAgents) > 0 {
+		ua := strings.ToLower(pageView.UserAgent)
+		for _, needle := range c.excludeUserAgents {
+			if strings.Contains(ua, needle) {
+				return PageView{}, false
+			}
+		}
+	}
+
+	if c.stripQuery {
+		pageView.Path = pathOnly
+	}
+	if c.stripTrailingSlash {
+		pageView.Path = stripTrailingSlash(pageView.Path)
+	}
+	return pageView, true
+}
+
+// stripTrailingSlash removes the slash ending p's path part, keeping any
+// query string in place.
+func stripTrailingSlash(p string) string {
+	pathOnly, query, hasQuery := strings.Cut(p, "?")
+	if len(pathOnly) > 1 {
+		pathOnly = strings.TrimRight(pathOnly, "/")
+		if pathOnly == "" {
+			pathOnly = "/"
+		}
+	}
+	if hasQuery {
+		return pathOnly + "?" + query
+	}
+	return pathOnly
+}
+
+// ruleSwitch holds the rules currently in force. The tailer reads it once
+// per line and a reload swaps it whole, so a line is never matched against
+// half of an old and half of a new rule set.
+type ruleSwitch struct {
+	current atomic.Pointer[compiledRules]
+}
+
+func newRuleSwitch(c compiledRules) *ruleSwitch {
+	s := &ruleSwitch{}
+	s.current.Store(&c)
+	return s
+}
+
+func (s *ruleSwitch) load() compiledRules { return *s.current.Load() }
+
+// watchReloads calls reload each time the reload signal fires, until the
+// channel is closed or done is.
+func watchReloads(done <-chan struct{}, signals <-chan os.Signal, reload func()) {
+	for {
+		select {
+		case <-done:
+			return
+		case _, ok := <-signals:
+			if !ok {
+				return
+			}
+			reload()
+		}
+	}
+}
+
+// reloadRules re-reads the rules through load into rules. A rule set that
+// fails to load or compile is logged and discarded, leaving the previous
+// one active.
+func reloadRules(load func() (Rules, error), rules *ruleSwitch) {
+	r, err := load()
+	if err != nil {
+		log.Printf("Config reload failed, keeping previous rules: %v", err)
+		return
+	}
+	c, err := compileRules(r)
+	if err != nil {
+		log.Printf("Config reload failed, keeping previous rules: %v", err)
+		return
+	}
+	rules.current.Store(&c)
+	log.Printf("Config reloaded: %d excluded path(s), %d excluded user agent(s), %d excluded host(s), %d host alias(es)",
+		len(c.excludePaths), len(c.excludeUserAgents), len(c.excludeHosts), len(c.hostAliases))
+}
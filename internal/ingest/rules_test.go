@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func mustCompileRules(t *testing.T, r Rules) compiledRules {
+	t.Helper()
+	c, err := compileRules(r)
+	if err != nil {
+		t.Fatalf("compileRules: %v", err)
+	}
+	return c
+}
+
+func TestApplyRules(t *testing.T) {
+	rules := mustCompileRules(t, Rules{
+		ExcludePaths:       []string{"/healthz", "/admin/*"},
+		ExcludeUserAgents:  []string{"UptimeRobot"},
+		ExcludeHosts:       []string{"Staging.example.com"},
+		HostAliases:        map[string]string{"WWW.example.com": "example.com"},
+		StripQuery:         true,
+		StripTrailingSlash: true,
+	})
+
+	cases := []struct {
+		name     string
+		in       PageView
+		wantKeep bool
+		wantHost string
+		wantPath string
+	}{
+		{name: "plain", in: PageView{Host: "example.com", Path: "/post"}, wantKeep: true, wantHost: "example.com", wantPath: "/post"},
+		{name: "alias", in: PageView{Host: "www.example.com", Path: "/"}, wantKeep: true, wantHost: "example.com", wantPath: "/"},
+		{name: "query and slash", in: PageView{Host: "example.com", Path: "/about/?utm_source=x"}, wantKeep: true, wantHost: "example.com", wantPath: "/about"},
+		{name: "excluded path", in: PageView{Host: "example.com", Path: "/healthz?probe=1"}},
+		{name: "excluded glob", in: PageView{Host: "example.com", Path: "/admin/users"}},
+		{name: "excluded agent", in: PageView{Host: "example.com", Path: "/", UserAgent: "Mozilla/5.0 (compatible; uptimerobot/2.0)"}},
+		{name: "excluded host", in: PageView{Host: "staging.example.com", Path: "/"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, keep := applyRules(rules, tc.in)
+			if keep != tc.wantKeep {
+				t.Fatalf("keep = %v, want %v", keep, tc.wantKeep)
+			}
+			if !keep {
+				return
+			}
+			if got.Host != tc.wantHost || got.Path != tc.wantPath {
+				t.Errorf("got host %q path %q, want %q %q", got.Host, got.Path, tc.wantHost, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestApplyRules_ZeroValueKeepsEverything(t *testing.T) {
+	in := PageView{Host: "example.com", Path: "/about/?a=1", UserAgent: "curl"}
+	got, keep := applyRules(mustCompileRules(t, Rules{}), in)
+	if !keep || got != in {
+		t.Errorf("applyRules(zero) = %+v, %v; want the page view unchanged", got, keep)
+	}
+}
+
+func TestCompileRules_RejectsMalformed(t *testing.T) {
+	for name, r := range map[string]Rules{
+		"relative path": {ExcludePaths: []string{"healthz"}},
+		"bad glob":      {ExcludePaths: []string{"/[a"}},
+		"empty agent":   {ExcludeUserAgents: []string{""}},
+		"empty alias":   {HostAliases: map[string]string{"www.example.com": ""}},
+	} {
+		if _, err := compileRules(r); err == nil {
+			t.Errorf("%s: compileRules succeeded, want an error", name)
+		}
+	}
+}
+
+func TestReloadRules_KeepsPreviousOnError(t *testing.T) {
+	rules := newRuleSwitch(mustCompileRules(t, Rules{ExcludePaths: []string{"/old"}}))
+
+	reloadRules(func() (Rules, error) { return Rules{}, errors.New("broken file") }, rules)
+	reloadRules(func() (Rules, error) { return Rules{ExcludePaths: []string{"no-slash"}}, nil }, rules)
+	if _, keep := applyRules(rules.load(), PageView{Path: "/old"}); keep {
+		t.Fatal("a failed reload replaced the rules in force")
+	}
+
+	reloadRules(func() (Rules, error) { return Rules{ExcludePaths: []string{"/new"}}, nil }, rules)
+	if _, keep := applyRules(rules.load(), PageView{Path: "/old"}); !keep {
+		t.Error("/old still excluded after a successful reload")
+	}
+	if _, keep := applyRules(rules.load(), PageView{Path: "/new"}); keep {
+		t.Error("/new not excluded after a successful reload")
+	}
+}
+
+// A reload swaps the rules under a running tailer: lines read before it keep
+// the old rules, lines after it get the new ones, and none are lost.
+func TestTailLog_PicksUpReloadedRules(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(logPath, []byte(accessLogLine("/a")+"\n"), 0o600); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	rules := newRuleSwitch(compiledRules{})
+	reload := make(chan os.Signal, 1)
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		watchReloads(ctx.Done(), reload, func() {
+			reloadRules(func() (Rules, error) {
+				return Rules{ExcludePaths: []string{"/b"}}, nil
+			}, rules)
+		})
+	}()
+
+	pageViews := make(chan PageView, 10)
+	tailDone := make(chan struct{})
+	go func() {
+		defer close(tailDone)
+		stages, err := buildPipeline(nil, nil, rules)
+		if err != nil {
+			t.Errorf("buildPipeline: %v", err)
+			return
+		}
+		settings := parseSettings{DefaultHost: "default", Stages: stages}
+		if err := tailLog(ctx, []string{"-n", "+1", "-F", logPath}, settings, pageViews, discardLines{}); err != nil {
+			t.Errorf("tailLog: %v", err)
+		}
+	}()
+
+	if pv := waitForPageView(t, pageViews); pv.Path != "/a" {
+		t.Fatalf("first page view = %s, want /a", pv.Path)
+	}
+
+	reload <- syscall.SIGHUP
+	// Wait for the swap so the next lines are read under the new rules.
+	for {
+		if _, keep := applyRules(rules.load(), PageView{Path: "/b"}); !keep {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, p := range []string{"/b", "/c"} {
+		if err := appendAccessLogLine(logPath, p); err != nil {
+			t.Fatalf("append log: %v", err)
+		}
+	}
+	if pv := waitForPageView(t, pageViews); pv.Path != "/c" {
+		t.Errorf("page view after reload = %s, want /c (/b excluded)", pv.Path)
+	}
+
+	cancel()
+	<-tailDone
+	<-watchDone
+}
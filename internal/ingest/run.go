@@ -10,14 +10,90 @@ import (
 	"time"
 
 	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+	"github.com/Elysium-Labs-EU/theia/internal/live"
+	"github.com/Elysium-Labs-EU/theia/internal/promsink"
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
 )
 
-func Run(ctx context.Context, dbPath string, logPath string) error {
+// Config is the narrow set of inputs the daemon needs.
+type Config struct {
+	DBPath  string
+	LogPath string
+	// LiveAddr is where the realtime "visitors right now" endpoint listens;
+	// empty disables it.
+	LiveAddr string
+	// MetricsAddr is where the daemon serves Prometheus metrics about its
+	// own ingestion (lines read, write latency, queue depth); empty
+	// disables it.
+	MetricsAddr string
+	// DeadLetterPath is the rotating file redacted samples of unparseable
+	// lines are written to; empty disables it (failures are still counted).
+	DeadLetterPath string
+	// ParseFailureThreshold is the share of failing lines above which the
+	// daemon warns; 0 uses DefaultParseFailureThreshold.
+	ParseFailureThreshold float64
+	// DefaultHost buckets lines that carry no host; THEIA_DEFAULT_HOST
+	// overrides it, and empty falls back to "default".
+	DefaultHost string
+	// Rules are the exclusion, normalization and host rules in force at
+	// startup.
+	Rules Rules
+	// Reload receives a value whenever Rules should be re-read through
+	// LoadRules, and the goals and funnels from the database (the daemon
+	// wires SIGHUP to it). Nil disables reloading.
+	Reload <-chan os.Signal
+	// LoadRules re-reads the rules on reload; an error keeps the rules
+	// already in force.
+	LoadRules func() (Rules, error)
+	// StageOrder names the pipeline stages every parsed line runs through,
+	// in order; empty uses DefaultStageOrder followed by CustomStages.
+	StageOrder []string
+	// CustomStages are stages supplied by a program embedding the daemon,
+	// available to StageOrder by name alongside the built-in ones.
+	CustomStages []NamedStage
+	// Retention is how long the periodic cleanup keeps each table's rows,
+	// per host; a zero Default uses DefaultRetention.
+	Retention RetentionPolicy
+	// Notifier reports readiness, status and watchdog pings to systemd;
+	// the zero value reports nothing.
+	Notifier systemd.Notifier
+}
+
+func Run(ctx context.Context, cfg Config) error {
+	dbPath, logPath := cfg.DBPath, cfg.LogPath
+
+	stream, err := isStreamInput(logPath)
+	if err != nil {
+		return err
+	}
 	// "tail -F" retries indefinitely when the file is missing or
 	// unreadable rather than exiting, so a bad --log-path would otherwise
 	// leave the daemon polling forever with no visible error. Fail fast
-	// here with a clear message instead.
-	if err := checkLogFileReadable(logPath); err != nil {
+	// here with a clear message instead. (Opening a named pipe for this
+	// check would block until a writer appears, so streams skip it.)
+	if !stream {
+		if err := checkLogFileReadable(logPath); err != nil {
+			return err
+		}
+	}
+
+	retention := cfg.Retention
+	if retention.Default == (Retention{}) {
+		retention.Default = DefaultRetention()
+	}
+	if err := validateRetentionPolicy(retention); err != nil {
+		return err
+	}
+
+	initialRules, err := compileRules(cfg.Rules)
+	if err != nil {
+		return err
+	}
+	pageViewRules := newRuleSwitch(initialRules)
+	stages, err := buildPipeline(cfg.StageOrder, cfg.CustomStages, pageViewRules)
+	if err != nil {
 		return err
 	}
 
@@ -44,6 +120,7 @@ func Run(ctx context.Context, dbPath string, logPath string) error {
 		return fmt.Errorf("failed to acquire migration lock: %w", lockErr)
 	}
 
+	notifyIngest(cfg.Notifier, systemd.Status("Migrating database schema"))
 	migrationsErr := database.RunMigrations(db, database.MigrationsFS, database.MigrationsPath)
 	if migrationsErr != nil {
 		_ = release() // release error is not actionable on the failure path
@@ -57,49 +134,175 @@ func Run(ctx context.Context, dbPath string, logPath string) error {
 	} else {
 		log.Printf("Database schema version: %d (dirty: %v)", version, dirty)
 		if dirty {
-			log.Fatal("Database is in a dirty state. Manual intervention required.")
+			return database.DirtyError(version)
+		}
+	}
+
+	rules, err := loadConversionRules(ctx, db)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutdown landed while the goals were loading; same graceful
+			// exit as a cancel during Open above.
+			return nil
 		}
+		return err
 	}
 
 	pageViews := make(chan PageView, 100)
+	recent := newRecentWindows()
+	metrics := newDaemonMetrics(logPath, time.Now())
+
+	// The input can end without a shutdown signal (a stream hitting EOF, or
+	// tail exiting on its own), so the background goroutines get their own
+	// cancel to stop them once it does.
+	ctx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
 
 	// Draining pageViews and running a cleanup already in flight at shutdown
 	// must not be aborted by the same cancellation that signals shutdown, so
 	// DB writes use a context that keeps values but drops the cancel signal.
 	dbCtx := context.WithoutCancel(ctx)
 
+	conversions := newConversionSwitch(rules)
 	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		processPageviews(dbCtx, db, pageViews)
+		processPageviews(dbCtx, db, pageViews, conversions, recent, metrics)
 	}()
 	go func() {
 		defer wg.Done()
-		runPeriodicCleanup(ctx, dbCtx, db, time.NewTicker(12*time.Hour))
+		runPeriodicCleanup(ctx, dbCtx, db, retention, time.NewTicker(CleanupInterval), metrics)
 	}()
 
+	if cfg.Reload != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watchReloads(ctx.Done(), cfg.Reload, func() {
+				if cfg.LoadRules != nil {
+					reloadRules(cfg.LoadRules, pageViewRules)
+				}
+				reloadConversionRules(ctx, db, conversions)
+			})
+		}()
+	}
+
+	if cfg.LiveAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The live view is a convenience next to ingestion, so failing to
+			// bind it (e.g. the port is taken) is logged rather than taking
+			// the daemon down with it.
+			if err := live.Run(ctx, recent.Visitors, recent.Scanners, cfg.LiveAddr); err != nil {
+				log.Printf("Warning: live view unavailable: %v", err)
+			}
+		}()
+		log.Printf("Live view listening on %s", cfg.LiveAddr)
+	}
+
+	if cfg.MetricsAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Like the live view, the daemon's own metrics are there to
+			// watch ingestion, not part of it, so a bind failure is logged
+			// and ingestion carries on.
+			snapshot := func() promsink.DaemonSnapshot { return metrics.snapshot(len(pageViews), cap(pageViews)) }
+			if err := promsink.RunDaemon(ctx, cfg.MetricsAddr, snapshot); err != nil {
+				log.Printf("Warning: daemon metrics unavailable: %v", err)
+			}
+		}()
+		log.Printf("Daemon metrics listening on %s", cfg.MetricsAddr)
+	}
+
+	rejects := newRejectLog(dbCtx, db, cfg.DeadLetterPath, cfg.ParseFailureThreshold)
+	lines := meteredLines{lines: rejects, metrics: metrics}
+
+	// The database is migrated and every worker is running, so from here on
+	// lines are ingested as they arrive: that's what READY promises.
+	notifyIngest(cfg.Notifier, systemd.Ready, systemd.Status("Reading "+logPath))
+	if cfg.Notifier.Socket != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			superviseIngest(ctx, cfg.Notifier, metrics, func() (int, int) { return len(pageViews), cap(pageViews) })
+		}()
+	}
+	settings := parseSettings{
+		DefaultHost: resolveDefaultHost(cfg.DefaultHost),
+		Stages:      stages,
+	}
+
 	// tailLog blocks until ctx is canceled (e.g. by a SIGINT/SIGTERM wired
 	// in by cmd.Execute), at which point exec.CommandContext kills the
 	// "tail -F" child and unblocks the scanner loop below. A non-nil
 	// return instead means tail exited on its own (e.g. missing file,
 	// permission denied), which must reach the caller as a real failure.
-	tailErr := tailLog(ctx, buildTailArgs(logPath), pageViews)
-	if tailErr != nil {
-		log.Printf("Log tailing stopped: %v", tailErr)
+	// A stream is read directly instead, and also ends cleanly at EOF.
+	var inputErr error
+	if stream {
+		log.Printf("Reading log stream from %s", logPath)
+		inputErr = readLogStream(ctx, logPath, settings, pageViews, lines)
 	} else {
+		inputErr = tailLog(ctx, buildTailArgs(logPath), settings, pageViews, lines)
+	}
+	switch {
+	case inputErr != nil:
+		log.Printf("Log reading stopped: %v", inputErr)
+	case ctx.Err() != nil:
 		log.Println("Shutdown signal received, stopping...")
+	default:
+		log.Println("Log stream ended, stopping...")
 	}
 
+	notifyIngest(cfg.Notifier, systemd.Stopping)
+	stopWorkers()
 	close(pageViews)
 	wg.Wait()
 
-	if tailErr != nil {
-		return fmt.Errorf("tailing log: %w", tailErr)
+	if inputErr != nil {
+		return fmt.Errorf("reading log: %w", inputErr)
 	}
 	return nil
 }
 
+// loadConversionRules reads the goal and funnel definitions once at startup.
+// They're matched against every page view, so a malformed row (e.g.
+// hand-edited in sqlite) is skipped with a warning instead of failing the
+// daemon or silently never matching.
+func loadConversionRules(ctx context.Context, db *sql.DB) (conversionRules, error) {
+	goalDefs, err := goals.List(ctx, db)
+	if err != nil {
+		return conversionRules{}, fmt.Errorf("loading goals: %w", err)
+	}
+	funnelDefs, err := funnels.List(ctx, db)
+	if err != nil {
+		return conversionRules{}, fmt.Errorf("loading funnels: %w", err)
+	}
+
+	var rules conversionRules
+	for _, g := range goalDefs {
+		if err := goals.Validate(g); err != nil {
+			log.Printf("Warning: skipping goal: %v", err)
+			continue
+		}
+		rules.Goals = append(rules.Goals, g)
+	}
+	for _, f := range funnelDefs {
+		if err := funnels.Validate(f); err != nil {
+			log.Printf("Warning: skipping funnel: %v", err)
+			continue
+		}
+		rules.Funnels = append(rules.Funnels, f)
+	}
+	if len(rules.Goals) > 0 || len(rules.Funnels) > 0 {
+		log.Printf("Tracking %d goal(s) and %d funnel(s)", len(rules.Goals), len(rules.Funnels))
+	}
+	return rules, nil
+}
+
 // checkLogFileReadable returns a wrapped, actionable error (unwrappable via
 // errors.Is against fs.ErrNotExist / fs.ErrPermission) if logPath can't be
 // opened for reading.
@@ -127,18 +330,27 @@ func buildTailArgs(logPath string) []string {
 	return []string{"-n", "0", "-F", logPath}
 }
 
-// runPeriodicCleanup runs performAllCleanups on a timer until shutdown is
-// canceled. dbCtx (not shutdown) is used for the cleanup queries themselves,
-// so a cleanup already running when shutdown fires can still complete.
-func runPeriodicCleanup(shutdown context.Context, dbCtx context.Context, db *sql.DB, ticker *time.Ticker) {
-	performAllCleanups(dbCtx, db)
+// runPeriodicCleanup runs PerformCleanup on a timer until shutdown is
+// canceled, timing each run and counting the bytes it reclaimed into
+// metrics. dbCtx (not shutdown) is used for
+// the cleanup queries themselves, so a cleanup already running when shutdown
+// fires can still complete.
+func runPeriodicCleanup(shutdown context.Context, dbCtx context.Context, db *sql.DB, policy RetentionPolicy, ticker *time.Ticker, metrics *daemonMetrics) {
+	cleanup := func() {
+		started := time.Now()
+		reclaimed := PerformCleanup(dbCtx, db, policy)
+		took := time.Since(started)
+		log.Printf("Cleanup finished in %s, reclaiming %d bytes", took.Round(time.Millisecond), reclaimed)
+		metrics.cleanupFinished(time.Now(), took, reclaimed)
+	}
+	cleanup()
 
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			performAllCleanups(dbCtx, db)
+			cleanup()
 		case <-shutdown.Done():
 			log.Println("Cleanup goroutine shutting down...")
 			return
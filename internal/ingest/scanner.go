@@ -0,0 +1,88 @@
+package ingest
+
+import (
+	"net"
+	"strings"
+)
+
+// Scanner signatures a request path can match, as recorded in hourly_scans.
+// They mirror the paths docs/nginx-hardening.md blocks: nothing a real
+// visitor requests, and nearly everything an automated vulnerability scanner
+// does.
+const (
+	SignatureWordPress   = "wordpress"
+	SignatureEnvFile     = "env_file"
+	SignatureVCS         = "vcs"
+	SignatureCredentials = "credentials"
+	SignatureCGI         = "cgi"
+	SignatureTraversal   = "traversal"
+)
+
+// traversalMarkers are the encodings of "../" scanners use to walk out of
+// the web root. They're checked against the raw, still-encoded path.
+var traversalMarkers = []string{"../", "..\\", "..%2f", "..%5c", "%2e%2e"}
+
+// segmentSignatures maps a path segment (lowercased) to the signature it
+// indicates wherever it appears in the path, e.g. /blog/wp-login.php or
+// /app/.git/config.
+var segmentSignatures = map[string]string{
+	"wp-admin":         SignatureWordPress,
+	"wp-login.php":     SignatureWordPress,
+	"wp-includes":      SignatureWordPress,
+	"wp-content":       SignatureWordPress,
+	"xmlrpc.php":       SignatureWordPress,
+	".git":             SignatureVCS,
+	".svn":             SignatureVCS,
+	".hg":              SignatureVCS,
+	".htaccess":        SignatureCredentials,
+	".htpasswd":        SignatureCredentials,
+	".aws":             SignatureCredentials,
+	"sftp-config.json": SignatureCredentials,
+}
+
+// detectScanner returns the signature a request for rawPath answered with
+// status matches, or "" for ordinary traffic. Only refused requests (4xx,
+// including nginx's 444) count: a WordPress site serves /wp-content/ and
+// /wp-login.php to real visitors, and their IPs must never end up in a deny
+// list.
+func detectScanner(rawPath string, status int) string {
+	if status < 400 || status > 499 {
+		return ""
+	}
+
+	lower := strings.ToLower(rawPath)
+	for _, marker := range traversalMarkers {
+		if strings.Contains(lower, marker) {
+			return SignatureTraversal
+		}
+	}
+
+	if i := strings.IndexByte(lower, '?'); i >= 0 {
+		lower = lower[:i]
+	}
+	if strings.HasPrefix(lower, "/cgi-bin/") {
+		return SignatureCGI
+	}
+	for _, segment := range strings.Split(lower, "/") {
+		if signature, ok := segmentSignatures[segment]; ok {
+			return signature
+		}
+		// .env, .env.local, .env.production.bak, ...
+		if segment == ".env" || strings.HasPrefix(segment, ".env.") {
+			return SignatureEnvFile
+		}
+	}
+	return ""
+}
+
+// scannerIP returns ip in canonical form if it's a literal IP address, or ""
+// otherwise. Offending IPs end up in nginx deny rules and fail2ban input, so
+// anything that isn't strictly an address (a hostname, or a crafted value
+// from a misconfigured real_ip setup) is never passed through.
+func scannerIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	return parsed.String()
+}
@@ -0,0 +1,166 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func TestDetectScanner(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", ""},
+		{"/blog/environment-variables", ""},
+		{"/docs/git-basics", ""},
+		{"/.env", SignatureEnvFile},
+		{"/app/.env.production.bak", SignatureEnvFile},
+		{"/wp-login.php", SignatureWordPress},
+		{"/blog/wp-admin/setup-config.php", SignatureWordPress},
+		{"/XMLRPC.PHP", SignatureWordPress},
+		{"/.git/config", SignatureVCS},
+		{"/.svn/entries", SignatureVCS},
+		{"/sftp-config.json", SignatureCredentials},
+		{"/.aws/credentials", SignatureCredentials},
+		{"/cgi-bin/luci", SignatureCGI},
+		{"/static/../../etc/passwd", SignatureTraversal},
+		{"/files/%2e%2e/%2e%2e/etc/passwd", SignatureTraversal},
+		{"/search?q=.env", ""},
+	}
+
+	for _, tt := range tests {
+		if got := detectScanner(tt.path, 404); got != tt.want {
+			t.Errorf("detectScanner(%q, 404) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// A probe path that was served is a real page on the site, e.g. a
+// WordPress theme's assets, not a scan.
+func TestDetectScannerOnlyCountsRefusedRequests(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{200, ""},
+		{301, ""},
+		{403, SignatureWordPress},
+		{404, SignatureWordPress},
+		{444, SignatureWordPress},
+		{500, ""},
+	}
+
+	for _, tt := range tests {
+		if got := detectScanner("/wp-login.php", tt.status); got != tt.want {
+			t.Errorf("detectScanner(/wp-login.php, %d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+// parseAndClassify runs line through the parser and the default pipeline,
+// as the tailer does.
+func parseAndClassify(t *testing.T, line string) PageView {
+	t.Helper()
+	pv, err := parseNginxLog(line, "default")
+	if err != nil {
+		t.Fatalf("parseNginxLog: %v", err)
+	}
+	pv, keep := runPipeline(testParseSettings(t).Stages, pv)
+	if !keep {
+		t.Fatalf("default pipeline dropped %q", line)
+	}
+	return pv
+}
+
+func TestPipelineTagsScannerIP(t *testing.T) {
+	pv := parseAndClassify(t, `203.0.113.9 - - [24/Dec/2024:10:30:45 +0000] "GET /.env HTTP/1.1" 404 0 "-" "curl/8.0"`)
+	if pv.ScanSignature != SignatureEnvFile || pv.ClientIP != "203.0.113.9" {
+		t.Errorf("expected an env_file probe from 203.0.113.9, got signature %q ip %q", pv.ScanSignature, pv.ClientIP)
+	}
+
+	pv = parseAndClassify(t, `203.0.113.9 - - [24/Dec/2024:10:30:45 +0000] "GET / HTTP/1.1" 200 10 "-" "Mozilla/5.0"`)
+	if pv.ClientIP != "" || pv.RemoteIP != "" {
+		t.Errorf("ordinary visitors must not carry their IP, got ClientIP %q RemoteIP %q", pv.ClientIP, pv.RemoteIP)
+	}
+
+	pv = parseAndClassify(t, `evil;host - - [24/Dec/2024:10:30:45 +0000] "GET /.env HTTP/1.1" 404 0 "-" "curl/8.0"`)
+	if pv.ScanSignature == "" || pv.ClientIP != "" {
+		t.Errorf("a non-IP client address must be counted but not reported, got signature %q ip %q", pv.ScanSignature, pv.ClientIP)
+	}
+}
+
+// Scanner probes land in hourly_scans and the in-memory offender list, and
+// nowhere else.
+func TestProcessPageviewsSeparatesScannerTraffic(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	ts := time.Now()
+	pageViews := make(chan PageView, 10)
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/", StatusCode: 200, IDHash: "alice"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/.env", StatusCode: 404, IDHash: "bot", ScanSignature: SignatureEnvFile, ClientIP: "203.0.113.9"}
+	pageViews <- PageView{Timestamp: ts, Host: "example.com", Path: "/.git/config", StatusCode: 404, IDHash: "bot", ScanSignature: SignatureVCS, ClientIP: "203.0.113.9"}
+	close(pageViews)
+
+	recent := newRecentWindows()
+	processPageviews(t.Context(), db, pageViews, newConversionSwitch(conversionRules{}), recent, newDaemonMetrics("test", time.Now()))
+
+	var scans, pageViewRows, statusRows, visitorRows int
+	for query, dest := range map[string]*int{
+		`SELECT COALESCE(SUM(count), 0) FROM hourly_scans`: &scans,
+		`SELECT COUNT(*) FROM hourly_stats`:                &pageViewRows,
+		`SELECT COUNT(*) FROM hourly_status_codes`:         &statusRows,
+		`SELECT COUNT(*) FROM visitor_days`:                &visitorRows,
+	} {
+		if err := db.QueryRowContext(t.Context(), query).Scan(dest); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if scans != 2 {
+		t.Errorf("hourly_scans total = %d, want 2", scans)
+	}
+	if pageViewRows != 1 || statusRows != 1 || visitorRows != 1 {
+		t.Errorf("expected only the real visit in hourly_stats/status codes/visitor_days, got %d/%d/%d rows", pageViewRows, statusRows, visitorRows)
+	}
+
+	offenders := recent.Scanners.Offenders(ts, 1)
+	if len(offenders) != 1 || offenders[0].IP != "203.0.113.9" || offenders[0].Hits != 2 {
+		t.Errorf("expected 203.0.113.9 with 2 hits, got %+v", offenders)
+	}
+	if snap := recent.Visitors.Snapshot(ts, "", 10); snap.Pageviews5m != 1 {
+		t.Errorf("live view should only count the real visit, got %d pageviews", snap.Pageviews5m)
+	}
+}
+
+// On a WordPress site, /wp-content/ is where real pages live: a request nginx
+// served is a page view, and its visitor's IP is never reported.
+func TestProcessPageviewsCountsServedWordPressPaths(t *testing.T) {
+	db, _ := setupTestDB(t)
+	t.Cleanup(func() {
+		_ = database.Close(db)
+	})
+
+	pv := parseAndClassify(t, `203.0.113.9 - - [24/Dec/2024:10:30:45 +0000] "GET /wp-content/plugins/forms/submit.php HTTP/1.1" 200 512 "-" "Mozilla/5.0"`)
+	if pv.ScanSignature != "" || pv.ClientIP != "" {
+		t.Fatalf("a served /wp-content/ request is not a probe, got signature %q ip %q", pv.ScanSignature, pv.ClientIP)
+	}
+
+	pageViews := make(chan PageView, 1)
+	pageViews <- pv
+	close(pageViews)
+	recent := newRecentWindows()
+	processPageviews(t.Context(), db, pageViews, newConversionSwitch(conversionRules{}), recent, newDaemonMetrics("test", time.Now()))
+
+	views := queryInt(t, db, `SELECT COALESCE(SUM(page_views), 0) FROM hourly_stats WHERE path = ?`, pv.Path)
+	scans := queryInt(t, db, `SELECT COALESCE(SUM(count), 0) FROM hourly_scans`)
+	if views != 1 || scans != 0 {
+		t.Errorf("expected 1 page view and no scans, got %d page views and %d scans", views, scans)
+	}
+	if offenders := recent.Scanners.Offenders(pv.Timestamp, 1); len(offenders) != 0 {
+		t.Errorf("a real visitor must not be listed as an offender, got %+v", offenders)
+	}
+}
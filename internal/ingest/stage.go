@@ -0,0 +1,142 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stage is one step of the pipeline a parsed page view passes through before
+// it's counted. A stage can rewrite fields (e.g. normalize the path), tag the
+// page view by setting its classification fields (IsBot, IsStatic,
+// ScanSignature), or drop it by returning false.
+//
+// Stages run on the tailer goroutine, one line at a time, so Apply must not
+// block; a stage that keeps state between calls must guard it itself.
+type Stage interface {
+	Apply(pageView PageView) (PageView, bool)
+}
+
+// StageFunc adapts an ordinary function to Stage.
+type StageFunc func(pageView PageView) (PageView, bool)
+
+// Apply calls f.
+func (f StageFunc) Apply(pageView PageView) (PageView, bool) { return f(pageView) }
+
+// NamedStage is a Stage together with the name a pipeline order refers to it
+// by.
+type NamedStage struct {
+	Name  string
+	Stage Stage
+}
+
+// Names of the built-in stages.
+const (
+	// StageBot sets IsBot from the user agent.
+	StageBot = "bot"
+	// StageStatic sets IsStatic for asset paths (CSS, images, fonts, ...).
+	StageStatic = "static"
+	// StageScanner sets ScanSignature (and ClientIP) for refused
+	// vulnerability-scanner probes, which are then counted apart from page
+	// views.
+	StageScanner = "scanner"
+	// StageRules applies the operator's exclusion, normalization and host
+	// rules; it always reads the rules currently in force, so a SIGHUP
+	// reload takes effect without rebuilding the pipeline.
+	StageRules = "rules"
+)
+
+// DefaultStageOrder is the pipeline used when none is configured:
+// classification first, so rules see tagged page views.
+func DefaultStageOrder() []string {
+	return []string{StageBot, StageStatic, StageScanner, StageRules}
+}
+
+func builtinStages(rules *ruleSwitch) map[string]Stage {
+	return map[string]Stage{
+		StageBot: StageFunc(func(pv PageView) (PageView, bool) {
+			pv.IsBot = detectBot(pv.UserAgent)
+			return pv, true
+		}),
+		StageStatic: StageFunc(func(pv PageView) (PageView, bool) {
+			pv.IsStatic = isStaticAsset(pv.Path)
+			return pv, true
+		}),
+		StageScanner: StageFunc(func(pv PageView) (PageView, bool) {
+			pv.ScanSignature = detectScanner(pv.Path, pv.StatusCode)
+			if pv.ScanSignature != "" {
+				pv.ClientIP = scannerIP(pv.RemoteIP)
+			}
+			return pv, true
+		}),
+		StageRules: StageFunc(func(pv PageView) (PageView, bool) {
+			return applyRules(rules.load(), pv)
+		}),
+	}
+}
+
+// ValidateStageOrder reports whether order can be built from the built-in
+// stages plus custom, so a bad config is rejected before the daemon starts.
+func ValidateStageOrder(order []string, custom []NamedStage) error {
+	_, err := buildPipeline(order, custom, newRuleSwitch(compiledRules{}))
+	return err
+}
+
+// buildPipeline resolves order into the stages to run. An empty order means
+// DefaultStageOrder followed by every custom stage, in the order given;
+// otherwise only the named stages run, so a built-in can be left out or moved.
+func buildPipeline(order []string, custom []NamedStage, rules *ruleSwitch) ([]NamedStage, error) {
+	available := builtinStages(rules)
+	var customNames []string
+	for _, c := range custom {
+		if c.Name == "" || c.Stage == nil {
+			return nil, fmt.Errorf("invalid custom stage %q: name and stage are both required", c.Name)
+		}
+		if _, exists := available[c.Name]; exists {
+			return nil, fmt.Errorf("invalid custom stage %q: name already taken", c.Name)
+		}
+		available[c.Name] = c.Stage
+		customNames = append(customNames, c.Name)
+	}
+
+	if len(order) == 0 {
+		order = append(DefaultStageOrder(), customNames...)
+	}
+
+	pipeline := make([]NamedStage, 0, len(order))
+	seen := map[string]bool{}
+	for _, name := range order {
+		stage, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown pipeline stage %q (built-in stages: %s)", name, strings.Join(DefaultStageOrder(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("pipeline stage %q is listed more than once", name)
+		}
+		seen[name] = true
+		pipeline = append(pipeline, NamedStage{Name: name, Stage: stage})
+	}
+	return pipeline, nil
+}
+
+// runPipeline passes pageView through stages in order, stopping at the
+// first that drops it.
+func runPipeline(stages []NamedStage, pageView PageView) (PageView, bool) {
+	pageView, droppedBy := applyStages(stages, pageView)
+	return pageView, droppedBy == ""
+}
+
+// applyStages is runPipeline reporting the name of the stage that dropped
+// pageView, or "" when every stage kept it.
+func applyStages(stages []NamedStage, pageView PageView) (PageView, string) {
+	for _, s := range stages {
+		var keep bool
+		pageView, keep = s.Stage.Apply(pageView)
+		if !keep {
+			return PageView{}, s.Name
+		}
+	}
+	// The raw client address is only there for stages to inspect; it must
+	// not travel on to the counters.
+	pageView.RemoteIP = ""
+	return pageView, ""
+}
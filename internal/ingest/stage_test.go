@@ -0,0 +1,77 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildPipeline_DefaultOrderThenCustom(t *testing.T) {
+	tagger := NamedStage{Name: "tag-internal", Stage: StageFunc(func(pv PageView) (PageView, bool) {
+		if strings.HasPrefix(pv.UserAgent, "AcmeMonitor") {
+			pv.IsBot = true
+		}
+		return pv, true
+	})}
+
+	stages, err := buildPipeline(nil, []NamedStage{tagger}, newRuleSwitch(compiledRules{}))
+	if err != nil {
+		t.Fatalf("buildPipeline: %v", err)
+	}
+	var names []string
+	for _, s := range stages {
+		names = append(names, s.Name)
+	}
+	if got, want := strings.Join(names, ","), "bot,static,scanner,rules,tag-internal"; got != want {
+		t.Errorf("stage order = %s, want %s", got, want)
+	}
+
+	pv, keep := runPipeline(stages, PageView{Path: "/", UserAgent: "AcmeMonitor/1.0", RemoteIP: "192.0.2.1"})
+	if !keep || !pv.IsBot {
+		t.Errorf("custom stage didn't tag the page view: %+v keep=%v", pv, keep)
+	}
+	if pv.RemoteIP != "" {
+		t.Errorf("RemoteIP = %q after the pipeline, want it cleared", pv.RemoteIP)
+	}
+}
+
+func TestBuildPipeline_ExplicitOrder(t *testing.T) {
+	dropAdmin := NamedStage{Name: "drop-admin", Stage: StageFunc(func(pv PageView) (PageView, bool) {
+		return pv, !strings.HasPrefix(pv.Path, "/admin")
+	})}
+
+	// Leaving "static" out counts asset requests like any other page view.
+	stages, err := buildPipeline([]string{"drop-admin", StageBot}, []NamedStage{dropAdmin}, newRuleSwitch(compiledRules{}))
+	if err != nil {
+		t.Fatalf("buildPipeline: %v", err)
+	}
+
+	if _, keep := runPipeline(stages, PageView{Path: "/admin/users"}); keep {
+		t.Error("drop-admin stage kept /admin/users")
+	}
+	pv, keep := runPipeline(stages, PageView{Path: "/style.css", UserAgent: "Googlebot"})
+	if !keep || pv.IsStatic || !pv.IsBot {
+		t.Errorf("got %+v keep=%v, want a kept bot view not tagged static", pv, keep)
+	}
+}
+
+func TestBuildPipeline_Errors(t *testing.T) {
+	noop := StageFunc(func(pv PageView) (PageView, bool) { return pv, true })
+	cases := map[string]struct {
+		order  []string
+		custom []NamedStage
+		want   string
+	}{
+		"unknown":          {order: []string{"geoip"}, want: "unknown pipeline stage"},
+		"duplicate":        {order: []string{StageBot, StageBot}, want: "more than once"},
+		"shadows built-in": {custom: []NamedStage{{Name: StageBot, Stage: noop}}, want: "already taken"},
+		"nil stage":        {custom: []NamedStage{{Name: "x"}}, want: "required"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateStageOrder(tc.order, tc.custom)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
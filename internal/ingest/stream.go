@@ -0,0 +1,149 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// StdinLogPath is the --log-path value that reads the access log from
+// standard input, e.g. piped from `docker logs -f nginx`.
+const StdinLogPath = "-"
+
+// isStreamInput reports whether logPath is a stream (stdin or a named pipe)
+// rather than a regular file. Streams have no rotation to follow and no
+// offset to start from, so they're read directly instead of through tail,
+// and ingestion ends at EOF.
+func isStreamInput(logPath string) (bool, error) {
+	if logPath == StdinLogPath {
+		return true, nil
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return false, fmt.Errorf("opening log file %q: %w", logPath, err)
+	}
+	return info.Mode()&fs.ModeNamedPipe != 0, nil
+}
+
+// openLogStream opens stdin or the named pipe at logPath for streamLog.
+//
+// Opening a FIFO blocks until a writer opens the other end, which could be
+// never; if ctx is canceled first, the pending open is released by briefly
+// opening the write end ourselves.
+func openLogStream(ctx context.Context, logPath string) (io.ReadCloser, error) {
+	if logPath == StdinLogPath {
+		stdin, err := openNonblocking(syscall.Stdin, "stdin")
+		if err != nil {
+			return nil, err
+		}
+		return stdin, nil
+	}
+
+	type result struct {
+		file *os.File
+		err  error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		f, err := os.Open(logPath) //nolint:gosec // path is an operator-provided flag, not user input
+		opened <- result{file: f, err: err}
+	}()
+
+	select {
+	case r := <-opened:
+		if r.err != nil {
+			return nil, fmt.Errorf("opening log pipe %q: %w", logPath, r.err)
+		}
+		return r.file, nil
+	case <-ctx.Done():
+		if w, err := os.OpenFile(logPath, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil { //nolint:gosec // same operator-provided path
+			_ = w.Close() // close error is not actionable; the reader sees EOF either way
+		}
+		if r := <-opened; r.file != nil {
+			_ = r.file.Close() // close error is not actionable during shutdown
+		}
+		return nil, nil //nolint:nilnil // shutdown before a writer appeared is not an error
+	}
+}
+
+// nonblockingFile is a pollable duplicate of a descriptor theia didn't open,
+// such as stdin. O_NONBLOCK belongs to the open file description, which the
+// duplicate shares with the original and with whoever handed it to theia (a
+// terminal, or the pipeline's other end), so Close puts the flag back the way
+// it was found.
+type nonblockingFile struct {
+	*os.File
+	fd          int
+	wasBlocking bool
+}
+
+// openNonblocking returns a pollable duplicate of fd, so that closing it on
+// shutdown unblocks a pending read instead of waiting for the next line.
+func openNonblocking(fd int, name string) (*nonblockingFile, error) {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFL, 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("opening %s: %w", name, errno)
+	}
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", name, err)
+	}
+	if err := syscall.SetNonblock(dup, true); err != nil {
+		_ = syscall.Close(dup) // close error is not actionable on the failure path
+		return nil, fmt.Errorf("opening %s: %w", name, err)
+	}
+	return &nonblockingFile{
+		File:        os.NewFile(uintptr(dup), name),
+		fd:          fd,
+		wasBlocking: flags&syscall.O_NONBLOCK == 0,
+	}, nil
+}
+
+// Close closes the duplicate, then restores blocking mode on the original
+// descriptor if it had it.
+func (f *nonblockingFile) Close() error {
+	err := f.File.Close()
+	if f.wasBlocking {
+		if restoreErr := syscall.SetNonblock(f.fd, false); restoreErr != nil && err == nil {
+			err = fmt.Errorf("restoring blocking mode on %s: %w", f.Name(), restoreErr)
+		}
+	}
+	return err
+}
+
+// readLogStream reads the stream at logPath (stdin for "-") until EOF or
+// until ctx is canceled.
+func readLogStream(ctx context.Context, logPath string, settings parseSettings, pageViews chan<- PageView, lines lineRecorder) error {
+	input, err := openLogStream(ctx, logPath)
+	if err != nil || input == nil {
+		return err
+	}
+	return streamLog(ctx, input, settings, pageViews, lines)
+}
+
+// streamLog reads log lines from input until EOF or until ctx is canceled,
+// and closes input before returning. Like tailLog, every line's outcome is
+// reported to lines and kept lines pass through settings' stages.
+func streamLog(ctx context.Context, input io.ReadCloser, settings parseSettings, pageViews chan<- PageView, lines lineRecorder) error {
+	// Closing input unblocks a read in progress, so shutdown doesn't have to
+	// wait for the writer to send another line.
+	stop := context.AfterFunc(ctx, func() { _ = input.Close() })
+	defer func() {
+		if stop() {
+			_ = input.Close() // close error is not actionable once reading is done
+		}
+	}()
+
+	err := scanLogLines(input, settings, pageViews, lines)
+	if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading log stream: %w", err)
+	}
+	return nil
+}
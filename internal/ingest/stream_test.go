@@ -0,0 +1,213 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+)
+
+func TestStreamLog_EndsCleanlyAtEOF(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+
+	pageViews := make(chan PageView, 10)
+	done := make(chan error, 1)
+	settings := testParseSettings(t)
+	go func() {
+		done <- streamLog(t.Context(), r, settings, pageViews, discardLines{})
+	}()
+
+	for _, p := range []string{"/a", "/b"} {
+		if _, err := w.WriteString(accessLogLine(p) + "\n"); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("streamLog: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamLog did not return at EOF")
+	}
+	if got := len(pageViews); got != 2 {
+		t.Errorf("got %d page views, want 2", got)
+	}
+}
+
+// A writer that stays open but silent (e.g. `kubectl logs -f` on a quiet
+// pod) must not hold up shutdown.
+func TestStreamLog_StopsOnCancelWhileWaitingForInput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	defer w.Close() //nolint:errcheck // close error in defer is not actionable
+
+	ctx, cancel := context.WithCancel(t.Context())
+	pageViews := make(chan PageView, 1)
+	done := make(chan error, 1)
+	settings := testParseSettings(t)
+	go func() {
+		done <- streamLog(ctx, r, settings, pageViews, discardLines{})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("streamLog: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamLog did not return after cancellation")
+	}
+}
+
+func TestIsStreamInput(t *testing.T) {
+	dir := t.TempDir()
+	regular := filepath.Join(dir, "access.log")
+	createTestLogFile(t, regular, nil)
+	fifo := filepath.Join(dir, "access.fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Fatalf("mkfifo: %v", err)
+	}
+
+	for path, want := range map[string]bool{StdinLogPath: true, fifo: true, regular: false} {
+		got, err := isStreamInput(path)
+		if err != nil {
+			t.Fatalf("isStreamInput(%q): %v", path, err)
+		}
+		if got != want {
+			t.Errorf("isStreamInput(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+// Run against a named pipe ingests what's written to it and shuts down on
+// its own once the writer closes, with everything flushed to the database.
+func TestRun_NamedPipeShutsDownAtEOF(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	fifo := filepath.Join(dir, "access.fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Fatalf("mkfifo: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(t.Context(), Config{DBPath: dbPath, LogPath: fifo})
+	}()
+
+	// Opening the write end blocks until Run has opened the read end.
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open fifo for writing: %v", err)
+	}
+	for _, p := range []string{"/a", "/a", "/b"} {
+		if _, err := w.WriteString(accessLogLine(p) + "\n"); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after the pipe's writer closed")
+	}
+
+	db, err := database.Open(t.Context(), dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	var views int
+	if err := db.QueryRowContext(t.Context(), `SELECT COALESCE(SUM(page_views), 0) FROM hourly_stats`).Scan(&views); err != nil && err != sql.ErrNoRows {
+		t.Fatalf("count page views: %v", err)
+	}
+	if views != 3 {
+		t.Errorf("page views = %d, want 3", views)
+	}
+}
+
+// Shutdown must not hang while no writer has opened the pipe yet.
+func TestRun_NamedPipeStopsOnCancelBeforeWriter(t *testing.T) {
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "access.fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Fatalf("mkfifo: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, Config{DBPath: filepath.Join(dir, "test.db"), LogPath: fifo})
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation with no pipe writer")
+	}
+}
+
+// Stdin's O_NONBLOCK is shared with the terminal or pipeline that started
+// theia, so it must be blocking again once theia stops reading.
+func TestOpenNonblocking_RestoresBlockingModeOnClose(t *testing.T) {
+	var fds [2]int
+	if err := syscall.Pipe(fds[:]); err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = syscall.Close(fds[0])
+		_ = syscall.Close(fds[1])
+	})
+
+	nonblocking := func() bool {
+		t.Helper()
+		flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fds[0]), syscall.F_GETFL, 0)
+		if errno != 0 {
+			t.Fatalf("F_GETFL: %v", errno)
+		}
+		return flags&syscall.O_NONBLOCK != 0
+	}
+
+	f, err := openNonblocking(fds[0], "pipe")
+	if err != nil {
+		t.Fatalf("openNonblocking: %v", err)
+	}
+	if !nonblocking() {
+		t.Fatal("expected the shared description to be non-blocking while open")
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if nonblocking() {
+		t.Error("descriptor left non-blocking after Close")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 )
@@ -15,19 +16,33 @@ import (
 // keeps memory use bounded while staying generous enough for realistic traffic.
 const maxLogLineSize = 1 << 20 // 1 MiB
 
+// parseSettings are what tailLog needs to turn a raw line into a page view
+// ready to be counted.
+type parseSettings struct {
+	DefaultHost string
+	Stages      []NamedStage
+}
+
 // tailLog runs "tail" over tailArgs and streams parsed lines to pageViews
-// until ctx is canceled or the tail process exits.
+// until ctx is canceled or the tail process exits. Lines that carry no host
+// are attributed to settings.DefaultHost, and every parsed line passes through
+// settings.Stages, which decide whether and how it's counted. Every line's outcome is
+// reported to lines, so unparseable ones are accounted for rather than
+// dropped silently.
 //
 // A non-nil error means tail exited on its own (e.g. the log file is
 // missing, or unreadable due to permissions) rather than being killed by
 // ctx cancellation; it wraps tail's own stderr diagnostic so the caller can
 // surface a clear, actionable message instead of the daemon silently going
 // idle.
-func tailLog(ctx context.Context, tailArgs []string, pageViews chan<- PageView) error {
+func tailLog(ctx context.Context, tailArgs []string, settings parseSettings, pageViews chan<- PageView, lines lineRecorder) error {
 	tailLogCommand := exec.CommandContext(ctx, "tail", tailArgs...) //nolint:gosec // args are internal, not user input
 
 	var stderr bytes.Buffer
 	tailLogCommand.Stderr = &stderr
+	if r, ok := lines.(rotationRecorder); ok {
+		tailLogCommand.Stderr = io.MultiWriter(&stderr, &rotationWatcher{onRotate: r.rotated})
+	}
 
 	readCloser, err := tailLogCommand.StdoutPipe()
 	if err != nil {
@@ -45,22 +60,7 @@ func tailLog(ctx context.Context, tailArgs []string, pageViews chan<- PageView)
 		return fmt.Errorf("starting log reading: %w", err)
 	}
 
-	scanner := bufio.NewScanner(readCloser)
-	scanner.Buffer(make([]byte, 64*1024), maxLogLineSize*2)
-	scanner.Split(splitLinesSkippingOverlong(maxLogLineSize, func(size int) {
-		fmt.Printf("skipping log line of %d bytes, exceeds %d byte limit; ingestion continues\n", size, maxLogLineSize)
-	}))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		pageView, err := parseNginxLog(line)
-		if err != nil {
-			fmt.Printf("error occurred during parsing of the line, got: %v\n", err)
-			continue
-		}
-		pageViews <- pageView
-	}
-	if scanErr := scanner.Err(); scanErr != nil {
+	if scanErr := scanLogLines(readCloser, settings, pageViews, lines); scanErr != nil {
 		fmt.Printf("error occurred during reading of the log, got: %v\n", scanErr)
 	}
 
@@ -81,6 +81,51 @@ func tailLog(ctx context.Context, tailArgs []string, pageViews chan<- PageView)
 	return fmt.Errorf("tail exited unexpectedly: %w: %s", waitErr, stderrMsg)
 }
 
+// filterRecorder is implemented by a lineRecorder that also wants to know
+// which pipeline stage dropped a parsed line.
+type filterRecorder interface {
+	filtered(stage string)
+}
+
+// rotationRecorder is implemented by a lineRecorder that wants to know when
+// tailLog notices the followed file being rotated.
+type rotationRecorder interface {
+	rotated()
+}
+
+// scanLogLines parses every line read from r until EOF or a read error,
+// which it returns. Lines that fail to parse are reported to lines; the rest
+// run through settings.Stages and, unless a stage drops them, are sent to
+// pageViews.
+func scanLogLines(r io.Reader, settings parseSettings, pageViews chan<- PageView, lines lineRecorder) error {
+	filters, _ := lines.(filterRecorder)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineSize*2)
+	scanner.Split(splitLinesSkippingOverlong(maxLogLineSize, func(size int) {
+		fmt.Printf("skipping log line of %d bytes, exceeds %d byte limit; ingestion continues\n", size, maxLogLineSize)
+		lines.rejected("", ReasonOverlong)
+	}))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		pageView, err := parseNginxLog(line, settings.DefaultHost)
+		if err != nil {
+			lines.rejected(line, rejectReason(err))
+			continue
+		}
+		lines.accepted()
+		pageView, droppedBy := applyStages(settings.Stages, pageView)
+		if droppedBy != "" {
+			if filters != nil {
+				filters.filtered(droppedBy)
+			}
+			continue
+		}
+		pageViews <- pageView
+	}
+	return scanner.Err()
+}
+
 // splitLinesSkippingOverlong is a bufio.SplitFunc equivalent to bufio.ScanLines
 // except that, instead of aborting the whole scan with bufio.ErrTooLong once a
 // line exceeds maxSize, it discards that line and keeps scanning so later
@@ -147,3 +192,39 @@ func dropCR(data []byte) []byte {
 	}
 	return data
 }
+
+// maxRotationNoticeSize bounds how much of an unterminated stderr line
+// rotationWatcher keeps while waiting for its newline.
+const maxRotationNoticeSize = 4096
+
+// rotationWatcher is an io.Writer over tail's stderr that calls onRotate for
+// every notice GNU tail -F prints when the followed file is replaced
+// (rename-and-create rotation) or truncated in place (copytruncate).
+type rotationWatcher struct {
+	onRotate func()
+	pending  []byte
+}
+
+func (w *rotationWatcher) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		if isRotationNotice(string(w.pending[:i])) {
+			w.onRotate()
+		}
+		w.pending = w.pending[i+1:]
+	}
+	if len(w.pending) > maxRotationNoticeSize {
+		w.pending = nil
+	}
+	return len(p), nil
+}
+
+func isRotationNotice(line string) bool {
+	return strings.Contains(line, "has been replaced") ||
+		strings.Contains(line, "has appeared") ||
+		strings.Contains(line, "file truncated")
+}
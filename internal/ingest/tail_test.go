@@ -27,10 +27,11 @@ func TestTailLogFollowsRenameBasedRotation(t *testing.T) {
 	defer cancel()
 
 	pageViews := make(chan PageView, 10)
+	settings := testParseSettings(t)
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
-		if err := tailLog(ctx, []string{"-F", logPath}, pageViews); err != nil {
+		if err := tailLog(ctx, []string{"-F", logPath}, settings, pageViews, discardLines{}); err != nil {
 			t.Errorf("tailLog returned unexpected error: %v", err)
 		}
 	}()
@@ -67,7 +68,7 @@ func TestTailLogFollowsRenameBasedRotation(t *testing.T) {
 func TestTailLog_ReturnsErrorWithStderrForInvalidArgs(t *testing.T) {
 	pageViews := make(chan PageView, 1)
 
-	err := tailLog(t.Context(), []string{"--this-flag-does-not-exist"}, pageViews)
+	err := tailLog(t.Context(), []string{"--this-flag-does-not-exist"}, testParseSettings(t), pageViews, discardLines{})
 	if err == nil {
 		t.Fatal("expected tailLog to return an error for an invalid tail argument, got nil")
 	}
@@ -101,3 +102,21 @@ func appendAccessLogLine(path, urlPath string) error {
 	_, err = file.WriteString(accessLogLine(urlPath) + "\n")
 	return err
 }
+
+// discardLines is a lineRecorder for tests that don't exercise parse
+// failure accounting.
+type discardLines struct{}
+
+func (discardLines) accepted()                    {}
+func (discardLines) rejected(line, reason string) {}
+
+// testParseSettings parses with the built-in default host and pipeline, and
+// no rules.
+func testParseSettings(t *testing.T) parseSettings {
+	t.Helper()
+	stages, err := buildPipeline(nil, nil, newRuleSwitch(compiledRules{}))
+	if err != nil {
+		t.Fatalf("buildPipeline: %v", err)
+	}
+	return parseSettings{DefaultHost: "default", Stages: stages}
+}
@@ -3,12 +3,23 @@ package ingest
 import "time"
 
 type PageView struct {
-	Timestamp  time.Time
-	Host       string
-	Path       string
-	Referrer   string
-	UserAgent  string
-	IDHash     string
+	Timestamp time.Time
+	Host      string
+	Method    string
+	Path      string
+	Referrer  string
+	UserAgent string
+	IDHash    string
+	// ScanSignature is set when the request matches a known scanner probe;
+	// such requests are counted in hourly_scans instead of as page views.
+	ScanSignature string
+	// ClientIP is only set for scanner requests, so the offending address
+	// can be reported from memory. Ordinary visitors are identified by
+	// IDHash alone.
+	ClientIP string
+	// RemoteIP is the client address as logged. It's only available to
+	// pipeline stages and is cleared before the page view is counted.
+	RemoteIP   string
 	StatusCode int
 	BytesSent  int
 	IsBot      bool
@@ -19,16 +30,14 @@ type VisitorDay struct {
 	FirstSeen time.Time
 	Hash      string
 	Host      string
-	Year      int
-	YearDay   int
+	// Day is the UTC day since the Unix epoch, see bucket.Day.
+	Day int64
 }
 
 type HourlyStatusCodes struct {
 	Path       string
 	Host       string
-	Hour       int
-	YearDay    int
-	Year       int
+	Bucket     int64
 	StatusCode int
 	Count      int
 }
@@ -37,18 +46,14 @@ type HourlyReferrers struct {
 	Path     string
 	Host     string
 	Referrer string
-	Hour     int
-	YearDay  int
-	Year     int
+	Bucket   int64
 	Count    int
 }
 
 type HourlyStats struct {
 	Path      string
 	Host      string
-	Hour      int
-	YearDay   int
-	Year      int
+	Bucket    int64
 	Pageviews int
 	BotViews  int
 	IsStatic  bool
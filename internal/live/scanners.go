@@ -0,0 +1,129 @@
+package live
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ScannerSpan is how long an offending IP is remembered after its last
+	// probe. It's deliberately short: the list feeds bans that should be
+	// applied promptly, and IPs are never written to disk.
+	ScannerSpan = time.Hour
+
+	// maxScannerIPs bounds how many offending IPs are held. Past it the IP
+	// seen least recently is forgotten first.
+	maxScannerIPs = 10_000
+)
+
+// ScanHit is one request matching a scanner signature.
+type ScanHit struct {
+	Time      time.Time
+	IP        string
+	Signature string
+}
+
+// Offender is one IP's scanner activity within ScannerSpan.
+//
+//nolint:govet // fieldalignment: JSON output field order follows struct order; reordering would change the rendered output
+type Offender struct {
+	IP         string    `json:"ip"`
+	Hits       int       `json:"hits"`
+	Signatures []string  `json:"signatures"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+type offenderState struct {
+	signatures map[string]struct{}
+	firstSeen  time.Time
+	lastSeen   time.Time
+	hits       int
+}
+
+// Scanners is a bounded, concurrency-safe record of the IPs that sent
+// scanner probes recently, written by the ingest loop and read by the live
+// endpoint.
+type Scanners struct {
+	offenders map[string]*offenderState
+	mu        sync.Mutex
+}
+
+// NewScanners returns an empty Scanners.
+func NewScanners() *Scanners {
+	return &Scanners{offenders: map[string]*offenderState{}}
+}
+
+// Record counts h against its IP, forgetting IPs idle for longer than
+// ScannerSpan (and, if still at capacity, the least recently seen one).
+func (s *Scanners) Record(h ScanHit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.offenders[h.IP]
+	if !ok {
+		if len(s.offenders) >= maxScannerIPs {
+			s.evict(h.Time)
+		}
+		o = &offenderState{signatures: map[string]struct{}{}, firstSeen: h.Time}
+		s.offenders[h.IP] = o
+	}
+	o.hits++
+	o.signatures[h.Signature] = struct{}{}
+	if h.Time.After(o.lastSeen) {
+		o.lastSeen = h.Time
+	}
+}
+
+func (s *Scanners) evict(now time.Time) {
+	cutoff := now.Add(-ScannerSpan)
+	oldestIP, oldest := "", time.Time{}
+	for ip, o := range s.offenders {
+		if o.lastSeen.Before(cutoff) {
+			delete(s.offenders, ip)
+			continue
+		}
+		if oldestIP == "" || o.lastSeen.Before(oldest) {
+			oldestIP, oldest = ip, o.lastSeen
+		}
+	}
+	if len(s.offenders) >= maxScannerIPs {
+		delete(s.offenders, oldestIP)
+	}
+}
+
+// Offenders returns every IP with at least minHits probes whose last probe
+// is within ScannerSpan of now, most active first.
+func (s *Scanners) Offenders(now time.Time, minHits int) []Offender {
+	cutoff := now.Add(-ScannerSpan)
+
+	s.mu.Lock()
+	results := []Offender{}
+	for ip, o := range s.offenders {
+		if o.hits < minHits || o.lastSeen.Before(cutoff) {
+			continue
+		}
+		signatures := make([]string, 0, len(o.signatures))
+		for sig := range o.signatures {
+			signatures = append(signatures, sig)
+		}
+		sort.Strings(signatures)
+		results = append(results, Offender{
+			IP:         ip,
+			Hits:       o.hits,
+			Signatures: signatures,
+			FirstSeen:  o.firstSeen,
+			LastSeen:   o.lastSeen,
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Hits != results[j].Hits {
+			return results[i].Hits > results[j].Hits
+		}
+		return results[i].IP < results[j].IP
+	})
+	return results
+}
@@ -0,0 +1,48 @@
+package live
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestScannersOffenders(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	s := NewScanners()
+
+	s.Record(ScanHit{Time: now.Add(-2 * ScannerSpan), IP: "198.51.100.1", Signature: "env_file"})
+	for range 3 {
+		s.Record(ScanHit{Time: now, IP: "203.0.113.9", Signature: "wordpress"})
+	}
+	s.Record(ScanHit{Time: now, IP: "203.0.113.9", Signature: "env_file"})
+	s.Record(ScanHit{Time: now, IP: "2001:db8::1", Signature: "vcs"})
+
+	got := s.Offenders(now, 1)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 recent offenders (the stale one dropped), got %+v", got)
+	}
+	if got[0].IP != "203.0.113.9" || got[0].Hits != 4 || len(got[0].Signatures) != 2 || got[0].Signatures[0] != "env_file" {
+		t.Errorf("expected 203.0.113.9 first with 4 hits over env_file and wordpress, got %+v", got[0])
+	}
+
+	if got := s.Offenders(now, 2); len(got) != 1 {
+		t.Errorf("expected min hits to filter out single-probe IPs, got %+v", got)
+	}
+}
+
+func TestScannersEvictsAtCapacity(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	s := NewScanners()
+
+	s.Record(ScanHit{Time: now.Add(-time.Minute), IP: "oldest", Signature: "env_file"})
+	for i := range maxScannerIPs {
+		s.Record(ScanHit{Time: now, IP: fmt.Sprintf("10.0.%d.%d", i/256, i%256), Signature: "env_file"})
+	}
+
+	if len(s.offenders) != maxScannerIPs {
+		t.Fatalf("expected %d tracked IPs, got %d", maxScannerIPs, len(s.offenders))
+	}
+	if _, ok := s.offenders["oldest"]; ok {
+		t.Error("expected the least recently seen IP to be evicted first")
+	}
+}
@@ -0,0 +1,111 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shutdownGrace bounds how long Run waits for in-flight requests to finish
+// once ctx is canceled before forcing the listener closed.
+const shutdownGrace = 5 * time.Second
+
+// defaultTop is how many paths/referrers a snapshot lists when the request
+// doesn't say.
+const defaultTop = 10
+
+// NewServer builds the live endpoint's http.Server over w and s. It does
+// not listen — the caller controls the accept loop and shutdown.
+func NewServer(w *Window, s *Scanners, addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/live", Handler(w))
+	mux.HandleFunc("GET /api/v1/scanners", ScannersHandler(s))
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// Handler serves the current Snapshot of w as JSON. It takes optional host
+// and top query params, like the stats API.
+func Handler(w *Window) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		host := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("host")))
+
+		top := defaultTop
+		if v := r.URL.Query().Get("top"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(rw, fmt.Sprintf("invalid top %q: must be a positive integer", v), http.StatusBadRequest)
+				return
+			}
+			top = n
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(w.Snapshot(time.Now(), host, top))
+	}
+}
+
+// scannersResponse wraps the offender list so fields can be added later
+// without breaking clients that decode it.
+type scannersResponse struct {
+	Offenders []Offender `json:"offenders"`
+}
+
+// ScannersHandler serves the IPs in s that sent at least min_hits (default
+// 1) scanner probes within ScannerSpan, as JSON.
+func ScannersHandler(s *Scanners) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		minHits := 1
+		if v := r.URL.Query().Get("min_hits"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(rw, fmt.Sprintf("invalid min_hits %q: must be a positive integer", v), http.StatusBadRequest)
+				return
+			}
+			minHits = n
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(scannersResponse{Offenders: s.Offenders(time.Now(), minHits)})
+	}
+}
+
+// Run serves w and s on addr and blocks until ctx is canceled or the server
+// fails to start, then shuts it down gracefully.
+func Run(ctx context.Context, w *Window, s *Scanners, addr string) error {
+	srv := NewServer(w, s, addr)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- fmt.Errorf("live server: %w", err)
+			return
+		}
+		errCh <- nil
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		// Draining in-flight requests must not be aborted by the same
+		// cancellation that signals shutdown, so Shutdown uses a fresh
+		// timeout derived from a context that keeps values but drops the
+		// cancel signal.
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownGrace)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("shutting down live server: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler_ServesSnapshot(t *testing.T) {
+	w := NewWindow()
+	w.Record(Hit{Time: time.Now(), Host: "example.com", Path: "/post", Visitor: "a"})
+	w.Record(Hit{Time: time.Now(), Host: "other.com", Path: "/", Visitor: "b"})
+
+	rec := httptest.NewRecorder()
+	Handler(w)(rec, httptest.NewRequest(http.MethodGet, "/api/v1/live?host=Example.com", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200, body: %s", rec.Code, rec.Body.String())
+	}
+	var got Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Visitors5m != 1 || len(got.TopPaths) != 1 || got.TopPaths[0].Value != "/post" {
+		t.Errorf("expected only example.com's visitor on /post, got %+v", got)
+	}
+}
+
+func TestHandler_BadTop(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler(NewWindow())(rec, httptest.NewRequest(http.MethodGet, "/api/v1/live?top=0", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status: got %d, want 400", rec.Code)
+	}
+}
+
+func TestRun_ShutsDownCleanlyOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(ctx, NewWindow(), NewScanners(), "127.0.0.1:0")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Run() on context cancel = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after context cancellation")
+	}
+}
+
+func TestRun_ReturnsErrorWhenListenFails(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	if err := Run(context.Background(), NewWindow(), NewScanners(), listener.Addr().String()); err == nil {
+		t.Fatal("Run() with an already-bound address = nil error, want non-nil")
+	}
+}
@@ -0,0 +1,179 @@
+// Package live keeps short sliding windows of recent traffic in memory — page
+// views, so the daemon can answer "who is on the site right now" within
+// seconds instead of waiting for the hourly rollups in sqlite to fill, and
+// scanner probes, so offending IPs can be banned without ever writing them
+// to disk.
+package live
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ShortSpan and LongSpan are the two windows a Snapshot reports on.
+	ShortSpan = 5 * time.Minute
+	LongSpan  = 30 * time.Minute
+
+	// maxHits bounds how many page views the window holds. A viral spike or
+	// a scanner can push far more than this through in 30 minutes; past the
+	// cap the oldest hits are dropped first, so the short window — the one
+	// that matters during a spike — stays accurate the longest.
+	maxHits = 100_000
+)
+
+// Hit is the slice of a page view the live window needs. Visitor is the
+// same daily-rotating hash the daemon stores in visitor_days.
+type Hit struct {
+	Time     time.Time
+	Host     string
+	Path     string
+	Referrer string
+	Visitor  string
+}
+
+// Window is a bounded, concurrency-safe buffer of the last LongSpan of hits,
+// written by the ingest loop and read by the live endpoint.
+type Window struct {
+	hits []Hit
+	mu   sync.Mutex
+}
+
+// NewWindow returns an empty Window.
+func NewWindow() *Window {
+	return &Window{}
+}
+
+// Record appends h and drops hits that have aged out of LongSpan relative to
+// it. Log lines arrive (nearly) in time order, so expired hits are always at
+// the front.
+func (w *Window) Record(h Hit) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.hits = append(w.hits, h)
+
+	cutoff := h.Time.Add(-LongSpan)
+	drop := 0
+	for drop < len(w.hits) && w.hits[drop].Time.Before(cutoff) {
+		drop++
+	}
+	if over := len(w.hits) - maxHits; over > drop {
+		drop = over
+	}
+	// Re-slicing rather than copying keeps Record O(1) amortized: the
+	// dropped prefix is released the next time append outgrows the array.
+	w.hits = w.hits[drop:]
+}
+
+// Count is one path or referrer in a Snapshot: the distinct visitors and the
+// page views it had over ShortSpan.
+type Count struct {
+	Value     string `json:"value"`
+	Visitors  int    `json:"visitors"`
+	Pageviews int    `json:"pageviews"`
+}
+
+// Snapshot is the live view at one instant.
+//
+//nolint:govet // fieldalignment: JSON output field order follows struct order; reordering would change the rendered output
+type Snapshot struct {
+	GeneratedAt  time.Time `json:"generated_at"`
+	Visitors5m   int       `json:"visitors_5m"`
+	Pageviews5m  int       `json:"pageviews_5m"`
+	Visitors30m  int       `json:"visitors_30m"`
+	Pageviews30m int       `json:"pageviews_30m"`
+	TopPaths     []Count   `json:"top_paths"`
+	TopReferrers []Count   `json:"top_referrers"`
+}
+
+// Snapshot summarizes the hits in w as of now, optionally filtered to one
+// host. TopPaths and TopReferrers cover ShortSpan and are ranked by distinct
+// visitors, then page views; top bounds their length.
+func (w *Window) Snapshot(now time.Time, host string, top int) Snapshot {
+	w.mu.Lock()
+	hits := append([]Hit(nil), w.hits...)
+	w.mu.Unlock()
+
+	return summarize(hits, now, host, top)
+}
+
+type visitorKey struct {
+	host    string
+	visitor string
+}
+
+type tally struct {
+	visitors  map[visitorKey]struct{}
+	pageviews int
+}
+
+func summarize(hits []Hit, now time.Time, host string, top int) Snapshot {
+	shortCutoff := now.Add(-ShortSpan)
+	longCutoff := now.Add(-LongSpan)
+
+	short := map[visitorKey]struct{}{}
+	long := map[visitorKey]struct{}{}
+	paths := map[string]*tally{}
+	referrers := map[string]*tally{}
+
+	snap := Snapshot{GeneratedAt: now}
+	for _, h := range hits {
+		if h.Time.Before(longCutoff) || h.Time.After(now) {
+			continue
+		}
+		if host != "" && h.Host != host {
+			continue
+		}
+		key := visitorKey{host: h.Host, visitor: h.Visitor}
+		long[key] = struct{}{}
+		snap.Pageviews30m++
+
+		if h.Time.Before(shortCutoff) {
+			continue
+		}
+		short[key] = struct{}{}
+		snap.Pageviews5m++
+		count(paths, h.Path, key)
+		if h.Referrer != "" && h.Referrer != "-" {
+			count(referrers, h.Referrer, key)
+		}
+	}
+
+	snap.Visitors5m = len(short)
+	snap.Visitors30m = len(long)
+	snap.TopPaths = ranked(paths, top)
+	snap.TopReferrers = ranked(referrers, top)
+	return snap
+}
+
+func count(tallies map[string]*tally, value string, key visitorKey) {
+	t, ok := tallies[value]
+	if !ok {
+		t = &tally{visitors: map[visitorKey]struct{}{}}
+		tallies[value] = t
+	}
+	t.visitors[key] = struct{}{}
+	t.pageviews++
+}
+
+func ranked(tallies map[string]*tally, top int) []Count {
+	results := make([]Count, 0, len(tallies))
+	for value, t := range tallies {
+		results = append(results, Count{Value: value, Visitors: len(t.visitors), Pageviews: t.pageviews})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Visitors != results[j].Visitors {
+			return results[i].Visitors > results[j].Visitors
+		}
+		if results[i].Pageviews != results[j].Pageviews {
+			return results[i].Pageviews > results[j].Pageviews
+		}
+		return results[i].Value < results[j].Value
+	})
+	if top > 0 && len(results) > top {
+		results = results[:top]
+	}
+	return results
+}
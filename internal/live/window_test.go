@@ -0,0 +1,73 @@
+package live
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotCountsShortAndLongWindows(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	w := NewWindow()
+
+	w.Record(Hit{Time: now.Add(-20 * time.Minute), Host: "example.com", Path: "/old", Visitor: "a"})
+	w.Record(Hit{Time: now.Add(-2 * time.Minute), Host: "example.com", Path: "/post", Referrer: "https://news.ycombinator.com/", Visitor: "b"})
+	w.Record(Hit{Time: now.Add(-1 * time.Minute), Host: "example.com", Path: "/post", Referrer: "https://news.ycombinator.com/", Visitor: "c"})
+	w.Record(Hit{Time: now.Add(-1 * time.Minute), Host: "example.com", Path: "/post", Referrer: "-", Visitor: "c"})
+	w.Record(Hit{Time: now, Host: "other.com", Path: "/", Visitor: "d"})
+
+	got := w.Snapshot(now, "", 10)
+	if got.Visitors5m != 3 || got.Pageviews5m != 4 {
+		t.Errorf("5m: got %d visitors / %d pageviews, want 3 / 4", got.Visitors5m, got.Pageviews5m)
+	}
+	if got.Visitors30m != 4 || got.Pageviews30m != 5 {
+		t.Errorf("30m: got %d visitors / %d pageviews, want 4 / 5", got.Visitors30m, got.Pageviews30m)
+	}
+	if len(got.TopPaths) != 2 || got.TopPaths[0] != (Count{Value: "/post", Visitors: 2, Pageviews: 3}) {
+		t.Errorf("TopPaths = %+v, want /post first with 2 visitors and 3 pageviews", got.TopPaths)
+	}
+	if len(got.TopReferrers) != 1 || got.TopReferrers[0].Visitors != 2 {
+		t.Errorf("TopReferrers = %+v, want only the HN referrer with 2 visitors", got.TopReferrers)
+	}
+
+	filtered := w.Snapshot(now, "other.com", 10)
+	if filtered.Visitors30m != 1 || len(filtered.TopPaths) != 1 {
+		t.Errorf("host filter: got %+v, want only other.com's single visitor", filtered)
+	}
+}
+
+func TestRecordDropsExpiredHits(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	w := NewWindow()
+
+	w.Record(Hit{Time: now.Add(-LongSpan - time.Minute), Path: "/gone", Visitor: "a"})
+	w.Record(Hit{Time: now, Path: "/here", Visitor: "b"})
+
+	if len(w.hits) != 1 || w.hits[0].Path != "/here" {
+		t.Errorf("expected only the recent hit to be kept, got %+v", w.hits)
+	}
+}
+
+func TestRecordCapsHits(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	w := NewWindow()
+
+	for range maxHits + 10 {
+		w.Record(Hit{Time: now, Path: "/", Visitor: "a"})
+	}
+	if len(w.hits) != maxHits {
+		t.Errorf("expected window capped at %d hits, got %d", maxHits, len(w.hits))
+	}
+}
+
+func TestSnapshotTopBoundsLists(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	w := NewWindow()
+	for _, p := range []string{"/a", "/b", "/c"} {
+		w.Record(Hit{Time: now, Path: p, Visitor: p})
+	}
+
+	got := w.Snapshot(now, "", 2)
+	if len(got.TopPaths) != 2 {
+		t.Errorf("expected 2 paths with top=2, got %+v", got.TopPaths)
+	}
+}
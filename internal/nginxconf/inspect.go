@@ -0,0 +1,108 @@
+package nginxconf
+
+import "strings"
+
+// CombinedFormat is the definition of nginx's predefined "combined"
+// log_format, used by every access_log that doesn't name a format.
+const CombinedFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+
+// AccessLog is one access_log directive that writes to a file, with the
+// log_format it uses.
+type AccessLog struct {
+	// Path is the file or destination as written, e.g.
+	// "/var/log/nginx/access.log" or "syslog:server=unix:/dev/log".
+	Path string `json:"path"`
+	// FormatName is the log_format the directive names; "combined" when it
+	// names none.
+	FormatName string `json:"format_name"`
+	// Format is FormatName's definition with its strings joined the way
+	// nginx joins them; empty when no log_format of that name exists.
+	Format string `json:"format"`
+	// Gzip is set when nginx compresses what it writes (the gzip parameter).
+	Gzip bool `json:"gzip,omitempty"`
+	// ServerNames are the server_name values of the enclosing server block;
+	// empty for an access_log at http level.
+	ServerNames []string `json:"server_names,omitempty"`
+	File        string   `json:"file"`
+	Line        int      `json:"line"`
+}
+
+// Report is what Inspect finds in a configuration.
+type Report struct {
+	AccessLogs []AccessLog `json:"access_logs"`
+	// Formats maps every log_format name to its definition, including the
+	// predefined "combined".
+	Formats map[string]string `json:"formats"`
+}
+
+// Inspect lists cfg's HTTP access logs in the order nginx reads them.
+// "access_log off" is skipped, as are the stream and mail modules' logs,
+// which aren't HTTP requests.
+func Inspect(cfg Config) Report {
+	formats := map[string]string{"combined": CombinedFormat}
+	collectFormats(cfg.Directives, formats)
+	return Report{
+		AccessLogs: accessLogs(cfg.Directives, nil, formats),
+		Formats:    formats,
+	}
+}
+
+func collectFormats(directives []Directive, formats map[string]string) {
+	for _, d := range directives {
+		if d.Name == "log_format" && len(d.Args) >= 2 {
+			parts := d.Args[1:]
+			if strings.HasPrefix(parts[0], "escape=") {
+				parts = parts[1:]
+			}
+			formats[d.Args[0]] = strings.Join(parts, "")
+		}
+		collectFormats(d.Block, formats)
+	}
+}
+
+func accessLogs(directives []Directive, serverNames []string, formats map[string]string) []AccessLog {
+	var logs []AccessLog
+	for _, d := range directives {
+		switch d.Name {
+		case "stream", "mail":
+			continue
+		case "server":
+			logs = append(logs, accessLogs(d.Block, blockServerNames(d.Block), formats)...)
+			continue
+		case "access_log":
+			if len(d.Args) == 0 || d.Args[0] == "off" {
+				continue
+			}
+			formatName := "combined"
+			if len(d.Args) > 1 && !strings.Contains(d.Args[1], "=") {
+				formatName = d.Args[1]
+			}
+			log := AccessLog{
+				Path:        d.Args[0],
+				FormatName:  formatName,
+				Format:      formats[formatName],
+				ServerNames: serverNames,
+				File:        d.File,
+				Line:        d.Line,
+			}
+			for _, arg := range d.Args[1:] {
+				if arg == "gzip" || strings.HasPrefix(arg, "gzip=") {
+					log.Gzip = true
+				}
+			}
+			logs = append(logs, log)
+		}
+		logs = append(logs, accessLogs(d.Block, serverNames, formats)...)
+	}
+	return logs
+}
+
+func blockServerNames(block []Directive) []string {
+	var names []string
+	for _, d := range block {
+		if d.Name == "server_name" {
+			names = append(names, d.Args...)
+		}
+	}
+	return names
+}
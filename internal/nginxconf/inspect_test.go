@@ -0,0 +1,70 @@
+package nginxconf_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/internal/nginxconf"
+)
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "nginx.conf")
+	writeFile(t, main, `http {
+    log_format theia_combined '$remote_addr - $remote_user [$time_local] '
+                              '"$request" $status $body_bytes_sent '
+                              '"$http_referer" "$http_user_agent" "$host"';
+    log_format json escape=json '{"ip":"$remote_addr"}';
+    access_log /var/log/nginx/access.log theia_combined buffer=32k;
+
+    server {
+        server_name blog.example.com;
+        access_log /var/log/nginx/blog.log;
+        location /static/ {
+            access_log off;
+        }
+        location /api/ {
+            access_log /var/log/nginx/api.json.gz json gzip=5;
+        }
+    }
+}
+stream {
+    access_log /var/log/nginx/stream.log;
+}
+`)
+	conf, err := nginxconf.Load(main)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	report := nginxconf.Inspect(conf)
+
+	wantFormat := `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" "$host"`
+	want := []nginxconf.AccessLog{
+		{Path: "/var/log/nginx/access.log", FormatName: "theia_combined", Format: wantFormat, File: main, Line: 6},
+		{Path: "/var/log/nginx/blog.log", FormatName: "combined", Format: nginxconf.CombinedFormat, ServerNames: []string{"blog.example.com"}, File: main, Line: 10},
+		{Path: "/var/log/nginx/api.json.gz", FormatName: "json", Format: `{"ip":"$remote_addr"}`, Gzip: true, ServerNames: []string{"blog.example.com"}, File: main, Line: 15},
+	}
+	if !reflect.DeepEqual(report.AccessLogs, want) {
+		t.Errorf("AccessLogs =\n%+v\nwant\n%+v", report.AccessLogs, want)
+	}
+	if report.Formats["combined"] != nginxconf.CombinedFormat || report.Formats["theia_combined"] != wantFormat {
+		t.Errorf("Formats = %+v", report.Formats)
+	}
+}
+
+func TestInspect_UndefinedFormat(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "nginx.conf")
+	writeFile(t, main, "http { access_log /var/log/nginx/access.log nope; }\n")
+	conf, err := nginxconf.Load(main)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	logs := nginxconf.Inspect(conf).AccessLogs
+	if len(logs) != 1 || logs[0].FormatName != "nope" || logs[0].Format != "" {
+		t.Errorf("AccessLogs = %+v, want one log naming an undefined format", logs)
+	}
+}
@@ -0,0 +1,365 @@
+// Package nginxconf reads an nginx configuration — nginx.conf and every file
+// it includes, or the output of `nginx -T` — so theia can find the access logs
+// nginx writes and the log_format each one uses, instead of the operator
+// guessing which files to point the daemon at.
+//
+// It understands nginx's syntax (directives, blocks, quoting, comments and
+// include globs), not the meaning of every module's directives.
+package nginxconf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultPath is where distribution packages install nginx's main config.
+const DefaultPath = "/etc/nginx/nginx.conf"
+
+// maxIncludeDepth bounds include nesting, so an include loop fails instead
+// of recursing forever.
+const maxIncludeDepth = 16
+
+// Config is a parsed nginx configuration with every include expanded in
+// place.
+type Config struct {
+	// Main is the path of the top-level file, nginx.conf.
+	Main string
+	// Directives are Main's top-level directives.
+	Directives []Directive
+}
+
+// Directive is one nginx directive, e.g. `access_log /var/log/x.log main;`,
+// or a block such as `server { ... }`.
+type Directive struct {
+	Name string
+	// Args are the directive's arguments with quotes removed and escapes
+	// resolved.
+	Args []string
+	// Block holds the directives inside { }, and is nil for a directive
+	// ending in ";".
+	Block []Directive
+	// File and Line are where the directive is written, after includes are
+	// expanded.
+	File string
+	Line int
+}
+
+// source is where config files are read from: the filesystem, or the files
+// captured in an `nginx -T` dump.
+type source struct {
+	read func(name string) ([]byte, error)
+	glob func(pattern string) ([]string, error)
+}
+
+// Load reads the nginx configuration at path and every file it includes.
+// Relative include paths resolve against path's directory, as nginx resolves
+// them against its conf directory.
+func Load(path string) (Config, error) {
+	src := source{
+		read: os.ReadFile,
+		glob: filepath.Glob,
+	}
+	return load(src, path)
+}
+
+// ParseDump reads the output of `nginx -T`, which prints every config file
+// nginx loaded, each after a "# configuration file <path>:" line. The first
+// file is taken as the main config and includes resolve against the dumped
+// files, so the result matches what the running nginx sees even when the
+// files aren't readable from here.
+func ParseDump(r io.Reader) (Config, error) {
+	files := map[string][]byte{}
+	var main, current string
+	var content strings.Builder
+	flush := func() {
+		if current != "" {
+			files[current] = []byte(content.String())
+		}
+		content.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := dumpFileHeader(line); ok {
+			flush()
+			current = name
+			if main == "" {
+				main = name
+			}
+			continue
+		}
+		if current != "" {
+			content.WriteString(line)
+			content.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Config{}, fmt.Errorf("reading nginx -T output: %w", err)
+	}
+	flush()
+	if main == "" {
+		return Config{}, errors.New(`no "# configuration file" sections found; expected the output of nginx -T`)
+	}
+
+	src := source{
+		read: func(name string) ([]byte, error) {
+			data, ok := files[name]
+			if !ok {
+				return nil, fmt.Errorf("%s is not in the nginx -T output: %w", name, os.ErrNotExist)
+			}
+			return data, nil
+		},
+		glob: func(pattern string) ([]string, error) {
+			var matches []string
+			for name := range files {
+				ok, err := filepath.Match(pattern, name)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					matches = append(matches, name)
+				}
+			}
+			slices.Sort(matches)
+			return matches, nil
+		},
+	}
+	return load(src, main)
+}
+
+func dumpFileHeader(line string) (string, bool) {
+	name, ok := strings.CutPrefix(line, "# configuration file ")
+	if !ok {
+		return "", false
+	}
+	name, ok = strings.CutSuffix(name, ":")
+	return name, ok && name != ""
+}
+
+func load(src source, path string) (Config, error) {
+	directives, err := loadFile(src, path, filepath.Dir(path), 0)
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{Main: path, Directives: directives}, nil
+}
+
+func loadFile(src source, name, confDir string, depth int) ([]Directive, error) {
+	data, err := src.read(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading nginx config: %w", err)
+	}
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	directives, _, err := parseBlock(tokens, 0, name, false)
+	if err != nil {
+		return nil, err
+	}
+	return expandIncludes(src, directives, confDir, depth)
+}
+
+// expandIncludes replaces every include directive with the directives of
+// the files it names, in the order nginx reads them.
+func expandIncludes(src source, directives []Directive, confDir string, depth int) ([]Directive, error) {
+	out := make([]Directive, 0, len(directives))
+	for _, d := range directives {
+		if d.Name != "include" {
+			if d.Block != nil {
+				block, err := expandIncludes(src, d.Block, confDir, depth)
+				if err != nil {
+					return nil, err
+				}
+				d.Block = block
+			}
+			out = append(out, d)
+			continue
+		}
+
+		if len(d.Args) != 1 {
+			return nil, fmt.Errorf("%s:%d: include takes exactly one argument", d.File, d.Line)
+		}
+		if depth >= maxIncludeDepth {
+			return nil, fmt.Errorf("%s:%d: includes nested more than %d deep (include loop?)", d.File, d.Line, maxIncludeDepth)
+		}
+		pattern := d.Args[0]
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(confDir, pattern)
+		}
+		names := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			// Like nginx, a glob that matches nothing is not an error.
+			matches, err := src.glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: include %q: %w", d.File, d.Line, d.Args[0], err)
+			}
+			names = matches
+		}
+		for _, name := range names {
+			included, err := loadFile(src, name, confDir, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: include: %w", d.File, d.Line, err)
+			}
+			out = append(out, included...)
+		}
+	}
+	return out, nil
+}
+
+// token is one lexical element of an nginx config: a word, a quoted string,
+// or one of the unquoted punctuation tokens ";", "{" and "}".
+type token struct {
+	text   string
+	line   int
+	quoted bool
+}
+
+func isPunct(t token, p string) bool { return !t.quoted && t.text == p }
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == ';' || c == '{' || c == '}':
+			tokens = append(tokens, token{text: string(c), line: line})
+			i++
+		case c == '"' || c == '\'':
+			start := line
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(src) {
+					return nil, fmt.Errorf("line %d: unterminated string", start)
+				}
+				ch := src[i]
+				if ch == c {
+					i++
+					break
+				}
+				if ch == '\\' && i+1 < len(src) {
+					b.WriteString(unescape(src[i+1], c))
+					if src[i+1] == '\n' {
+						line++
+					}
+					i += 2
+					continue
+				}
+				if ch == '\n' {
+					line++
+				}
+				b.WriteByte(ch)
+				i++
+			}
+			tokens = append(tokens, token{text: b.String(), line: start, quoted: true})
+		default:
+			start := i
+			inVar := false
+			for i < len(src) {
+				ch := src[i]
+				if ch == '\\' && i+1 < len(src) {
+					i += 2
+					continue
+				}
+				// "${name}" is a variable, not the start of a block.
+				if ch == '{' && i > start && src[i-1] == '$' {
+					inVar = true
+				} else if ch == '}' && inVar {
+					inVar = false
+				} else if ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' || ch == ';' || ch == '{' || ch == '}' {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, token{text: src[start:i], line: line})
+		}
+	}
+	return tokens, nil
+}
+
+// unescape resolves the character after a backslash inside a string quoted
+// with quote, the way nginx does.
+func unescape(ch, quote byte) string {
+	switch ch {
+	case quote, '\\':
+		return string(ch)
+	case 't':
+		return "\t"
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	default:
+		return "\\" + string(ch)
+	}
+}
+
+// parseBlock reads directives from tokens starting at pos until the end of
+// the file, or, when nested, until the "}" closing the block. It returns the
+// position after the last token consumed.
+func parseBlock(tokens []token, pos int, file string, nested bool) ([]Directive, int, error) {
+	directives := []Directive{}
+	for pos < len(tokens) {
+		t := tokens[pos]
+		if isPunct(t, "}") {
+			if !nested {
+				return nil, pos, fmt.Errorf(`%s:%d: unexpected "}"`, file, t.line)
+			}
+			return directives, pos + 1, nil
+		}
+		if isPunct(t, ";") || isPunct(t, "{") {
+			return nil, pos, fmt.Errorf("%s:%d: unexpected %q", file, t.line, t.text)
+		}
+
+		d := Directive{Name: t.text, File: file, Line: t.line}
+		pos++
+		for {
+			if pos >= len(tokens) {
+				return nil, pos, fmt.Errorf(`%s:%d: unexpected end of file, expecting ";" or "}"`, file, d.Line)
+			}
+			t = tokens[pos]
+			if isPunct(t, ";") {
+				pos++
+				break
+			}
+			if isPunct(t, "{") {
+				block, next, err := parseBlock(tokens, pos+1, file, true)
+				if err != nil {
+					return nil, next, err
+				}
+				d.Block = block
+				pos = next
+				break
+			}
+			if isPunct(t, "}") {
+				return nil, pos, fmt.Errorf(`%s:%d: unexpected "}"`, file, t.line)
+			}
+			d.Args = append(d.Args, t.text)
+			pos++
+		}
+		directives = append(directives, d)
+	}
+	if nested {
+		return nil, pos, fmt.Errorf(`%s: unexpected end of file, expecting "}"`, file)
+	}
+	return directives, pos, nil
+}
@@ -0,0 +1,172 @@
+package nginxconf_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Elysium-Labs-EU/theia/internal/nginxconf"
+)
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func names(directives []nginxconf.Directive) []string {
+	var out []string
+	for _, d := range directives {
+		out = append(out, d.Name)
+	}
+	return out
+}
+
+func TestLoad_ParsesSyntax(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "nginx.conf")
+	writeFile(t, main, `# global settings
+user www-data;
+http {
+    log_format main '$remote_addr "$request"'   # trailing comment
+                    " \"$host\"";
+    map $http_upgrade ${connection_upgrade}x { default upgrade; }
+    server {
+        server_name example.com www.example.com;
+        location / { return 200 "a;b{c}"; }
+    }
+}
+`)
+
+	conf, err := nginxconf.Load(main)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if conf.Main != main {
+		t.Errorf("Main = %q, want %q", conf.Main, main)
+	}
+	if got := names(conf.Directives); !reflect.DeepEqual(got, []string{"user", "http"}) {
+		t.Fatalf("top-level directives = %v", got)
+	}
+
+	http := conf.Directives[1]
+	if http.Line != 3 || http.File != main {
+		t.Errorf("http at %s:%d, want %s:3", http.File, http.Line, main)
+	}
+	logFormat := http.Block[0]
+	want := []string{"main", `$remote_addr "$request"`, ` "$host"`}
+	if !reflect.DeepEqual(logFormat.Args, want) {
+		t.Errorf("log_format args = %q, want %q", logFormat.Args, want)
+	}
+	if m := http.Block[1]; m.Name != "map" || m.Args[1] != "${connection_upgrade}x" || len(m.Block) != 1 {
+		t.Errorf("map = %+v, want ${...} kept inside the word", m)
+	}
+	server := http.Block[2]
+	if server.Line != 7 || !reflect.DeepEqual(server.Block[0].Args, []string{"example.com", "www.example.com"}) {
+		t.Errorf("server = %+v", server)
+	}
+	if ret := server.Block[1].Block[0]; !reflect.DeepEqual(ret.Args, []string{"200", "a;b{c}"}) {
+		t.Errorf("return args = %q", ret.Args)
+	}
+}
+
+func TestLoad_ExpandsIncludes(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "nginx.conf")
+	writeFile(t, main, `http {
+    include conf.d/*.conf;
+    include sites-enabled/only;
+    include missing/*.conf;
+}
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "b.conf"), "server { server_name b; }\n")
+	writeFile(t, filepath.Join(dir, "conf.d", "a.conf"), "server { server_name a; }\n")
+	writeFile(t, filepath.Join(dir, "sites-enabled", "only"), "server {\n    include snippets/log.conf;\n}\n")
+	writeFile(t, filepath.Join(dir, "snippets", "log.conf"), "access_log /var/log/nginx/x.log;\n")
+
+	conf, err := nginxconf.Load(main)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	servers := conf.Directives[0].Block
+	if len(servers) != 3 {
+		t.Fatalf("got %d directives in http, want 3 servers: %+v", len(servers), servers)
+	}
+	if servers[0].Block[0].Args[0] != "a" || servers[1].Block[0].Args[0] != "b" {
+		t.Errorf("glob includes not in sorted order: %+v", servers)
+	}
+	accessLog := servers[2].Block[0]
+	wantFile := filepath.Join(dir, "snippets", "log.conf")
+	if accessLog.Name != "access_log" || accessLog.File != wantFile || accessLog.Line != 1 {
+		t.Errorf("included access_log = %+v, want it at %s:1", accessLog, wantFile)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"missing semicolon", map[string]string{"nginx.conf": "user www-data\n"}, "unexpected end of file"},
+		{"unclosed block", map[string]string{"nginx.conf": "http {\n"}, `expecting "}"`},
+		{"stray brace", map[string]string{"nginx.conf": "}\n"}, `unexpected "}"`},
+		{"unterminated string", map[string]string{"nginx.conf": "user 'www;\n"}, "unterminated string"},
+		{"missing include", map[string]string{"nginx.conf": "include nope.conf;\n"}, "nope.conf"},
+		{"include loop", map[string]string{"nginx.conf": "include loop.conf;\n", "loop.conf": "include loop.conf;\n"}, "include loop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				writeFile(t, filepath.Join(dir, name), contents)
+			}
+			_, err := nginxconf.Load(filepath.Join(dir, "nginx.conf"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDump(t *testing.T) {
+	dump := `nginx: the configuration file /etc/nginx/nginx.conf syntax is ok
+# configuration file /etc/nginx/nginx.conf:
+http {
+    access_log /var/log/nginx/access.log;
+    include /etc/nginx/sites-enabled/*;
+}
+
+# configuration file /etc/nginx/sites-enabled/example:
+server {
+    server_name example.com;
+}
+
+`
+	conf, err := nginxconf.ParseDump(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseDump: %v", err)
+	}
+	if conf.Main != "/etc/nginx/nginx.conf" {
+		t.Errorf("Main = %q", conf.Main)
+	}
+	http := conf.Directives[0].Block
+	if got := names(http); !reflect.DeepEqual(got, []string{"access_log", "server"}) {
+		t.Fatalf("http directives = %v", got)
+	}
+	if http[1].File != "/etc/nginx/sites-enabled/example" || http[1].Line != 1 {
+		t.Errorf("server at %s:%d", http[1].File, http[1].Line)
+	}
+}
+
+func TestParseDump_RejectsOtherInput(t *testing.T) {
+	if _, err := nginxconf.ParseDump(strings.NewReader("http {}\n")); err == nil {
+		t.Error("want an error for input without nginx -T file headers")
+	}
+}
@@ -0,0 +1,199 @@
+package promsink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DaemonSnapshot is the daemon's view of its own health at one instant:
+// how much of its input it has read and what became of it, how fast it
+// writes, and when it last made progress. Unlike Snapshot it comes from
+// memory, not the database, and resets when the daemon restarts.
+type DaemonSnapshot struct {
+	// Input is the log the daemon reads, used as the "input" label.
+	Input     string
+	StartTime time.Time
+
+	LinesRead   uint64
+	LinesParsed uint64
+	// LinesFailed counts lines that could not be parsed, by reason.
+	LinesFailed map[string]uint64
+	// LinesFiltered counts parsed lines a pipeline stage dropped, by stage.
+	LinesFiltered map[string]uint64
+	// PageViewsDropped counts page views that reached the writer but are
+	// missing from the totals because their main database write failed.
+	PageViewsDropped uint64
+	// DBWriteErrors counts failed database writes, by table.
+	DBWriteErrors map[string]uint64
+	// DBWriteDuration is how long recording one page view took.
+	DBWriteDuration Histogram
+
+	// QueueDepth is how many parsed page views are waiting for the writer,
+	// out of QueueCapacity; a full queue stalls reading.
+	QueueDepth    int
+	QueueCapacity int
+
+	// Rotations counts log rotations (replacement or truncation) noticed
+	// while following the input.
+	Rotations uint64
+
+	CleanupRuns         uint64
+	CleanupSeconds      float64
+	LastCleanupDuration time.Duration
+	LastCleanup         time.Time
+	// ReclaimedBytes is how much the incremental vacuum after each cleanup
+	// has shrunk the database file by.
+	ReclaimedBytes int64
+
+	// LastLineRead and LastIngested are when a line was last read and a
+	// page view last written; zero when none has been yet.
+	LastLineRead time.Time
+	LastIngested time.Time
+}
+
+// Histogram is a Prometheus histogram's state: Buckets hold cumulative
+// counts of observations at or below each UpperBound, in ascending order,
+// and Count includes the ones above the last bound.
+type Histogram struct {
+	Buckets []Bucket
+	Sum     float64
+	Count   uint64
+}
+
+// Bucket is one cumulative histogram bucket.
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// RenderDaemon formats s as Prometheus text-exposition format, under the
+// theia_daemon_ prefix so it can share a Prometheus job with Render's
+// analytics metrics without colliding.
+func RenderDaemon(s DaemonSnapshot) string {
+	var b strings.Builder
+	input := "input=" + quote(s.Input)
+
+	gauge(&b, "theia_daemon_start_time_seconds", "Unix time the daemon started.")
+	fmt.Fprintf(&b, "theia_daemon_start_time_seconds %s\n", unixSeconds(s.StartTime))
+
+	counter(&b, "theia_daemon_log_lines_read_total", "Log lines read, parsed or not.")
+	fmt.Fprintf(&b, "theia_daemon_log_lines_read_total{%s} %d\n", input, s.LinesRead)
+
+	counter(&b, "theia_daemon_log_lines_parsed_total", "Log lines parsed into page views.")
+	fmt.Fprintf(&b, "theia_daemon_log_lines_parsed_total{%s} %d\n", input, s.LinesParsed)
+
+	counter(&b, "theia_daemon_log_lines_failed_total", "Log lines that could not be parsed, by reason.")
+	for _, reason := range sortedKeys(s.LinesFailed) {
+		fmt.Fprintf(&b, "theia_daemon_log_lines_failed_total{%s,reason=%s} %d\n", input, quote(reason), s.LinesFailed[reason])
+	}
+
+	counter(&b, "theia_daemon_log_lines_filtered_total", "Parsed log lines dropped by a pipeline stage, by stage.")
+	for _, stage := range sortedKeys(s.LinesFiltered) {
+		fmt.Fprintf(&b, "theia_daemon_log_lines_filtered_total{%s,stage=%s} %d\n", input, quote(stage), s.LinesFiltered[stage])
+	}
+
+	counter(&b, "theia_daemon_pageviews_dropped_total", "Page views lost because their database write failed.")
+	fmt.Fprintf(&b, "theia_daemon_pageviews_dropped_total %d\n", s.PageViewsDropped)
+
+	counter(&b, "theia_daemon_db_write_errors_total", "Failed database writes, by table.")
+	for _, table := range sortedKeys(s.DBWriteErrors) {
+		fmt.Fprintf(&b, "theia_daemon_db_write_errors_total{table=%s} %d\n", quote(table), s.DBWriteErrors[table])
+	}
+
+	b.WriteString("# HELP theia_daemon_db_write_duration_seconds Time taken to record one page view in the database.\n")
+	b.WriteString("# TYPE theia_daemon_db_write_duration_seconds histogram\n")
+	for _, bucket := range s.DBWriteDuration.Buckets {
+		fmt.Fprintf(&b, "theia_daemon_db_write_duration_seconds_bucket{le=%s} %d\n", quote(formatFloat(bucket.UpperBound)), bucket.Count)
+	}
+	fmt.Fprintf(&b, "theia_daemon_db_write_duration_seconds_bucket{le=\"+Inf\"} %d\n", s.DBWriteDuration.Count)
+	fmt.Fprintf(&b, "theia_daemon_db_write_duration_seconds_sum %s\n", formatFloat(s.DBWriteDuration.Sum))
+	fmt.Fprintf(&b, "theia_daemon_db_write_duration_seconds_count %d\n", s.DBWriteDuration.Count)
+
+	gauge(&b, "theia_daemon_queue_depth", "Parsed page views waiting to be written.")
+	fmt.Fprintf(&b, "theia_daemon_queue_depth %d\n", s.QueueDepth)
+	gauge(&b, "theia_daemon_queue_capacity", "Page views the queue holds before reading stalls.")
+	fmt.Fprintf(&b, "theia_daemon_queue_capacity %d\n", s.QueueCapacity)
+
+	counter(&b, "theia_daemon_log_rotations_total", "Log rotations noticed while following the input.")
+	fmt.Fprintf(&b, "theia_daemon_log_rotations_total{%s} %d\n", input, s.Rotations)
+
+	b.WriteString("# HELP theia_daemon_cleanup_duration_seconds Time taken by retention cleanups.\n")
+	b.WriteString("# TYPE theia_daemon_cleanup_duration_seconds summary\n")
+	fmt.Fprintf(&b, "theia_daemon_cleanup_duration_seconds_sum %s\n", formatFloat(s.CleanupSeconds))
+	fmt.Fprintf(&b, "theia_daemon_cleanup_duration_seconds_count %d\n", s.CleanupRuns)
+	gauge(&b, "theia_daemon_last_cleanup_duration_seconds", "Time taken by the most recent retention cleanup.")
+	fmt.Fprintf(&b, "theia_daemon_last_cleanup_duration_seconds %s\n", formatFloat(s.LastCleanupDuration.Seconds()))
+	gauge(&b, "theia_daemon_last_cleanup_timestamp_seconds", "Unix time the most recent retention cleanup finished; 0 before the first.")
+	fmt.Fprintf(&b, "theia_daemon_last_cleanup_timestamp_seconds %s\n", unixSeconds(s.LastCleanup))
+	counter(&b, "theia_daemon_vacuum_reclaimed_bytes_total", "Bytes the database file shrank by in the vacuum after each cleanup.")
+	fmt.Fprintf(&b, "theia_daemon_vacuum_reclaimed_bytes_total %d\n", s.ReclaimedBytes)
+
+	gauge(&b, "theia_daemon_last_line_timestamp_seconds", "Unix time a log line was last read; 0 before the first.")
+	fmt.Fprintf(&b, "theia_daemon_last_line_timestamp_seconds{%s} %s\n", input, unixSeconds(s.LastLineRead))
+	gauge(&b, "theia_daemon_last_ingested_timestamp_seconds", "Unix time a page view was last written; 0 before the first.")
+	fmt.Fprintf(&b, "theia_daemon_last_ingested_timestamp_seconds{%s} %s\n", input, unixSeconds(s.LastIngested))
+
+	return b.String()
+}
+
+// DaemonHandler serves snapshot's result as Prometheus metrics on every
+// scrape.
+func DaemonHandler(snapshot func() DaemonSnapshot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write([]byte(RenderDaemon(snapshot())))
+	}
+}
+
+// NewDaemonServer builds the daemon's own metrics endpoint. It does not
+// listen — the caller controls the accept loop and shutdown.
+func NewDaemonServer(addr string, snapshot func() DaemonSnapshot) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", DaemonHandler(snapshot))
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// RunDaemon serves the daemon's own metrics on addr until ctx is canceled
+// or the server fails to start, then shuts it down gracefully.
+func RunDaemon(ctx context.Context, addr string, snapshot func() DaemonSnapshot) error {
+	return serve(ctx, NewDaemonServer(addr, snapshot), nil)
+}
+
+func counter(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+}
+
+func gauge(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// unixSeconds renders t as fractional Unix seconds, or 0 for the zero time.
+func unixSeconds(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return formatFloat(float64(t.UnixNano()) / 1e9)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,84 @@
+package promsink
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderDaemon(t *testing.T) {
+	snap := DaemonSnapshot{
+		Input:            "/var/log/nginx/access.log",
+		StartTime:        time.Unix(1_700_000_000, 0),
+		LinesRead:        10,
+		LinesParsed:      8,
+		LinesFailed:      map[string]uint64{"no_match": 2},
+		LinesFiltered:    map[string]uint64{"rules": 3},
+		PageViewsDropped: 1,
+		DBWriteErrors:    map[string]uint64{"hourly_stats": 1},
+		DBWriteDuration: Histogram{
+			Buckets: []Bucket{{UpperBound: 0.001, Count: 4}, {UpperBound: 0.01, Count: 5}},
+			Sum:     0.0125,
+			Count:   6,
+		},
+		QueueDepth:          7,
+		QueueCapacity:       100,
+		Rotations:           2,
+		CleanupRuns:         1,
+		CleanupSeconds:      0.5,
+		LastCleanupDuration: 500 * time.Millisecond,
+		ReclaimedBytes:      8192,
+		LastIngested:        time.Unix(1_700_000_100, 500_000_000),
+	}
+
+	got := RenderDaemon(snap)
+
+	input := `input="/var/log/nginx/access.log"`
+	for _, want := range []string{
+		`theia_daemon_start_time_seconds 1.7e+09`,
+		`theia_daemon_log_lines_read_total{` + input + `} 10`,
+		`theia_daemon_log_lines_parsed_total{` + input + `} 8`,
+		`theia_daemon_log_lines_failed_total{` + input + `,reason="no_match"} 2`,
+		`theia_daemon_log_lines_filtered_total{` + input + `,stage="rules"} 3`,
+		`theia_daemon_pageviews_dropped_total 1`,
+		`theia_daemon_db_write_errors_total{table="hourly_stats"} 1`,
+		"# TYPE theia_daemon_db_write_duration_seconds histogram",
+		`theia_daemon_db_write_duration_seconds_bucket{le="0.001"} 4`,
+		`theia_daemon_db_write_duration_seconds_bucket{le="0.01"} 5`,
+		`theia_daemon_db_write_duration_seconds_bucket{le="+Inf"} 6`,
+		`theia_daemon_db_write_duration_seconds_sum 0.0125`,
+		`theia_daemon_db_write_duration_seconds_count 6`,
+		`theia_daemon_queue_depth 7`,
+		`theia_daemon_queue_capacity 100`,
+		`theia_daemon_log_rotations_total{` + input + `} 2`,
+		`theia_daemon_cleanup_duration_seconds_count 1`,
+		`theia_daemon_last_cleanup_duration_seconds 0.5`,
+		`theia_daemon_last_cleanup_timestamp_seconds 0`,
+		`theia_daemon_vacuum_reclaimed_bytes_total 8192`,
+		`theia_daemon_last_line_timestamp_seconds{` + input + `} 0`,
+		`theia_daemon_last_ingested_timestamp_seconds{` + input + `} 1.7000001005e+09`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("RenderDaemon() missing line %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestDaemonHandler(t *testing.T) {
+	srv := NewDaemonServer("127.0.0.1:0", func() DaemonSnapshot { return DaemonSnapshot{Input: "-", LinesRead: 3} })
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `theia_daemon_log_lines_read_total{input="-"} 3`) {
+		t.Errorf("body missing lines read, got:\n%s", rec.Body.String())
+	}
+}
@@ -1,6 +1,8 @@
 // Package promsink renders theia's pageview, status-code, and referrer
 // counts as Prometheus text-exposition metrics, served on their own
 // address independent of the bearer-authed JSON/CSV API in apiserver.
+// It also renders the daemon's own ingestion health (DaemonSnapshot), which
+// the daemon serves itself.
 package promsink
 
 import (
@@ -17,6 +19,7 @@ type Snapshot struct {
 	Paths       []query.PathStat
 	StatusCodes []query.StatusStat
 	Referrers   []query.ReferrerStat
+	ParseHealth query.ParseHealth
 }
 
 // Render formats s as Prometheus text-exposition format (version 0.0.4):
@@ -42,6 +45,16 @@ func Render(s Snapshot) string {
 		fmt.Fprintf(&b, "theia_referrers_total{referrer=%s} %d\n", quote(r.Referrer), r.Count)
 	}
 
+	b.WriteString("# HELP theia_log_lines_total Total access log lines read, parsed or not.\n")
+	b.WriteString("# TYPE theia_log_lines_total counter\n")
+	fmt.Fprintf(&b, "theia_log_lines_total %d\n", s.ParseHealth.Lines)
+
+	b.WriteString("# HELP theia_parse_failures_total Total access log lines that could not be parsed, by reason.\n")
+	b.WriteString("# TYPE theia_parse_failures_total counter\n")
+	for _, f := range s.ParseHealth.Reasons {
+		fmt.Fprintf(&b, "theia_parse_failures_total{reason=%s} %d\n", quote(f.Reason), f.Count)
+	}
+
 	return b.String()
 }
 
@@ -15,7 +15,12 @@ func TestRender_EmptySnapshot(t *testing.T) {
 		"# HELP theia_status_codes_total Total responses by HTTP status code.\n" +
 		"# TYPE theia_status_codes_total counter\n" +
 		"# HELP theia_referrers_total Total page views by referrer.\n" +
-		"# TYPE theia_referrers_total counter\n"
+		"# TYPE theia_referrers_total counter\n" +
+		"# HELP theia_log_lines_total Total access log lines read, parsed or not.\n" +
+		"# TYPE theia_log_lines_total counter\n" +
+		"theia_log_lines_total 0\n" +
+		"# HELP theia_parse_failures_total Total access log lines that could not be parsed, by reason.\n" +
+		"# TYPE theia_parse_failures_total counter\n"
 
 	if got != want {
 		t.Errorf("Render() =\n%q\nwant\n%q", got, want)
@@ -33,6 +38,10 @@ func TestRender_PopulatedSnapshot(t *testing.T) {
 		Referrers: []query.ReferrerStat{
 			{Referrer: "https://google.com", Count: 7},
 		},
+		ParseHealth: query.ParseHealth{
+			Reasons: []query.ParseFailureStat{{Reason: "no_match", Count: 3}},
+			Lines:   45,
+		},
 	}
 
 	got := Render(snap)
@@ -41,6 +50,8 @@ func TestRender_PopulatedSnapshot(t *testing.T) {
 		`theia_pageviews_total{host="example.com",path="/"} 42`,
 		`theia_status_codes_total{status_code="200"} 100`,
 		`theia_referrers_total{referrer="https://google.com"} 7`,
+		`theia_log_lines_total 45`,
+		`theia_parse_failures_total{reason="no_match"} 3`,
 	}
 	for _, want := range wantLines {
 		if !strings.Contains(got, want) {
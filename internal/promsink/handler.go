@@ -2,6 +2,7 @@ package promsink
 
 import (
 	"database/sql"
+	"net"
 	"net/http"
 	"time"
 
@@ -22,6 +23,9 @@ type Config struct {
 	// attacker-controlled or just high-cardinality access log can't turn
 	// every unique path into its own Prometheus time series.
 	Top int
+	// Listener, when set, is served instead of listening on Addr — e.g. a
+	// socket systemd passed in through socket activation.
+	Listener net.Listener
 }
 
 // Handler builds the /metrics HTTP handler: on every scrape it re-queries
@@ -47,7 +51,13 @@ func Handler(db *sql.DB, top int) http.HandlerFunc {
 			return
 		}
 
+		parseHealth, err := query.GetParseHealthRange(r.Context(), db, epoch, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
-		_, _ = w.Write([]byte(Render(Snapshot{Paths: paths, StatusCodes: statusCodes, Referrers: referrers})))
+		_, _ = w.Write([]byte(Render(Snapshot{Paths: paths, StatusCodes: statusCodes, Referrers: referrers, ParseHealth: parseHealth})))
 	}
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
 	"github.com/Elysium-Labs-EU/theia/internal/promsink"
 )
 
@@ -33,10 +34,10 @@ func setupTestDB(t *testing.T) *sql.DB {
 func insertHourlyStat(t *testing.T, db *sql.DB, path, host string, ts time.Time, pageViews int) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_stats (hour, year_day, year, path, host, page_views, is_static)
-		VALUES (?, ?, ?, ?, ?, ?, 0)
-		ON CONFLICT(hour, year_day, year, path, host) DO UPDATE SET page_views = page_views + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host, pageViews, pageViews,
+		INSERT INTO hourly_stats (bucket, path, host, page_views, is_static)
+		VALUES (?, ?, ?, ?, 0)
+		ON CONFLICT(bucket, path, host) DO UPDATE SET page_views = page_views + ?`,
+		bucket.Hour(ts), path, host, pageViews, pageViews,
 	)
 	if err != nil {
 		t.Fatalf("insert hourly stat: %v", err)
@@ -46,10 +47,10 @@ func insertHourlyStat(t *testing.T, db *sql.DB, path, host string, ts time.Time,
 func insertStatusCode(t *testing.T, db *sql.DB, path, host string, ts time.Time, statusCode, count int) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_status_codes (hour, year_day, year, path, host, status_code, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, status_code) DO UPDATE SET count = count + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host, statusCode, count, count,
+		INSERT INTO hourly_status_codes (bucket, path, host, status_code, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, status_code) DO UPDATE SET count = count + ?`,
+		bucket.Hour(ts), path, host, statusCode, count, count,
 	)
 	if err != nil {
 		t.Fatalf("insert status code: %v", err)
@@ -59,10 +60,10 @@ func insertStatusCode(t *testing.T, db *sql.DB, path, host string, ts time.Time,
 func insertReferrer(t *testing.T, db *sql.DB, path, host, referrer string, ts time.Time, count int) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_referrers (hour, year_day, year, path, host, referrer, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, referrer) DO UPDATE SET count = count + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host, referrer, count, count,
+		INSERT INTO hourly_referrers (bucket, path, host, referrer, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, referrer) DO UPDATE SET count = count + ?`,
+		bucket.Hour(ts), path, host, referrer, count, count,
 	)
 	if err != nil {
 		t.Fatalf("insert referrer: %v", err)
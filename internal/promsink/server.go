@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 )
@@ -29,11 +30,21 @@ func NewServer(db *sql.DB, cfg Config) *http.Server {
 // Run starts the metrics server and blocks until ctx is canceled or the
 // server fails to start, then shuts it down gracefully.
 func Run(ctx context.Context, db *sql.DB, cfg Config) error {
-	srv := NewServer(db, cfg)
+	return serve(ctx, NewServer(db, cfg), cfg.Listener)
+}
 
+// serve runs srv, on l or else on srv.Addr, until ctx is canceled or it
+// fails to start, then shuts it down gracefully.
+func serve(ctx context.Context, srv *http.Server, l net.Listener) error {
 	errCh := make(chan error, 1)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if l == nil {
+			err = srv.ListenAndServe()
+		} else {
+			err = srv.Serve(l)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errCh <- fmt.Errorf("metrics server: %w", err)
 			return
 		}
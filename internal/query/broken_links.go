@@ -0,0 +1,87 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BrokenLink is one (path, referrer, status) combination that got an error
+// (4xx or 5xx) response. Internal is set when the referrer is a page on the
+// same host — a link on the site itself that can be fixed, rather than an
+// external one that can only be redirected.
+//
+//nolint:govet // fieldalignment: JSON output field order follows struct order; reordering would change the rendered output
+type BrokenLink struct {
+	Path       string `json:"path"`
+	Host       string `json:"host"`
+	Referrer   string `json:"referrer"`
+	StatusCode int    `json:"status_code"`
+	Count      int    `json:"count"`
+	Internal   bool   `json:"internal"`
+}
+
+// GetBrokenLinks returns the most frequent error (path, referrer, status)
+// combinations since the given time.
+func GetBrokenLinks(ctx context.Context, db *sql.DB, since time.Time, host string, limit int) ([]BrokenLink, error) {
+	return getBrokenLinks(ctx, db, sinceHourClause, sinceHourArgs(since), host, limit)
+}
+
+// GetBrokenLinksRange is GetBrokenLinks over an explicit [from, to] range
+// instead of an open-ended "since now" window.
+func GetBrokenLinksRange(ctx context.Context, db *sql.DB, from, to time.Time, host string, limit int) ([]BrokenLink, error) {
+	return getBrokenLinks(ctx, db, hourRangeClause, hourRangeArgs(from, to), host, limit)
+}
+
+func getBrokenLinks(ctx context.Context, db *sql.DB, where string, args []any, host string, limit int) ([]BrokenLink, error) {
+	q := `
+	SELECT path, host, referrer, status_code, SUM(count) AS total
+	FROM hourly_broken_links
+	WHERE ` + where
+	if host != "" {
+		q += hostFilterClause
+		args = append(args, host)
+	}
+	q += " GROUP BY path, host, referrer, status_code ORDER BY total DESC, path, referrer LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, q, args...) //nolint:gosec // where is one of two fixed literals, not user input
+	if err != nil {
+		return nil, fmt.Errorf("querying broken links: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	results := []BrokenLink{}
+	for rows.Next() {
+		var l BrokenLink
+		if err := rows.Scan(&l.Path, &l.Host, &l.Referrer, &l.StatusCode, &l.Count); err != nil {
+			return nil, fmt.Errorf("scanning broken link: %w", err)
+		}
+		l.Internal = isInternalReferrer(l.Referrer, l.Host)
+		results = append(results, l)
+	}
+	return results, rows.Err()
+}
+
+// isInternalReferrer reports whether referrer is a URL on host, ignoring a
+// leading "www." on either side and any port. Without a logged host (the
+// "default" bucket) there's nothing to compare against, so nothing counts as
+// internal.
+func isInternalReferrer(referrer, host string) bool {
+	if referrer == "" || referrer == "-" || host == "" || host == "default" {
+		return false
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	refHost := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	ownHost := strings.ToLower(host)
+	if h, _, found := strings.Cut(ownHost, ":"); found {
+		ownHost = h
+	}
+	return refHost == strings.TrimPrefix(ownHost, "www.")
+}
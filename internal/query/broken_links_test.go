@@ -0,0 +1,66 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+)
+
+func TestGetBrokenLinks(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	now := time.Now()
+	for _, seed := range []struct {
+		path, host, referrer string
+		status, count        int
+		ts                   time.Time
+	}{
+		{"/old-post", "example.com", "https://twitter.com/", 404, 300, now},
+		{"/old-post", "example.com", "https://www.example.com/archive", 404, 4, now},
+		{"/old-post", "example.com", "http://EXAMPLE.com:8080/", 404, 2, now},
+		{"/api", "example.com", "-", 500, 3, now},
+		{"/old-post", "other.com", "https://example.com/", 404, 9, now},
+		{"/older", "example.com", "https://example.com/", 404, 1000, now.AddDate(0, 0, -30)},
+	} {
+		_, err := db.ExecContext(t.Context(), `
+			INSERT INTO hourly_broken_links (bucket, path, host, referrer, status_code, count)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			bucket.Hour(seed.ts), seed.path, seed.host, seed.referrer, seed.status, seed.count)
+		if err != nil {
+			t.Fatalf("insert broken link: %v", err)
+		}
+	}
+
+	got, err := query.GetBrokenLinks(t.Context(), db, now.AddDate(0, 0, -7), "example.com", 10)
+	if err != nil {
+		t.Fatalf("GetBrokenLinks: %v", err)
+	}
+	want := []query.BrokenLink{
+		{Path: "/old-post", Host: "example.com", Referrer: "https://twitter.com/", StatusCode: 404, Count: 300},
+		{Path: "/old-post", Host: "example.com", Referrer: "https://www.example.com/archive", StatusCode: 404, Count: 4, Internal: true},
+		{Path: "/api", Host: "example.com", Referrer: "-", StatusCode: 500, Count: 3},
+		{Path: "/old-post", Host: "example.com", Referrer: "http://EXAMPLE.com:8080/", StatusCode: 404, Count: 2, Internal: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetBrokenLinks() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// other.com's 404 was linked from example.com: external from other.com's
+	// point of view.
+	all, err := query.GetBrokenLinksRange(t.Context(), db, now.AddDate(0, 0, -7), now, "other.com", 10)
+	if err != nil {
+		t.Fatalf("GetBrokenLinksRange: %v", err)
+	}
+	if len(all) != 1 || all[0].Internal {
+		t.Errorf("expected other.com's single external broken link, got %+v", all)
+	}
+}
@@ -0,0 +1,86 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FunnelStep is one step of a funnel over a window: how many unique visitors
+// reached it (having reached every earlier step the same day), and how many
+// of the previous step's visitors it lost.
+type FunnelStep struct {
+	PathPattern string `json:"path_pattern"`
+	Step        int    `json:"step"`
+	Visitors    int    `json:"visitors"`
+	DropOff     int    `json:"drop_off"`
+	// FromPrevious is Visitors as a fraction of the previous step's
+	// visitors (1 for the first step), and FromStart as a fraction of the
+	// first step's. Both are 0 when the step they're relative to had none.
+	FromPrevious float64 `json:"from_previous"`
+	FromStart    float64 `json:"from_start"`
+}
+
+// GetFunnelSteps returns every step of the funnel called name with its
+// visitor counts summed over [from, to], optionally filtered by host. A
+// funnel with no steps defined yields an empty slice.
+func GetFunnelSteps(ctx context.Context, db *sql.DB, name string, from, to time.Time, host string) ([]FunnelStep, error) {
+	args := dayRangeArgs(from, to)
+
+	q := `
+	SELECT s.step, s.path_pattern, COALESCE(SUM(d.visitors), 0)
+	FROM funnel_steps s
+	LEFT JOIN daily_funnel_steps d ON d.funnel = s.funnel AND d.step = s.step
+	  AND d.day BETWEEN ? AND ?`
+	if host != "" {
+		q += " AND d.host = ?"
+		args = append(args, host)
+	}
+	q += `
+	WHERE s.funnel = ?
+	GROUP BY s.step, s.path_pattern
+	ORDER BY s.step`
+	args = append(args, name)
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying funnel steps: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	results := []FunnelStep{}
+	for rows.Next() {
+		var s FunnelStep
+		if err := rows.Scan(&s.Step, &s.PathPattern, &s.Visitors); err != nil {
+			return nil, fmt.Errorf("scanning funnel step: %w", err)
+		}
+		results = append(results, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return withDropOff(results), nil
+}
+
+func withDropOff(steps []FunnelStep) []FunnelStep {
+	for i := range steps {
+		if i == 0 {
+			if steps[i].Visitors > 0 {
+				steps[i].FromPrevious = 1
+				steps[i].FromStart = 1
+			}
+			continue
+		}
+		prev, start := steps[i-1].Visitors, steps[0].Visitors
+		steps[i].DropOff = prev - steps[i].Visitors
+		if prev > 0 {
+			steps[i].FromPrevious = float64(steps[i].Visitors) / float64(prev)
+		}
+		if start > 0 {
+			steps[i].FromStart = float64(steps[i].Visitors) / float64(start)
+		}
+	}
+	return steps
+}
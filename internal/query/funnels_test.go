@@ -0,0 +1,76 @@
+package query_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/funnels"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+)
+
+func seedFunnelStep(t *testing.T, db *sql.DB, funnel, host string, ts time.Time, step, visitors int) {
+	t.Helper()
+	_, err := db.ExecContext(t.Context(), `
+		INSERT INTO daily_funnel_steps (day, host, funnel, step, visitors)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(day, host, funnel, step) DO UPDATE SET visitors = visitors + ?`,
+		bucket.Day(ts), host, funnel, step, visitors, visitors,
+	)
+	if err != nil {
+		t.Fatalf("insert funnel step: %v", err)
+	}
+}
+
+func TestGetFunnelSteps(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	ctx := t.Context()
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	old := now.AddDate(0, 0, -30)
+
+	if err := funnels.Add(ctx, db, funnels.Funnel{Name: "checkout", Steps: []string{"/pricing", "/signup", "/welcome"}}); err != nil {
+		t.Fatalf("add funnel: %v", err)
+	}
+	seedFunnelStep(t, db, "checkout", "example.com", now, 1, 60)
+	seedFunnelStep(t, db, "checkout", "example.com", yesterday, 1, 40)
+	seedFunnelStep(t, db, "checkout", "example.com", now, 2, 25)
+	seedFunnelStep(t, db, "checkout", "other.com", now, 2, 1000)
+	seedFunnelStep(t, db, "checkout", "example.com", old, 1, 999)
+
+	got, err := query.GetFunnelSteps(ctx, db, "checkout", now.AddDate(0, 0, -7), now, "example.com")
+	if err != nil {
+		t.Fatalf("GetFunnelSteps: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected all 3 steps (including one with no visitors), got %+v", got)
+	}
+
+	want := []query.FunnelStep{
+		{Step: 1, PathPattern: "/pricing", Visitors: 100, DropOff: 0, FromPrevious: 1, FromStart: 1},
+		{Step: 2, PathPattern: "/signup", Visitors: 25, DropOff: 75, FromPrevious: 0.25, FromStart: 0.25},
+		{Step: 3, PathPattern: "/welcome", Visitors: 0, DropOff: 25, FromPrevious: 0, FromStart: 0},
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestGetFunnelSteps_UnknownFunnel(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	got, err := query.GetFunnelSteps(t.Context(), db, "nope", time.Now().AddDate(0, 0, -7), time.Now(), "")
+	if err != nil {
+		t.Fatalf("GetFunnelSteps: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Errorf("expected an empty, non-nil slice, got %#v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GoalStat is one goal's completions and converting visitors over a window.
+// ConversionRate is UniqueVisitors divided by the window's total unique
+// visitors (for the same host filter), or 0 when there were none.
+type GoalStat struct {
+	Goal           string  `json:"goal"`
+	Completions    int     `json:"completions"`
+	UniqueVisitors int     `json:"unique_visitors"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// GetGoalStats returns every defined goal's totals since the given time,
+// including goals with no completions yet.
+func GetGoalStats(ctx context.Context, db *sql.DB, since time.Time, host string) ([]GoalStat, error) {
+	q := `
+	SELECT g.name, COALESCE(SUM(h.completions), 0), COALESCE(SUM(h.unique_visitors), 0)
+	FROM goals g
+	LEFT JOIN hourly_goals h ON h.goal = g.name
+	  AND h.bucket >= ?`
+
+	args := sinceHourArgs(since)
+	if host != "" {
+		q += " AND h.host = ?"
+		args = append(args, host)
+	}
+	q += " GROUP BY g.name ORDER BY g.name"
+
+	stats, err := scanGoalStats(ctx, db, q, args)
+	if err != nil {
+		return nil, err
+	}
+
+	visitors, err := getGoalVisitors(ctx, db, sinceDayClause, sinceDayArgs(since), host)
+	if err != nil {
+		return nil, err
+	}
+	return withConversionRates(stats, visitors), nil
+}
+
+// GetGoalStatsRange is GetGoalStats over an explicit [from, to] range
+// instead of an open-ended "since now" window.
+func GetGoalStatsRange(ctx context.Context, db *sql.DB, from, to time.Time, host string) ([]GoalStat, error) {
+	args := hourRangeArgs(from, to)
+
+	q := `
+	SELECT g.name, COALESCE(SUM(h.completions), 0), COALESCE(SUM(h.unique_visitors), 0)
+	FROM goals g
+	LEFT JOIN hourly_goals h ON h.goal = g.name
+	  AND h.bucket BETWEEN ? AND ?`
+	if host != "" {
+		q += " AND h.host = ?"
+		args = append(args, host)
+	}
+	q += " GROUP BY g.name ORDER BY g.name"
+
+	stats, err := scanGoalStats(ctx, db, q, args)
+	if err != nil {
+		return nil, err
+	}
+
+	visitors, err := getGoalVisitors(ctx, db, dayRangeClause, dayRangeArgs(from, to), host)
+	if err != nil {
+		return nil, err
+	}
+	return withConversionRates(stats, visitors), nil
+}
+
+func scanGoalStats(ctx context.Context, db *sql.DB, q string, args []any) ([]GoalStat, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying goal stats: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	results := []GoalStat{}
+	for rows.Next() {
+		var g GoalStat
+		if err := rows.Scan(&g.Goal, &g.Completions, &g.UniqueVisitors); err != nil {
+			return nil, fmt.Errorf("scanning goal stat: %w", err)
+		}
+		results = append(results, g)
+	}
+	return results, rows.Err()
+}
+
+// getGoalVisitors counts the unique visitors conversion rates divide by.
+// Goal completions aren't rolled up, so neither are these: only visitor_days,
+// kept as long as hourly_goals, is counted.
+func getGoalVisitors(ctx context.Context, db *sql.DB, clause string, args []any, host string) (int, error) {
+	q := `
+	SELECT COUNT(DISTINCT hash)
+	FROM visitor_days
+	WHERE `
+	q += clause
+	if host != "" {
+		q += hostFilterClause
+		args = append(args, host)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, q, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("querying unique visitors: %w", err)
+	}
+	return count, nil
+}
+
+func withConversionRates(stats []GoalStat, totalVisitors int) []GoalStat {
+	if totalVisitors == 0 {
+		return stats
+	}
+	for i := range stats {
+		stats[i].ConversionRate = float64(stats[i].UniqueVisitors) / float64(totalVisitors)
+	}
+	return stats
+}
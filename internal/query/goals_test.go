@@ -0,0 +1,93 @@
+package query_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/goals"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+)
+
+func seedGoal(t *testing.T, db *sql.DB, goal, host string, ts time.Time, completions, uniqueVisitors int) {
+	t.Helper()
+	_, err := db.ExecContext(t.Context(), `
+		INSERT INTO hourly_goals (bucket, host, goal, completions, unique_visitors)
+		VALUES (?, ?, ?, ?, ?)`,
+		bucket.Hour(ts), host, goal, completions, uniqueVisitors,
+	)
+	if err != nil {
+		t.Fatalf("insert hourly goal: %v", err)
+	}
+}
+
+func TestGetGoalStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	ctx := t.Context()
+	now := time.Now()
+	old := now.AddDate(0, 0, -10)
+
+	for _, g := range []goals.Goal{
+		{Name: "signup", Method: "POST", PathPattern: "/register", StatusCode: 302},
+		{Name: "unused", PathPattern: "/never"},
+	} {
+		if err := goals.Add(ctx, db, g); err != nil {
+			t.Fatalf("add goal: %v", err)
+		}
+	}
+
+	// 10 unique visitors in range, 2 of whom converted (3 completions).
+	insertHourlyStat(t, db, "/", "example.com", now, statSeed{PageViews: 20, UniqueVisitors: 10})
+	seedGoal(t, db, "signup", "example.com", now, 3, 2)
+	seedGoal(t, db, "signup", "example.com", old, 50, 40)
+
+	got, err := query.GetGoalStats(ctx, db, now.AddDate(0, 0, -7), "")
+	if err != nil {
+		t.Fatalf("GetGoalStats: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected both defined goals, got %+v", got)
+	}
+
+	signup, unused := got[0], got[1]
+	if signup.Goal != "signup" || signup.Completions != 3 || signup.UniqueVisitors != 2 {
+		t.Errorf("signup = %+v, want 3 completions / 2 converters (old data excluded)", signup)
+	}
+	if signup.ConversionRate != 0.2 {
+		t.Errorf("signup conversion rate = %v, want 0.2", signup.ConversionRate)
+	}
+	if unused.Goal != "unused" || unused.Completions != 0 || unused.ConversionRate != 0 {
+		t.Errorf("unused = %+v, want zero totals", unused)
+	}
+}
+
+func TestGetGoalStatsRange_HostFilter(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	ctx := t.Context()
+	now := time.Now()
+
+	if err := goals.Add(ctx, db, goals.Goal{Name: "signup", PathPattern: "/register"}); err != nil {
+		t.Fatalf("add goal: %v", err)
+	}
+	insertHourlyStat(t, db, "/", "example.com", now, statSeed{PageViews: 4, UniqueVisitors: 4})
+	insertHourlyStat(t, db, "/", "other.com", now, statSeed{PageViews: 4, UniqueVisitors: 4})
+	seedGoal(t, db, "signup", "example.com", now, 1, 1)
+	seedGoal(t, db, "signup", "other.com", now, 7, 3)
+
+	got, err := query.GetGoalStatsRange(ctx, db, now.AddDate(0, 0, -1), now, "other.com")
+	if err != nil {
+		t.Fatalf("GetGoalStatsRange: %v", err)
+	}
+	if len(got) != 1 || got[0].Completions != 7 || got[0].UniqueVisitors != 3 {
+		t.Fatalf("expected other.com's 7 completions / 3 converters only, got %+v", got)
+	}
+	if got[0].ConversionRate != 0.75 {
+		t.Errorf("conversion rate = %v, want 0.75", got[0].ConversionRate)
+	}
+}
@@ -0,0 +1,79 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ParseFailureStat is how many log lines were rejected for one reason.
+type ParseFailureStat struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+// ParseHealth summarizes how much of the access log the daemon could read
+// over a window. Lines counts every line seen — parsed (human and bot page
+// views, and scanner probes) plus rejected — and FailureRate is Failures divided by Lines, or 0
+// when there were none. Parse failures carry no host, so there's no host
+// filter.
+type ParseHealth struct {
+	Reasons     []ParseFailureStat `json:"reasons"`
+	Failures    int                `json:"failures"`
+	Lines       int                `json:"lines"`
+	FailureRate float64            `json:"failure_rate"`
+}
+
+// GetParseHealth returns parse failure totals since the given time.
+func GetParseHealth(ctx context.Context, db *sql.DB, since time.Time) (ParseHealth, error) {
+	return getParseHealth(ctx, db, sinceHourClause, sinceHourArgs(since))
+}
+
+// GetParseHealthRange is GetParseHealth over an explicit [from, to] range
+// instead of an open-ended "since now" window.
+func GetParseHealthRange(ctx context.Context, db *sql.DB, from, to time.Time) (ParseHealth, error) {
+	return getParseHealth(ctx, db, hourRangeClause, hourRangeArgs(from, to))
+}
+
+func getParseHealth(ctx context.Context, db *sql.DB, where string, args []any) (ParseHealth, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT reason, SUM(count) AS total
+	FROM hourly_parse_failures
+	WHERE `+where+`
+	GROUP BY reason
+	ORDER BY total DESC, reason`, args...) //nolint:gosec // where is one of two fixed literals, not user input
+	if err != nil {
+		return ParseHealth{}, fmt.Errorf("querying parse failures: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	health := ParseHealth{Reasons: []ParseFailureStat{}}
+	for rows.Next() {
+		var s ParseFailureStat
+		if err := rows.Scan(&s.Reason, &s.Count); err != nil {
+			return ParseHealth{}, fmt.Errorf("scanning parse failure: %w", err)
+		}
+		health.Reasons = append(health.Reasons, s)
+		health.Failures += s.Count
+	}
+	if err := rows.Err(); err != nil {
+		return ParseHealth{}, fmt.Errorf("iterating parse failures: %w", err)
+	}
+
+	var parsed int
+	err = db.QueryRowContext(ctx, `
+	SELECT
+		(SELECT COALESCE(SUM(page_views + bot_views), 0) FROM hourly_stats WHERE `+where+`) +
+		(SELECT COALESCE(SUM(count), 0) FROM hourly_scans WHERE `+where+`)`,
+		append(append([]any{}, args...), args...)...).Scan(&parsed) //nolint:gosec // where is one of two fixed literals, not user input
+	if err != nil {
+		return ParseHealth{}, fmt.Errorf("querying parsed lines: %w", err)
+	}
+
+	health.Lines = parsed + health.Failures
+	if health.Lines > 0 {
+		health.FailureRate = float64(health.Failures) / float64(health.Lines)
+	}
+	return health, nil
+}
@@ -0,0 +1,68 @@
+package query_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+)
+
+func insertParseFailures(t *testing.T, db *sql.DB, reason string, ts time.Time, count int) {
+	t.Helper()
+	_, err := db.ExecContext(t.Context(), `
+		INSERT INTO hourly_parse_failures (bucket, reason, count)
+		VALUES (?, ?, ?)
+		ON CONFLICT(bucket, reason) DO UPDATE SET count = count + ?`,
+		bucket.Hour(ts), reason, count, count,
+	)
+	if err != nil {
+		t.Fatalf("insert parse failures: %v", err)
+	}
+}
+
+func TestGetParseHealth(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	now := time.Now()
+	insertHourlyStat(t, db, "/", "example.com", now, statSeed{PageViews: 70, BotViews: 10})
+	insertParseFailures(t, db, "no_match", now, 15)
+	insertParseFailures(t, db, "bad_timestamp", now, 5)
+	insertParseFailures(t, db, "no_match", now.AddDate(0, 0, -30), 1000)
+
+	got, err := query.GetParseHealth(t.Context(), db, now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("GetParseHealth: %v", err)
+	}
+	if got.Failures != 20 || got.Lines != 100 || got.FailureRate != 0.2 {
+		t.Errorf("got %d failures of %d lines (rate %v), want 20 of 100 (0.2)", got.Failures, got.Lines, got.FailureRate)
+	}
+	want := []query.ParseFailureStat{{Reason: "no_match", Count: 15}, {Reason: "bad_timestamp", Count: 5}}
+	if len(got.Reasons) != len(want) || got.Reasons[0] != want[0] || got.Reasons[1] != want[1] {
+		t.Errorf("Reasons = %+v, want %+v", got.Reasons, want)
+	}
+
+	ranged, err := query.GetParseHealthRange(t.Context(), db, now.AddDate(0, 0, -31), now.AddDate(0, 0, -29))
+	if err != nil {
+		t.Fatalf("GetParseHealthRange: %v", err)
+	}
+	if ranged.Failures != 1000 || ranged.FailureRate != 1 {
+		t.Errorf("range: got %+v, want only the 1000 old failures", ranged)
+	}
+}
+
+func TestGetParseHealth_Empty(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	got, err := query.GetParseHealth(t.Context(), db, time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("GetParseHealth: %v", err)
+	}
+	if got.Lines != 0 || got.FailureRate != 0 || got.Reasons == nil {
+		t.Errorf("expected zero totals and an empty, non-nil Reasons, got %#v", got)
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
 )
 
 type Summary struct {
@@ -29,11 +34,12 @@ type ReferrerStat struct {
 	Count    int
 }
 
-// SeriesPoint is one bucket of a time series returned by GetSeries — either
-// a calendar day or an hour within a day, depending on the requested
-// group_by. UniqueVisitors is only meaningful for day buckets: the schema
-// tracks distinct visitors per (host, day), not per hour, so hour buckets
-// always report it as 0.
+// SeriesPoint is one bucket of a time series returned by GetSeries — an
+// hour, a calendar day or a calendar month, depending on the requested
+// group_by and on the resolution still kept for the period. Date says which:
+// "2006-01-02T15:00:00", "2006-01-02" or "2006-01". UniqueVisitors is only
+// meaningful for day and month buckets: the schema tracks distinct visitors
+// per (host, day), not per hour, so hour buckets always report it as 0.
 type SeriesPoint struct {
 	Date           string `json:"date"`
 	PageViews      int    `json:"page_views"`
@@ -41,41 +47,74 @@ type SeriesPoint struct {
 	BotViews       int    `json:"bot_views"`
 }
 
-func sinceFilter(since time.Time) (year, yearDay int) {
-	return since.Year(), since.YearDay()
+// The hourly_* tables are keyed by bucket, the UTC hour since the Unix
+// epoch, and the daily ones (visitor_days and friends) by day, the UTC day
+// since the epoch (see internal/bucket), so every window below is a plain
+// integer range the (host, bucket) and (host, day) indexes can answer. The
+// clauses are fixed literals, with the bind values from the *Args helpers,
+// so gosec's SQL-concatenation check can see there's no injectable input.
+const (
+	sinceHourClause = "bucket >= ?"
+	hourRangeClause = "bucket BETWEEN ? AND ?"
+	sinceDayClause  = "day >= ?"
+	dayRangeClause  = "day BETWEEN ? AND ?"
+)
+
+// startOfDay is midnight of t's calendar day in t's location.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// firstHourFrom is the first hour bucket that starts at or after t. Where a
+// timezone's offset isn't whole hours, that leaves the bucket straddling
+// midnight to the day it starts in, the same rule GetSeries buckets by.
+func firstHourFrom(t time.Time) int64 {
+	return bucket.Hour(t.Add(time.Hour - time.Nanosecond))
 }
 
-// dateRangeClause is the WHERE fragment for an inclusive [from, to] date
-// range expressed in the (year, year_day) terms every hourly_* table is
-// keyed on. It's a fixed literal (see rangeArgs for the bind values) rather
-// than built at runtime, so gosec's SQL-concatenation check can see there's
-// no injectable input in the query string.
-const dateRangeClause = "((year > ? OR (year = ? AND year_day >= ?)) AND (year < ? OR (year = ? AND year_day <= ?)))"
+// sinceHourArgs returns the bind arg for sinceHourClause: every bucket from
+// the start of since's calendar day, in since's location.
+func sinceHourArgs(since time.Time) []any {
+	return []any{firstHourFrom(startOfDay(since))}
+}
+
+// hourRangeArgs returns the bind args for hourRangeClause over the calendar
+// days from and to fall on, each read in its own location: from's midnight
+// up to, but not including, the midnight after to.
+func hourRangeArgs(from, to time.Time) []any {
+	end := startOfDay(to).AddDate(0, 0, 1)
+	return []any{firstHourFrom(startOfDay(from)), bucket.Hour(end.Add(-time.Nanosecond))}
+}
+
+// sinceDayArgs and dayRangeArgs are the daily tables' counterparts. Those
+// only know UTC days, so a day in another timezone is matched by date, not
+// by instant.
+func sinceDayArgs(since time.Time) []any {
+	return []any{bucket.CalendarDay(since)}
+}
+
+func dayRangeArgs(from, to time.Time) []any {
+	return []any{bucket.CalendarDay(from), bucket.CalendarDay(to)}
+}
 
 // hostFilterClause is the WHERE fragment appended to every query below when
 // a host filter is requested.
 const hostFilterClause = " AND host = ?"
 
-// rangeArgs returns the bind args for dateRangeClause over [from, to].
-func rangeArgs(from, to time.Time) []any {
-	fromYear, fromDay := from.Year(), from.YearDay()
-	toYear, toDay := to.Year(), to.YearDay()
-	return []any{fromYear, fromYear, fromDay, toYear, toYear, toDay}
-}
-
 func GetSummary(ctx context.Context, db *sql.DB, since time.Time, host string) (Summary, error) {
-	year, yearDay := sinceFilter(since)
+	return getSummary(ctx, db, sinceWindow(since), host)
+}
 
+func getSummary(ctx context.Context, db *sql.DB, w window, host string) (Summary, error) {
 	q := `
 	SELECT
 		COALESCE(SUM(page_views), 0),
 		COALESCE(SUM(bot_views), 0)
-	FROM hourly_stats
-	WHERE (year > ? OR (year = ? AND year_day >= ?))`
+	FROM ` + statsTiers
 
-	args := []any{year, year, yearDay}
+	args := tierArgs(w)
 	if host != "" {
-		q += hostFilterClause
+		q += hostWhereClause
 		args = append(args, host)
 	}
 
@@ -84,7 +123,7 @@ func GetSummary(ctx context.Context, db *sql.DB, since time.Time, host string) (
 		return Summary{}, fmt.Errorf("querying summary: %w", err)
 	}
 
-	uniqueVisitors, err := getUniqueVisitors(ctx, db, year, yearDay, host)
+	uniqueVisitors, err := getUniqueVisitors(ctx, db, w, host)
 	if err != nil {
 		return Summary{}, err
 	}
@@ -93,15 +132,14 @@ func GetSummary(ctx context.Context, db *sql.DB, since time.Time, host string) (
 	return s, nil
 }
 
-func getUniqueVisitors(ctx context.Context, db *sql.DB, year, yearDay int, host string) (int, error) {
+func getUniqueVisitors(ctx context.Context, db *sql.DB, w window, host string) (int, error) {
 	q := `
-	SELECT COUNT(DISTINCT hash)
-	FROM visitor_days
-	WHERE (year > ? OR (year = ? AND year_day >= ?))`
+	SELECT ` + visitorsExpr + `
+	FROM ` + visitorsTiers
 
-	args := []any{year, year, yearDay}
+	args := visitorTierArgs(w)
 	if host != "" {
-		q += hostFilterClause
+		q += hostWhereClause
 		args = append(args, host)
 	}
 
@@ -113,15 +151,22 @@ func getUniqueVisitors(ctx context.Context, db *sql.DB, year, yearDay int, host
 }
 
 func GetTopPaths(ctx context.Context, db *sql.DB, since time.Time, host string, limit int) ([]PathStat, error) {
-	year, yearDay := sinceFilter(since)
+	return getTopPaths(ctx, db, sinceWindow(since), host, limit)
+}
 
+// GetTopPathsRange is GetTopPaths over an explicit [from, to] range instead
+// of an open-ended "since now" window.
+func GetTopPathsRange(ctx context.Context, db *sql.DB, from, to time.Time, host string, limit int) ([]PathStat, error) {
+	return getTopPaths(ctx, db, rangeWindow(from, to), host, limit)
+}
+
+func getTopPaths(ctx context.Context, db *sql.DB, w window, host string, limit int) ([]PathStat, error) {
 	q := `
 	SELECT path, host, SUM(page_views) as total_pv
-	FROM hourly_stats
-	WHERE (year > ? OR (year = ? AND year_day >= ?))
-	  AND is_static = 0`
+	FROM ` + statsTiers + `
+	WHERE is_static = 0`
 
-	args := []any{year, year, yearDay}
+	args := tierArgs(w)
 	if host != "" {
 		q += hostFilterClause
 		args = append(args, host)
@@ -147,16 +192,23 @@ func GetTopPaths(ctx context.Context, db *sql.DB, since time.Time, host string,
 }
 
 func GetStatusCodes(ctx context.Context, db *sql.DB, since time.Time, host string) ([]StatusStat, error) {
-	year, yearDay := sinceFilter(since)
+	return getStatusCodes(ctx, db, sinceWindow(since), host)
+}
+
+// GetStatusCodesRange is GetStatusCodes over an explicit [from, to] range
+// instead of an open-ended "since now" window.
+func GetStatusCodesRange(ctx context.Context, db *sql.DB, from, to time.Time, host string) ([]StatusStat, error) {
+	return getStatusCodes(ctx, db, rangeWindow(from, to), host)
+}
 
+func getStatusCodes(ctx context.Context, db *sql.DB, w window, host string) ([]StatusStat, error) {
 	q := `
 	SELECT status_code, SUM(count) as total
-	FROM hourly_status_codes
-	WHERE (year > ? OR (year = ? AND year_day >= ?))`
+	FROM ` + statusCodesTiers
 
-	args := []any{year, year, yearDay}
+	args := tierArgs(w)
 	if host != "" {
-		q += hostFilterClause
+		q += hostWhereClause
 		args = append(args, host)
 	}
 	q += " GROUP BY status_code ORDER BY total DESC"
@@ -179,15 +231,22 @@ func GetStatusCodes(ctx context.Context, db *sql.DB, since time.Time, host strin
 }
 
 func GetTopReferrers(ctx context.Context, db *sql.DB, since time.Time, host string, limit int) ([]ReferrerStat, error) {
-	year, yearDay := sinceFilter(since)
+	return getTopReferrers(ctx, db, sinceWindow(since), host, limit)
+}
 
+// GetTopReferrersRange is GetTopReferrers over an explicit [from, to] range
+// instead of an open-ended "since now" window.
+func GetTopReferrersRange(ctx context.Context, db *sql.DB, from, to time.Time, host string, limit int) ([]ReferrerStat, error) {
+	return getTopReferrers(ctx, db, rangeWindow(from, to), host, limit)
+}
+
+func getTopReferrers(ctx context.Context, db *sql.DB, w window, host string, limit int) ([]ReferrerStat, error) {
 	q := `
 	SELECT referrer, SUM(count) as total
-	FROM hourly_referrers
-	WHERE (year > ? OR (year = ? AND year_day >= ?))
-	  AND referrer != '-'`
+	FROM ` + referrersTiers + `
+	WHERE referrer != '-'`
 
-	args := []any{year, year, yearDay}
+	args := tierArgs(w)
 	if host != "" {
 		q += hostFilterClause
 		args = append(args, host)
@@ -213,247 +272,144 @@ func GetTopReferrers(ctx context.Context, db *sql.DB, since time.Time, host stri
 }
 
 // GetSeries returns page view/bot view/unique visitor totals bucketed by
-// calendar day or by hour over [from, to], optionally filtered by host.
-// groupBy must be "day" or "hour".
+// hour, calendar day or calendar month over [from, to], optionally filtered
+// by host. groupBy must be "hour", "day" or "month".
+//
+// Buckets are stored in UTC but reported in from's location, the display
+// timezone: days run from local midnight to midnight and hours are labeled
+// with the local wall clock, so a team in Europe sees the daily totals they
+// expect from a server logging in UTC. Unique visitors are counted per UTC
+// day, so a local day reports the visitors of the UTC day with its date.
+//
+// Periods only kept at a coarser resolution than groupBy, once rolled up
+// into the daily or monthly tables, are reported at that resolution, so a
+// two year range grouped by day ends in monthly points for its oldest part.
 func GetSeries(ctx context.Context, db *sql.DB, from, to time.Time, host, groupBy string) ([]SeriesPoint, error) {
 	switch groupBy {
-	case "hour":
-		return getSeriesByHour(ctx, db, from, to, host)
-	case "day", "":
-		return getSeriesByDay(ctx, db, from, to, host)
+	case "":
+		groupBy = resolutionDay
+	case resolutionHour, resolutionDay, resolutionMonth:
 	default:
-		return nil, fmt.Errorf("invalid group_by %q: must be \"day\" or \"hour\"", groupBy)
+		return nil, fmt.Errorf("invalid group_by %q: must be \"hour\", \"day\" or \"month\"", groupBy)
 	}
-}
 
-func getSeriesByDay(ctx context.Context, db *sql.DB, from, to time.Time, host string) ([]SeriesPoint, error) {
-	args := rangeArgs(from, to)
-
-	q := `
-	SELECT year, year_day, COALESCE(SUM(page_views), 0), COALESCE(SUM(bot_views), 0)
-	FROM hourly_stats
-	WHERE `
-	q += dateRangeClause
-	if host != "" {
-		q += hostFilterClause
-		args = append(args, host)
-	}
-	q += " GROUP BY year, year_day ORDER BY year, year_day"
-
-	rows, err := db.QueryContext(ctx, q, args...)
+	w := rangeWindow(from, to)
+	buckets, err := getTierTotals(ctx, db, w, host)
 	if err != nil {
-		return nil, fmt.Errorf("querying daily series: %w", err)
-	}
-	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
-
-	type dayTotals struct {
-		year, yearDay, pageViews, botViews int
-	}
-	days := []dayTotals{}
-	for rows.Next() {
-		var d dayTotals
-		if scanErr := rows.Scan(&d.year, &d.yearDay, &d.pageViews, &d.botViews); scanErr != nil {
-			return nil, fmt.Errorf("scanning daily series: %w", scanErr)
-		}
-		days = append(days, d)
-	}
-	if rowsErr := rows.Err(); rowsErr != nil {
-		return nil, rowsErr
+		return nil, err
 	}
+	points := groupTierTotals(buckets, groupBy, from.Location())
 
-	visitors, err := getUniqueVisitorsByDay(ctx, db, from, to, host)
+	visitors, err := getUniqueVisitorsByBucket(ctx, db, w, host)
 	if err != nil {
 		return nil, err
 	}
-
-	results := make([]SeriesPoint, 0, len(days))
-	for _, d := range days {
-		results = append(results, SeriesPoint{
-			Date:           yearDayToDate(d.year, d.yearDay),
-			PageViews:      d.pageViews,
-			UniqueVisitors: visitors[dayKey{d.year, d.yearDay}],
-			BotViews:       d.botViews,
-		})
-	}
-	return results, nil
-}
-
-type dayKey struct {
-	year, yearDay int
-}
-
-func getUniqueVisitorsByDay(ctx context.Context, db *sql.DB, from, to time.Time, host string) (map[dayKey]int, error) {
-	args := rangeArgs(from, to)
-
-	q := `
-	SELECT year, year_day, COUNT(DISTINCT hash)
-	FROM visitor_days
-	WHERE `
-	q += dateRangeClause
-	if host != "" {
-		q += hostFilterClause
-		args = append(args, host)
+	byLabel := map[string]int{}
+	for _, v := range visitors {
+		byLabel[tierLabel(v.resolution, v.at, groupBy, from.Location())] += v.visitors
 	}
-	q += " GROUP BY year, year_day"
-
-	rows, err := db.QueryContext(ctx, q, args...)
-	if err != nil {
-		return nil, fmt.Errorf("querying unique visitors by day: %w", err)
-	}
-	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
-
-	visitors := map[dayKey]int{}
-	for rows.Next() {
-		var k dayKey
-		var count int
-		if err := rows.Scan(&k.year, &k.yearDay, &count); err != nil {
-			return nil, fmt.Errorf("scanning unique visitors by day: %w", err)
+	for i := range points {
+		if !isHourLabel(points[i].Date) {
+			points[i].UniqueVisitors = byLabel[points[i].Date]
 		}
-		visitors[k] = count
 	}
-	return visitors, rows.Err()
+	return points, nil
 }
 
-func getSeriesByHour(ctx context.Context, db *sql.DB, from, to time.Time, host string) ([]SeriesPoint, error) {
-	args := rangeArgs(from, to)
-
-	q := `
-	SELECT year, year_day, hour, COALESCE(SUM(page_views), 0), COALESCE(SUM(bot_views), 0)
-	FROM hourly_stats
-	WHERE `
-	q += dateRangeClause
-	if host != "" {
-		q += hostFilterClause
-		args = append(args, host)
-	}
-	q += " GROUP BY year, year_day, hour ORDER BY year, year_day, hour"
-
-	rows, err := db.QueryContext(ctx, q, args...)
-	if err != nil {
-		return nil, fmt.Errorf("querying hourly series: %w", err)
-	}
-	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
-
-	results := []SeriesPoint{}
-	for rows.Next() {
-		var year, yearDay, hour, pageViews, botViews int
-		if err := rows.Scan(&year, &yearDay, &hour, &pageViews, &botViews); err != nil {
-			return nil, fmt.Errorf("scanning hourly series: %w", err)
-		}
-		results = append(results, SeriesPoint{
-			Date:      fmt.Sprintf("%sT%02d:00:00", yearDayToDate(year, yearDay), hour),
-			PageViews: pageViews,
-			BotViews:  botViews,
-		})
-	}
-	return results, rows.Err()
+// isHourLabel reports whether a series label names an hour, which unique
+// visitors, counted per day, can't be split into.
+func isHourLabel(label string) bool {
+	return strings.Contains(label, "T")
 }
 
-func yearDayToDate(year, yearDay int) string {
-	return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, yearDay-1).Format("2006-01-02")
+// tierTotals is one bucket of statsTiers, summed over paths.
+type tierTotals struct {
+	resolution          string
+	at                  int64
+	pageViews, botViews int
+	visitors            int
 }
 
-// GetTopPathsRange is GetTopPaths over an explicit [from, to] range instead
-// of an open-ended "since now" window.
-func GetTopPathsRange(ctx context.Context, db *sql.DB, from, to time.Time, host string, limit int) ([]PathStat, error) {
-	args := rangeArgs(from, to)
-
+func getTierTotals(ctx context.Context, db *sql.DB, w window, host string) ([]tierTotals, error) {
 	q := `
-	SELECT path, host, SUM(page_views) as total_pv
-	FROM hourly_stats
-	WHERE `
-	q += dateRangeClause
-	q += `
-	  AND is_static = 0`
+	SELECT resolution, at, COALESCE(SUM(page_views), 0), COALESCE(SUM(bot_views), 0)
+	FROM ` + statsTiers
+
+	args := tierArgs(w)
 	if host != "" {
-		q += hostFilterClause
+		q += hostWhereClause
 		args = append(args, host)
 	}
-	q += " GROUP BY path, host ORDER BY total_pv DESC LIMIT ?"
-	args = append(args, limit)
+	q += " GROUP BY resolution, at"
 
 	rows, err := db.QueryContext(ctx, q, args...)
 	if err != nil {
-		return nil, fmt.Errorf("querying top paths: %w", err)
+		return nil, fmt.Errorf("querying series: %w", err)
 	}
 	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
 
-	results := []PathStat{}
+	results := []tierTotals{}
 	for rows.Next() {
-		var p PathStat
-		if err := rows.Scan(&p.Path, &p.Host, &p.Pageviews); err != nil {
-			return nil, fmt.Errorf("scanning path stat: %w", err)
+		var b tierTotals
+		if err := rows.Scan(&b.resolution, &b.at, &b.pageViews, &b.botViews); err != nil {
+			return nil, fmt.Errorf("scanning series: %w", err)
 		}
-		results = append(results, p)
+		results = append(results, b)
 	}
 	return results, rows.Err()
 }
 
-// GetStatusCodesRange is GetStatusCodes over an explicit [from, to] range
-// instead of an open-ended "since now" window.
-func GetStatusCodesRange(ctx context.Context, db *sql.DB, from, to time.Time, host string) ([]StatusStat, error) {
-	args := rangeArgs(from, to)
-
-	q := `
-	SELECT status_code, SUM(count) as total
-	FROM hourly_status_codes
-	WHERE `
-	q += dateRangeClause
-	if host != "" {
-		q += hostFilterClause
-		args = append(args, host)
-	}
-	q += " GROUP BY status_code ORDER BY total DESC"
-
-	rows, err := db.QueryContext(ctx, q, args...)
-	if err != nil {
-		return nil, fmt.Errorf("querying status codes: %w", err)
+// groupTierTotals sums buckets into one point per distinct label, in
+// chronological order. Buckets sharing a label (every hour of a day, or the
+// repeated hour when DST ends) merge. Labels of every resolution share a
+// prefix layout, so sorting them as strings sorts them by time.
+func groupTierTotals(buckets []tierTotals, groupBy string, loc *time.Location) []SeriesPoint {
+	byLabel := map[string]*SeriesPoint{}
+	for _, b := range buckets {
+		l := tierLabel(b.resolution, b.at, groupBy, loc)
+		p, ok := byLabel[l]
+		if !ok {
+			p = &SeriesPoint{Date: l}
+			byLabel[l] = p
+		}
+		p.PageViews += b.pageViews
+		p.BotViews += b.botViews
 	}
-	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
 
-	results := []StatusStat{}
-	for rows.Next() {
-		var s StatusStat
-		if err := rows.Scan(&s.StatusCode, &s.Count); err != nil {
-			return nil, fmt.Errorf("scanning status stat: %w", err)
-		}
-		results = append(results, s)
+	points := make([]SeriesPoint, 0, len(byLabel))
+	for _, l := range slices.Sorted(maps.Keys(byLabel)) {
+		points = append(points, *byLabel[l])
 	}
-	return results, rows.Err()
+	return points
 }
 
-// GetTopReferrersRange is GetTopReferrers over an explicit [from, to] range
-// instead of an open-ended "since now" window.
-func GetTopReferrersRange(ctx context.Context, db *sql.DB, from, to time.Time, host string, limit int) ([]ReferrerStat, error) {
-	args := rangeArgs(from, to)
-
+// getUniqueVisitorsByBucket counts unique visitors per bucket of
+// visitorsTiers over w.
+func getUniqueVisitorsByBucket(ctx context.Context, db *sql.DB, w window, host string) ([]tierTotals, error) {
 	q := `
-	SELECT referrer, SUM(count) as total
-	FROM hourly_referrers
-	WHERE `
-	q += dateRangeClause
-	q += `
-	  AND referrer != '-'`
+	SELECT resolution, at, ` + visitorsExpr + `
+	FROM ` + visitorsTiers
+
+	args := visitorTierArgs(w)
 	if host != "" {
-		q += hostFilterClause
+		q += hostWhereClause
 		args = append(args, host)
 	}
-	q += " GROUP BY referrer ORDER BY total DESC LIMIT ?"
-	args = append(args, limit)
+	q += " GROUP BY resolution, at"
 
 	rows, err := db.QueryContext(ctx, q, args...)
 	if err != nil {
-		return nil, fmt.Errorf("querying top referrers: %w", err)
+		return nil, fmt.Errorf("querying unique visitors by day: %w", err)
 	}
 	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
 
-	results := []ReferrerStat{}
+	results := []tierTotals{}
 	for rows.Next() {
-		var r ReferrerStat
-		if err := rows.Scan(&r.Referrer, &r.Count); err != nil {
-			return nil, fmt.Errorf("scanning referrer stat: %w", err)
+		var b tierTotals
+		if err := rows.Scan(&b.resolution, &b.at, &b.visitors); err != nil {
+			return nil, fmt.Errorf("scanning unique visitors by day: %w", err)
 		}
-		results = append(results, r)
+		results = append(results, b)
 	}
 	return results, rows.Err()
 }
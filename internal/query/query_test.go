@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
 	"github.com/Elysium-Labs-EU/theia/internal/query"
 )
 
@@ -50,12 +52,12 @@ func insertHourlyStat(t *testing.T, db *sql.DB, path, host string, ts time.Time,
 		staticInt = 1
 	}
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_stats (hour, year_day, year, path, host, page_views, is_static, bot_views)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host) DO UPDATE SET
+		INSERT INTO hourly_stats (bucket, path, host, page_views, is_static, bot_views)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host) DO UPDATE SET
 			page_views = page_views + ?,
 			bot_views = bot_views + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host,
+		bucket.Hour(ts), path, host,
 		s.PageViews, staticInt, s.BotViews,
 		s.PageViews, s.BotViews,
 	)
@@ -74,10 +76,10 @@ func insertDistinctVisitorDays(t *testing.T, db *sql.DB, path, host string, ts t
 	for i := range count {
 		hash := fmt.Sprintf("%s|%s|%d", host, path, i)
 		_, err := db.ExecContext(t.Context(), `
-			INSERT INTO visitor_days (hash, host, year, year_day, first_seen)
-			VALUES (?, ?, ?, ?, datetime('now'))
-			ON CONFLICT(hash, host, year, year_day) DO NOTHING`,
-			hash, host, ts.Year(), ts.YearDay(),
+			INSERT INTO visitor_days (hash, host, day, first_seen)
+			VALUES (?, ?, ?, datetime('now'))
+			ON CONFLICT(hash, host, day) DO NOTHING`,
+			hash, host, bucket.Day(ts),
 		)
 		if err != nil {
 			t.Fatalf("insert visitor day: %v", err)
@@ -88,10 +90,10 @@ func insertDistinctVisitorDays(t *testing.T, db *sql.DB, path, host string, ts t
 func insertStatusCode(t *testing.T, db *sql.DB, path, host string, ts time.Time, statusCode, count int) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_status_codes (hour, year_day, year, path, host, status_code, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, status_code) DO UPDATE SET count = count + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host, statusCode, count, count,
+		INSERT INTO hourly_status_codes (bucket, path, host, status_code, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, status_code) DO UPDATE SET count = count + ?`,
+		bucket.Hour(ts), path, host, statusCode, count, count,
 	)
 	if err != nil {
 		t.Fatalf("insert status code: %v", err)
@@ -101,10 +103,10 @@ func insertStatusCode(t *testing.T, db *sql.DB, path, host string, ts time.Time,
 func insertReferrer(t *testing.T, db *sql.DB, path, host, referrer string, ts time.Time, count int) {
 	t.Helper()
 	_, err := db.ExecContext(t.Context(), `
-		INSERT INTO hourly_referrers (hour, year_day, year, path, host, referrer, count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(hour, year_day, year, path, host, referrer) DO UPDATE SET count = count + ?`,
-		ts.Hour(), ts.YearDay(), ts.Year(), path, host, referrer, count, count,
+		INSERT INTO hourly_referrers (bucket, path, host, referrer, count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bucket, path, host, referrer) DO UPDATE SET count = count + ?`,
+		bucket.Hour(ts), path, host, referrer, count, count,
 	)
 	if err != nil {
 		t.Fatalf("insert referrer: %v", err)
@@ -421,6 +423,81 @@ func TestGetSeriesByHour(t *testing.T) {
 	}
 }
 
+// Buckets are stored in UTC; from's location decides which local day each
+// falls on. Mar 10 22:30 UTC is still Mar 10 in Amsterdam, 23:30 UTC is
+// already Mar 11.
+func TestGetSeriesByDay_DisplayTimezone(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	for _, seed := range []struct {
+		ts        time.Time
+		pageViews int
+	}{
+		{time.Date(2026, 3, 9, 22, 30, 0, 0, time.UTC), 100},  // Mar 9 23:30 local, before the range
+		{time.Date(2026, 3, 10, 22, 30, 0, 0, time.UTC), 2},   // Mar 10 23:30 local
+		{time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC), 5},   // Mar 11 00:30 local
+		{time.Date(2026, 3, 11, 23, 30, 0, 0, time.UTC), 200}, // Mar 12 00:30 local, after the range
+	} {
+		insertHourlyStat(t, db, "/", "example.com", seed.ts, statSeed{PageViews: seed.pageViews})
+	}
+
+	from := time.Date(2026, 3, 10, 0, 0, 0, 0, amsterdam)
+	to := time.Date(2026, 3, 11, 0, 0, 0, 0, amsterdam)
+	series, err := query.GetSeries(context.Background(), db, from, to, "", "day")
+	if err != nil {
+		t.Fatalf("GetSeries: %v", err)
+	}
+	want := []query.SeriesPoint{{Date: "2026-03-10", PageViews: 2}, {Date: "2026-03-11", PageViews: 5}}
+	if !reflect.DeepEqual(series, want) {
+		t.Errorf("GetSeries in Europe/Amsterdam = %+v, want %+v", series, want)
+	}
+
+	series, err = query.GetSeries(context.Background(), db, from.UTC(), to.UTC(), "", "day")
+	if err != nil {
+		t.Fatalf("GetSeries: %v", err)
+	}
+	// from.UTC() is Mar 9 23:00 UTC, so the UTC range is Mar 9 - Mar 10.
+	want = []query.SeriesPoint{{Date: "2026-03-09", PageViews: 100}, {Date: "2026-03-10", PageViews: 7}}
+	if !reflect.DeepEqual(series, want) {
+		t.Errorf("GetSeries in UTC = %+v, want %+v", series, want)
+	}
+}
+
+// When DST ends in Amsterdam (Oct 25 2026, 03:00 CEST -> 02:00 CET) two UTC
+// hours share the local label 02:00 and are reported as one.
+func TestGetSeriesByHour_DSTEnd(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	insertHourlyStat(t, db, "/", "example.com", time.Date(2026, 10, 24, 23, 10, 0, 0, time.UTC), statSeed{PageViews: 1})
+	insertHourlyStat(t, db, "/", "example.com", time.Date(2026, 10, 25, 0, 10, 0, 0, time.UTC), statSeed{PageViews: 2})
+	insertHourlyStat(t, db, "/", "example.com", time.Date(2026, 10, 25, 1, 10, 0, 0, time.UTC), statSeed{PageViews: 3})
+	insertHourlyStat(t, db, "/", "example.com", time.Date(2026, 10, 25, 2, 10, 0, 0, time.UTC), statSeed{PageViews: 4})
+
+	day := time.Date(2026, 10, 25, 0, 0, 0, 0, amsterdam)
+	series, err := query.GetSeries(context.Background(), db, day, day, "", "hour")
+	if err != nil {
+		t.Fatalf("GetSeries: %v", err)
+	}
+	want := []query.SeriesPoint{
+		{Date: "2026-10-25T01:00:00", PageViews: 1},
+		{Date: "2026-10-25T02:00:00", PageViews: 5},
+		{Date: "2026-10-25T03:00:00", PageViews: 4},
+	}
+	if !reflect.DeepEqual(series, want) {
+		t.Errorf("GetSeries = %+v, want %+v", series, want)
+	}
+}
+
 func TestGetSeries_InvalidGroupBy(t *testing.T) {
 	db := setupTestDB(t)
 	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
@@ -0,0 +1,46 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ScanStat is how many requests matched one scanner signature.
+type ScanStat struct {
+	Signature string `json:"signature"`
+	Count     int    `json:"count"`
+}
+
+// GetScanStats returns scanner probe counts per signature since the given
+// time, most frequent first.
+func GetScanStats(ctx context.Context, db *sql.DB, since time.Time, host string) ([]ScanStat, error) {
+	q := `
+	SELECT signature, SUM(count) AS total
+	FROM hourly_scans
+	WHERE ` + sinceHourClause
+
+	args := sinceHourArgs(since)
+	if host != "" {
+		q += hostFilterClause
+		args = append(args, host)
+	}
+	q += " GROUP BY signature ORDER BY total DESC, signature"
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying scan stats: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // close error in defer is not actionable
+
+	results := []ScanStat{}
+	for rows.Next() {
+		var s ScanStat
+		if err := rows.Scan(&s.Signature, &s.Count); err != nil {
+			return nil, fmt.Errorf("scanning scan stat: %w", err)
+		}
+		results = append(results, s)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,44 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+)
+
+func TestGetScanStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+
+	now := time.Now()
+	for _, seed := range []struct {
+		host, signature string
+		ts              time.Time
+		count           int
+	}{
+		{"example.com", "env_file", now, 3},
+		{"example.com", "wordpress", now, 7},
+		{"other.com", "env_file", now, 100},
+		{"example.com", "vcs", now.AddDate(0, 0, -30), 50},
+	} {
+		_, err := db.ExecContext(t.Context(), `
+			INSERT INTO hourly_scans (bucket, host, signature, count)
+			VALUES (?, ?, ?, ?)`,
+			bucket.Hour(seed.ts), seed.host, seed.signature, seed.count)
+		if err != nil {
+			t.Fatalf("insert scan: %v", err)
+		}
+	}
+
+	got, err := query.GetScanStats(t.Context(), db, now.AddDate(0, 0, -7), "example.com")
+	if err != nil {
+		t.Fatalf("GetScanStats: %v", err)
+	}
+	want := []query.ScanStat{{Signature: "wordpress", Count: 7}, {Signature: "env_file", Count: 3}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("GetScanStats() = %+v, want %+v", got, want)
+	}
+}
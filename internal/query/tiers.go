@@ -0,0 +1,155 @@
+package query
+
+import (
+	"math"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+)
+
+// Stats, status codes, referrers and unique visitors outlive the hourly
+// tables: the daemon's cleanup rolls expiring hours into daily_* tables and
+// expiring days into monthly_* ones. Every period is held by exactly one
+// tier, so these queries read the union of all three over their window and
+// each period answers at the finest resolution still kept for it. A window
+// reaching into monthly data counts those months whole.
+
+// Resolutions a tier's rows, and so a SeriesPoint, can cover.
+const (
+	resolutionHour  = "hour"
+	resolutionDay   = "day"
+	resolutionMonth = "month"
+)
+
+const monthRangeClause = "month BETWEEN ? AND ?"
+
+// window is a time range as the bounds of each tier's BETWEEN: hour, day and
+// month buckets.
+type window struct {
+	hours, days, months [2]int64
+}
+
+// rangeWindow covers the calendar days from and to fall on, like
+// hourRangeArgs and dayRangeArgs, and the calendar months they fall in.
+func rangeWindow(from, to time.Time) window {
+	end := startOfDay(to).AddDate(0, 0, 1)
+	return window{
+		hours:  [2]int64{firstHourFrom(startOfDay(from)), bucket.Hour(end.Add(-time.Nanosecond))},
+		days:   [2]int64{bucket.CalendarDay(from), bucket.CalendarDay(to)},
+		months: [2]int64{bucket.CalendarMonth(from), bucket.CalendarMonth(to)},
+	}
+}
+
+// sinceWindow is rangeWindow with no end.
+func sinceWindow(since time.Time) window {
+	return window{
+		hours:  [2]int64{firstHourFrom(startOfDay(since)), math.MaxInt64},
+		days:   [2]int64{bucket.CalendarDay(since), math.MaxInt64},
+		months: [2]int64{bucket.CalendarMonth(since), math.MaxInt64},
+	}
+}
+
+// tierArgs returns the bind values for statsTiers, statusCodesTiers and
+// referrersTiers over w.
+func tierArgs(w window) []any {
+	return []any{w.hours[0], w.hours[1], w.days[0], w.days[1], w.months[0], w.months[1]}
+}
+
+// visitorTierArgs returns the bind values for visitorsTiers over w, whose
+// finest tier counts days.
+func visitorTierArgs(w window) []any {
+	return []any{w.days[0], w.days[1], w.days[0], w.days[1], w.months[0], w.months[1]}
+}
+
+// The *Tiers sources are subqueries over one metric's tables, each row
+// labeled with its tier's resolution and bucket (at). A host filter on the
+// outer query is pushed down into every arm, where the (host, bucket),
+// (host, day) and (host, month) indexes answer it.
+const (
+	statsTiers = `(
+		SELECT 'hour' AS resolution, bucket AS at, path, host, page_views, is_static, bot_views
+		FROM hourly_stats WHERE ` + hourRangeClause + `
+		UNION ALL
+		SELECT 'day', day, path, host, page_views, is_static, bot_views
+		FROM daily_stats WHERE ` + dayRangeClause + `
+		UNION ALL
+		SELECT 'month', month, path, host, page_views, is_static, bot_views
+		FROM monthly_stats WHERE ` + monthRangeClause + `
+	)`
+
+	statusCodesTiers = `(
+		SELECT host, status_code, count FROM hourly_status_codes WHERE ` + hourRangeClause + `
+		UNION ALL
+		SELECT host, status_code, count FROM daily_status_codes WHERE ` + dayRangeClause + `
+		UNION ALL
+		SELECT host, status_code, count FROM monthly_status_codes WHERE ` + monthRangeClause + `
+	)`
+
+	referrersTiers = `(
+		SELECT host, referrer, count FROM hourly_referrers WHERE ` + hourRangeClause + `
+		UNION ALL
+		SELECT host, referrer, count FROM daily_referrers WHERE ` + dayRangeClause + `
+		UNION ALL
+		SELECT host, referrer, count FROM monthly_referrers WHERE ` + monthRangeClause + `
+	)`
+
+	// visitorsTiers keeps visitor_days' hashes, so recent days count
+	// distinct visitors across hosts, while rolled up days only have a
+	// count per host. Hashes rotate daily, so days add up either way.
+	visitorsTiers = `(
+		SELECT 'day' AS resolution, day AS at, host, hash, NULL AS visitors
+		FROM visitor_days WHERE ` + dayRangeClause + `
+		UNION ALL
+		SELECT 'day', day, host, NULL, visitors
+		FROM daily_visitors WHERE ` + dayRangeClause + `
+		UNION ALL
+		SELECT 'month', month, host, NULL, visitors
+		FROM monthly_visitors WHERE ` + monthRangeClause + `
+	)`
+)
+
+// hostWhereClause filters a *Tiers source by host.
+const hostWhereClause = " WHERE host = ?"
+
+// visitorsExpr counts unique visitors over rows of visitorsTiers.
+const visitorsExpr = "COUNT(DISTINCT hash) + COALESCE(SUM(visitors), 0)"
+
+// resolutionRank orders resolutions from finest to coarsest.
+func resolutionRank(resolution string) int {
+	switch resolution {
+	case resolutionMonth:
+		return 2
+	case resolutionDay:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// tierLabel is the series label for the bucket at of a tier with the given
+// resolution, grouped by groupBy. A bucket coarser than groupBy keeps its own
+// resolution. Hours are labeled on loc's wall clock; days and months are
+// UTC ones, matched to loc's by date.
+func tierLabel(resolution string, at int64, groupBy string, loc *time.Location) string {
+	var t time.Time
+	switch resolution {
+	case resolutionHour:
+		t = bucket.HourStart(at).In(loc)
+	case resolutionDay:
+		t = bucket.DayStart(at)
+	default:
+		t = bucket.MonthStart(at)
+	}
+
+	if resolutionRank(resolution) > resolutionRank(groupBy) {
+		groupBy = resolution
+	}
+	switch groupBy {
+	case resolutionHour:
+		return t.Format("2006-01-02T15:00:00")
+	case resolutionMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
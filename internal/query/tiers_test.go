@@ -0,0 +1,102 @@
+package query_test
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/database"
+	"github.com/Elysium-Labs-EU/theia/internal/bucket"
+	"github.com/Elysium-Labs-EU/theia/internal/query"
+)
+
+// seedTiers stores one period at each resolution, as the daemon's rollups
+// leave them: March 2024 only as a month, 10 June 2025 as a day, and
+// 19 July 2026 10:00 UTC as an hour.
+func seedTiers(t *testing.T, db *sql.DB) {
+	t.Helper()
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`INSERT INTO monthly_stats (month, path, host, page_views, is_static, bot_views) VALUES (?, '/old', 'example.com', 100, 0, 7)`,
+			[]any{bucket.Month(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))}},
+		{`INSERT INTO monthly_visitors (month, host, visitors) VALUES (?, 'example.com', 40)`,
+			[]any{bucket.Month(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))}},
+		{`INSERT INTO daily_stats (day, path, host, page_views, is_static, bot_views) VALUES (?, '/', 'example.com', 10, 0, 1)`,
+			[]any{bucket.Day(time.Date(2025, time.June, 10, 0, 0, 0, 0, time.UTC))}},
+		{`INSERT INTO daily_visitors (day, host, visitors) VALUES (?, 'example.com', 4)`,
+			[]any{bucket.Day(time.Date(2025, time.June, 10, 0, 0, 0, 0, time.UTC))}},
+	}
+	for _, s := range statements {
+		if _, err := db.ExecContext(t.Context(), s.query, s.args...); err != nil {
+			t.Fatalf("seed %q: %v", s.query, err)
+		}
+	}
+	insertHourlyStat(t, db, "/", "example.com", time.Date(2026, time.July, 19, 10, 30, 0, 0, time.UTC), statSeed{PageViews: 5, UniqueVisitors: 3})
+}
+
+func TestGetSeries_AcrossRollupTiers(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	seedTiers(t, db)
+
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.July, 20, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		groupBy string
+		want    []query.SeriesPoint
+	}{
+		{"day", []query.SeriesPoint{
+			{Date: "2024-03", PageViews: 100, UniqueVisitors: 40, BotViews: 7},
+			{Date: "2025-06-10", PageViews: 10, UniqueVisitors: 4, BotViews: 1},
+			{Date: "2026-07-19", PageViews: 5, UniqueVisitors: 3},
+		}},
+		{"month", []query.SeriesPoint{
+			{Date: "2024-03", PageViews: 100, UniqueVisitors: 40, BotViews: 7},
+			{Date: "2025-06", PageViews: 10, UniqueVisitors: 4, BotViews: 1},
+			{Date: "2026-07", PageViews: 5, UniqueVisitors: 3},
+		}},
+		{"hour", []query.SeriesPoint{
+			{Date: "2024-03", PageViews: 100, UniqueVisitors: 40, BotViews: 7},
+			{Date: "2025-06-10", PageViews: 10, UniqueVisitors: 4, BotViews: 1},
+			{Date: "2026-07-19T10:00:00", PageViews: 5},
+		}},
+	} {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			series, err := query.GetSeries(context.Background(), db, from, to, "example.com", tt.groupBy)
+			if err != nil {
+				t.Fatalf("GetSeries: %v", err)
+			}
+			if !reflect.DeepEqual(series, tt.want) {
+				t.Errorf("GetSeries = %+v, want %+v", series, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryAndTopPaths_AcrossRollupTiers(t *testing.T) {
+	db := setupTestDB(t)
+	defer database.Close(db) //nolint:errcheck // close error in defer is not actionable
+	seedTiers(t, db)
+
+	summary, err := query.GetSummary(context.Background(), db, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), "")
+	if err != nil {
+		t.Fatalf("GetSummary: %v", err)
+	}
+	if want := (query.Summary{Pageviews: 115, UniqueVisitors: 47, BotViews: 8}); summary != want {
+		t.Errorf("GetSummary = %+v, want %+v", summary, want)
+	}
+
+	// From 2025 on, the monthly tier's March 2024 is outside the window.
+	paths, err := query.GetTopPathsRange(context.Background(), db,
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, time.July, 20, 0, 0, 0, 0, time.UTC), "example.com", 10)
+	if err != nil {
+		t.Fatalf("GetTopPathsRange: %v", err)
+	}
+	if want := []query.PathStat{{Path: "/", Host: "example.com", Pageviews: 15}}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("GetTopPathsRange = %+v, want %+v", paths, want)
+	}
+}
@@ -0,0 +1,27 @@
+package query
+
+import "time"
+
+// Timezones are the display timezones reports can be shown in. Every
+// table is stored in UTC; these only move day boundaries and hour labels.
+type Timezones struct {
+	// Default applies to every report without a more specific zone; nil
+	// means UTC.
+	Default *time.Location
+	// Hosts gives a host its own zone, used when a report is filtered to
+	// that host — e.g. a site whose audience is in another region than the
+	// rest.
+	Hosts map[string]*time.Location
+}
+
+// DisplayLocation is the timezone a report on host (empty for all hosts)
+// is shown in: host's own zone, else tz.Default, else UTC.
+func DisplayLocation(tz Timezones, host string) *time.Location {
+	if loc, ok := tz.Hosts[host]; ok && host != "" {
+		return loc
+	}
+	if tz.Default != nil {
+		return tz.Default
+	}
+	return time.UTC
+}
@@ -0,0 +1,60 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenFDsStart is the first file descriptor systemd passes sockets on;
+// 0-2 stay stdin, stdout and stderr.
+const listenFDsStart = 3
+
+// ListenersFromEnv returns the sockets systemd passed this process through
+// socket activation (LISTEN_FDS, LISTEN_PID), in the order of the socket
+// unit's Listen= lines, or none when it wasn't socket-activated. It unsets
+// the variables so a child process can't mistake them for its own.
+func ListenersFromEnv() ([]net.Listener, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_PID")     // unset errors are not actionable
+	_ = os.Unsetenv("LISTEN_FDS")     // unset errors are not actionable
+	_ = os.Unsetenv("LISTEN_FDNAMES") // unset errors are not actionable
+
+	if fds == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q: must be a non-negative count", fds)
+	}
+	return listenersFrom(listenFDsStart, count)
+}
+
+// listenersFrom wraps count inherited descriptors, starting at first, as
+// listeners. Each is marked close-on-exec, as sd_listen_fds(3) does, so the
+// "tail" or other children never hold the socket open.
+func listenersFrom(first, count int) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, count)
+	for fd := first; fd < first+count; fd++ {
+		syscall.CloseOnExec(fd)
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		l, err := net.FileListener(file)
+		// FileListener dups the descriptor, so the original is closed either
+		// way.
+		_ = file.Close() // close error is not actionable
+		if err != nil {
+			closeAll(listeners)
+			return nil, fmt.Errorf("socket-activated fd %d is not a listening socket: %w", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func closeAll(listeners []net.Listener) {
+	for _, l := range listeners {
+		_ = l.Close() // close error is not actionable on the failure path
+	}
+}
@@ -0,0 +1,81 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenersFrom_WrapsInheritedSocket(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close() //nolint:errcheck // close error in defer is not actionable
+	file, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("dup listener: %v", err)
+	}
+	// listenersFrom takes ownership of the descriptor, as it would of one
+	// systemd passed in, so hand it a raw one no *os.File will close later.
+	fd, err := syscall.Dup(int(file.Fd()))
+	_ = file.Close()
+	if err != nil {
+		t.Fatalf("dup: %v", err)
+	}
+
+	got, err := listenersFrom(fd, 1)
+	if err != nil {
+		t.Fatalf("listenersFrom: %v", err)
+	}
+	defer closeAll(got)
+	if len(got) != 1 || got[0].Addr().String() != l.Addr().String() {
+		t.Fatalf("listeners = %v, want one on %s", got, l.Addr())
+	}
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestListenersFrom_RejectsNonSocket(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "not-a-socket")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	fd, err := syscall.Dup(int(f.Fd()))
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("dup: %v", err)
+	}
+
+	if _, err := listenersFrom(fd, 1); err == nil {
+		t.Error("listenersFrom on a regular file = nil error, want one")
+	}
+}
+
+func TestListenersFromEnv_IgnoresAnotherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	got, err := ListenersFromEnv()
+	if err != nil || len(got) != 0 {
+		t.Fatalf("ListenersFromEnv = %v, %v; want none", got, err)
+	}
+	if _, set := os.LookupEnv("LISTEN_FDS"); set {
+		t.Error("LISTEN_FDS still set; it must not leak to child processes")
+	}
+}
+
+func TestListenersFromEnv_InvalidCount(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "many")
+
+	if _, err := ListenersFromEnv(); err == nil {
+		t.Error("want an error for a non-numeric LISTEN_FDS")
+	}
+}
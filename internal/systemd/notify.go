@@ -0,0 +1,92 @@
+// Package systemd speaks the two protocols theia's long-running commands
+// share with the service manager: sd_notify(3) readiness, status and
+// watchdog messages, and sd_listen_fds(3) socket activation. Both are plain
+// environment variables and file descriptors, so no libsystemd is needed,
+// and outside systemd every function here is a no-op.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Messages understood by the service manager; STATUS= takes free text and
+// is built with Status.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notifier is where sd_notify messages go and how often the watchdog must
+// hear from the process. The zero value, for a process not started by
+// systemd (or by a unit without Type=notify), sends nothing.
+type Notifier struct {
+	// Socket is the NOTIFY_SOCKET path; a leading "@" names an abstract
+	// socket.
+	Socket string
+	// WatchdogInterval is the unit's WatchdogSec, after which systemd
+	// restarts a process that hasn't sent Watchdog; 0 when it isn't set.
+	WatchdogInterval time.Duration
+}
+
+// NotifierFromEnv reads NOTIFY_SOCKET, WATCHDOG_USEC and WATCHDOG_PID as
+// systemd sets them for the main process. A watchdog meant for another PID
+// (e.g. inherited by a child) is ignored.
+func NotifierFromEnv() (Notifier, error) {
+	n := Notifier{Socket: os.Getenv("NOTIFY_SOCKET")}
+
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return n, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n, nil
+	}
+	micros, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || micros <= 0 {
+		return Notifier{}, fmt.Errorf("invalid WATCHDOG_USEC %q: must be a positive number of microseconds", usec)
+	}
+	n.WatchdogInterval = time.Duration(micros) * time.Microsecond
+	return n, nil
+}
+
+// Status formats a STATUS= message; newlines would end the message early,
+// so they're folded into spaces.
+func Status(text string) string {
+	return "STATUS=" + strings.ReplaceAll(text, "\n", " ")
+}
+
+// Notify sends states (e.g. Ready, Status("...")) to n's socket as a single
+// datagram. It does nothing when n has no socket.
+func Notify(n Notifier, states ...string) error {
+	if n.Socket == "" || len(states) == 0 {
+		return nil
+	}
+	name := n.Socket
+	if strings.HasPrefix(name, "@") {
+		name = "\x00" + name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("connecting to notify socket %q: %w", n.Socket, err)
+	}
+	defer conn.Close() //nolint:errcheck // close error in defer is not actionable
+
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("writing to notify socket %q: %w", n.Socket, err)
+	}
+	return nil
+}
+
+// PingInterval is how often to send Watchdog so that one late ping doesn't
+// get the process restarted: half of WatchdogInterval, as sd_watchdog_enabled(3)
+// recommends. It is 0 when the watchdog is off.
+func PingInterval(n Notifier) time.Duration {
+	return n.WatchdogInterval / 2
+}
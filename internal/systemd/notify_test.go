@@ -0,0 +1,120 @@
+package systemd_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Elysium-Labs-EU/theia/internal/systemd"
+)
+
+// fakeNotifySocket stands in for systemd's notify socket: it returns a
+// Notifier pointed at a unixgram socket the test reads datagrams from.
+func fakeNotifySocket(t *testing.T) (systemd.Notifier, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen on fake notify socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return systemd.Notifier{Socket: path}, conn
+}
+
+func readDatagram(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read notify datagram: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify_SendsOneDatagram(t *testing.T) {
+	notifier, conn := fakeNotifySocket(t)
+
+	if err := systemd.Notify(notifier, systemd.Ready, systemd.Status("Reading access.log\nnow")); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if got, want := readDatagram(t, conn), "READY=1\nSTATUS=Reading access.log now"; got != want {
+		t.Errorf("datagram = %q, want %q", got, want)
+	}
+}
+
+func TestNotify_AbstractSocket(t *testing.T) {
+	name := "theia-test-" + strconv.Itoa(os.Getpid())
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: "\x00" + name, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("abstract unix sockets unavailable: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck // close error in defer is not actionable
+
+	if err := systemd.Notify(systemd.Notifier{Socket: "@" + name}, systemd.Watchdog); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := readDatagram(t, conn); got != systemd.Watchdog {
+		t.Errorf("datagram = %q, want %q", got, systemd.Watchdog)
+	}
+}
+
+func TestNotify_NoSocketIsNoop(t *testing.T) {
+	if err := systemd.Notify(systemd.Notifier{}, systemd.Ready); err != nil {
+		t.Errorf("Notify without a socket = %v, want nil", err)
+	}
+}
+
+func TestNotify_MissingSocket(t *testing.T) {
+	notifier := systemd.Notifier{Socket: filepath.Join(t.TempDir(), "gone.sock")}
+	if err := systemd.Notify(notifier, systemd.Ready); err == nil {
+		t.Error("Notify to a missing socket = nil, want an error")
+	}
+}
+
+func TestNotifierFromEnv(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name         string
+		usec, wdPID  string
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{name: "no watchdog"},
+		{name: "watchdog for this process", usec: "20000000", wdPID: pid, wantInterval: 20 * time.Second},
+		{name: "watchdog without a pid", usec: "1000000", wantInterval: time.Second},
+		{name: "watchdog for another process", usec: "20000000", wdPID: "1"},
+		{name: "invalid usec", usec: "soon", wdPID: pid, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_SOCKET", "/run/systemd/notify")
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.wdPID)
+
+			got, err := systemd.NotifierFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NotifierFromEnv: %v", err)
+			}
+			want := systemd.Notifier{Socket: "/run/systemd/notify", WatchdogInterval: tt.wantInterval}
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+			if systemd.PingInterval(got) != tt.wantInterval/2 {
+				t.Errorf("PingInterval = %v, want %v", systemd.PingInterval(got), tt.wantInterval/2)
+			}
+		})
+	}
+}
@@ -2,7 +2,13 @@
 // page views by parsing nginx access logs.
 package main
 
-import "github.com/Elysium-Labs-EU/theia/cmd"
+import (
+	"github.com/Elysium-Labs-EU/theia/cmd"
+
+	// Display timezones (--tz, the API's tz param, the config's timezone)
+	// must resolve on minimal hosts and containers without /usr/share/zoneinfo.
+	_ "time/tzdata"
+)
 
 func main() {
 	cmd.Execute()